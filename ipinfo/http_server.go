@@ -6,6 +6,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/metrics"
 )
 
 // ErrInvalidIP error for invalid ip input
@@ -34,6 +36,7 @@ func NewHTTPServer(sugar *zap.SugaredLogger, dataDir string, host string) (*HTTP
 }
 
 func (h *HTTPServer) register() {
+	metrics.Instrument(h.r)
 	h.r.GET("/ip/:ip", h.lookupIPCountry)
 }
 
@@ -1,8 +1,10 @@
 package app
 
 import (
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/urfave/cli"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
 )
 
 const (
@@ -14,14 +16,19 @@ const (
 func NewEthereumNodeFlags() cli.Flag {
 	return cli.StringFlag{
 		Name:   ethereumNodeFlag,
-		Usage:  "Ethereum Node URL",
+		Usage:  "Ethereum Node URL, multiple nodes are separated by comma and calls are failed over between them",
 		EnvVar: "ETHEREUM_NODE",
 		Value:  InfuraEndpoint,
 	}
 }
 
-// NewEthereumClientFromFlag returns Ethereum client from flag variable, or error if occurs
-func NewEthereumClientFromFlag(c *cli.Context) (*ethclient.Client, error) {
+// NewEthereumClientFromFlag returns Ethereum client from flag variable, or error if occurs.
+// The failover and rate limiting of client are configured by blockchain.NewMultiClientCliFlags.
+// The node flag of a command takes precedence over the global one.
+func NewEthereumClientFromFlag(c *cli.Context, sugar *zap.SugaredLogger) (*blockchain.MultiClient, error) {
 	ethereumNodeURL := c.GlobalString(ethereumNodeFlag)
-	return ethclient.Dial(ethereumNodeURL)
+	if c.IsSet(ethereumNodeFlag) || ethereumNodeURL == "" {
+		ethereumNodeURL = c.String(ethereumNodeFlag)
+	}
+	return blockchain.NewMultiClientFromContext(c, sugar, ethereumNodeURL)
 }
@@ -0,0 +1,102 @@
+package backfill
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+)
+
+const (
+	fromBlockFlag = "from-block"
+	toBlockFlag   = "to-block"
+	fromTimeFlag  = "from-time"
+	toTimeFlag    = "to-time"
+	jobSizeFlag   = "job-size"
+	workersFlag   = "workers"
+	jobDBFlag     = "job-db"
+)
+
+// NewCliFlags returns cli flags to configure a backfill run.
+func NewCliFlags(defaultJobDB string, defaultJobSize uint64) []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  fromBlockFlag,
+			Usage: "Backfill from block",
+		},
+		cli.StringFlag{
+			Name:  toBlockFlag,
+			Usage: "Backfill to block, inclusive",
+		},
+		cli.StringFlag{
+			Name:  fromTimeFlag,
+			Usage: "Backfill from the first block at or after given time, example: 2018-10-01 or 2018-10-01T00:00:00Z",
+		},
+		cli.StringFlag{
+			Name:  toTimeFlag,
+			Usage: "Backfill to the last block before given time, example: 2018-10-02 or 2018-10-02T00:00:00Z",
+		},
+		cli.Uint64Flag{
+			Name:  jobSizeFlag,
+			Usage: "Number of blocks of each backfill job",
+			Value: defaultJobSize,
+		},
+		cli.IntFlag{
+			Name:  workersFlag,
+			Usage: "Number of backfill jobs processed concurrently",
+			Value: DefaultWorkers,
+		},
+		cli.StringFlag{
+			Name:  jobDBFlag,
+			Usage: "Path to BoltDB file to store backfill jobs status, an interrupted run is resumed with the same file",
+			Value: defaultJobDB,
+		},
+	}
+}
+
+// blockFromContext returns the block number given by block flag or time flag.
+// The block at time is the first block at or after given time, adjusted by offset.
+func blockFromContext(c *cli.Context, resolver *blockchain.BlockTimeResolver, blockFlag, timeFlag string, offset int64) (uint64, error) {
+	block, ok, err := blockchain.BlockFromContext(c, resolver, blockFlag, timeFlag, offset)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("one of --%s and --%s is required", blockFlag, timeFlag)
+	}
+	return block, nil
+}
+
+// NewRangeFromContext returns the backfill range from cli flags, the time
+// flags are converted to block numbers using given resolver.
+func NewRangeFromContext(c *cli.Context, resolver *blockchain.BlockTimeResolver) (Range, error) {
+	fromBlock, err := blockFromContext(c, resolver, fromBlockFlag, fromTimeFlag, 0)
+	if err != nil {
+		return Range{}, err
+	}
+	toBlock, err := blockFromContext(c, resolver, toBlockFlag, toTimeFlag, -1)
+	if err != nil {
+		return Range{}, err
+	}
+	if fromBlock > toBlock {
+		return Range{}, fmt.Errorf("invalid block range: from %d to %d", fromBlock, toBlock)
+	}
+
+	jobSize := c.Uint64(jobSizeFlag)
+	if jobSize == 0 {
+		return Range{}, fmt.Errorf("invalid job size: %d", jobSize)
+	}
+	return Range{FromBlock: fromBlock, ToBlock: toBlock, JobSize: jobSize}, nil
+}
+
+// NewRunnerFromContext returns a backfill runner with job store configured
+// by cli flags. The returned store must be closed by caller.
+func NewRunnerFromContext(c *cli.Context, sugar *zap.SugaredLogger, process ProcessFunc) (*Runner, *BoltStore, error) {
+	store, err := NewBoltStore(sugar, c.String(jobDBFlag))
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewRunner(sugar, store, c.Int(workersFlag), process), store, nil
+}
@@ -0,0 +1,44 @@
+package backfill
+
+// Status is the processing status of a backfill job.
+type Status string
+
+const (
+	// StatusPending is the status of a job that is not processed yet, or
+	// was interrupted while processing.
+	StatusPending Status = "pending"
+	// StatusDone is the status of a successfully processed job.
+	StatusDone Status = "done"
+	// StatusFailed is the status of a job that returned error, it is retried
+	// in next run.
+	StatusFailed Status = "failed"
+)
+
+// Job is a block range of a backfill run.
+type Job struct {
+	FromBlock uint64 `json:"from_block"`
+	ToBlock   uint64 `json:"to_block"`
+	Status    Status `json:"status"`
+	Attempts  int    `json:"attempts"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Range is the block range of a backfill run, split to jobs of JobSize blocks.
+type Range struct {
+	FromBlock uint64 `json:"from_block"`
+	ToBlock   uint64 `json:"to_block"`
+	JobSize   uint64 `json:"job_size"`
+}
+
+// Jobs splits the range to pending jobs.
+func (r Range) Jobs() []Job {
+	var jobs []Job
+	for from := r.FromBlock; from <= r.ToBlock; from += r.JobSize {
+		to := from + r.JobSize - 1
+		if to > r.ToBlock {
+			to = r.ToBlock
+		}
+		jobs = append(jobs, Job{FromBlock: from, ToBlock: to, Status: StatusPending})
+	}
+	return jobs
+}
@@ -0,0 +1,62 @@
+package backfill
+
+import (
+	"time"
+)
+
+// progress tracks the number of processed jobs of a backfill run to
+// estimate the remaining time.
+type progress struct {
+	total     int
+	done      int
+	failed    int
+	remaining int
+	processed int
+	startedAt time.Time
+	now       func() time.Time
+}
+
+func newProgress(jobs []Job) *progress {
+	p := &progress{
+		total:     len(jobs),
+		startedAt: time.Now(),
+		now:       time.Now,
+	}
+	for _, job := range jobs {
+		if job.Status == StatusDone {
+			p.done++
+		} else {
+			p.remaining++
+		}
+	}
+	return p
+}
+
+// record updates the progress with the result of a processed job.
+func (p *progress) record(job Job) {
+	p.processed++
+	p.remaining--
+	if job.Status == StatusDone {
+		p.done++
+	} else {
+		p.failed++
+	}
+}
+
+// percent returns the percentage of done jobs.
+func (p *progress) percent() float64 {
+	if p.total == 0 {
+		return 100
+	}
+	return float64(p.done) * 100 / float64(p.total)
+}
+
+// eta returns the estimated remaining duration, based on the average
+// duration of jobs processed in current run.
+func (p *progress) eta() time.Duration {
+	if p.processed == 0 {
+		return 0
+	}
+	elapsed := p.now().Sub(p.startedAt)
+	return (elapsed / time.Duration(p.processed) * time.Duration(p.remaining)).Round(time.Second)
+}
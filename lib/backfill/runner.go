@@ -0,0 +1,146 @@
+package backfill
+
+import (
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// DefaultWorkers is the default number of jobs processed concurrently.
+const DefaultWorkers = 1
+
+// Store is the persistent storage of backfill jobs.
+type Store interface {
+	Init(r Range) ([]Job, error)
+	SaveJob(job Job) error
+}
+
+// ProcessFunc processes the blocks from fromBlock to toBlock, inclusive.
+type ProcessFunc func(fromBlock, toBlock uint64) error
+
+// Runner processes a large block range by splitting it to jobs. The status
+// of jobs are persisted, so an interrupted run can be resumed, only the
+// failed or unfinished jobs are processed again.
+type Runner struct {
+	sugar   *zap.SugaredLogger
+	store   Store
+	workers int
+	process ProcessFunc
+}
+
+// NewRunner creates a new Runner instance.
+func NewRunner(sugar *zap.SugaredLogger, store Store, workers int, process ProcessFunc) *Runner {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	return &Runner{
+		sugar:   sugar,
+		store:   store,
+		workers: workers,
+		process: process,
+	}
+}
+
+// Run processes all jobs of given range that are not done yet. Failed jobs
+// do not stop the run, an error is returned at the end if any job failed.
+func (r *Runner) Run(rng Range) error {
+	logger := r.sugar.With(
+		"func", "lib/backfill/Runner.Run",
+		"from_block", rng.FromBlock,
+		"to_block", rng.ToBlock,
+	)
+
+	if rng.JobSize == 0 {
+		return fmt.Errorf("invalid job size: %d", rng.JobSize)
+	}
+	if rng.FromBlock > rng.ToBlock {
+		return fmt.Errorf("invalid block range: from %d to %d", rng.FromBlock, rng.ToBlock)
+	}
+
+	jobs, err := r.store.Init(rng)
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		saveErr  error
+		todo     = make(chan Job)
+		progress = newProgress(jobs)
+	)
+	logger.Infow("starting backfill",
+		"total_jobs", progress.total,
+		"done_jobs", progress.done,
+		"remaining_jobs", progress.remaining)
+
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range todo {
+				job = r.processJob(job)
+
+				mu.Lock()
+				if err := r.store.SaveJob(job); err != nil && saveErr == nil {
+					saveErr = err
+				}
+				progress.record(job)
+				logger.Infow("backfill progress",
+					"job_from_block", job.FromBlock,
+					"job_to_block", job.ToBlock,
+					"job_status", job.Status,
+					"done", progress.done,
+					"failed", progress.failed,
+					"total", progress.total,
+					"percent", fmt.Sprintf("%.2f%%", progress.percent()),
+					"eta", progress.eta().String())
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		if job.Status == StatusDone {
+			continue
+		}
+		mu.Lock()
+		stop := saveErr != nil
+		mu.Unlock()
+		if stop {
+			break
+		}
+		todo <- job
+	}
+	close(todo)
+	wg.Wait()
+
+	if saveErr != nil {
+		return saveErr
+	}
+	if progress.failed > 0 {
+		return fmt.Errorf("%d of %d jobs failed, run again to retry", progress.failed, progress.total)
+	}
+	logger.Info("backfill completed")
+	return nil
+}
+
+// processJob runs the process function on given job and updates its status.
+func (r *Runner) processJob(job Job) Job {
+	job.Attempts++
+	if err := r.process(job.FromBlock, job.ToBlock); err != nil {
+		r.sugar.Errorw("backfill job failed",
+			"func", "lib/backfill/Runner.processJob",
+			"from_block", job.FromBlock,
+			"to_block", job.ToBlock,
+			"attempts", job.Attempts,
+			"err", err)
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		return job
+	}
+	job.Status = StatusDone
+	job.Error = ""
+	return job
+}
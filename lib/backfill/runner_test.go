@@ -0,0 +1,105 @@
+package backfill
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestRangeJobs(t *testing.T) {
+	jobs := Range{FromBlock: 100, ToBlock: 349, JobSize: 100}.Jobs()
+	assert.Equal(t, []Job{
+		{FromBlock: 100, ToBlock: 199, Status: StatusPending},
+		{FromBlock: 200, ToBlock: 299, Status: StatusPending},
+		{FromBlock: 300, ToBlock: 349, Status: StatusPending},
+	}, jobs)
+}
+
+func TestProgressETA(t *testing.T) {
+	p := newProgress([]Job{
+		{Status: StatusDone},
+		{Status: StatusPending},
+		{Status: StatusFailed},
+		{Status: StatusPending},
+	})
+	assert.Equal(t, 3, p.remaining)
+	assert.Equal(t, time.Duration(0), p.eta())
+
+	p.now = func() time.Time { return p.startedAt.Add(10 * time.Second) }
+	p.record(Job{Status: StatusDone})
+	assert.Equal(t, float64(50), p.percent())
+	assert.Equal(t, 20*time.Second, p.eta())
+}
+
+func TestRunnerResume(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	dir, err := ioutil.TempDir("", "backfill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jobs.db")
+
+	var (
+		mu        sync.Mutex
+		processed []uint64
+		failBlock = uint64(250)
+		rng       = Range{FromBlock: 0, ToBlock: 499, JobSize: 100}
+	)
+	process := func(fromBlock, toBlock uint64) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if failBlock >= fromBlock && failBlock <= toBlock {
+			return errors.New("node unavailable")
+		}
+		processed = append(processed, fromBlock)
+		return nil
+	}
+
+	store, err := NewBoltStore(sugar, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewRunner(sugar, store, 2, process).Run(rng)
+	assert.EqualError(t, err, "1 of 5 jobs failed, run again to retry")
+	sort.Slice(processed, func(i, j int) bool { return processed[i] < processed[j] })
+	assert.Equal(t, []uint64{0, 100, 300, 400}, processed)
+	assert.NoError(t, store.Close())
+
+	// resumed run only retries the failed job
+	processed = nil
+	failBlock = 1000
+	store, err = NewBoltStore(sugar, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	assert.NoError(t, NewRunner(sugar, store, 2, process).Run(rng))
+	assert.Equal(t, []uint64{200}, processed)
+
+	jobs, err := store.Init(rng)
+	assert.NoError(t, err)
+	for _, job := range jobs {
+		assert.Equal(t, StatusDone, job.Status)
+		if job.FromBlock == 200 {
+			assert.Equal(t, 2, job.Attempts)
+		}
+	}
+
+	// different range is rejected
+	_, err = store.Init(Range{FromBlock: 0, ToBlock: 999, JobSize: 100})
+	assert.Error(t, err)
+}
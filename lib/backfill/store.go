@@ -0,0 +1,121 @@
+package backfill
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/boltutil"
+)
+
+const (
+	// jobsBucket stores the jobs of backfill run: from block --> job.
+	jobsBucket = "jobs"
+	// metaBucket stores the range of backfill run.
+	metaBucket = "meta"
+	rangeKey   = "range"
+)
+
+// BoltStore is the BoltDB implementation of backfill job store.
+type BoltStore struct {
+	sugar *zap.SugaredLogger
+	db    *bolt.DB
+}
+
+// NewBoltStore opens the BoltDB file at given path and creates the buckets
+// required to store backfill jobs.
+func NewBoltStore(sugar *zap.SugaredLogger, path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{jobsBucket, metaBucket} {
+			if _, cErr := tx.CreateBucketIfNotExists([]byte(bucket)); cErr != nil {
+				return cErr
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &BoltStore{sugar: sugar, db: db}, nil
+}
+
+// Init returns the stored jobs of given range. If the store is empty, the
+// range is split into pending jobs and persisted. An error is returned if the
+// store contains jobs of a different range.
+func (bs *BoltStore) Init(r Range) ([]Job, error) {
+	logger := bs.sugar.With(
+		"func", "lib/backfill/BoltStore.Init",
+		"from_block", r.FromBlock,
+		"to_block", r.ToBlock,
+		"job_size", r.JobSize,
+	)
+
+	var jobs []Job
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket([]byte(metaBucket))
+		if v := meta.Get([]byte(rangeKey)); v != nil {
+			var stored Range
+			if err := json.Unmarshal(v, &stored); err != nil {
+				return err
+			}
+			if stored != r {
+				return fmt.Errorf("job store contains jobs of a different range: from %d to %d, job size %d",
+					stored.FromBlock, stored.ToBlock, stored.JobSize)
+			}
+
+			logger.Info("resuming backfill from job store")
+			return tx.Bucket([]byte(jobsBucket)).ForEach(func(_, v []byte) error {
+				var job Job
+				if err := json.Unmarshal(v, &job); err != nil {
+					return err
+				}
+				jobs = append(jobs, job)
+				return nil
+			})
+		}
+
+		logger.Info("initializing backfill jobs")
+		v, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if err = meta.Put([]byte(rangeKey), v); err != nil {
+			return err
+		}
+		jobs = r.Jobs()
+		for _, job := range jobs {
+			if err = putJob(tx, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+func putJob(tx *bolt.Tx, job Job) error {
+	v, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte(jobsBucket)).Put(boltutil.Uint64ToBytes(job.FromBlock), v)
+}
+
+// SaveJob persists the status of given job.
+func (bs *BoltStore) SaveJob(job Job) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return putJob(tx, job)
+	})
+}
+
+// Close closes the underlying BoltDB file.
+func (bs *BoltStore) Close() error {
+	return bs.db.Close()
+}
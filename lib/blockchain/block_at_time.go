@@ -0,0 +1,161 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// averageBlockTimeSample is the number of latest blocks to estimate the
+	// average block time from.
+	averageBlockTimeSample = 10000
+	// defaultAverageBlockTime is the assumed average block time if the chain
+	// is too short to estimate it.
+	defaultAverageBlockTime = 15 * time.Second
+)
+
+// HeaderReader is the subset of Ethereum client methods to read block headers.
+type HeaderReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+func fetchHeader(client HeaderReader, number *big.Int, timeout time.Duration) (*types.Header, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// parity and geth are not compatible in mix hash, the header is still usable
+	// when error is returned together with a non nil header
+	header, err := client.HeaderByNumber(ctx, number)
+	if err != nil && header == nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// averageBlockTime returns the average block time of the blocks before the
+// given latest block.
+func (btr *BlockTimeResolver) averageBlockTime(latest *types.Header) (time.Duration, error) {
+	latestNumber := latest.Number.Uint64()
+	if latestNumber == 0 {
+		return defaultAverageBlockTime, nil
+	}
+
+	var sampleNumber uint64
+	if latestNumber > averageBlockTimeSample {
+		sampleNumber = latestNumber - averageBlockTimeSample
+	}
+	sampleTime, err := btr.Resolve(sampleNumber)
+	if err != nil {
+		return 0, err
+	}
+
+	avg := time.Unix(latest.Time.Int64(), 0).Sub(sampleTime) / time.Duration(latestNumber-sampleNumber)
+	if avg <= 0 {
+		return defaultAverageBlockTime, nil
+	}
+	return avg, nil
+}
+
+// BlockAtTime returns the number of the first block that has timestamp at or
+// after given time. If given time is after the latest block, the number of the
+// next block to be mined is returned.
+//
+// The search starts at the block estimated by the average block time, expands
+// around it until the result is bracketed, then narrows down by binary search.
+// Block timestamps are read through the resolver caches, so searching for
+// nearby times only requests a few headers from node.
+func (btr *BlockTimeResolver) BlockAtTime(t time.Time) (uint64, error) {
+	latest, err := fetchHeader(btr.ethClient, nil, headerTimeout)
+	if err != nil {
+		return 0, err
+	}
+	latestNumber := latest.Number.Uint64()
+	if latest.Time.Int64() < t.Unix() {
+		return latestNumber + 1, nil
+	}
+
+	avg, err := btr.averageBlockTime(latest)
+	if err != nil {
+		return 0, err
+	}
+
+	// blocks returns the estimated number of blocks mined in given duration, at least 1
+	blocks := func(d time.Duration) uint64 {
+		if d < 0 {
+			d = -d
+		}
+		if n := uint64(d / avg); n > 0 {
+			return n
+		}
+		return 1
+	}
+
+	var guess uint64
+	if behind := blocks(time.Unix(latest.Time.Int64(), 0).Sub(t)); behind < latestNumber {
+		guess = latestNumber - behind
+	}
+	guessTime, err := btr.Resolve(guess)
+	if err != nil {
+		return 0, err
+	}
+
+	// lo is a block before given time and hi is a block at or after given time,
+	// the latest block is known to be at or after given time.
+	var lo, hi uint64
+	step := blocks(guessTime.Sub(t))
+	if guessTime.Before(t) {
+		lo = guess
+		for {
+			hi = lo + step
+			if hi >= latestNumber {
+				hi = latestNumber
+				break
+			}
+			hiTime, rErr := btr.Resolve(hi)
+			if rErr != nil {
+				return 0, rErr
+			}
+			if !hiTime.Before(t) {
+				break
+			}
+			lo, step = hi, step*2
+		}
+	} else {
+		hi = guess
+		for {
+			if hi == 0 {
+				return 0, nil
+			}
+			if step >= hi {
+				lo = 0
+			} else {
+				lo = hi - step
+			}
+			loTime, rErr := btr.Resolve(lo)
+			if rErr != nil {
+				return 0, rErr
+			}
+			if loTime.Before(t) {
+				break
+			}
+			hi, step = lo, step*2
+		}
+	}
+
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		midTime, rErr := btr.Resolve(mid)
+		if rErr != nil {
+			return 0, rErr
+		}
+		if midTime.Before(t) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi, nil
+}
@@ -0,0 +1,124 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// mockHeaderReader returns headers of a chain with 1000 blocks, the block
+// time is 15 seconds. It counts the number of requested headers.
+type mockHeaderReader struct {
+	requests int
+}
+
+const (
+	mockGenesisTime  = 1538352000
+	mockLatestNumber = 999
+)
+
+func (mhr *mockHeaderReader) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	mhr.requests++
+	if number == nil {
+		number = big.NewInt(mockLatestNumber)
+	}
+	return &types.Header{
+		Number: number,
+		Time:   big.NewInt(mockGenesisTime + number.Int64()*15),
+	}, nil
+}
+
+func TestBlockAtTime(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	var tests = []struct {
+		t     time.Time
+		block uint64
+	}{
+		{t: time.Unix(mockGenesisTime-100, 0), block: 0},
+		{t: time.Unix(mockGenesisTime, 0), block: 0},
+		{t: time.Unix(mockGenesisTime+15*100, 0), block: 100},
+		{t: time.Unix(mockGenesisTime+15*100+1, 0), block: 101},
+		{t: time.Unix(mockGenesisTime+15*mockLatestNumber, 0), block: mockLatestNumber},
+		{t: time.Unix(mockGenesisTime+15*mockLatestNumber+1, 0), block: mockLatestNumber + 1},
+	}
+
+	for _, tc := range tests {
+		resolver, err := NewBlockTimeResolver(sugar, &mockHeaderReader{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := resolver.BlockAtTime(tc.t)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.block, block, "block at %s", tc.t)
+	}
+
+	// the search is seeded by average block time, only a few headers are
+	// requested for a chain with steady block time
+	client := &mockHeaderReader{}
+	resolver, err := NewBlockTimeResolver(sugar, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := resolver.BlockAtTime(time.Unix(mockGenesisTime+15*500+7, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(501), block)
+	assert.True(t, client.requests <= 5, "requests: %d", client.requests)
+}
+
+// unsteadyHeaderReader returns headers of a chain with 1000 blocks, the block
+// time is 60 seconds for the first half of the chain and 1 second after.
+type unsteadyHeaderReader struct{}
+
+func unsteadyBlockTime(number int64) int64 {
+	if number < 500 {
+		return mockGenesisTime + number*60
+	}
+	return mockGenesisTime + 500*60 + (number - 500)
+}
+
+func (uhr unsteadyHeaderReader) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		number = big.NewInt(mockLatestNumber)
+	}
+	return &types.Header{
+		Number: number,
+		Time:   big.NewInt(unsteadyBlockTime(number.Int64())),
+	}, nil
+}
+
+func TestBlockAtTimeUnsteady(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := NewBlockTimeResolver(logger.Sugar(), unsteadyHeaderReader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, number := range []int64{0, 1, 100, 250, 499, 500, 501, 750, 999} {
+		block, err := resolver.BlockAtTime(time.Unix(unsteadyBlockTime(number), 0))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(number), block)
+
+		// a second before block time is the timestamp of previous block in
+		// the second half of the chain
+		expected := uint64(number)
+		if number > 500 {
+			expected--
+		}
+		block, err = resolver.BlockAtTime(time.Unix(unsteadyBlockTime(number)-1, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, block)
+	}
+}
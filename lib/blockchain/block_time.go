@@ -9,36 +9,71 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+)
 
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
+const (
+	// headerTimeout is the timeout of fetching a single block header.
+	headerTimeout = 3 * time.Second
+	// batchHeaderTimeout is the timeout of fetching the headers of a
+	// ResolveMany call in batches.
+	batchHeaderTimeout = 30 * time.Second
+	// DefaultStoreConfirmations is the default number of confirmations a
+	// block requires before its timestamp is persisted.
+	DefaultStoreConfirmations = 12
 )
 
 // BlockTimeResolver is a helper to get transaction timestamp from block number.
-// It has a cache for one block.
+// The recently resolved timestamps are cached in memory, and optionally in a
+// persistent store that survives restarts. Missing timestamps of multiple
+// blocks are fetched in JSON-RPC batches if the client supports it.
 type BlockTimeResolver struct {
 	mu        *sync.RWMutex
-	ethClient *ethclient.Client // eth client
+	ethClient HeaderReader // eth client
 	sugar     *zap.SugaredLogger
+	store     *BoltBlockTimeStore
+	// storeConfirmations is the number of confirmations a block requires
+	// before its timestamp is persisted to store.
+	storeConfirmations uint64
+	// head is the latest known head block, refreshed when a fetched block
+	// is not confirmed at it.
+	head uint64
 
-	cachedHeaders   map[uint64]*types.Header
+	cachedTimes     map[uint64]time.Time
 	cachedIndices   []uint64
 	maxCachedBlocks int
 }
 
+// BlockTimeResolverOption configures the optional parameters of BlockTimeResolver.
+type BlockTimeResolverOption func(*BlockTimeResolver)
+
+// WithBlockTimeStore persists the resolved timestamps of blocks that have at
+// least given number of confirmations to given store. The timestamps of more
+// recent blocks might still change on chain reorganisation, so they are only
+// cached in memory.
+func WithBlockTimeStore(store *BoltBlockTimeStore, confirmations uint64) BlockTimeResolverOption {
+	return func(btr *BlockTimeResolver) {
+		btr.store = store
+		btr.storeConfirmations = confirmations
+	}
+}
+
 // NewBlockTimeResolver returns BlockTimeResolver instance given a ethereum client.
-func NewBlockTimeResolver(sugar *zap.SugaredLogger, client *ethclient.Client) (*BlockTimeResolver, error) {
-	// maxCachedBlocks is the maximum number of block headers in cache, must > 1.
-	const maxCachedBlocks = 10
+func NewBlockTimeResolver(sugar *zap.SugaredLogger, client HeaderReader, options ...BlockTimeResolverOption) (*BlockTimeResolver, error) {
+	// maxCachedBlocks is the maximum number of block timestamps in memory cache, must > 1.
+	const maxCachedBlocks = 1000
 
-	return &BlockTimeResolver{
+	btr := &BlockTimeResolver{
 		mu:        &sync.RWMutex{},
 		ethClient: client,
 		sugar:     sugar,
 
-		cachedHeaders:   make(map[uint64]*types.Header, maxCachedBlocks),
+		cachedTimes:     make(map[uint64]time.Time, maxCachedBlocks),
 		maxCachedBlocks: maxCachedBlocks,
-	}, nil
+	}
+	for _, option := range options {
+		option(btr)
+	}
+	return btr, nil
 }
 
 func sortUint64s(a []uint64) {
@@ -46,29 +81,175 @@ func sortUint64s(a []uint64) {
 }
 
 // Resolve returns timestamp from block number.
-// It cachedHeaders block number and block header to reduces the number of request
-// to node.
 func (btr *BlockTimeResolver) Resolve(blockNumber uint64) (time.Time, error) {
-	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	times, err := btr.ResolveMany([]uint64{blockNumber})
+	if err != nil {
+		return time.Unix(0, 0), err
+	}
+	return times[blockNumber], nil
+}
+
+// ResolveMany returns the timestamps of given block numbers. The timestamps
+// are looked up in memory cache, then in persistent store, and the missing
+// ones are fetched from node at once.
+func (btr *BlockTimeResolver) ResolveMany(blockNumbers []uint64) (map[uint64]time.Time, error) {
+	logger := btr.sugar.With("func", "lib/blockchain/BlockTimeResolver.ResolveMany")
+
+	result := make(map[uint64]time.Time)
+	var missing []uint64
 
-	// cache hit happy path
 	btr.mu.RLock()
-	header, ok := btr.cachedHeaders[blockNumber]
-	if ok {
-		btr.sugar.Debugw("block timestamp resolver cache hit", "block_number", blockNumber)
-		ts := time.Unix(header.Time.Int64(), 0).UTC()
-		btr.mu.RUnlock()
-		return ts, nil
+	for _, number := range blockNumbers {
+		if _, ok := result[number]; ok {
+			continue
+		}
+		if ts, ok := btr.cachedTimes[number]; ok {
+			result[number] = ts
+			continue
+		}
+		// marks the number as seen to not add duplicates to missing
+		result[number] = time.Time{}
+		missing = append(missing, number)
 	}
 	btr.mu.RUnlock()
 
-	// cache miss
-	btr.sugar.Debugw("block timestamp resolver cache miss", "block_number", blockNumber)
+	logger.Debugw("block timestamp resolver memory cache lookup",
+		"blocks", len(result),
+		"missing", len(missing))
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	if btr.store != nil {
+		stored, err := btr.store.Get(missing)
+		if err != nil {
+			return nil, err
+		}
+		btr.cache(stored)
+
+		var remaining []uint64
+		for _, number := range missing {
+			if ts, ok := stored[number]; ok {
+				result[number] = ts
+				continue
+			}
+			remaining = append(remaining, number)
+		}
+		missing = remaining
+
+		logger.Debugw("block timestamp resolver store lookup",
+			"stored", len(stored),
+			"missing", len(missing))
+		if len(missing) == 0 {
+			return result, nil
+		}
+	}
+
+	fetched, err := btr.fetch(missing)
+	if err != nil {
+		return nil, err
+	}
+	if btr.store != nil {
+		if err = btr.persist(fetched); err != nil {
+			return nil, err
+		}
+	}
+	btr.cache(fetched)
+
+	for number, ts := range fetched {
+		result[number] = ts
+	}
+	return result, nil
+}
+
+// fetch returns the timestamps of given blocks from node, in JSON-RPC batches
+// if the client supports it or one by one otherwise.
+func (btr *BlockTimeResolver) fetch(blockNumbers []uint64) (map[uint64]time.Time, error) {
+	result := make(map[uint64]time.Time, len(blockNumbers))
+
+	if batchReader, ok := btr.ethClient.(BatchHeaderReader); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), batchHeaderTimeout)
+		defer cancel()
+
+		headers, err := batchReader.HeadersByNumber(ctx, blockNumbers)
+		if err != nil {
+			return nil, err
+		}
+		for i, header := range headers {
+			result[blockNumbers[i]] = time.Unix(header.Time.Int64(), 0).UTC()
+		}
+		return result, nil
+	}
+
+	for _, number := range blockNumbers {
+		ts, err := btr.fetchOne(number)
+		if err != nil {
+			return nil, err
+		}
+		result[number] = ts
+	}
+	return result, nil
+}
+
+// persist stores the given timestamps of blocks that have enough
+// confirmations.
+func (btr *BlockTimeResolver) persist(times map[uint64]time.Time) error {
+	var latest uint64
+	for number := range times {
+		if number > latest {
+			latest = number
+		}
+	}
+	head, err := btr.headBlock(latest)
+	if err != nil {
+		return err
+	}
+
+	confirmed := make(map[uint64]time.Time, len(times))
+	for number, ts := range times {
+		if number+btr.storeConfirmations <= head {
+			confirmed[number] = ts
+		}
+	}
+	if len(confirmed) == 0 {
+		return nil
+	}
+	return btr.store.Put(confirmed)
+}
+
+// headBlock returns the head block, it is only fetched from node if given
+// block does not have enough confirmations at the latest known head.
+func (btr *BlockTimeResolver) headBlock(block uint64) (uint64, error) {
+	btr.mu.RLock()
+	head := btr.head
+	btr.mu.RUnlock()
+	if block+btr.storeConfirmations <= head {
+		return head, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), headerTimeout)
+	defer cancel()
+	// parity and geth are not compatible in mix hash, the header is still usable
+	// when error is returned together with a non nil header
+	header, err := btr.ethClient.HeaderByNumber(ctx, nil)
+	if err != nil && header == nil {
+		return 0, err
+	}
+	head = header.Number.Uint64()
+
 	btr.mu.Lock()
-	defer btr.mu.Unlock()
+	if head > btr.head {
+		btr.head = head
+	}
+	btr.mu.Unlock()
+	return head, nil
+}
 
-	header, err := btr.ethClient.HeaderByNumber(timeout, big.NewInt(int64(blockNumber)))
+func (btr *BlockTimeResolver) fetchOne(blockNumber uint64) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), headerTimeout)
+	defer cancel()
+
+	header, err := btr.ethClient.HeaderByNumber(ctx, big.NewInt(0).SetUint64(blockNumber))
 	if err != nil && header == nil {
 		return time.Unix(0, 0), err
 	}
@@ -76,20 +257,38 @@ func (btr *BlockTimeResolver) Resolve(blockNumber uint64) (time.Time, error) {
 	// error because parity and geth are not compatible in mix hash
 	// so we ignore it as we can still get time from block
 	if err != nil && strings.Contains(err.Error(), "missing required field") {
-		btr.sugar.Infof("ignore block header error: %s", "err", err)
+		btr.sugar.Infow("ignore block header error", "err", err)
 	}
+	return time.Unix(header.Time.Int64(), 0).UTC(), nil
+}
 
-	if len(btr.cachedHeaders) >= btr.maxCachedBlocks {
-		oldestBlockNumber := btr.cachedIndices[0]
-		btr.sugar.Debugw("purging oldest cached header",
-			"block_number", oldestBlockNumber,
-			"max_cached_blocks", btr.maxCachedBlocks)
-		btr.cachedIndices = btr.cachedIndices[1:]
-		delete(btr.cachedHeaders, oldestBlockNumber)
+// cache adds given timestamps to memory cache, purging the earliest cached
+// blocks if the cache is full. The blocks are purged in the order they are
+// cached rather than by number, as a backfill of old blocks would otherwise
+// purge the cached blocks that it just resolved.
+func (btr *BlockTimeResolver) cache(times map[uint64]time.Time) {
+	btr.mu.Lock()
+	defer btr.mu.Unlock()
+
+	var added []uint64
+	for number, ts := range times {
+		if _, ok := btr.cachedTimes[number]; ok {
+			continue
+		}
+		added = append(added, number)
+		btr.cachedTimes[number] = ts
 	}
-	btr.cachedIndices = append(btr.cachedIndices, blockNumber)
-	sortUint64s(btr.cachedIndices)
-	btr.cachedHeaders[blockNumber] = header
+	// the blocks cached at once are purged from the lowest number
+	sortUint64s(added)
+	btr.cachedIndices = append(btr.cachedIndices, added...)
 
-	return time.Unix(header.Time.Int64(), 0).UTC(), nil
+	if purged := len(btr.cachedIndices) - btr.maxCachedBlocks; purged > 0 {
+		btr.sugar.Debugw("purging earliest cached block timestamps",
+			"purged", purged,
+			"max_cached_blocks", btr.maxCachedBlocks)
+		for _, number := range btr.cachedIndices[:purged] {
+			delete(btr.cachedTimes, number)
+		}
+		btr.cachedIndices = btr.cachedIndices[purged:]
+	}
 }
@@ -5,4 +5,5 @@ import "time"
 // BlockTimeResolverInterface define the functionality
 type BlockTimeResolverInterface interface {
 	Resolve(blockNumber uint64) (time.Time, error)
+	ResolveMany(blockNumbers []uint64) (map[uint64]time.Time, error)
 }
@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/KyberNetwork/reserve-stats/lib/boltutil"
+)
+
+// blockTimesBucket stores the timestamps of blocks: block number --> unix timestamp.
+const blockTimesBucket = "block_times"
+
+// BoltBlockTimeStore is a persistent cache of block timestamps in BoltDB.
+// The cache never expires, as the timestamp of a block only changes if the
+// block is replaced by chain reorganisation, BlockTimeResolver only stores
+// the blocks that have enough confirmations.
+type BoltBlockTimeStore struct {
+	db *bolt.DB
+}
+
+// NewBoltBlockTimeStore opens the BoltDB file at given path to store block
+// timestamps.
+func NewBoltBlockTimeStore(path string) (*BoltBlockTimeStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Update(func(tx *bolt.Tx) error {
+		_, cErr := tx.CreateBucketIfNotExists([]byte(blockTimesBucket))
+		return cErr
+	}); err != nil {
+		return nil, err
+	}
+	return &BoltBlockTimeStore{db: db}, nil
+}
+
+// Get returns the stored timestamps of given blocks, blocks that are not
+// stored are absent from the result.
+func (bs *BoltBlockTimeStore) Get(numbers []uint64) (map[uint64]time.Time, error) {
+	result := make(map[uint64]time.Time)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blockTimesBucket))
+		for _, number := range numbers {
+			if v := b.Get(boltutil.Uint64ToBytes(number)); v != nil {
+				result[number] = time.Unix(int64(boltutil.BytesToUint64(v)), 0).UTC()
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Put stores the timestamps of given blocks.
+func (bs *BoltBlockTimeStore) Put(times map[uint64]time.Time) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blockTimesBucket))
+		for number, ts := range times {
+			if err := b.Put(boltutil.Uint64ToBytes(number), boltutil.Uint64ToBytes(uint64(ts.Unix()))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the underlying BoltDB file.
+func (bs *BoltBlockTimeStore) Close() error {
+	return bs.db.Close()
+}
@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestBlockTimeResolverResolveMany(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	tmpDir, err := ioutil.TempDir("", "block_time_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	dbPath := filepath.Join(tmpDir, "block_times.db")
+
+	fn := newFakeNode(t, 1000)
+	defer fn.Close()
+
+	mc, err := NewMultiClient(sugar, []string{fn.URL}, WithHealthCheckInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	store, err := NewBoltBlockTimeStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := NewBlockTimeResolver(sugar, mc, WithBlockTimeStore(store, DefaultStoreConfirmations))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks []uint64
+	for i := uint64(1); i <= 250; i++ {
+		blocks = append(blocks, i, i)
+	}
+
+	// missing headers are fetched in batches, the head block is fetched to
+	// check confirmations before persisting
+	fn.resetRequests()
+	times, err := resolver.ResolveMany(blocks)
+	assert.NoError(t, err)
+	assert.Len(t, times, 250)
+	for number, ts := range times {
+		assert.Equal(t, time.Unix(fakeBlockTime(int64(number)), 0).UTC(), ts)
+	}
+	assert.Equal(t, 4, fn.resetRequests())
+
+	// the known head is reused for confirmed blocks
+	_, err = resolver.Resolve(500)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fn.resetRequests())
+
+	// block 995 does not have enough confirmations at head 1000
+	_, err = resolver.Resolve(995)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fn.resetRequests())
+
+	// resolved timestamps are cached in memory
+	ts, err := resolver.Resolve(100)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(fakeBlockTime(100), 0).UTC(), ts)
+	assert.Equal(t, 0, fn.resetRequests())
+
+	// resolved timestamps are persisted across restarts
+	if err = store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	store, err = NewBoltBlockTimeStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	resolver, err = NewBlockTimeResolver(sugar, mc, WithBlockTimeStore(store, DefaultStoreConfirmations))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	times, err = resolver.ResolveMany([]uint64{10, 20, 500})
+	assert.NoError(t, err)
+	assert.Len(t, times, 3)
+	assert.Equal(t, time.Unix(fakeBlockTime(500), 0).UTC(), times[500])
+	assert.Equal(t, 0, fn.resetRequests())
+
+	// the unconfirmed block is not persisted
+	_, err = resolver.Resolve(995)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fn.resetRequests())
+
+	fn.setFail(true)
+	_, err = resolver.ResolveMany([]uint64{400})
+	assert.Error(t, err)
+}
+
+func TestBlockTimeResolverCachePurge(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver, err := NewBlockTimeResolver(logger.Sugar(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver.maxCachedBlocks = 3
+
+	cacheBlocks := func(numbers ...uint64) {
+		times := make(map[uint64]time.Time)
+		for _, number := range numbers {
+			times[number] = time.Unix(fakeBlockTime(int64(number)), 0).UTC()
+		}
+		resolver.cache(times)
+	}
+
+	cacheBlocks(100, 101)
+	// the lower blocks cached later are kept, the earliest cached block is purged
+	cacheBlocks(10, 11)
+	assert.Len(t, resolver.cachedTimes, 3)
+	assert.NotContains(t, resolver.cachedTimes, uint64(100))
+	for _, number := range []uint64{101, 10, 11} {
+		assert.Contains(t, resolver.cachedTimes, number)
+	}
+	assert.Equal(t, []uint64{101, 10, 11}, resolver.cachedIndices)
+}
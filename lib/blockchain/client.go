@@ -0,0 +1,18 @@
+package blockchain
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Client is the Ethereum node client used by crawlers. It is implemented by
+// *ethclient.Client for a single node and by MultiClient for a set of nodes.
+type Client interface {
+	bind.ContractBackend
+	HeaderReader
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
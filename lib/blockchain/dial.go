@@ -0,0 +1,111 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// batchMethod is the method label of JSON-RPC batch requests.
+const batchMethod = "batch"
+
+var (
+	rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ethereum_rpc_duration_seconds",
+		Help:    "Latency of Ethereum node JSON-RPC calls by method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+	rpcErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ethereum_rpc_errors_total",
+		Help: "Number of failed Ethereum node JSON-RPC calls by method.",
+	}, []string{"method"})
+)
+
+func init() {
+	prometheus.MustRegister(rpcDuration, rpcErrors)
+}
+
+// Dial connects to Ethereum node at given URL. The latency and errors of
+// JSON-RPC calls over HTTP are recorded in metrics, websocket and IPC
+// connections are not instrumented.
+func Dial(url string) (*ethclient.Client, error) {
+	rpcClient, err := dialRPC(url)
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(rpcClient), nil
+}
+
+func dialRPC(url string) (*rpc.Client, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return rpc.Dial(url)
+	}
+
+	return rpc.DialHTTPWithClient(url, &http.Client{
+		Transport: &instrumentedTransport{next: http.DefaultTransport},
+	})
+}
+
+type jsonrpcRequest struct {
+	Method string `json:"method"`
+}
+
+type jsonrpcResponse struct {
+	Error json.RawMessage `json:"error"`
+}
+
+// instrumentedTransport records the latency and errors of JSON-RPC calls
+// sent over HTTP.
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+func (it *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := batchMethod
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		var call jsonrpcRequest
+		if err = json.Unmarshal(body, &call); err == nil {
+			method = call.Method
+		}
+	}
+
+	start := time.Now()
+	resp, err := it.next.RoundTrip(req)
+	rpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil || resp.StatusCode != http.StatusOK {
+		rpcErrors.WithLabelValues(method).Inc()
+		return resp, err
+	}
+
+	if method == batchMethod {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		rpcErrors.WithLabelValues(method).Inc()
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	var result jsonrpcResponse
+	if json.Unmarshal(body, &result) != nil || (len(result.Error) != 0 && string(result.Error) != "null") {
+		rpcErrors.WithLabelValues(method).Inc()
+	}
+	return resp, nil
+}
@@ -0,0 +1,46 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/KyberNetwork/reserve-stats/lib/metrics"
+)
+
+func TestDialMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if bytes.Contains(body, []byte("net_version")) {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"1"}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"header not found"}}`))
+	}))
+	defer ts.Close()
+
+	client, err := Dial(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	networkID, err := client.NetworkID(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), networkID.Int64())
+	_, err = client.HeaderByNumber(context.Background(), nil)
+	assert.Error(t, err)
+
+	resp := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, metrics.Path, nil))
+	body := resp.Body.String()
+	assert.True(t, strings.Contains(body, `ethereum_rpc_duration_seconds_count{method="net_version"} 1`), body)
+	assert.True(t, strings.Contains(body, `ethereum_rpc_errors_total{method="eth_getBlockByNumber"} 1`), body)
+	assert.False(t, strings.Contains(body, `ethereum_rpc_errors_total{method="net_version"}`), body)
+}
@@ -0,0 +1,148 @@
+package blockchain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+const (
+	nodeRPSFlag                     = "node-rps"
+	nodeHealthCheckIntervalFlag     = "node-health-check-interval"
+	nodeMaxBlockLagFlag             = "node-max-block-lag"
+	blockTimeCacheDBFlag            = "block-time-cache-db"
+	blockTimeCacheConfirmationsFlag = "block-time-cache-confirmations"
+)
+
+// NewMultiClientCliFlags returns cli flags to configure the failover and
+// rate limiting of Ethereum client with multiple nodes.
+func NewMultiClientCliFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.Float64Flag{
+			Name:   nodeRPSFlag,
+			Usage:  "Maximum number of requests per second sent to all Ethereum nodes, 0 means no limit",
+			EnvVar: "NODE_RPS",
+		},
+		cli.DurationFlag{
+			Name:   nodeHealthCheckIntervalFlag,
+			Usage:  "Interval between two health checks of Ethereum nodes by block height",
+			EnvVar: "NODE_HEALTH_CHECK_INTERVAL",
+			Value:  defaultHealthCheckInterval,
+		},
+		cli.Uint64Flag{
+			Name:   nodeMaxBlockLagFlag,
+			Usage:  "Maximum number of blocks an Ethereum node can be behind the highest node to be considered healthy",
+			EnvVar: "NODE_MAX_BLOCK_LAG",
+			Value:  defaultMaxBlockLag,
+		},
+	}
+}
+
+// NewMultiClientFromContext creates a MultiClient connecting to the nodes of
+// given comma separated URLs, configured by cli flags.
+func NewMultiClientFromContext(c *cli.Context, sugar *zap.SugaredLogger, nodeURLs string) (*MultiClient, error) {
+	var urls []string
+	for _, url := range strings.Split(nodeURLs, ",") {
+		url = strings.TrimSpace(url)
+		if err := validation.Validate(url, validation.Required, is.URL); err != nil {
+			return nil, fmt.Errorf("invalid node url: %q, error: %s", url, err)
+		}
+		urls = append(urls, url)
+	}
+
+	return NewMultiClient(sugar, urls,
+		WithRequestsPerSecond(c.Float64(nodeRPSFlag)),
+		WithHealthCheckInterval(c.Duration(nodeHealthCheckIntervalFlag)),
+		WithMaxBlockLag(c.Uint64(nodeMaxBlockLagFlag)),
+	)
+}
+
+// NewBlockTimeResolverCliFlags returns cli flags to configure the persistent
+// cache of BlockTimeResolver.
+func NewBlockTimeResolverCliFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   blockTimeCacheDBFlag,
+			Usage:  "Path to BoltDB file to cache block timestamps across restarts, block timestamps are only cached in memory if empty",
+			EnvVar: "BLOCK_TIME_CACHE_DB",
+		},
+		cli.Uint64Flag{
+			Name:   blockTimeCacheConfirmationsFlag,
+			Usage:  "Number of confirmations required before a block timestamp is cached in BoltDB",
+			EnvVar: "BLOCK_TIME_CACHE_CONFIRMATIONS",
+			Value:  DefaultStoreConfirmations,
+		},
+	}
+}
+
+// NewBlockTimeResolverFromContext creates a BlockTimeResolver reading block
+// headers from given client, configured by cli flags.
+func NewBlockTimeResolverFromContext(c *cli.Context, sugar *zap.SugaredLogger, client HeaderReader) (*BlockTimeResolver, error) {
+	var options []BlockTimeResolverOption
+	if path := c.String(blockTimeCacheDBFlag); len(path) != 0 {
+		sugar.Infow("caching block timestamps in BoltDB",
+			"path", path,
+			"confirmations", c.Uint64(blockTimeCacheConfirmationsFlag))
+		store, err := NewBoltBlockTimeStore(path)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, WithBlockTimeStore(store, c.Uint64(blockTimeCacheConfirmationsFlag)))
+	}
+	return NewBlockTimeResolver(sugar, client, options...)
+}
+
+// timeLayouts are the accepted layouts of time flags.
+var timeLayouts = []string{time.RFC3339, "2006-01-02"}
+
+// ParseTime parses the value of a time flag, in RFC3339 or date only format.
+func ParseTime(val string) (time.Time, error) {
+	var (
+		t   time.Time
+		err error
+	)
+	for _, layout := range timeLayouts {
+		if t, err = time.Parse(layout, val); err == nil {
+			return t, nil
+		}
+	}
+	return t, err
+}
+
+// BlockFromContext returns the block number given by block flag, or the
+// first block at or after the time given by time flag adjusted by offset. The
+// flags are exclusive, it returns false if none of them is given.
+func BlockFromContext(c *cli.Context, resolver *BlockTimeResolver, blockFlag, timeFlag string, offset int64) (uint64, bool, error) {
+	blockVal, timeVal := c.String(blockFlag), c.String(timeFlag)
+	switch {
+	case blockVal != "" && timeVal != "":
+		return 0, false, fmt.Errorf("only one of --%s and --%s is allowed", blockFlag, timeFlag)
+	case blockVal != "":
+		block, err := strconv.ParseUint(blockVal, 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid %s: %q, error: %s", blockFlag, blockVal, err)
+		}
+		return block, true, nil
+	case timeVal != "":
+		t, err := ParseTime(timeVal)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid %s: %q, error: %s", timeFlag, timeVal, err)
+		}
+		block, err := resolver.BlockAtTime(t)
+		if err != nil {
+			return 0, false, err
+		}
+		if offset < 0 && block == 0 {
+			return 0, false, fmt.Errorf("no block found before %s", t.String())
+		}
+		return uint64(int64(block) + offset), true, nil
+	default:
+		return 0, false, nil
+	}
+}
@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// defaultHeaderBatchSize is the maximum number of headers requested in a
+// single JSON-RPC batch.
+const defaultHeaderBatchSize = 100
+
+// BatchHeaderReader reads the block headers of multiple blocks using
+// JSON-RPC batches.
+type BatchHeaderReader interface {
+	HeadersByNumber(ctx context.Context, numbers []uint64) ([]*types.Header, error)
+}
+
+// batchHeaders returns the headers of given blocks in a single JSON-RPC
+// batch, in the same order as given numbers.
+func batchHeaders(ctx context.Context, client *rpc.Client, numbers []uint64) ([]*types.Header, error) {
+	var (
+		results = make([]json.RawMessage, len(numbers))
+		batch   = make([]rpc.BatchElem, len(numbers))
+	)
+	for i, number := range numbers {
+		batch[i] = rpc.BatchElem{
+			Method: "eth_getBlockByNumber",
+			Args:   []interface{}{hexutil.EncodeUint64(number), false},
+			Result: &results[i],
+		}
+	}
+
+	if err := client.BatchCallContext(ctx, batch); err != nil {
+		return nil, err
+	}
+
+	headers := make([]*types.Header, len(numbers))
+	for i, elem := range batch {
+		if elem.Error != nil {
+			return nil, elem.Error
+		}
+		if len(results[i]) == 0 || string(results[i]) == "null" {
+			return nil, fmt.Errorf("block %d not found", numbers[i])
+		}
+
+		header := new(types.Header)
+		// parity and geth are not compatible in mix hash, the header is still
+		// usable when its number and time are decoded
+		if err := header.UnmarshalJSON(results[i]); err != nil && (header.Number == nil || header.Time == nil) {
+			return nil, err
+		}
+		headers[i] = header
+	}
+	return headers, nil
+}
+
+// HeadersByNumber returns the headers of given blocks, in the same order as
+// given numbers. The headers are requested in batches, each batch is failed
+// over separately and charged to the rate limit per header.
+func (mc *MultiClient) HeadersByNumber(ctx context.Context, numbers []uint64) ([]*types.Header, error) {
+	var headers []*types.Header
+	for start := 0; start < len(numbers); start += defaultHeaderBatchSize {
+		end := start + defaultHeaderBatchSize
+		if end > len(numbers) {
+			end = len(numbers)
+		}
+
+		var batch []*types.Header
+		if err := mc.callBatch(ctx, "HeadersByNumber", end-start, func(n *node) error {
+			var bErr error
+			batch, bErr = batchHeaders(ctx, n.rpc, numbers[start:end])
+			return bErr
+		}); err != nil {
+			return nil, err
+		}
+		headers = append(headers, batch...)
+	}
+	return headers, nil
+}
@@ -10,3 +10,12 @@ type MockBlockTimeResolve struct {
 func (btr *MockBlockTimeResolve) Resolve(_ uint64) (time.Time, error) {
 	return time.Now(), nil
 }
+
+// ResolveMany return current time as mock result for all blocks
+func (btr *MockBlockTimeResolve) ResolveMany(blockNumbers []uint64) (map[uint64]time.Time, error) {
+	result := make(map[uint64]time.Time, len(blockNumbers))
+	for _, number := range blockNumbers {
+		result[number] = time.Now()
+	}
+	return result, nil
+}
@@ -0,0 +1,420 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultHealthCheckInterval = 30 * time.Second
+	defaultHealthCheckTimeout  = 5 * time.Second
+	defaultMaxBlockLag         = 5
+)
+
+// node is an Ethereum node of MultiClient and its last known health.
+type node struct {
+	// index is the position of node in configured URLs, it is logged instead
+	// of URL which might contain API key
+	index  int
+	rpc    *rpc.Client
+	client *ethclient.Client
+	// height is the latest block number of node at last health check
+	height uint64
+	// failed is true if node failed to respond to last health check or to
+	// a call after it
+	failed bool
+	// lagging is true if node is too many blocks behind the highest node
+	lagging bool
+}
+
+// rank returns the preference of node: healthy nodes are preferred to
+// lagging nodes, which are preferred to failed nodes.
+func (n *node) rank() int {
+	switch {
+	case n.failed:
+		return 0
+	case n.lagging:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// MultiClient is an Ethereum client that routes calls to the healthiest of
+// several nodes. Nodes are health checked periodically by block height, a
+// node is unhealthy if it fails to respond or lags too many blocks behind
+// the highest one. A failed call is retried on the next node, and all calls,
+// retries included, share a requests per second budget.
+type MultiClient struct {
+	sugar   *zap.SugaredLogger
+	limiter *rateLimiter
+
+	mu    sync.RWMutex
+	nodes []*node
+
+	healthCheckInterval time.Duration
+	healthCheckTimeout  time.Duration
+	maxBlockLag         uint64
+	rps                 float64
+	quit                chan struct{}
+}
+
+// MultiClientOption configures the optional parameters of MultiClient.
+type MultiClientOption func(*MultiClient)
+
+// WithRequestsPerSecond limits the number of requests sent to all nodes per
+// second. Zero means no limit.
+func WithRequestsPerSecond(rps float64) MultiClientOption {
+	return func(mc *MultiClient) {
+		mc.rps = rps
+	}
+}
+
+// WithHealthCheckInterval sets the interval between two health checks of nodes.
+func WithHealthCheckInterval(interval time.Duration) MultiClientOption {
+	return func(mc *MultiClient) {
+		mc.healthCheckInterval = interval
+	}
+}
+
+// WithMaxBlockLag sets the maximum number of blocks a node can be behind the
+// highest node to be considered healthy.
+func WithMaxBlockLag(maxBlockLag uint64) MultiClientOption {
+	return func(mc *MultiClient) {
+		mc.maxBlockLag = maxBlockLag
+	}
+}
+
+// NewMultiClient creates a new MultiClient instance connecting to given node
+// URLs. The nodes are health checked before returning and then periodically
+// in background until the client is closed.
+func NewMultiClient(sugar *zap.SugaredLogger, urls []string, options ...MultiClientOption) (*MultiClient, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no Ethereum node configured")
+	}
+
+	mc := &MultiClient{
+		sugar:               sugar,
+		healthCheckInterval: defaultHealthCheckInterval,
+		healthCheckTimeout:  defaultHealthCheckTimeout,
+		maxBlockLag:         defaultMaxBlockLag,
+		quit:                make(chan struct{}),
+	}
+	for _, option := range options {
+		option(mc)
+	}
+	mc.limiter = newRateLimiter(mc.rps)
+
+	for i, url := range urls {
+		rpcClient, err := dialRPC(url)
+		if err != nil {
+			return nil, err
+		}
+		mc.nodes = append(mc.nodes, &node{index: i, rpc: rpcClient, client: ethclient.NewClient(rpcClient)})
+	}
+
+	mc.checkHealth()
+	if mc.healthCheckInterval > 0 {
+		go mc.healthCheckLoop()
+	}
+	return mc, nil
+}
+
+func (mc *MultiClient) healthCheckLoop() {
+	ticker := time.NewTicker(mc.healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mc.checkHealth()
+		case <-mc.quit:
+			return
+		}
+	}
+}
+
+// checkHealth updates the block height of all nodes concurrently, the ones
+// that fail to respond or lag behind are marked unhealthy.
+func (mc *MultiClient) checkHealth() {
+	logger := mc.sugar.With("func", "lib/blockchain/MultiClient.checkHealth")
+
+	mc.mu.RLock()
+	nodes := append([]*node{}, mc.nodes...)
+	mc.mu.RUnlock()
+
+	var (
+		wg      sync.WaitGroup
+		heights = make([]uint64, len(nodes))
+		errs    = make([]error, len(nodes))
+	)
+	for i, n := range nodes {
+		wg.Add(1)
+		go func(i int, n *node) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), mc.healthCheckTimeout)
+			defer cancel()
+			if errs[i] = mc.limiter.wait(ctx, 1); errs[i] != nil {
+				return
+			}
+			header, err := n.client.HeaderByNumber(ctx, nil)
+			if err != nil && header == nil {
+				errs[i] = err
+				return
+			}
+			heights[i] = header.Number.Uint64()
+		}(i, n)
+	}
+	wg.Wait()
+
+	var best uint64
+	for i := range nodes {
+		if errs[i] == nil && heights[i] > best {
+			best = heights[i]
+		}
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for i, n := range nodes {
+		failed := errs[i] != nil
+		lagging := !failed && heights[i]+mc.maxBlockLag < best
+		if failed != n.failed || lagging != n.lagging {
+			logger.Infow("node health changed",
+				"node", n.index,
+				"failed", failed,
+				"lagging", lagging,
+				"height", heights[i],
+				"best_height", best,
+				"err", errs[i])
+		}
+		n.height = heights[i]
+		n.failed = failed
+		n.lagging = lagging
+	}
+}
+
+// ranked returns the nodes ordered by preference: healthy nodes first, then
+// highest block height. Unhealthy nodes are kept as last resort.
+func (mc *MultiClient) ranked() []*node {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	nodes := append([]*node{}, mc.nodes...)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].rank() != nodes[j].rank() {
+			return nodes[i].rank() > nodes[j].rank()
+		}
+		return nodes[i].height > nodes[j].height
+	})
+	return nodes
+}
+
+// httpStatusErrPattern matches the error returned by rpc client for a non 2xx
+// HTTP response, which is the status text of the response.
+var httpStatusErrPattern = regexp.MustCompile(`^(\d{3}) `)
+
+// isNodeFailure returns true if given call error indicates that the node is
+// unable to serve: a transport error, a timeout, a 5xx HTTP response or a 429
+// HTTP response of rate limited node, which is retried on other nodes. The
+// JSON-RPC error responses, for example a reverted call or a rejected logs
+// filter of too large range, are the answers of a working node.
+func isNodeFailure(err error) bool {
+	if err == nil || err == ethereum.NotFound {
+		return false
+	}
+	if _, ok := err.(rpc.Error); ok {
+		return false
+	}
+	if match := httpStatusErrPattern.FindStringSubmatch(err.Error()); match != nil {
+		status, _ := strconv.Atoi(match[1])
+		return status >= 500 || status == http.StatusTooManyRequests
+	}
+	return true
+}
+
+func (mc *MultiClient) markFailed(n *node) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	n.failed = true
+}
+
+// call runs given function with the client of nodes in preference order until
+// it succeeds. A failed node is tried last until next health check.
+// The result errors that do not indicate node failure, including JSON-RPC
+// error responses, are returned as is without trying other nodes.
+func (mc *MultiClient) call(ctx context.Context, method string, fn func(n *node) error) error {
+	return mc.callBatch(ctx, method, 1, fn)
+}
+
+// callBatch is call for a JSON-RPC batch of given size, which is charged to
+// the rate limit as that many requests.
+func (mc *MultiClient) callBatch(ctx context.Context, method string, size int, fn func(n *node) error) error {
+	logger := mc.sugar.With(
+		"func", "lib/blockchain/MultiClient.call",
+		"method", method,
+	)
+
+	var err error
+	for _, n := range mc.ranked() {
+		if err = mc.limiter.wait(ctx, size); err != nil {
+			return err
+		}
+
+		err = fn(n)
+		if !isNodeFailure(err) || ctx.Err() != nil {
+			return err
+		}
+
+		logger.Warnw("Ethereum node call failed, trying next node", "node", n.index, "err", err)
+		mc.markFailed(n)
+	}
+	return err
+}
+
+// Close stops the health checks and closes the connections to nodes.
+func (mc *MultiClient) Close() {
+	close(mc.quit)
+	for _, n := range mc.nodes {
+		n.client.Close()
+	}
+}
+
+// HeaderByNumber returns a block header from the healthiest node. As parity
+// and geth are not compatible in mix hash, the header is returned together
+// with the error if the node returns both.
+func (mc *MultiClient) HeaderByNumber(ctx context.Context, number *big.Int) (header *types.Header, err error) {
+	var headerErr error
+	err = mc.call(ctx, "HeaderByNumber", func(n *node) error {
+		header, headerErr = n.client.HeaderByNumber(ctx, number)
+		if header != nil {
+			return nil
+		}
+		return headerErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	return header, headerErr
+}
+
+// TransactionByHash returns the transaction with given hash.
+func (mc *MultiClient) TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error) {
+	err = mc.call(ctx, "TransactionByHash", func(n *node) error {
+		var cErr error
+		tx, isPending, cErr = n.client.TransactionByHash(ctx, hash)
+		return cErr
+	})
+	return tx, isPending, err
+}
+
+// TransactionReceipt returns the receipt of given transaction.
+func (mc *MultiClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (receipt *types.Receipt, err error) {
+	err = mc.call(ctx, "TransactionReceipt", func(n *node) error {
+		var cErr error
+		receipt, cErr = n.client.TransactionReceipt(ctx, txHash)
+		return cErr
+	})
+	return receipt, err
+}
+
+// CodeAt returns the contract code of given account.
+func (mc *MultiClient) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) (code []byte, err error) {
+	err = mc.call(ctx, "CodeAt", func(n *node) error {
+		var cErr error
+		code, cErr = n.client.CodeAt(ctx, contract, blockNumber)
+		return cErr
+	})
+	return code, err
+}
+
+// CallContract executes a message call transaction.
+func (mc *MultiClient) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) (result []byte, err error) {
+	err = mc.call(ctx, "CallContract", func(n *node) error {
+		var cErr error
+		result, cErr = n.client.CallContract(ctx, call, blockNumber)
+		return cErr
+	})
+	return result, err
+}
+
+// PendingCodeAt returns the contract code of given account in pending state.
+func (mc *MultiClient) PendingCodeAt(ctx context.Context, account common.Address) (code []byte, err error) {
+	err = mc.call(ctx, "PendingCodeAt", func(n *node) error {
+		var cErr error
+		code, cErr = n.client.PendingCodeAt(ctx, account)
+		return cErr
+	})
+	return code, err
+}
+
+// PendingNonceAt returns the account nonce of given account in pending state.
+func (mc *MultiClient) PendingNonceAt(ctx context.Context, account common.Address) (nonce uint64, err error) {
+	err = mc.call(ctx, "PendingNonceAt", func(n *node) error {
+		var cErr error
+		nonce, cErr = n.client.PendingNonceAt(ctx, account)
+		return cErr
+	})
+	return nonce, err
+}
+
+// SuggestGasPrice returns the currently suggested gas price.
+func (mc *MultiClient) SuggestGasPrice(ctx context.Context) (price *big.Int, err error) {
+	err = mc.call(ctx, "SuggestGasPrice", func(n *node) error {
+		var cErr error
+		price, cErr = n.client.SuggestGasPrice(ctx)
+		return cErr
+	})
+	return price, err
+}
+
+// EstimateGas estimates the gas needed to execute given transaction.
+func (mc *MultiClient) EstimateGas(ctx context.Context, call ethereum.CallMsg) (gas uint64, err error) {
+	err = mc.call(ctx, "EstimateGas", func(n *node) error {
+		var cErr error
+		gas, cErr = n.client.EstimateGas(ctx, call)
+		return cErr
+	})
+	return gas, err
+}
+
+// SendTransaction injects a signed transaction into the pending pool.
+func (mc *MultiClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	return mc.call(ctx, "SendTransaction", func(n *node) error {
+		return n.client.SendTransaction(ctx, tx)
+	})
+}
+
+// FilterLogs executes a filter query.
+func (mc *MultiClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) (logs []types.Log, err error) {
+	err = mc.call(ctx, "FilterLogs", func(n *node) error {
+		var cErr error
+		logs, cErr = n.client.FilterLogs(ctx, query)
+		return cErr
+	})
+	return logs, err
+}
+
+// SubscribeFilterLogs subscribes to the results of a streaming filter query
+// on the healthiest node that supports subscriptions. The subscription is
+// not failed over when the node drops it.
+func (mc *MultiClient) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (sub ethereum.Subscription, err error) {
+	err = mc.call(ctx, "SubscribeFilterLogs", func(n *node) error {
+		var cErr error
+		sub, cErr = n.client.SubscribeFilterLogs(ctx, query, ch)
+		return cErr
+	})
+	return sub, err
+}
@@ -0,0 +1,267 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeNode is a JSON-RPC server answering eth_getBlockByNumber with headers
+// up to given height, single requests and batches are both supported.
+type fakeNode struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	height   int64
+	fail     bool
+	limited  bool
+	rpcError bool
+	requests int
+}
+
+type fakeRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func newFakeNode(t *testing.T, height int64) *fakeNode {
+	fn := &fakeNode{height: height}
+	fn.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		fn.mu.Lock()
+		defer fn.mu.Unlock()
+		fn.requests++
+		if fn.fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if fn.limited {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if body[0] != '[' {
+			var req fakeRequest
+			if err := json.Unmarshal(body, &req); err != nil {
+				t.Fatal(err)
+			}
+			json.NewEncoder(w).Encode(fn.respond(t, req))
+			return
+		}
+
+		var reqs []fakeRequest
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			t.Fatal(err)
+		}
+		var resps []interface{}
+		for _, req := range reqs {
+			resps = append(resps, fn.respond(t, req))
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+	return fn
+}
+
+func (fn *fakeNode) respond(t *testing.T, req fakeRequest) interface{} {
+	if fn.rpcError {
+		return map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"error": map[string]interface{}{
+				"code":    -32005,
+				"message": "query returned more than 10000 results",
+			},
+		}
+	}
+
+	number := fn.height
+	var tag string
+	if err := json.Unmarshal(req.Params[0], &tag); err != nil {
+		t.Fatal(err)
+	}
+	if tag != "latest" {
+		n, _ := big.NewInt(0).SetString(tag[2:], 16)
+		number = n.Int64()
+	}
+
+	header, err := json.Marshal(&types.Header{
+		Number:     big.NewInt(number),
+		Time:       big.NewInt(fakeBlockTime(number)),
+		Difficulty: big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      req.ID,
+		"result":  json.RawMessage(header),
+	}
+}
+
+// fakeBlockTime returns the timestamp of given block of fake node.
+func fakeBlockTime(number int64) int64 {
+	return 1539000000 + number*15
+}
+
+func (fn *fakeNode) setFail(fail bool) {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	fn.fail = fail
+}
+
+func (fn *fakeNode) setLimited(limited bool) {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	fn.limited = limited
+}
+
+func (fn *fakeNode) setRPCError(rpcError bool) {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	fn.rpcError = rpcError
+}
+
+func (fn *fakeNode) resetRequests() int {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	requests := fn.requests
+	fn.requests = 0
+	return requests
+}
+
+func TestMultiClientFailover(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	var (
+		lagging = newFakeNode(t, 100)
+		best    = newFakeNode(t, 110)
+		down    = newFakeNode(t, 110)
+	)
+	defer lagging.Close()
+	defer best.Close()
+	defer down.Close()
+	down.setFail(true)
+
+	mc, err := NewMultiClient(sugar, []string{lagging.URL, down.URL, best.URL}, WithHealthCheckInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+	lagging.resetRequests()
+	best.resetRequests()
+	down.resetRequests()
+
+	// the highest healthy node is preferred
+	header, err := mc.HeaderByNumber(context.Background(), big.NewInt(50))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50), header.Number.Int64())
+	assert.Equal(t, 1, best.resetRequests())
+	assert.Equal(t, 0, lagging.resetRequests())
+	assert.Equal(t, 0, down.resetRequests())
+
+	// failed call is retried on next node, the failed node is not tried again
+	best.setFail(true)
+	header, err = mc.HeaderByNumber(context.Background(), big.NewInt(60))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), header.Number.Int64())
+	assert.Equal(t, 1, best.resetRequests())
+	assert.Equal(t, 1, lagging.resetRequests())
+	assert.Equal(t, 0, down.resetRequests())
+
+	_, err = mc.HeaderByNumber(context.Background(), big.NewInt(70))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, best.resetRequests())
+	assert.Equal(t, 1, lagging.resetRequests())
+
+	// recovered node is preferred again after health check
+	best.setFail(false)
+	mc.checkHealth()
+	best.resetRequests()
+	_, err = mc.HeaderByNumber(context.Background(), big.NewInt(80))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, best.resetRequests())
+
+	// JSON-RPC error response is returned as is, the node stays healthy
+	best.resetRequests()
+	lagging.resetRequests()
+	down.resetRequests()
+	best.setRPCError(true)
+	_, err = mc.HeaderByNumber(context.Background(), big.NewInt(85))
+	if assert.Error(t, err) {
+		_, ok := err.(rpc.Error)
+		assert.True(t, ok, "expected JSON-RPC error, got %v", err)
+	}
+	assert.Equal(t, 1, best.resetRequests())
+	assert.Equal(t, 0, lagging.resetRequests())
+	assert.Equal(t, 0, down.resetRequests())
+
+	best.setRPCError(false)
+	_, err = mc.HeaderByNumber(context.Background(), big.NewInt(86))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, best.resetRequests())
+	assert.Equal(t, 0, lagging.resetRequests())
+
+	// rate limited node is failed over
+	best.setLimited(true)
+	_, err = mc.HeaderByNumber(context.Background(), big.NewInt(87))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, best.resetRequests())
+	assert.Equal(t, 1, lagging.resetRequests())
+	best.setLimited(false)
+	mc.checkHealth()
+	best.resetRequests()
+	lagging.resetRequests()
+
+	// all nodes failed
+	for _, n := range []*fakeNode{lagging, best, down} {
+		n.setFail(true)
+	}
+	_, err = mc.HeaderByNumber(context.Background(), big.NewInt(90))
+	assert.Error(t, err)
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1539000000, 0)
+	rl := newRateLimiter(10)
+	rl.now = func() time.Time { return now }
+
+	assert.Equal(t, time.Duration(0), rl.reserve(1))
+	assert.Equal(t, 100*time.Millisecond, rl.reserve(1))
+	assert.Equal(t, 200*time.Millisecond, rl.reserve(1))
+
+	// budget is not accumulated while idle
+	now = now.Add(time.Minute)
+	assert.Equal(t, time.Duration(0), rl.reserve(1))
+	assert.Equal(t, 100*time.Millisecond, rl.reserve(1))
+
+	// a batch is charged per request it contains
+	now = now.Add(time.Minute)
+	assert.Equal(t, time.Duration(0), rl.reserve(5))
+	assert.Equal(t, 500*time.Millisecond, rl.reserve(1))
+
+	assert.Equal(t, time.Duration(0), newRateLimiter(0).reserve(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, rl.wait(ctx, 1))
+}
@@ -0,0 +1,60 @@
+package blockchain
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces requests evenly to stay within a requests per second
+// budget. A zero value rateLimiter does not limit.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	now      func() time.Time
+}
+
+func newRateLimiter(rps float64) *rateLimiter {
+	rl := &rateLimiter{now: time.Now}
+	if rps > 0 {
+		rl.interval = time.Duration(float64(time.Second) / rps)
+	}
+	return rl
+}
+
+// reserve returns the waiting duration before a request of given cost, in
+// number of requests, is allowed.
+func (rl *rateLimiter) reserve(cost int) time.Duration {
+	if rl.interval == 0 {
+		return 0
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := rl.now()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	wait := rl.next.Sub(now)
+	rl.next = rl.next.Add(time.Duration(cost) * rl.interval)
+	return wait
+}
+
+// wait blocks until a request of given cost is allowed or given context is
+// done. A JSON-RPC batch costs as many requests as it contains.
+func (rl *rateLimiter) wait(ctx context.Context, cost int) error {
+	wait := rl.reserve(cost)
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -17,3 +17,37 @@ var (
 	// KCCAddr is Kyber Community Coupon token address
 	KCCAddr = common.HexToAddress("0x09677D0175DEC51E2215426Cddd055a71bf4228d")
 )
+
+// TokenAddresses is the addresses of the tokens that trade logs handle
+// specially, as deployed on a network.
+type TokenAddresses struct {
+	KNC  common.Address
+	WETH common.Address
+	KCC  common.Address
+}
+
+// MainnetTokenAddresses returns the token addresses on mainnet.
+func MainnetTokenAddresses() TokenAddresses {
+	return TokenAddresses{
+		KNC:  KNCAddr,
+		WETH: WETHAddr,
+		KCC:  KCCAddr,
+	}
+}
+
+// NotBurnTokens returns the tokens of which the trades on KyberNetwork do not
+// emit burn fee event.
+func (t TokenAddresses) NotBurnTokens() []common.Address {
+	return []common.Address{ETHAddr, t.WETH, t.KCC}
+}
+
+// IsBurnable indicate if the burn fee event was emitted when
+// the given token was trade on KyberNetwork
+func (t TokenAddresses) IsBurnable(token common.Address) bool {
+	for _, notBurnToken := range t.NotBurnTokens() {
+		if token == notBurnToken {
+			return false
+		}
+	}
+	return true
+}
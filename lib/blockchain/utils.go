@@ -3,16 +3,8 @@ package blockchain
 import (
 	"math"
 	"math/big"
-
-	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
-var notBurnTokens = map[ethereum.Address]struct{}{
-	ETHAddr:  {},
-	WETHAddr: {},
-	KCCAddr:  {},
-}
-
 // floatToBigInt converts a float to a big int with specific decimal
 // Example:
 // - floatToBigInt(1, 4) = 10000
@@ -30,10 +22,3 @@ func floatToBigInt(amount float64, decimal int64) *big.Int {
 func EthToWei(n float64) *big.Int {
 	return floatToBigInt(n, 18)
 }
-
-// IsBurnable indicate if the burn fee event was emitted when
-// the given token was trade on KyberNetwork
-func IsBurnable(token ethereum.Address) bool {
-	_, notBurn := notBurnTokens[token]
-	return !notBurn
-}
@@ -0,0 +1,76 @@
+package broadcast
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending any request when broadcast API
+// is failing consecutively and the circuit breaker is open.
+var ErrCircuitOpen = errors.New("broadcast circuit breaker is open")
+
+// circuitBreaker stops sending requests to broadcast API after a number of
+// consecutive failures. Once the cool down period passed, a single trial
+// request is allowed, the circuit is closed again if it succeeds.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	coolDown  time.Duration
+	failures  int
+	openedAt  time.Time
+	trialing  bool
+	now       func() time.Time
+}
+
+func newCircuitBreaker(threshold int, coolDown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		coolDown:  coolDown,
+		now:       time.Now,
+	}
+}
+
+// allow returns ErrCircuitOpen if the request should not be sent.
+func (cb *circuitBreaker) allow() error {
+	if cb.threshold <= 0 {
+		return nil
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.failures < cb.threshold {
+		return nil
+	}
+	if cb.trialing || cb.now().Sub(cb.openedAt) < cb.coolDown {
+		return ErrCircuitOpen
+	}
+	cb.trialing = true
+	return nil
+}
+
+// release ends a request allowed by circuit breaker without recording its
+// result, as the request is cancelled before broadcast API answers.
+func (cb *circuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.trialing = false
+}
+
+// record reports the result of a request allowed by circuit breaker.
+func (cb *circuitBreaker) record(success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.trialing = false
+	if success {
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.failures >= cb.threshold {
+		cb.openedAt = cb.now()
+	}
+}
@@ -1,23 +1,30 @@
 package broadcast
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
 
-func newTestGeoInfo(server *httptest.Server) (*Client, error) {
+func newTestGeoInfo(server *httptest.Server, options ...ClientOption) (*Client, error) {
 	logger, err := zap.NewDevelopment()
 	if err != nil {
 		return nil, err
 	}
 	defer logger.Sync()
 	sugar := logger.Sugar()
-	return NewClient(sugar, server.URL)
+	return NewClient(sugar, server.URL, options...)
 }
 
 func TestGetValidResponse(t *testing.T) {
@@ -47,7 +54,7 @@ func TestGetValidResponse(t *testing.T) {
 	if err != nil {
 		t.Error("Could not create Client object", "err", err.Error())
 	}
-	ip, country, err := g.GetTxInfo(tx)
+	ip, country, err := g.GetTxInfo(context.Background(), tx)
 	if err != nil {
 		t.Error("Could not get ipInfo")
 	}
@@ -84,8 +91,204 @@ func TestInvalidResponse(t *testing.T) {
 	if err != nil {
 		t.Error("Could not create Client object", "err", err.Error())
 	}
-	_, _, err = g.GetTxInfo(tx)
+	_, _, err = g.GetTxInfo(context.Background(), tx)
 	if err != nil {
 		t.Errorf("Get unexpected error: %s", err.Error())
 	}
 }
+
+func TestUnknownErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"success": false, "err": "internal failure"}`))
+	}))
+	defer server.Close()
+
+	g, err := newTestGeoInfo(server)
+	assert.NoError(t, err)
+	_, _, err = g.GetTxInfo(context.Background(), "0x01")
+	assert.EqualError(t, err, `broadcast server returns unknown error: "internal failure"`)
+}
+
+func TestRetryOnServerError(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			http.Error(rw, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"success": true, "data": {"IP": "31.166.85.223", "Country": "SA"}}`))
+	}))
+	defer server.Close()
+
+	g, err := newTestGeoInfo(server, WithRetry(3, time.Millisecond, 5*time.Millisecond))
+	assert.NoError(t, err)
+	ip, country, err := g.GetTxInfo(context.Background(), "0x01")
+	assert.NoError(t, err)
+	assert.Equal(t, "31.166.85.223", ip)
+	assert.Equal(t, "SA", country)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	g, err := newTestGeoInfo(server,
+		WithRetry(1, time.Millisecond, time.Millisecond),
+		WithCircuitBreaker(2, time.Hour),
+	)
+	assert.NoError(t, err)
+
+	_, _, err = g.GetTxInfo(context.Background(), "0x01")
+	assert.EqualError(t, err, "broadcast server returns status 503")
+	_, _, err = g.GetTxInfo(context.Background(), "0x01")
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// cool down passed, a trial request is allowed
+	g.breaker.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, _, err = g.GetTxInfo(context.Background(), "0x01")
+	assert.EqualError(t, err, "broadcast server returns status 503")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestContextCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	g, err := newTestGeoInfo(server, WithRetry(10, time.Hour, time.Hour))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err = g.GetTxInfo(ctx, "0x01")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestCachedClient(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"success": true, "data": {"IP": "31.166.85.223", "Country": "SA"}}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "broadcast")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	g, err := newTestGeoInfo(server)
+	assert.NoError(t, err)
+
+	path := filepath.Join(dir, "cache.db")
+	cc, err := NewCachedClient(g.sugar, g, path)
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		ip, country, err := cc.GetTxInfo(context.Background(), "0x01")
+		assert.NoError(t, err)
+		assert.Equal(t, "31.166.85.223", ip)
+		assert.Equal(t, "SA", country)
+	}
+	assert.NoError(t, cc.Close())
+
+	// the cache is persisted after reopening
+	cc, err = NewCachedClient(g.sugar, g, path)
+	assert.NoError(t, err)
+	_, _, err = cc.GetTxInfo(context.Background(), "0x01")
+	assert.NoError(t, err)
+	assert.NoError(t, cc.Close())
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestCircuitBreakerRecordsOnlyAnswers(t *testing.T) {
+	var (
+		requests int32
+		status   int32 = http.StatusBadRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if s := int(atomic.LoadInt32(&status)); s != http.StatusOK {
+			http.Error(rw, "bad request", s)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"success": false, "err": "Can not find the transaction. Check Tx again"}`))
+	}))
+	defer server.Close()
+
+	g, err := newTestGeoInfo(server,
+		WithRetry(0, time.Millisecond, time.Millisecond),
+		WithCircuitBreaker(2, time.Hour),
+	)
+	assert.NoError(t, err)
+
+	// a not found answer is a success
+	atomic.StoreInt32(&status, http.StatusOK)
+	_, _, err = g.GetTxInfo(context.Background(), "0x01")
+	assert.NoError(t, err)
+
+	// 4xx responses are failures
+	atomic.StoreInt32(&status, http.StatusBadRequest)
+	for i := 0; i < 2; i++ {
+		_, _, err = g.GetTxInfo(context.Background(), "0x01")
+		assert.EqualError(t, err, "broadcast server returns status 400")
+	}
+	_, _, err = g.GetTxInfo(context.Background(), "0x01")
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	// a cancelled trial request is not recorded, another trial is allowed
+	g.breaker.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = g.GetTxInfo(ctx, "0x01")
+	assert.Equal(t, context.Canceled, err)
+
+	atomic.StoreInt32(&status, http.StatusOK)
+	_, _, err = g.GetTxInfo(context.Background(), "0x01")
+	assert.NoError(t, err)
+}
+
+func TestCachedClientNotFoundTTL(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"success": false, "err": "Can not find the transaction. Check Tx again"}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "broadcast")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	g, err := newTestGeoInfo(server)
+	assert.NoError(t, err)
+	cc, err := NewCachedClient(g.sugar, g, filepath.Join(dir, "cache.db"), WithNotFoundTTL(time.Hour))
+	assert.NoError(t, err)
+	defer cc.Close()
+
+	for i := 0; i < 2; i++ {
+		ip, country, err := cc.GetTxInfo(context.Background(), "0x01")
+		assert.NoError(t, err)
+		assert.Empty(t, ip)
+		assert.Empty(t, country)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// the not found result is queried again after TTL
+	cc.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, _, err = cc.GetTxInfo(context.Background(), "0x01")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
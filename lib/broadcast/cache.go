@@ -0,0 +1,127 @@
+package broadcast
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+const (
+	txInfoBucket = "tx_info"
+
+	defaultNotFoundTTL = time.Hour
+)
+
+// txInfo is the stored geo information of a transaction.
+type txInfo struct {
+	IP      string `json:"ip"`
+	Country string `json:"country"`
+	// CachedAt is the time the result is cached, zero for the results
+	// cached before it is recorded.
+	CachedAt time.Time `json:"cached_at,omitempty"`
+}
+
+// notFound returns true if the transaction is not found by broadcast API.
+func (info txInfo) notFound() bool {
+	return info.IP == "" && info.Country == ""
+}
+
+// CachedClient is the wrapper of broadcast client that persists the results
+// in a local BoltDB file, the broadcast API is queried only once per transaction.
+// The transactions not found by broadcast API, which may be not yet known to it,
+// are queried again after the not found TTL.
+type CachedClient struct {
+	Interface
+
+	sugar       *zap.SugaredLogger
+	db          *bolt.DB
+	notFoundTTL time.Duration
+	now         func() time.Time
+}
+
+// CachedClientOption configures the optional parameters of CachedClient.
+type CachedClientOption func(*CachedClient)
+
+// WithNotFoundTTL sets how long the not found results are cached.
+func WithNotFoundTTL(ttl time.Duration) CachedClientOption {
+	return func(cc *CachedClient) {
+		cc.notFoundTTL = ttl
+	}
+}
+
+// NewCachedClient opens the BoltDB file at given path to cache results of the given client.
+func NewCachedClient(sugar *zap.SugaredLogger, client Interface, path string, options ...CachedClientOption) (*CachedClient, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Update(func(tx *bolt.Tx) error {
+		_, cErr := tx.CreateBucketIfNotExists([]byte(txInfoBucket))
+		return cErr
+	}); err != nil {
+		return nil, err
+	}
+
+	cc := &CachedClient{
+		Interface:   client,
+		sugar:       sugar,
+		db:          db,
+		notFoundTTL: defaultNotFoundTTL,
+		now:         time.Now,
+	}
+	for _, option := range options {
+		option(cc)
+	}
+	return cc, nil
+}
+
+// GetTxInfo returns the cached ip, country info of a tx, querying the
+// underlying client on cache miss or expired not found result. Only
+// successful results are cached.
+func (cc *CachedClient) GetTxInfo(ctx context.Context, tx string) (string, string, error) {
+	logger := cc.sugar.With("func", "lib/broadcast/CachedClient.GetTxInfo", "tx", tx)
+
+	var (
+		info  txInfo
+		found bool
+	)
+	if err := cc.db.View(func(btx *bolt.Tx) error {
+		v := btx.Bucket([]byte(txInfoBucket)).Get([]byte(tx))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &info)
+	}); err != nil {
+		return "", "", err
+	}
+	if found && (!info.notFound() || cc.now().Sub(info.CachedAt) < cc.notFoundTTL) {
+		logger.Debug("cache hit")
+		return info.IP, info.Country, nil
+	}
+
+	ip, country, err := cc.Interface.GetTxInfo(ctx, tx)
+	if err != nil {
+		return "", "", err
+	}
+
+	v, err := json.Marshal(txInfo{IP: ip, Country: country, CachedAt: cc.now().UTC()})
+	if err != nil {
+		return "", "", err
+	}
+	if err = cc.db.Update(func(btx *bolt.Tx) error {
+		return btx.Bucket([]byte(txInfoBucket)).Put([]byte(tx), v)
+	}); err != nil {
+		return "", "", err
+	}
+	return ip, country, nil
+}
+
+// Close closes the underlying BoltDB file.
+func (cc *CachedClient) Close() error {
+	return cc.db.Close()
+}
@@ -1,11 +1,13 @@
 package broadcast
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
@@ -13,11 +15,74 @@ import (
 // if the given transaction address not found.
 var errNotFoundMsg = "Can not find the transaction. Check Tx again"
 
+var requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "broadcast_requests_total",
+	Help: "Number of requests to broadcast API by result: success, error or circuit_open.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(requests)
+}
+
+const (
+	defaultTimeout          = 30 * time.Second
+	defaultMaxConcurrency   = 4
+	defaultMaxRetries       = 3
+	defaultInitialBackoff   = 500 * time.Millisecond
+	defaultMaxBackoff       = 10 * time.Second
+	defaultFailureThreshold = 5
+	defaultCoolDown         = time.Minute
+)
+
 // Client is the the real implementation of broadcast client interface
 type Client struct {
 	host   string
 	sugar  *zap.SugaredLogger
 	client *http.Client
+
+	// sem limits the number of concurrent requests sent to broadcast API.
+	sem            chan struct{}
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	breaker        *circuitBreaker
+}
+
+// ClientOption configures the optional parameters of broadcast client.
+type ClientOption func(*Client)
+
+// WithTimeout sets the timeout of a single request to broadcast API.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.client.Timeout = timeout
+	}
+}
+
+// WithMaxConcurrency sets the maximum number of concurrent requests.
+func WithMaxConcurrency(n int) ClientOption {
+	return func(c *Client) {
+		if n > 0 {
+			c.sem = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithRetry sets the number of retries of a failed request and the initial
+// backoff duration, which is doubled on each retry until maxBackoff.
+func WithRetry(maxRetries int, initialBackoff, maxBackoff time.Duration) ClientOption {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.initialBackoff = initialBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithCircuitBreaker configures the client to stop sending requests for coolDown
+// duration after threshold consecutive failures. Zero threshold disables it.
+func WithCircuitBreaker(threshold int, coolDown time.Duration) ClientOption {
+	return func(c *Client) {
+		c.breaker = newCircuitBreaker(threshold, coolDown)
+	}
 }
 
 type tradeLogGeoInfoResp struct {
@@ -29,40 +94,133 @@ type tradeLogGeoInfoResp struct {
 	} `json:"data"`
 }
 
-const timeout = time.Minute * 5
+// retryableError is a temporary failure of broadcast API: a network error or
+// a 5xx response, the request is retried with backoff.
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
 
 // NewClient creates a new broadcast client instance.
-func NewClient(sugar *zap.SugaredLogger, host string) (*Client, error) {
-	return &Client{
-		host:   host,
-		sugar:  sugar,
-		client: &http.Client{Timeout: timeout},
-	}, nil
+func NewClient(sugar *zap.SugaredLogger, host string, options ...ClientOption) (*Client, error) {
+	c := &Client{
+		host:           host,
+		sugar:          sugar,
+		client:         &http.Client{Timeout: defaultTimeout},
+		sem:            make(chan struct{}, defaultMaxConcurrency),
+		maxRetries:     defaultMaxRetries,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff:     defaultMaxBackoff,
+		breaker:        newCircuitBreaker(defaultFailureThreshold, defaultCoolDown),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c, nil
+}
+
+// GetTxInfo get ip, country info of a tx. Temporary failures are retried with
+// exponential backoff until the given context is done. Empty ip and country
+// are returned if the transaction is not found by broadcast API. Only the 2xx
+// and not found answers are recorded by circuit breaker as success, the
+// requests cancelled by given context are not recorded.
+func (c *Client) GetTxInfo(ctx context.Context, tx string) (ip string, country string, err error) {
+	logger := c.sugar.With("func", "lib/broadcast/Client.GetTxInfo", "tx", tx)
+
+	select {
+	case c.sem <- struct{}{}:
+		defer func() { <-c.sem }()
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+
+	backoff := c.initialBackoff
+	for attempt := 0; ; attempt++ {
+		if bErr := c.breaker.allow(); bErr != nil {
+			requests.WithLabelValues("circuit_open").Inc()
+			// returns the error of last attempt if the circuit is opened while retrying
+			if err == nil {
+				err = bErr
+			}
+			return "", "", err
+		}
+
+		ip, country, err = c.getTxInfo(ctx, tx)
+		if err == nil {
+			requests.WithLabelValues("success").Inc()
+			c.breaker.record(true)
+			return ip, country, nil
+		}
+		if ctx.Err() != nil {
+			c.breaker.release()
+			return "", "", ctx.Err()
+		}
+		requests.WithLabelValues("error").Inc()
+		c.breaker.record(false)
+		if _, ok := err.(retryableError); !ok {
+			return "", "", err
+		}
+
+		if attempt >= c.maxRetries {
+			return "", "", err
+		}
+
+		logger.Warnw("failed to get tx info, retrying", "err", err, "attempt", attempt+1, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return "", "", ctx.Err()
+		}
+
+		backoff *= 2
+		if backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
 }
 
-// GetTxInfo get ip, country info of a tx
-func (c *Client) GetTxInfo(tx string) (ip string, country string, err error) {
+// getTxInfo sends a single request to broadcast API.
+func (c *Client) getTxInfo(ctx context.Context, tx string) (string, string, error) {
 	url := fmt.Sprintf("%s/get-tx-info/%s", c.host, tx)
-	resp, err := c.client.Get(url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return "", "", err
 	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", "", ctx.Err()
+		}
+		return "", "", retryableError{err: err}
+	}
 	defer func() {
 		if cErr := resp.Body.Close(); cErr != nil {
 			c.sugar.Errorw("failed to close body", "err", cErr.Error())
 		}
 	}()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return "", "", retryableError{err: fmt.Errorf("broadcast server returns status %d", resp.StatusCode)}
+	}
+
 	response := tradeLogGeoInfoResp{}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", "", err
+	decodeErr := json.NewDecoder(resp.Body).Decode(&response)
+	if decodeErr == nil && !response.Success && response.Err == errNotFoundMsg {
+		c.sugar.Debugw("transaction not found", "tx", tx, "err", response.Err)
+		return "", "", nil
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return "", "", fmt.Errorf("broadcast server returns status %d", resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return "", "", decodeErr
 	}
 	if response.Success != true {
-		if response.Err == errNotFoundMsg {
-			c.sugar.Debugw("transaction not found", "tx", tx, "err", response.Err)
-			return "", "", nil
-		}
-		c.sugar.Errorw("server returns unknown error")
-		return "", "", err
+		return "", "", fmt.Errorf("broadcast server returns unknown error: %q", response.Err)
 	}
 	return response.Data.IP, response.Data.Country, nil
 }
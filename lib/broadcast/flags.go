@@ -12,6 +12,14 @@ import (
 const (
 	geoURLFlag         = "broadcast-url"
 	geoURLDefaultValue = "https://broadcast.kyber.network"
+
+	timeoutFlag          = "broadcast-timeout"
+	maxConcurrencyFlag   = "broadcast-max-concurrency"
+	maxRetriesFlag       = "broadcast-max-retries"
+	failureThresholdFlag = "broadcast-failure-threshold"
+	coolDownFlag         = "broadcast-cool-down"
+	cacheDBFlag          = "broadcast-cache-db"
+	notFoundTTLFlag      = "broadcast-not-found-ttl"
 )
 
 // NewCliFlags returns cli flags to configure a broadcast client.
@@ -19,15 +27,57 @@ func NewCliFlags() []cli.Flag {
 	return []cli.Flag{
 		cli.StringFlag{
 			Name:   geoURLFlag,
-			Usage:  "Fetch trade logs to block",
+			Usage:  "Broadcast API URL",
 			Value:  geoURLDefaultValue,
 			EnvVar: "GEO_URL",
 		},
+		cli.DurationFlag{
+			Name:   timeoutFlag,
+			Usage:  "Timeout of a single request to broadcast API",
+			Value:  defaultTimeout,
+			EnvVar: "BROADCAST_TIMEOUT",
+		},
+		cli.IntFlag{
+			Name:   maxConcurrencyFlag,
+			Usage:  "Maximum number of concurrent requests to broadcast API",
+			Value:  defaultMaxConcurrency,
+			EnvVar: "BROADCAST_MAX_CONCURRENCY",
+		},
+		cli.IntFlag{
+			Name:   maxRetriesFlag,
+			Usage:  "Number of retries with exponential backoff on network errors and 5xx responses",
+			Value:  defaultMaxRetries,
+			EnvVar: "BROADCAST_MAX_RETRIES",
+		},
+		cli.IntFlag{
+			Name:   failureThresholdFlag,
+			Usage:  "Number of consecutive failures to stop sending requests to broadcast API, 0 to disable",
+			Value:  defaultFailureThreshold,
+			EnvVar: "BROADCAST_FAILURE_THRESHOLD",
+		},
+		cli.DurationFlag{
+			Name:   coolDownFlag,
+			Usage:  "Waiting duration before sending requests again after reaching failure threshold",
+			Value:  defaultCoolDown,
+			EnvVar: "BROADCAST_COOL_DOWN",
+		},
+		cli.StringFlag{
+			Name:   cacheDBFlag,
+			Usage:  "Path to BoltDB file to cache broadcast API results, caching is disabled if empty",
+			EnvVar: "BROADCAST_CACHE_DB",
+		},
+		cli.DurationFlag{
+			Name:   notFoundTTLFlag,
+			Usage:  "How long the transactions not found by broadcast API are cached before querying again",
+			Value:  defaultNotFoundTTL,
+			EnvVar: "BROADCAST_NOT_FOUND_TTL",
+		},
 	}
 }
 
-// NewClientFromContext returns new core client from cli flags.
-func NewClientFromContext(sugar *zap.SugaredLogger, c *cli.Context) (*Client, error) {
+// NewClientFromContext returns new broadcast client from cli flags. The
+// returned client caches results locally if cache file is configured.
+func NewClientFromContext(sugar *zap.SugaredLogger, c *cli.Context) (Interface, error) {
 	geoURL := c.String(geoURLFlag)
 	err := validation.Validate(geoURL,
 		validation.Required,
@@ -37,5 +87,19 @@ func NewClientFromContext(sugar *zap.SugaredLogger, c *cli.Context) (*Client, er
 		return nil, fmt.Errorf("invalid geo url: %q, error: %s", geoURL, err)
 	}
 
-	return NewClient(sugar, geoURL)
+	client, err := NewClient(sugar, geoURL,
+		WithTimeout(c.Duration(timeoutFlag)),
+		WithMaxConcurrency(c.Int(maxConcurrencyFlag)),
+		WithRetry(c.Int(maxRetriesFlag), defaultInitialBackoff, defaultMaxBackoff),
+		WithCircuitBreaker(c.Int(failureThresholdFlag), c.Duration(coolDownFlag)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheDB := c.String(cacheDBFlag)
+	if cacheDB == "" {
+		return client, nil
+	}
+	return NewCachedClient(sugar, client, cacheDB, WithNotFoundTTL(c.Duration(notFoundTTLFlag)))
 }
@@ -1,6 +1,8 @@
 package broadcast
 
+import "context"
+
 // Interface represents a client o interact with Geoinfo APIs.
 type Interface interface {
-	GetTxInfo(tx string) (string, string, error)
+	GetTxInfo(ctx context.Context, tx string) (string, string, error)
 }
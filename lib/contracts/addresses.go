@@ -1,4 +0,0 @@
-package contracts
-
-// InternalNetworkContractAddress is the address of KyberNetwork network smart contract.
-const InternalNetworkContractAddress = "0x91a502C678605fbCe581eae053319747482276b9"
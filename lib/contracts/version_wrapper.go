@@ -1,56 +1,55 @@
 package contracts
 
 import (
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	ethereum "github.com/ethereum/go-ethereum/common"
+
+	"github.com/KyberNetwork/reserve-stats/lib/deployment"
 )
 
-// VersionedWrapper is the wrapper for wrapper contract, since there are two version of wrapper deployed on mainnet
+// VersionedWrapper is the wrapper for wrapper contract, since there are multiple versions of wrapper
+// deployed on a network, each is used in a different block range.
 type VersionedWrapper struct {
-	WrapperContractV1 *Wrapper
-	WrapperContractV2 *Wrapper
+	registry  *deployment.Registry
+	contracts map[ethereum.Address]*Wrapper
 }
 
-var (
-	//wrapperAddrV1 is the Kyber's wrapper Address before block 5726056
-	wrapperAddrV1 = ethereum.HexToAddress("0x533e6d1ffa2b96cf9c157475c76c38d1b13bc584")
-	//wrapperAddrV2 is the Kyber's wrapper Address after block 5726056
-	wrapperAddrV2 = ethereum.HexToAddress("0x6172AFC8c00c46E0D07ce3AF203828198194620a")
-)
-
-const (
-	//startingBlockV2 is the block where wrapper contract v2 is deployed and used.
-	startingBlockV2 = 5926056
-)
-
-// NewVersionedWrapper create wrapper contract with 2 different versions inside
-func NewVersionedWrapper(client bind.ContractBackend) (*VersionedWrapper, error) {
-	wrapperContractV1, err := NewWrapper(wrapperAddrV1, client)
-	if err != nil {
-		return nil, err
+// NewVersionedWrapper create wrapper contract with all versions deployed on the network of given registry.
+func NewVersionedWrapper(client bind.ContractBackend, registry *deployment.Registry) (*VersionedWrapper, error) {
+	deployments := registry.Deployments(deployment.WrapperContract)
+	if len(deployments) == 0 {
+		return nil, fmt.Errorf("wrapper contract is not deployed on network %s", registry.Network())
 	}
-	wrapperContractV2, err := NewWrapper(wrapperAddrV2, client)
-	if err != nil {
-		return nil, err
+
+	contracts := make(map[ethereum.Address]*Wrapper)
+	for _, d := range deployments {
+		contract, err := NewWrapper(d.Address, client)
+		if err != nil {
+			return nil, err
+		}
+		contracts[d.Address] = contract
 	}
 	return &VersionedWrapper{
-		WrapperContractV1: wrapperContractV1,
-		WrapperContractV2: wrapperContractV2,
+		registry:  registry,
+		contracts: contracts,
 	}, nil
 }
 
-// GetReserveRate call to the appropriate contract depends on block number
+// GetReserveRate call to the wrapper contract that is active at given block, or
+// the one still in use if block is zero.
 // return reserveRate, SanityRate and error if occurs
 func (vw *VersionedWrapper) GetReserveRate(block uint64, rsvAddr ethereum.Address, srcs, dest []ethereum.Address) ([]*big.Int, []*big.Int, error) {
-	if block == 0 {
-		//Latest rate from latest block at V2 contract
-		return vw.WrapperContractV2.GetReserveRate(nil, rsvAddr, srcs, dest)
-	} else if block >= startingBlockV2 {
-		//V2 contract, call at specific block
-		return vw.WrapperContractV2.GetReserveRate(&bind.CallOpts{BlockNumber: big.NewInt(int64(block))}, rsvAddr, srcs, dest)
+	address, err := vw.registry.Address(deployment.WrapperContract, block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var opts *bind.CallOpts
+	if block != 0 {
+		opts = &bind.CallOpts{BlockNumber: big.NewInt(int64(block))}
 	}
-	//default case: V1 contract.
-	return vw.WrapperContractV1.GetReserveRate(&bind.CallOpts{BlockNumber: big.NewInt(int64(block))}, rsvAddr, srcs, dest)
+	return vw.contracts[address].GetReserveRate(opts, rsvAddr, srcs, dest)
 }
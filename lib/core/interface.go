@@ -14,6 +14,15 @@ type Interface interface {
 	ToWei(common.Address, float64) (*big.Int, error)
 }
 
+// TokenNotFoundError is returned by LookupToken when no token has given ID.
+type TokenNotFoundError struct {
+	ID string
+}
+
+func (e TokenNotFoundError) Error() string {
+	return fmt.Sprintf("cannot find token %s", e.ID)
+}
+
 // LookupToken returns the token with given id from results of Tokens of given core client.
 func LookupToken(client Interface, ID string) (Token, error) {
 	tokens, err := client.Tokens()
@@ -25,5 +34,5 @@ func LookupToken(client Interface, ID string) (Token, error) {
 			return token, nil
 		}
 	}
-	return Token{}, fmt.Errorf("cannot find token %s", ID)
+	return Token{}, TokenNotFoundError{ID: ID}
 }
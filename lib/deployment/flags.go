@@ -0,0 +1,45 @@
+package deployment
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+)
+
+const (
+	networkFlag          = "network"
+	networkDefaultValue  = string(Mainnet)
+	deploymentConfigFlag = "deployment-config"
+)
+
+// NewCliFlags returns cli flags to configure the contracts deployments.
+func NewCliFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   networkFlag,
+			Usage:  "Ethereum network to crawl: mainnet, ropsten, kovan or dev",
+			Value:  networkDefaultValue,
+			EnvVar: "NETWORK",
+		},
+		cli.StringFlag{
+			Name:   deploymentConfigFlag,
+			Usage:  "Path to JSON file of contracts deployments per network, built-in deployments are used if not provided",
+			EnvVar: "DEPLOYMENT_CONFIG",
+		},
+	}
+}
+
+// NewRegistryFromContext returns new Registry from cli flags.
+func NewRegistryFromContext(c *cli.Context) (*Registry, error) {
+	network := Network(c.String(networkFlag))
+	switch network {
+	case Mainnet, Ropsten, Kovan, Dev:
+	default:
+		return nil, fmt.Errorf("unsupported network: %q", network)
+	}
+
+	if path := c.String(deploymentConfigFlag); path != "" {
+		return LoadRegistry(path, network)
+	}
+	return NewDefaultRegistry(network)
+}
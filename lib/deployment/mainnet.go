@@ -0,0 +1,58 @@
+package deployment
+
+import (
+	"fmt"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// mainnetConfig is the deployments of KyberNetwork contracts on mainnet.
+// It is used when no deployments configuration file is given.
+var mainnetConfig = Config{
+	Contracts: map[Contract][]Deployment{
+		PricingContract: {
+			{Address: ethereum.HexToAddress("0x798AbDA6Cc246D0EDbA912092A2a3dBd3d11191B")},
+		},
+		NetworkContract: {
+			{Address: ethereum.HexToAddress("0x818E6FECD516Ecc3849DAf6845e3EC868087B755")},
+		},
+		BurnerContract: {
+			{Address: ethereum.HexToAddress("0xed4f53268bfdFF39B36E8786247bA3A02Cf34B04")},
+		},
+		InternalNetworkContract: {
+			{Address: ethereum.HexToAddress("0x91a502C678605fbCe581eae053319747482276b9")},
+		},
+		InternalReserveContract: {
+			{Address: ethereum.HexToAddress("0x63825c174ab367968EC60f061753D3bbD36A0D8F")},
+		},
+		WrapperContract: {
+			{
+				Address:  ethereum.HexToAddress("0x533e6d1ffa2b96cf9c157475c76c38d1b13bc584"),
+				EndBlock: 5926055,
+			},
+			{
+				Address:    ethereum.HexToAddress("0x6172AFC8c00c46E0D07ce3AF203828198194620a"),
+				StartBlock: 5926056,
+			},
+		},
+	},
+	Tokens: map[string]ethereum.Address{
+		"KNC":  ethereum.HexToAddress("0xdd974D5C2e2928deA5F71b9825b8b646686BD200"),
+		"WETH": ethereum.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+		"KCC":  ethereum.HexToAddress("0x09677D0175DEC51E2215426Cddd055a71bf4228d"),
+	},
+}
+
+// defaultConfigs is the built-in deployments of supported networks.
+var defaultConfigs = map[Network]Config{
+	Mainnet: mainnetConfig,
+}
+
+// NewDefaultRegistry creates a new Registry from built-in deployments of given network.
+func NewDefaultRegistry(network Network) (*Registry, error) {
+	config, ok := defaultConfigs[network]
+	if !ok {
+		return nil, fmt.Errorf("no built-in deployments for network %s, a deployments config file is required", network)
+	}
+	return NewRegistry(network, config)
+}
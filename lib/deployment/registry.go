@@ -0,0 +1,213 @@
+package deployment
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+)
+
+// Network is the name of an Ethereum network KyberNetwork contracts are deployed to.
+type Network string
+
+const (
+	// Mainnet is the Ethereum main network.
+	Mainnet Network = "mainnet"
+	// Ropsten is the Ropsten test network.
+	Ropsten Network = "ropsten"
+	// Kovan is the Kovan test network.
+	Kovan Network = "kovan"
+	// Dev is a local development chain.
+	Dev Network = "dev"
+)
+
+// Contract is the name of a KyberNetwork smart contract.
+type Contract string
+
+const (
+	// PricingContract is the conversion rates contract of internal reserve.
+	PricingContract Contract = "pricing"
+	// NetworkContract is the KyberNetwork proxy contract.
+	NetworkContract Contract = "network"
+	// BurnerContract is the fee burner contract.
+	BurnerContract Contract = "burner"
+	// InternalNetworkContract is the KyberNetwork internal network contract.
+	InternalNetworkContract Contract = "internal_network"
+	// InternalReserveContract is the KyberNetwork's own reserve contract.
+	InternalReserveContract Contract = "internal_reserve"
+	// WrapperContract is the wrapper contract to query reserve rates.
+	WrapperContract Contract = "wrapper"
+)
+
+// Deployment is a deployment of a contract, that is used from StartBlock to EndBlock, inclusive.
+// EndBlock is zero if the deployment is still in use.
+type Deployment struct {
+	Address    ethereum.Address `json:"address"`
+	StartBlock uint64           `json:"start_block"`
+	EndBlock   uint64           `json:"end_block,omitempty"`
+}
+
+// isActive returns true if the deployment is used at given block.
+func (d Deployment) isActive(block uint64) bool {
+	return d.StartBlock <= block && (d.EndBlock == 0 || block <= d.EndBlock)
+}
+
+// overlaps returns true if the deployment is used at any block in given range.
+func (d Deployment) overlaps(fromBlock, toBlock uint64) bool {
+	return d.StartBlock <= toBlock && (d.EndBlock == 0 || fromBlock <= d.EndBlock)
+}
+
+// Config is the deployments of KyberNetwork contracts and tokens on a network.
+type Config struct {
+	Contracts map[Contract][]Deployment `json:"contracts"`
+	// Tokens is the mapping of token symbol to its address.
+	Tokens map[string]ethereum.Address `json:"tokens"`
+}
+
+// Registry resolves the address of KyberNetwork contracts of a network at any block height.
+type Registry struct {
+	network Network
+	config  Config
+}
+
+// NewRegistry creates a new Registry instance from given deployments configuration.
+func NewRegistry(network Network, config Config) (*Registry, error) {
+	for contract, deployments := range config.Contracts {
+		if len(deployments) == 0 {
+			return nil, fmt.Errorf("no deployment of contract %s on network %s", contract, network)
+		}
+		sort.Slice(deployments, func(i, j int) bool {
+			return deployments[i].StartBlock < deployments[j].StartBlock
+		})
+		for _, d := range deployments {
+			if d.EndBlock != 0 && d.EndBlock < d.StartBlock {
+				return nil, fmt.Errorf("invalid deployment of contract %s at %s: end block %d before start block %d",
+					contract, d.Address.Hex(), d.EndBlock, d.StartBlock)
+			}
+		}
+	}
+	return &Registry{network: network, config: config}, nil
+}
+
+// LoadRegistry reads the deployments configuration file at given path and
+// creates a new Registry for given network.
+// The configuration file is a JSON object of network name to Config.
+func LoadRegistry(path string, network Network) (*Registry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var configs map[Network]Config
+	if err = json.NewDecoder(f).Decode(&configs); err != nil {
+		return nil, fmt.Errorf("invalid deployments config %s: %s", path, err)
+	}
+
+	config, ok := configs[network]
+	if !ok {
+		return nil, fmt.Errorf("network %s is not configured in %s", network, path)
+	}
+	return NewRegistry(network, config)
+}
+
+// Network returns the network of registry.
+func (r *Registry) Network() Network {
+	return r.network
+}
+
+// Deployments returns all deployments of given contract, sorted by start block.
+func (r *Registry) Deployments(contract Contract) []Deployment {
+	return r.config.Contracts[contract]
+}
+
+// Address returns the address of given contract that is used at given block.
+// If block is zero, the address of the deployment still in use is returned.
+func (r *Registry) Address(contract Contract, block uint64) (ethereum.Address, error) {
+	deployments := r.config.Contracts[contract]
+	if len(deployments) == 0 {
+		return ethereum.Address{}, fmt.Errorf("contract %s is not deployed on network %s", contract, r.network)
+	}
+
+	if block == 0 {
+		latest := deployments[len(deployments)-1]
+		if latest.EndBlock != 0 {
+			return ethereum.Address{}, fmt.Errorf("contract %s on network %s is retired at block %d",
+				contract, r.network, latest.EndBlock)
+		}
+		return latest.Address, nil
+	}
+
+	for i := len(deployments) - 1; i >= 0; i-- {
+		if deployments[i].isActive(block) {
+			return deployments[i].Address, nil
+		}
+	}
+	return ethereum.Address{}, fmt.Errorf("contract %s is not deployed on network %s at block %d", contract, r.network, block)
+}
+
+// Addresses returns the addresses of given contract that are used at any block from
+// fromBlock to toBlock, inclusive. A returned contract may be used in part of
+// the range only, IsActive tells whether it is used at a given block.
+func (r *Registry) Addresses(contract Contract, fromBlock, toBlock uint64) []ethereum.Address {
+	var addresses []ethereum.Address
+	for _, d := range r.config.Contracts[contract] {
+		if d.overlaps(fromBlock, toBlock) {
+			addresses = append(addresses, d.Address)
+		}
+	}
+	return addresses
+}
+
+// IsActive returns true if given address is a deployment of given contract that
+// is used at given block.
+func (r *Registry) IsActive(contract Contract, address ethereum.Address, block uint64) bool {
+	for _, d := range r.config.Contracts[contract] {
+		if d.Address == address && d.isActive(block) {
+			return true
+		}
+	}
+	return false
+}
+
+// Token returns the address of token with given symbol.
+func (r *Registry) Token(symbol string) (ethereum.Address, error) {
+	address, ok := r.config.Tokens[symbol]
+	if !ok {
+		return ethereum.Address{}, fmt.Errorf("token %s is not deployed on network %s", symbol, r.network)
+	}
+	return address, nil
+}
+
+// TokenAddresses returns the addresses of the tokens that trade logs handle
+// specially on the network of registry. On mainnet, the tokens that are not
+// configured default to their mainnet addresses. On other networks, all of the
+// tokens must be configured.
+func (r *Registry) TokenAddresses() (blockchain.TokenAddresses, error) {
+	var tokens blockchain.TokenAddresses
+	if r.network == Mainnet {
+		tokens = blockchain.MainnetTokenAddresses()
+	}
+	for _, token := range []struct {
+		symbol  string
+		address *ethereum.Address
+	}{
+		{symbol: "KNC", address: &tokens.KNC},
+		{symbol: "WETH", address: &tokens.WETH},
+		{symbol: "KCC", address: &tokens.KCC},
+	} {
+		configured, ok := r.config.Tokens[token.symbol]
+		if !ok {
+			if r.network != Mainnet {
+				return blockchain.TokenAddresses{}, fmt.Errorf("token %s is not configured on network %s", token.symbol, r.network)
+			}
+			continue
+		}
+		*token.address = configured
+	}
+	return tokens, nil
+}
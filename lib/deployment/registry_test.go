@@ -0,0 +1,105 @@
+package deployment
+
+import (
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+)
+
+func TestDefaultRegistry(t *testing.T) {
+	registry, err := NewDefaultRegistry(Mainnet)
+	assert.NoError(t, err)
+
+	var (
+		wrapperV1 = ethereum.HexToAddress("0x533e6d1ffa2b96cf9c157475c76c38d1b13bc584")
+		wrapperV2 = ethereum.HexToAddress("0x6172AFC8c00c46E0D07ce3AF203828198194620a")
+	)
+
+	address, err := registry.Address(WrapperContract, 5926055)
+	assert.NoError(t, err)
+	assert.Equal(t, wrapperV1, address)
+
+	address, err = registry.Address(WrapperContract, 5926056)
+	assert.NoError(t, err)
+	assert.Equal(t, wrapperV2, address)
+
+	address, err = registry.Address(WrapperContract, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, wrapperV2, address)
+
+	assert.Equal(t, []ethereum.Address{wrapperV1, wrapperV2}, registry.Addresses(WrapperContract, 5926000, 5927000))
+	assert.Equal(t, []ethereum.Address{wrapperV2}, registry.Addresses(WrapperContract, 6000000, 6000100))
+
+	assert.True(t, registry.IsActive(WrapperContract, wrapperV1, 5926055))
+	assert.False(t, registry.IsActive(WrapperContract, wrapperV1, 5926056))
+	assert.True(t, registry.IsActive(WrapperContract, wrapperV2, 5926056))
+
+	tokens, err := registry.TokenAddresses()
+	assert.NoError(t, err)
+	assert.Equal(t, blockchain.MainnetTokenAddresses(), tokens)
+
+	_, err = NewDefaultRegistry(Kovan)
+	assert.Error(t, err)
+}
+
+func TestLoadRegistry(t *testing.T) {
+	registry, err := LoadRegistry("testdata/deployments.json", Ropsten)
+	assert.NoError(t, err)
+	assert.Equal(t, Ropsten, registry.Network())
+
+	_, err = registry.Address(NetworkContract, 99)
+	assert.Error(t, err, "contract is not deployed before start block")
+
+	address, err := registry.Address(NetworkContract, 150)
+	assert.NoError(t, err)
+	assert.Equal(t, ethereum.HexToAddress("0x91a502c678605fbce581eae053319747482276b9"), address)
+
+	address, err = registry.Address(NetworkContract, 250)
+	assert.NoError(t, err)
+	assert.Equal(t, ethereum.HexToAddress("0x818e6fecd516ecc3849daf6845e3ec868087b755"), address)
+
+	assert.Len(t, registry.Addresses(NetworkContract, 0, 99), 0)
+	assert.Len(t, registry.Addresses(PricingContract, 0, 1000), 0)
+
+	_, err = registry.Address(PricingContract, 150)
+	assert.Error(t, err)
+
+	token, err := registry.Token("KNC")
+	assert.NoError(t, err)
+	assert.Equal(t, ethereum.HexToAddress("0x4e470dc7321e84ca96fcaedd0c8abcebbaeb68c6"), token)
+
+	// the tokens are not defaulted to mainnet on other networks
+	_, err = registry.TokenAddresses()
+	assert.Error(t, err)
+
+	_, err = LoadRegistry("testdata/deployments.json", Kovan)
+	assert.Error(t, err)
+}
+
+func TestRegistryTokenAddresses(t *testing.T) {
+	var (
+		knc  = ethereum.HexToAddress("0x4e470dc7321e84ca96fcaedd0c8abcebbaeb68c6")
+		weth = ethereum.HexToAddress("0xbca556c912754bc8e7d4aad20ad69a1b1444f42d")
+		kcc  = ethereum.HexToAddress("0x0000000000000000000000000000000000000001")
+	)
+
+	registry, err := NewRegistry(Ropsten, Config{
+		Tokens: map[string]ethereum.Address{"KNC": knc, "WETH": weth, "KCC": kcc},
+	})
+	assert.NoError(t, err)
+	tokens, err := registry.TokenAddresses()
+	assert.NoError(t, err)
+	assert.Equal(t, blockchain.TokenAddresses{KNC: knc, WETH: weth, KCC: kcc}, tokens)
+
+	// the tokens that are not configured default to mainnet on mainnet only
+	registry, err = NewRegistry(Mainnet, Config{
+		Tokens: map[string]ethereum.Address{"KNC": knc},
+	})
+	assert.NoError(t, err)
+	tokens, err = registry.TokenAddresses()
+	assert.NoError(t, err)
+	assert.Equal(t, blockchain.TokenAddresses{KNC: knc, WETH: blockchain.WETHAddr, KCC: blockchain.KCCAddr}, tokens)
+}
@@ -66,5 +66,5 @@ func NewClientFromContext(c *cli.Context) (client.Client, error) {
 		return nil, err
 	}
 
-	return influxClient, nil
+	return newInstrumentedClient(influxClient), nil
 }
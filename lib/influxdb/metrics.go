@@ -0,0 +1,46 @@
+package influxdb
+
+import (
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	writeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "influxdb_write_duration_seconds",
+		Help:    "Latency of InfluxDB batch writes by database.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"database"})
+	writeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "influxdb_write_errors_total",
+		Help: "Number of failed InfluxDB batch writes by database.",
+	}, []string{"database"})
+)
+
+func init() {
+	prometheus.MustRegister(writeDuration, writeErrors)
+}
+
+// instrumentedClient is an InfluxDB client that records the latency and
+// errors of batch writes.
+type instrumentedClient struct {
+	client.Client
+}
+
+// newInstrumentedClient wraps given client to record metrics of writes.
+func newInstrumentedClient(c client.Client) client.Client {
+	return &instrumentedClient{Client: c}
+}
+
+// Write writes given batch points and records the write latency.
+func (ic *instrumentedClient) Write(bp client.BatchPoints) error {
+	start := time.Now()
+	err := ic.Client.Write(bp)
+	writeDuration.WithLabelValues(bp.Database()).Observe(time.Since(start).Seconds())
+	if err != nil {
+		writeErrors.WithLabelValues(bp.Database()).Inc()
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package influxdb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+	"go.uber.org/zap"
+)
+
+// DefaultRetentionPolicy is the retention policy created with a database,
+// which keeps data forever unless altered.
+const DefaultRetentionPolicy = "autogen"
+
+// RetentionPolicy is an InfluxDB retention policy of a database.
+type RetentionPolicy struct {
+	Name string
+	// Duration is how long data is kept, 0 to keep data forever.
+	Duration time.Duration
+	// Default is true if the points written without retention policy are
+	// written to this retention policy.
+	Default bool
+}
+
+// FormatDuration formats given duration as InfluxQL duration literal, 0 is
+// formatted as INF.
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d == 0:
+		return "INF"
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+}
+
+// shardGroupDuration returns the shard group duration that InfluxDB chooses
+// by default for given retention duration. It is given explicitly when a
+// retention policy is altered, as the shard group duration must not be longer
+// than the retention duration.
+func shardGroupDuration(d time.Duration) time.Duration {
+	switch {
+	case d == 0 || d > 180*24*time.Hour:
+		return 7 * 24 * time.Hour
+	case d < 2*24*time.Hour:
+		return time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
+
+func query(c client.Client, dbName, cmd string) ([]client.Result, error) {
+	response, err := c.Query(client.NewQuery(cmd, dbName, ""))
+	if err != nil {
+		return nil, err
+	}
+	if response.Error() != nil {
+		return nil, response.Error()
+	}
+	return response.Results, nil
+}
+
+// EnsureRetentionPolicy creates given retention policy on database, or alters
+// the existing retention policy of the same name if its duration or default
+// flag is different. The data out of the new duration is removed by InfluxDB
+// at the next retention check.
+func EnsureRetentionPolicy(sugar *zap.SugaredLogger, c client.Client, dbName string, rp RetentionPolicy) error {
+	logger := sugar.With(
+		"func", "lib/influxdb/EnsureRetentionPolicy",
+		"db", dbName,
+		"retention_policy", rp.Name,
+		"duration", FormatDuration(rp.Duration),
+	)
+
+	res, err := query(c, dbName, fmt.Sprintf(`SHOW RETENTION POLICIES ON "%s"`, dbName))
+	if err != nil {
+		return err
+	}
+
+	var exists, upToDate bool
+	for _, result := range res {
+		for _, series := range result.Series {
+			idxs := make(map[string]int)
+			for i, column := range series.Columns {
+				idxs[column] = i
+			}
+			for _, row := range series.Values {
+				if name, _ := row[idxs["name"]].(string); name != rp.Name {
+					continue
+				}
+				exists = true
+
+				durationStr, _ := row[idxs["duration"]].(string)
+				duration, pErr := time.ParseDuration(durationStr)
+				if pErr != nil {
+					return fmt.Errorf("invalid duration of retention policy %s: %v", rp.Name, row[idxs["duration"]])
+				}
+				isDefault, _ := row[idxs["default"]].(bool)
+				upToDate = duration == rp.Duration && (isDefault || !rp.Default)
+			}
+		}
+	}
+	if upToDate {
+		return nil
+	}
+
+	var stmt string
+	if exists {
+		stmt = fmt.Sprintf(`ALTER RETENTION POLICY "%s" ON "%s" DURATION %s`,
+			rp.Name, dbName, FormatDuration(rp.Duration))
+	} else {
+		stmt = fmt.Sprintf(`CREATE RETENTION POLICY "%s" ON "%s" DURATION %s REPLICATION 1`,
+			rp.Name, dbName, FormatDuration(rp.Duration))
+	}
+	stmt += " SHARD DURATION " + FormatDuration(shardGroupDuration(rp.Duration))
+	if rp.Default {
+		stmt += " DEFAULT"
+	}
+
+	logger.Infow("updating retention policy", "statement", stmt)
+	_, err = query(c, dbName, stmt)
+	return err
+}
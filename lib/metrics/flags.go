@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+const metricsAddressFlag = "metrics-listen"
+
+// NewCliFlags returns cli flags to configure the metrics server of
+// processes that do not serve HTTP, like crawler daemons.
+func NewCliFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   metricsAddressFlag,
+			Usage:  "Address to serve metrics endpoint, example: 127.0.0.1:9100. Metrics are not served if empty",
+			EnvVar: "METRICS_LISTEN",
+		},
+	}
+}
+
+// ServeFromContext serves the metrics endpoint in background on the address
+// configured by cli flags. It does nothing if no address is configured.
+func ServeFromContext(c *cli.Context, sugar *zap.SugaredLogger) {
+	address := c.String(metricsAddressFlag)
+	if address == "" {
+		return
+	}
+
+	logger := sugar.With(
+		"func", "lib/metrics/ServeFromContext",
+		"address", address,
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	go func() {
+		logger.Info("serving metrics")
+		if err := http.ListenAndServe(address, mux); err != nil {
+			logger.Errorw("metrics server stopped", "err", err)
+		}
+	}()
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute is the route label of requests that match no route.
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests served by route, method and status code.",
+	}, []string{"route", "method", "code"})
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of HTTP requests served by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+)
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpRequestDuration)
+}
+
+// routeResolver resolves the route path of a request from the name of its
+// handler, as gin context does not expose the matched route path.
+type routeResolver struct {
+	engine *gin.Engine
+	once   sync.Once
+	routes map[string]string
+}
+
+func (rr *routeResolver) route(method, handler string) string {
+	// the routes are registered after the middleware, they are read at
+	// first request
+	rr.once.Do(func() {
+		rr.routes = make(map[string]string)
+		for _, route := range rr.engine.Routes() {
+			rr.routes[route.Method+" "+route.Handler] = route.Path
+		}
+	})
+
+	if path, ok := rr.routes[method+" "+handler]; ok {
+		return path
+	}
+	return unmatchedRoute
+}
+
+// Instrument records the count and latency of requests served by given gin
+// engine, and registers the metrics endpoint to it. It must be called before
+// the routes are registered, as gin middlewares only apply to routes
+// registered after them.
+func Instrument(r *gin.Engine) {
+	resolver := &routeResolver{engine: r}
+	r.Use(func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := resolver.route(c.Request.Method, c.HandlerName())
+		httpRequests.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+		httpRequestDuration.WithLabelValues(route, c.Request.Method).Observe(time.Since(start).Seconds())
+	})
+	r.GET(Path, gin.WrapH(Handler()))
+}
@@ -0,0 +1,17 @@
+// Package metrics serves the operational metrics registered to the default
+// Prometheus registry.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Path is the path of metrics endpoint.
+const Path = "/metrics"
+
+// Handler returns the http handler serving metrics of default registry.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	Instrument(r)
+	r.GET("/test-route/:id", func(c *gin.Context) { c.String(http.StatusTeapot, c.Param("id")) })
+
+	for _, path := range []string{"/test-route/1", "/test-route/2", "/not-found"} {
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, Path, nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	body := resp.Body.String()
+	assert.True(t, strings.Contains(body, `http_requests_total{code="418",method="GET",route="/test-route/:id"} 2`), body)
+	assert.True(t, strings.Contains(body, `http_requests_total{code="404",method="GET",route="unmatched"} 1`), body)
+	assert.True(t, strings.Contains(body, `http_request_duration_seconds_count{method="GET",route="/test-route/:id"} 2`), body)
+}
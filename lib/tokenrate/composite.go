@@ -0,0 +1,154 @@
+package tokenrate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/tokenrate"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// DefaultMaxDeviation is the default maximum relative deviation from median
+// rate for a provider rate not to be considered as outlier.
+const DefaultMaxDeviation = 0.05
+
+var providerRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "eth_usd_rate_provider_requests_total",
+	Help: "Number of requests to ETH/USD rate providers, like CoinGecko, by provider and result.",
+}, []string{"provider", "result"})
+
+func init() {
+	prometheus.MustRegister(providerRequests)
+}
+
+// providerSetRateProvider is the rate provider that returns the names of
+// providers the rate is actually aggregated from.
+type providerSetRateProvider interface {
+	USDRateWithProviders(timestamp time.Time) (float64, []string, error)
+}
+
+// CompositeProvider is an ETH/USD rate provider that queries multiple
+// providers concurrently, drops the outliers and returns the median rate.
+// Failed providers are ignored, an error is returned only if all fail.
+type CompositeProvider struct {
+	sugar        *zap.SugaredLogger
+	providers    []tokenrate.ETHUSDRateProvider
+	maxDeviation float64
+}
+
+// NewCompositeProvider creates a new CompositeProvider instance.
+func NewCompositeProvider(sugar *zap.SugaredLogger, maxDeviation float64, providers ...tokenrate.ETHUSDRateProvider) (*CompositeProvider, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("no rate provider configured")
+	}
+	return &CompositeProvider{
+		sugar:        sugar,
+		providers:    providers,
+		maxDeviation: maxDeviation,
+	}, nil
+}
+
+type providerRate struct {
+	name string
+	rate float64
+}
+
+// median returns median rate of given rates sorted by rate.
+func median(rates []providerRate) float64 {
+	mid := len(rates) / 2
+	if len(rates)%2 == 1 {
+		return rates[mid].rate
+	}
+	return (rates[mid-1].rate + rates[mid].rate) / 2
+}
+
+// USDRate returns the median ETH/USD rate of configured providers.
+func (cp *CompositeProvider) USDRate(timestamp time.Time) (float64, error) {
+	rate, _, err := cp.USDRateWithProviders(timestamp)
+	return rate, err
+}
+
+// USDRateWithProviders returns the median ETH/USD rate of configured providers
+// and the names of providers that are not failed or dropped as outliers.
+func (cp *CompositeProvider) USDRateWithProviders(timestamp time.Time) (float64, []string, error) {
+	logger := cp.sugar.With(
+		"func", "lib/tokenrate/CompositeProvider.USDRateWithProviders",
+		"timestamp", timestamp.String(),
+	)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		rates []providerRate
+		errs  []string
+	)
+	for _, provider := range cp.providers {
+		wg.Add(1)
+		go func(provider tokenrate.ETHUSDRateProvider) {
+			defer wg.Done()
+			rate, err := provider.USDRate(timestamp)
+			if err == nil && rate <= 0 {
+				err = fmt.Errorf("invalid rate %f", rate)
+			}
+			if err != nil {
+				providerRequests.WithLabelValues(provider.Name(), "error").Inc()
+			} else {
+				providerRequests.WithLabelValues(provider.Name(), "success").Inc()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logger.Warnw("rate provider failed, falling back to other providers",
+					"provider", provider.Name(), "err", err)
+				errs = append(errs, fmt.Sprintf("%s: %s", provider.Name(), err))
+				return
+			}
+			rates = append(rates, providerRate{name: provider.Name(), rate: rate})
+		}(provider)
+	}
+	wg.Wait()
+
+	if len(rates) == 0 {
+		return 0, nil, fmt.Errorf("all rate providers failed: %s", strings.Join(errs, ", "))
+	}
+
+	sort.Slice(rates, func(i, j int) bool { return rates[i].rate < rates[j].rate })
+	med := median(rates)
+
+	// outliers can only be determined if there are at least 3 rates
+	if len(rates) >= 3 {
+		var inliers []providerRate
+		for _, r := range rates {
+			if math.Abs(r.rate-med)/med <= cp.maxDeviation {
+				inliers = append(inliers, r)
+				continue
+			}
+			logger.Warnw("dropped outlier rate", "provider", r.name, "rate", r.rate, "median", med)
+		}
+		rates = inliers
+		med = median(rates)
+	}
+
+	var names []string
+	for _, r := range rates {
+		names = append(names, r.name)
+	}
+	sort.Strings(names)
+	return med, names, nil
+}
+
+// Name returns the names of configured providers.
+func (cp *CompositeProvider) Name() string {
+	var names []string
+	for _, provider := range cp.providers {
+		names = append(names, provider.Name())
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,97 @@
+package tokenrate
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/tokenrate"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fixedProvider struct {
+	name string
+	rate float64
+	err  error
+}
+
+func (fp *fixedProvider) USDRate(_ time.Time) (float64, error) {
+	return fp.rate, fp.err
+}
+
+func (fp *fixedProvider) Name() string {
+	return fp.name
+}
+
+func TestCompositeProvider(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	var tests = []struct {
+		name      string
+		providers []tokenrate.ETHUSDRateProvider
+		rate      float64
+		chosen    []string
+		err       bool
+	}{
+		{
+			name: "median of providers",
+			providers: []tokenrate.ETHUSDRateProvider{
+				&fixedProvider{name: "a", rate: 200},
+				&fixedProvider{name: "b", rate: 202},
+				&fixedProvider{name: "c", rate: 201},
+			},
+			rate:   201,
+			chosen: []string{"a", "b", "c"},
+		},
+		{
+			name: "outlier dropped",
+			providers: []tokenrate.ETHUSDRateProvider{
+				&fixedProvider{name: "a", rate: 200},
+				&fixedProvider{name: "b", rate: 202},
+				&fixedProvider{name: "c", rate: 201},
+				&fixedProvider{name: "d", rate: 500},
+			},
+			rate:   201,
+			chosen: []string{"a", "b", "c"},
+		},
+		{
+			name: "fallback on failure",
+			providers: []tokenrate.ETHUSDRateProvider{
+				&fixedProvider{name: "a", err: errors.New("unavailable")},
+				&fixedProvider{name: "b", rate: 0},
+				&fixedProvider{name: "c", rate: 201},
+			},
+			rate:   201,
+			chosen: []string{"c"},
+		},
+		{
+			name: "all failed",
+			providers: []tokenrate.ETHUSDRateProvider{
+				&fixedProvider{name: "a", err: errors.New("unavailable")},
+			},
+			err: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cp, err := NewCompositeProvider(sugar, DefaultMaxDeviation, tc.providers...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rate, chosen, err := cp.USDRateWithProviders(time.Now())
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.rate, rate)
+			assert.Equal(t, tc.chosen, chosen)
+		})
+	}
+}
@@ -3,19 +3,29 @@ package tokenrate
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/KyberNetwork/reserve-stats/lib/influxdb"
 	"github.com/KyberNetwork/tokenrate"
 	"github.com/influxdata/influxdb/client/v2"
 	"go.uber.org/zap"
 )
 
+// rateBucket is the time bucket that trades share the same ETH/USD rate.
+const rateBucket = time.Hour
+
 //ETHUSDRateFetcher represent fetcher for ETH-USD rate
 type ETHUSDRateFetcher struct {
 	sugar        *zap.SugaredLogger
 	dbName       string
 	influxClient client.Client
 	rateProvider tokenrate.ETHUSDRateProvider
+
+	mu sync.RWMutex
+	// cached is the in-process memo of rates by the start time of bucket.
+	cached map[time.Time]ETHUSDRate
 }
 
 //NewETHUSDRateFetcher return new instance of ETHUSDFetcher
@@ -26,6 +36,7 @@ func NewETHUSDRateFetcher(sugar *zap.SugaredLogger, dbName string, client client
 		dbName:       dbName,
 		influxClient: client,
 		rateProvider: rateProvider,
+		cached:       make(map[time.Time]ETHUSDRate),
 	}
 
 	if err := fetcher.createDB(); err != nil {
@@ -34,37 +45,114 @@ func NewETHUSDRateFetcher(sugar *zap.SugaredLogger, dbName string, client client
 	return fetcher, nil
 }
 
-//FetchRates fetch ETH-USD rate and save to db
+// FetchRates returns the ETH-USD rate at given time. The rate is looked up in
+// in-process cache, then in stored rates of the same time bucket. The rate
+// provider is only called if none found, and the result is saved to db.
 func (ef *ETHUSDRateFetcher) FetchRates(blockNumber uint64, timestamp time.Time) (ETHUSDRate, error) {
 	var (
-		ethRate float64
-		rate    ETHUSDRate
-		err     error
+		logger = ef.sugar.With(
+			"func", "lib/tokenrate/ETHUSDRateFetcher.FetchRates",
+			"block_number", blockNumber,
+			"timestamp", timestamp.String(),
+		)
+		bucket = timestamp.UTC().Truncate(rateBucket)
+		rate   = ETHUSDRate{Timestamp: timestamp, BlockNumber: blockNumber}
 	)
-	if ethRate, err = ef.rateProvider.USDRate(timestamp); err != nil {
-		ef.sugar.Errorw("failed to get ETH/USD rate", "timestamp", timestamp.String())
+
+	ef.mu.RLock()
+	cached, ok := ef.cached[bucket]
+	ef.mu.RUnlock()
+	if ok {
+		logger.Debugw("cache hit", "rate", cached.Rate)
+		rate.Rate = cached.Rate
+		rate.Provider = cached.Provider
+		return rate, nil
+	}
+
+	stored, ok, err := ef.lookupRate(bucket)
+	if err != nil {
 		return rate, err
 	}
-	if ethRate != 0 {
-		ef.sugar.Debugw("got ETH/USD rate",
-			"rate", ethRate,
-			"timestamp", timestamp.String())
-
-		// Save rates to db
-		rate = ETHUSDRate{
-			Timestamp:   timestamp,
-			Rate:        ethRate,
-			Provider:    ef.rateProvider.Name(),
-			BlockNumber: blockNumber,
-		}
+	if ok {
+		logger.Debugw("found stored rate", "rate", stored.Rate, "provider", stored.Provider)
+		ef.cache(bucket, stored)
+		rate.Rate = stored.Rate
+		rate.Provider = stored.Provider
+		return rate, nil
+	}
 
-		if err := ef.SaveTokenRate(rate); err != nil {
-			return rate, err
-		}
+	ethRate, provider, err := ef.usdRate(timestamp)
+	if err != nil {
+		logger.Errorw("failed to get ETH/USD rate", "err", err)
+		return rate, err
+	}
+	if ethRate == 0 {
+		return rate, nil
 	}
+	logger.Debugw("got ETH/USD rate", "rate", ethRate, "provider", provider)
+
+	rate.Rate = ethRate
+	rate.Provider = provider
+	if err = ef.SaveTokenRate(rate); err != nil {
+		return rate, err
+	}
+	ef.cache(bucket, rate)
 	return rate, nil
 }
 
+// usdRate calls the rate provider, returning the rate and the provider name.
+// If the rate is aggregated from multiple providers, the name is the comma
+// separated list of providers the rate is actually from.
+func (ef *ETHUSDRateFetcher) usdRate(timestamp time.Time) (float64, string, error) {
+	if sp, ok := ef.rateProvider.(providerSetRateProvider); ok {
+		rate, providers, err := sp.USDRateWithProviders(timestamp)
+		return rate, strings.Join(providers, ","), err
+	}
+	rate, err := ef.rateProvider.USDRate(timestamp)
+	return rate, ef.rateProvider.Name(), err
+}
+
+func (ef *ETHUSDRateFetcher) cache(bucket time.Time, rate ETHUSDRate) {
+	ef.mu.Lock()
+	defer ef.mu.Unlock()
+	ef.cached[bucket] = rate
+}
+
+// lookupRate returns the first stored rate of the time bucket starting at given time.
+func (ef *ETHUSDRateFetcher) lookupRate(bucket time.Time) (ETHUSDRate, bool, error) {
+	var rate ETHUSDRate
+
+	q := fmt.Sprintf(`SELECT rate, provider FROM token_rate WHERE time >= '%s' AND time < '%s' LIMIT 1`,
+		bucket.Format(time.RFC3339),
+		bucket.Add(rateBucket).Format(time.RFC3339),
+	)
+	res, err := ef.queryDB(ef.influxClient, q)
+	if err != nil {
+		return rate, false, err
+	}
+	if len(res) == 0 || len(res[0].Series) == 0 || len(res[0].Series[0].Values) == 0 {
+		return rate, false, nil
+	}
+
+	// columns: time, rate, provider
+	row := res[0].Series[0].Values[0]
+	if len(row) != 3 {
+		return rate, false, fmt.Errorf("invalid token rate row %v", row)
+	}
+	if rate.Timestamp, err = influxdb.GetTimeFromInterface(row[0]); err != nil {
+		return rate, false, err
+	}
+	if rate.Rate, err = influxdb.GetFloat64FromInterface(row[1]); err != nil {
+		return rate, false, err
+	}
+	provider, ok := row[2].(string)
+	if !ok {
+		return rate, false, fmt.Errorf("invalid provider value %v", row[2])
+	}
+	rate.Provider = provider
+	return rate, rate.Rate != 0, nil
+}
+
 // createDB creates the database will be used for storing trade logs measurements.
 func (ef *ETHUSDRateFetcher) createDB() error {
 	_, err := ef.queryDB(ef.influxClient, fmt.Sprintf("CREATE DATABASE %s", ef.dbName))
@@ -89,7 +177,7 @@ func (ef *ETHUSDRateFetcher) queryDB(clnt client.Client, cmd string) (res []clie
 }
 
 //SaveTokenRate into influx
-func (ef ETHUSDRateFetcher) SaveTokenRate(rate ETHUSDRate) error {
+func (ef *ETHUSDRateFetcher) SaveTokenRate(rate ETHUSDRate) error {
 	bp, err := client.NewBatchPoints(client.BatchPointsConfig{
 		Database:  ef.dbName,
 		Precision: "ms",
@@ -1,11 +1,16 @@
 package tokenrate
 
 import (
+	"encoding/json"
+	"regexp"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/KyberNetwork/tokenrate/coingecko"
 	"github.com/influxdata/influxdb/client/v2"
+	"github.com/influxdata/influxdb/models"
+	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
 
@@ -43,3 +48,103 @@ func TestSaveTokenRate(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// mockInfluxClient is an in-memory influx client that supports writing and
+// querying token_rate points by time range.
+type mockInfluxClient struct {
+	client.Client
+	points []*client.Point
+}
+
+var timeRangeRegexp = regexp.MustCompile(`time >= '(.+)' AND time < '(.+)'`)
+
+func (mc *mockInfluxClient) Write(bp client.BatchPoints) error {
+	mc.points = append(mc.points, bp.Points()...)
+	return nil
+}
+
+func (mc *mockInfluxClient) Query(q client.Query) (*client.Response, error) {
+	matches := timeRangeRegexp.FindStringSubmatch(q.Command)
+	if matches == nil {
+		return &client.Response{}, nil
+	}
+	from, _ := time.Parse(time.RFC3339, matches[1])
+	to, _ := time.Parse(time.RFC3339, matches[2])
+
+	var values [][]interface{}
+	for _, pt := range mc.points {
+		if pt.Time().Before(from) || !pt.Time().Before(to) {
+			continue
+		}
+		fields, _ := pt.Fields()
+		values = append(values, []interface{}{
+			pt.Time().UTC().Format(time.RFC3339),
+			json.Number(strconv.FormatFloat(fields["rate"].(float64), 'f', -1, 64)),
+			pt.Tags()["provider"],
+		})
+	}
+	if len(values) == 0 {
+		return &client.Response{Results: []client.Result{{}}}, nil
+	}
+	return &client.Response{Results: []client.Result{{
+		Series: []models.Row{{Name: "token_rate", Columns: []string{"time", "rate", "provider"}, Values: values}},
+	}}}, nil
+}
+
+type countingProvider struct {
+	*Mock
+	calls int
+}
+
+func (cp *countingProvider) USDRate(timestamp time.Time) (float64, error) {
+	cp.calls++
+	return cp.Mock.USDRate(timestamp)
+}
+
+func TestFetchRatesLookup(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	var (
+		influxClient = &mockInfluxClient{}
+		provider     = &countingProvider{Mock: NewMock()}
+		ts           = time.Date(2018, 10, 1, 10, 15, 0, 0, time.UTC)
+	)
+
+	fetcher, err := NewETHUSDRateFetcher(sugar, "test_db", influxClient, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rate, err := fetcher.FetchRates(1, ts)
+	assert.NoError(t, err)
+	assert.Equal(t, ETHUSDRate{Timestamp: ts, Rate: 100, Provider: "tokenRateMock", BlockNumber: 1}, rate)
+
+	// same hour, served from in-process cache
+	rate, err = fetcher.FetchRates(2, ts.Add(30*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(100), rate.Rate)
+	assert.Equal(t, uint64(2), rate.BlockNumber)
+	assert.Equal(t, 1, provider.calls)
+	assert.Len(t, influxClient.points, 1)
+
+	// new fetcher instance, served from stored rates
+	fetcher, err = NewETHUSDRateFetcher(sugar, "test_db", influxClient, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rate, err = fetcher.FetchRates(3, ts.Add(40*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(100), rate.Rate)
+	assert.Equal(t, "tokenRateMock", rate.Provider)
+	assert.Equal(t, 1, provider.calls)
+
+	// next hour, provider is called
+	_, err = fetcher.FetchRates(4, ts.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, provider.calls)
+	assert.Len(t, influxClient.points, 2)
+}
@@ -0,0 +1,58 @@
+package tokenrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+const fileProviderName = "file"
+
+// FileProvider is an ETH/USD rate provider that replays historical rates read
+// from a JSON file, it is useful in tests and offline environments. The file
+// is a list of rates with timestamp, example:
+// [{"timestamp": "2018-10-01T00:00:00Z", "rate": 232.15}]
+type FileProvider struct {
+	rates []fileRate
+}
+
+type fileRate struct {
+	Timestamp time.Time `json:"timestamp"`
+	Rate      float64   `json:"rate"`
+}
+
+// NewFileProvider creates a new FileProvider instance with rates from given file.
+func NewFileProvider(path string) (*FileProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rates []fileRate
+	if err = json.NewDecoder(f).Decode(&rates); err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, errors.New("no rate found in file")
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Timestamp.Before(rates[j].Timestamp) })
+	return &FileProvider{rates: rates}, nil
+}
+
+// USDRate returns the latest rate in file at given timestamp.
+func (fp *FileProvider) USDRate(timestamp time.Time) (float64, error) {
+	i := sort.Search(len(fp.rates), func(i int) bool { return fp.rates[i].Timestamp.After(timestamp) })
+	if i == 0 {
+		return 0, fmt.Errorf("no rate found at %s", timestamp.String())
+	}
+	return fp.rates[i-1].Rate, nil
+}
+
+// Name returns the name of provider.
+func (fp *FileProvider) Name() string {
+	return fileProviderName
+}
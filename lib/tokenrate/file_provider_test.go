@@ -0,0 +1,32 @@
+package tokenrate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileProvider(t *testing.T) {
+	fp, err := NewFileProvider("testdata/eth_usd_rates.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		timestamp time.Time
+		rate      float64
+	}{
+		{timestamp: time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC), rate: 232.15},
+		{timestamp: time.Date(2018, 10, 2, 12, 0, 0, 0, time.UTC), rate: 229.72},
+		{timestamp: time.Date(2018, 10, 5, 0, 0, 0, 0, time.UTC), rate: 220.53},
+	}
+	for _, tc := range tests {
+		rate, err := fp.USDRate(tc.timestamp)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.rate, rate)
+	}
+
+	_, err = fp.USDRate(time.Date(2018, 9, 30, 0, 0, 0, 0, time.UTC))
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package tokenrate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/KyberNetwork/tokenrate"
+	"github.com/KyberNetwork/tokenrate/coingecko"
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+const (
+	providersFlag         = "eth-usd-providers"
+	providersDefaultValue = "coingecko"
+	rateFileFlag          = "eth-usd-rate-file"
+	maxDeviationFlag      = "eth-usd-max-deviation"
+)
+
+// NewCliFlags returns cli flags to configure ETH/USD rate providers.
+func NewCliFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   providersFlag,
+			Usage:  "Comma separated list of ETH/USD rate providers, supported: coingecko, file",
+			Value:  providersDefaultValue,
+			EnvVar: "ETH_USD_PROVIDERS",
+		},
+		cli.StringFlag{
+			Name:   rateFileFlag,
+			Usage:  "Path to JSON file of historical ETH/USD rates, required by file provider",
+			EnvVar: "ETH_USD_RATE_FILE",
+		},
+		cli.Float64Flag{
+			Name:   maxDeviationFlag,
+			Usage:  "Maximum relative deviation from median rate, rates deviate more are dropped as outliers",
+			Value:  DefaultMaxDeviation,
+			EnvVar: "ETH_USD_MAX_DEVIATION",
+		},
+	}
+}
+
+// NewProviderFromContext returns the composite ETH/USD rate provider of the
+// providers configured by cli flags.
+func NewProviderFromContext(sugar *zap.SugaredLogger, c *cli.Context) (*CompositeProvider, error) {
+	var providers []tokenrate.ETHUSDRateProvider
+
+	for _, name := range strings.Split(c.String(providersFlag), ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "coingecko":
+			providers = append(providers, coingecko.New())
+		case fileProviderName:
+			fp, err := NewFileProvider(c.String(rateFileFlag))
+			if err != nil {
+				return nil, fmt.Errorf("invalid ETH/USD rate file: %s", err)
+			}
+			providers = append(providers, fp)
+		case "":
+		default:
+			return nil, fmt.Errorf("unsupported ETH/USD rate provider: %q", name)
+		}
+	}
+
+	maxDeviation := c.Float64(maxDeviationFlag)
+	if maxDeviation <= 0 {
+		return nil, fmt.Errorf("invalid max deviation: %f", maxDeviation)
+	}
+	return NewCompositeProvider(sugar, maxDeviation, providers...)
+}
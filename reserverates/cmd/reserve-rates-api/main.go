@@ -7,9 +7,8 @@ import (
 	"github.com/KyberNetwork/reserve-stats/lib/httputil"
 
 	libapp "github.com/KyberNetwork/reserve-stats/lib/app"
-	"github.com/KyberNetwork/reserve-stats/lib/influxdb"
 	"github.com/KyberNetwork/reserve-stats/reserverates/http"
-	influxRateStorage "github.com/KyberNetwork/reserve-stats/reserverates/storage/influx"
+	"github.com/KyberNetwork/reserve-stats/reserverates/storage"
 
 	"github.com/urfave/cli"
 )
@@ -20,7 +19,7 @@ func newServerCli() *cli.App {
 	app.Name = "reserverates-server"
 	app.Usage = "server for query rate API"
 	app.Flags = append(app.Flags, httputil.NewHTTPCliFlags(httputil.ReserveRatesPort)...)
-	app.Flags = append(app.Flags, influxdb.NewCliFlags()...)
+	app.Flags = append(app.Flags, storage.NewCliFlags()...)
 	app.Action = func(c *cli.Context) error {
 		logger, err := libapp.NewLogger(c)
 		if err != nil {
@@ -28,12 +27,7 @@ func newServerCli() *cli.App {
 		}
 		defer logger.Sync()
 
-		influxClient, err := influxdb.NewClientFromContext(c)
-		if err != nil {
-			return err
-		}
-
-		rateStorage, err := influxRateStorage.NewRateInfluxDBStorage(logger.Sugar(), influxClient, dbName)
+		rateStorage, err := storage.NewStorageFromContext(c, logger.Sugar(), dbName)
 		if err != nil {
 			return err
 		}
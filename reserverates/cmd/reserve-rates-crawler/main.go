@@ -2,101 +2,306 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	libapp "github.com/KyberNetwork/reserve-stats/lib/app"
+	"github.com/KyberNetwork/reserve-stats/lib/backfill"
 	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
 	"github.com/KyberNetwork/reserve-stats/lib/core"
-	"github.com/KyberNetwork/reserve-stats/lib/influxdb"
+	"github.com/KyberNetwork/reserve-stats/lib/deployment"
+	"github.com/KyberNetwork/reserve-stats/lib/metrics"
 	"github.com/KyberNetwork/reserve-stats/reserverates/crawler"
-	influxRateStorage "github.com/KyberNetwork/reserve-stats/reserverates/storage/influx"
+	"github.com/KyberNetwork/reserve-stats/reserverates/storage"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/urfave/cli"
 	"go.uber.org/zap"
 )
 
 const (
-	addressesFlag = "addresses"
-	blockFlag     = "block"
+	addressesFlag      = "addresses"
+	blockFlag          = "block"
+	timeFlag           = "time"
+	blockStepFlag      = "block-step"
+	sampleIntervalFlag = "sample-interval"
+
+	// blockStepDefaultValue is about an hour of blocks
+	blockStepDefaultValue       = 240
+	backfillJobDBDefaultValue   = "reserve-rates-backfill.db"
+	backfillJobSizeDefaultValue = 2400
 )
 
+var blocksProcessed = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "reserve_rates_crawler_blocks_processed_total",
+	Help: "Number of sampled blocks which reserve rates are crawled and stored.",
+})
+
+func init() {
+	prometheus.MustRegister(blocksProcessed)
+}
+
 func newReserveCrawlerCli() *cli.App {
 	const dbName = "resever_rates"
 	app := libapp.NewApp()
 	app.Name = "reserverates"
 	app.Usage = "get the rates of all configured reserves at a certain block"
-	var block uint64
 	app.Flags = append(app.Flags,
 		cli.StringSliceFlag{
 			Name:   addressesFlag,
 			EnvVar: "RESERVE_ADDRESSES",
 			Usage:  "list of reserve contract addresses. Example: --addresses={\"0x1111\",\"0x222\"}",
 		},
-		cli.Uint64Flag{
-			Name:        blockFlag,
-			Value:       0,
-			Usage:       "block from which rate is queried. Default value is 0, in which case the latest rate is returned",
-			Destination: &block,
+		cli.StringFlag{
+			Name:  blockFlag,
+			Usage: "block from which rate is queried. If neither block nor time is given, the latest rate is returned",
+		},
+		cli.StringFlag{
+			Name:  timeFlag,
+			Usage: "query rate at the first block at or after given time, example: 2018-10-01 or 2018-10-01T00:00:00Z",
 		},
 		libapp.NewEthereumNodeFlags(),
 	)
+	app.Flags = append(app.Flags, blockchain.NewMultiClientCliFlags()...)
+	app.Flags = append(app.Flags, blockchain.NewBlockTimeResolverCliFlags()...)
 	app.Flags = append(app.Flags, core.NewCliFlags()...)
-	app.Flags = append(app.Flags, influxdb.NewCliFlags()...)
+	app.Flags = append(app.Flags, storage.NewCliFlags()...)
+	app.Flags = append(app.Flags, deployment.NewCliFlags()...)
 	app.Action = func(c *cli.Context) error {
-		addrs := c.StringSlice(addressesFlag)
-		client, err := libapp.NewEthereumClientFromFlag(c)
+		logger, err := libapp.NewLogger(c)
 		if err != nil {
 			return err
 		}
-		logger, err := libapp.NewLogger(c)
+		defer logger.Sync()
+
+		reserveRateCrawler, client, blockTimeResolver, err := newCrawlerFromContext(c, logger.Sugar(), dbName)
 		if err != nil {
 			return err
 		}
-		defer logger.Sync()
 
-		blockTimeResolver, err := blockchain.NewBlockTimeResolver(logger.Sugar(), client)
+		block, ok, err := blockchain.BlockFromContext(c, blockTimeResolver, blockFlag, timeFlag, 0)
 		if err != nil {
 			return err
 		}
-		coreClient, err := core.NewClientFromContext(logger.Sugar(), c)
+		if !ok {
+			// parity and geth are not compatible in mix hash, the header is still usable
+			// when error is returned together with a non nil header
+			currentBlock, err := client.HeaderByNumber(context.Background(), nil)
+			if err != nil && currentBlock == nil {
+				return err
+			}
+			block = currentBlock.Number.Uint64()
+		}
+
+		result, err := reserveRateCrawler.GetReserveRates(block)
 		if err != nil {
 			return err
 		}
-		influxClient, err := influxdb.NewClientFromContext(c)
+		logger.Info("rate result is", zap.Reflect("rates", result))
+		return nil
+	}
+
+	backfillFlags := []cli.Flag{
+		cli.StringSliceFlag{
+			Name:   addressesFlag,
+			EnvVar: "RESERVE_ADDRESSES",
+			Usage:  "list of reserve contract addresses. Example: --addresses={\"0x1111\",\"0x222\"}",
+		},
+		cli.Uint64Flag{
+			Name:  blockStepFlag,
+			Usage: "Number of blocks between two sampled blocks, the rates are crawled at blocks that are multiple of block step",
+			Value: blockStepDefaultValue,
+		},
+		cli.DurationFlag{
+			Name:  sampleIntervalFlag,
+			Usage: "If set, the rates are crawled at the first block at or after each multiple of given interval, example: 1h, instead of by block step",
+		},
+		libapp.NewEthereumNodeFlags(),
+	}
+	backfillFlags = append(backfillFlags, core.NewCliFlags()...)
+	backfillFlags = append(backfillFlags, storage.NewCliFlags()...)
+	backfillFlags = append(backfillFlags, deployment.NewCliFlags()...)
+	backfillFlags = append(backfillFlags, backfill.NewCliFlags(backfillJobDBDefaultValue, backfillJobSizeDefaultValue)...)
+	backfillFlags = append(backfillFlags, metrics.NewCliFlags()...)
+	backfillFlags = append(backfillFlags, blockchain.NewMultiClientCliFlags()...)
+	backfillFlags = append(backfillFlags, blockchain.NewBlockTimeResolverCliFlags()...)
+	app.Commands = []cli.Command{
+		{
+			Name:   "backfill",
+			Usage:  "Crawl reserve rates of sampled blocks in a block or date range in jobs, an interrupted run is resumed from the job store",
+			Action: backfillReserveRates(dbName),
+			Flags:  backfillFlags,
+		},
+		{
+			Name:   "migrate",
+			Usage:  "Downsample the existing reserve rates and apply the raw rates retention, an interrupted run is resumed on next run",
+			Action: migrateReserveRates(dbName),
+			Flags:  storage.NewCliFlags(),
+		},
+	}
+	return app
+}
+
+// newCrawlerFromContext creates the reserve rates crawler configured by cli flags.
+func newCrawlerFromContext(c *cli.Context, sugar *zap.SugaredLogger, dbName string) (*crawler.ResreveRatesCrawler, blockchain.Client, *blockchain.BlockTimeResolver, error) {
+	addrs := c.StringSlice(addressesFlag)
+	client, err := libapp.NewEthereumClientFromFlag(c, sugar)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	blockTimeResolver, err := blockchain.NewBlockTimeResolverFromContext(c, sugar, client)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	coreClient, err := core.NewClientFromContext(sugar, c)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	registry, err := deployment.NewRegistryFromContext(c)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rateStorage, err := storage.NewStorageFromContext(c, sugar, dbName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	reserveRateCrawler, err := crawler.NewReserveRatesCrawler(addrs, client, registry, coreClient, sugar, blockTimeResolver, rateStorage)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return reserveRateCrawler, client, blockTimeResolver, nil
+}
+
+// backfillReserveRates crawls the reserve rates of sampled blocks of given range.
+func backfillReserveRates(dbName string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		logger, err := libapp.NewLogger(c)
 		if err != nil {
 			return err
 		}
-		rateStorage, err := influxRateStorage.NewRateInfluxDBStorage(logger.Sugar(), influxClient, dbName)
+		defer logger.Sync()
+		sugar := logger.Sugar()
+
+		blockStep, sampleInterval := c.Uint64(blockStepFlag), c.Duration(sampleIntervalFlag)
+		if blockStep == 0 {
+			return fmt.Errorf("invalid block step: %d", blockStep)
+		}
+		if sampleInterval < 0 {
+			return fmt.Errorf("invalid sample interval: %s", sampleInterval)
+		}
+
+		reserveRateCrawler, _, blockTimeResolver, err := newCrawlerFromContext(c, sugar, dbName)
 		if err != nil {
 			return err
 		}
-		reserveRateCrawler, err := crawler.NewReserveRatesCrawler(addrs, client, coreClient, logger.Sugar(), blockTimeResolver, rateStorage)
+
+		rng, err := backfill.NewRangeFromContext(c, blockTimeResolver)
 		if err != nil {
 			return err
 		}
 
-		if block == 0 {
-			currentBlock, err := client.BlockByNumber(context.Background(), nil)
-			if err != nil {
-				return err
+		metrics.ServeFromContext(c, sugar)
+
+		runner, store, err := backfill.NewRunnerFromContext(c, sugar, func(fromBlock, toBlock uint64) error {
+			var blocks []uint64
+			if sampleInterval != 0 {
+				var sErr error
+				if blocks, sErr = sampleBlocksByTime(blockTimeResolver, fromBlock, toBlock, sampleInterval); sErr != nil {
+					return sErr
+				}
+			} else {
+				// first multiple of block step in range
+				for block := (fromBlock + blockStep - 1) / blockStep * blockStep; block <= toBlock; block += blockStep {
+					blocks = append(blocks, block)
+				}
+			}
+			// resolves the timestamps of all sampled blocks of the job at once
+			if _, rErr := blockTimeResolver.ResolveMany(blocks); rErr != nil {
+				return rErr
 			}
-			block = currentBlock.Number().Uint64()
+
+			for _, block := range blocks {
+				if _, cErr := reserveRateCrawler.GetReserveRates(block); cErr != nil {
+					return cErr
+				}
+				blocksProcessed.Inc()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
+		defer store.Close()
 
-		result, err := reserveRateCrawler.GetReserveRates(block)
+		return runner.Run(rng)
+	}
+}
+
+// migrateReserveRates runs the one-off migration of the reserve rates storage.
+func migrateReserveRates(dbName string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		logger, err := libapp.NewLogger(c)
 		if err != nil {
 			return err
 		}
-		logger.Info("rate result is", zap.Reflect("rates", result))
+		defer logger.Sync()
+		sugar := logger.Sugar()
+
+		rateStorage, err := storage.NewStorageFromContext(c, sugar, dbName)
+		if err != nil {
+			return err
+		}
+		migrator, ok := rateStorage.(storage.Migrator)
+		if !ok {
+			sugar.Infow("reserve rates storage needs no migration", "storage", fmt.Sprintf("%T", rateStorage))
+			return nil
+		}
+		if err = migrator.Migrate(); err != nil {
+			return err
+		}
+		sugar.Info("reserve rates storage is migrated")
 		return nil
 	}
-	return app
 }
 
-//reserverates --addresses=0xABCDEF,0xDEFGHI --block 100
+// reserverates --addresses=0xABCDEF,0xDEFGHI --block 100
 func main() {
 	app := newReserveCrawlerCli()
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// sampleBlocksByTime returns the first block at or after each multiple of
+// interval, for the multiples that fall in the time range of given blocks.
+func sampleBlocksByTime(resolver *blockchain.BlockTimeResolver, fromBlock, toBlock uint64, interval time.Duration) ([]uint64, error) {
+	// the boundaries after the timestamp of the block before fromBlock are
+	// at or before the timestamp of fromBlock
+	prevBlock := fromBlock
+	if prevBlock > 0 {
+		prevBlock--
+	}
+	times, err := resolver.ResolveMany([]uint64{prevBlock, toBlock})
+	if err != nil {
+		return nil, err
+	}
+	start, end := times[prevBlock], times[toBlock]
+	if prevBlock == fromBlock {
+		start = start.Add(-time.Second)
+	}
+
+	var blocks []uint64
+	for boundary := start.Truncate(interval).Add(interval); !boundary.After(end); boundary = boundary.Add(interval) {
+		block, bErr := resolver.BlockAtTime(boundary)
+		if bErr != nil {
+			return nil, bErr
+		}
+		// blocks that are mined after multiple intervals are sampled once
+		if len(blocks) == 0 || blocks[len(blocks)-1] != block {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
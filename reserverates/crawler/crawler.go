@@ -8,18 +8,13 @@ import (
 	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
 	"github.com/KyberNetwork/reserve-stats/lib/contracts"
 	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/lib/deployment"
 	rsvRateCommon "github.com/KyberNetwork/reserve-stats/reserverates/common"
 	"github.com/KyberNetwork/reserve-stats/reserverates/storage"
 	ethereum "github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"go.uber.org/zap"
 )
 
-var (
-	//InternalReserveAddr is the Kyber's own reserve address
-	InternalReserveAddr = ethereum.HexToAddress("0x63825c174ab367968EC60f061753D3bbD36A0D8F")
-)
-
 // ResreveRatesCrawler contains two wrapper contracts for V1 and V2 contract,
 // a set of addresses to crawl rates from and setting object to query for reserve's token settings
 type ResreveRatesCrawler struct {
@@ -29,11 +24,13 @@ type ResreveRatesCrawler struct {
 	sugar           *zap.SugaredLogger
 	blkTimeRsv      blockchain.BlockTimeResolverInterface
 	db              storage.ReserveRatesStorage
+	// internalReserves is the addresses of all deployments of Kyber's own reserve
+	internalReserves map[ethereum.Address]struct{}
 }
 
 // NewReserveRatesCrawler returns an instant of ReserveRatesCrawler.
-func NewReserveRatesCrawler(addrs []string, client *ethclient.Client, sett tokenSetting, sugar *zap.SugaredLogger, bl blockchain.BlockTimeResolverInterface, dbInstance storage.ReserveRatesStorage) (*ResreveRatesCrawler, error) {
-	wrpContract, err := contracts.NewVersionedWrapper(client)
+func NewReserveRatesCrawler(addrs []string, client blockchain.Client, registry *deployment.Registry, sett tokenSetting, sugar *zap.SugaredLogger, bl blockchain.BlockTimeResolverInterface, dbInstance storage.ReserveRatesStorage) (*ResreveRatesCrawler, error) {
+	wrpContract, err := contracts.NewVersionedWrapper(client, registry)
 	if err != nil {
 		return nil, err
 	}
@@ -41,18 +38,23 @@ func NewReserveRatesCrawler(addrs []string, client *ethclient.Client, sett token
 	for _, addr := range addrs {
 		ethAddrs = append(ethAddrs, ethereum.HexToAddress(addr))
 	}
+	internalReserves := make(map[ethereum.Address]struct{})
+	for _, d := range registry.Deployments(deployment.InternalReserveContract) {
+		internalReserves[d.Address] = struct{}{}
+	}
 	return &ResreveRatesCrawler{
-		wrapperContract: wrpContract,
-		Addresses:       ethAddrs,
-		tokenSetting:    sett,
-		sugar:           sugar,
-		blkTimeRsv:      bl,
-		db:              dbInstance,
+		wrapperContract:  wrpContract,
+		Addresses:        ethAddrs,
+		tokenSetting:     sett,
+		sugar:            sugar,
+		blkTimeRsv:       bl,
+		db:               dbInstance,
+		internalReserves: internalReserves,
 	}, nil
 }
 
 func (rrc *ResreveRatesCrawler) callTokens(rsvAddr ethereum.Address) ([]core.Token, error) {
-	if rsvAddr.Hex() == InternalReserveAddr.Hex() {
+	if _, ok := rrc.internalReserves[rsvAddr]; ok {
 		return rrc.tokenSetting.GetInternalTokens()
 	}
 	return rrc.tokenSetting.GetActiveTokens()
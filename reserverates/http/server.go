@@ -5,6 +5,7 @@ import (
 	"time"
 
 	_ "github.com/KyberNetwork/reserve-stats/lib/httputil/validators" // import custom validator functions
+	"github.com/KyberNetwork/reserve-stats/lib/metrics"
 	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
 	"github.com/KyberNetwork/reserve-stats/reserverates/common"
 	"github.com/KyberNetwork/reserve-stats/reserverates/storage"
@@ -76,6 +77,7 @@ func (sv *Server) reserveRates(c *gin.Context) {
 }
 
 func (sv *Server) register() {
+	metrics.Instrument(sv.r)
 	sv.r.GET("/reserve-rates", sv.reserveRates)
 }
 
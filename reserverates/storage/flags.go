@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/influxdb"
+	"github.com/KyberNetwork/reserve-stats/reserverates/storage/influx"
+	"github.com/KyberNetwork/reserve-stats/reserverates/storage/memory"
+)
+
+const (
+	storageFlag      = "storage"
+	rawRetentionFlag = "raw-rates-retention"
+
+	// InfluxDBBackend stores reserve rates in InfluxDB.
+	InfluxDBBackend = "influxdb"
+	// MemoryBackend stores reserve rates in memory of the process, for development only.
+	MemoryBackend = "memory"
+)
+
+// NewCliFlags returns cli flags to select and configure the reserve rates storage backend.
+func NewCliFlags() []cli.Flag {
+	flags := []cli.Flag{
+		cli.StringFlag{
+			Name:   storageFlag,
+			Usage:  fmt.Sprintf("Storage backend of reserve rates, one of: %s, %s", InfluxDBBackend, MemoryBackend),
+			EnvVar: "STORAGE",
+			Value:  InfluxDBBackend,
+		},
+		cli.DurationFlag{
+			Name:   rawRetentionFlag,
+			Usage:  "How long the raw reserve rates are kept in InfluxDB, 0 to keep forever, applied by the migrate command of the crawler. The hourly and daily downsampled rates are kept forever",
+			EnvVar: "RAW_RATES_RETENTION",
+		},
+	}
+	return append(flags, influxdb.NewCliFlags()...)
+}
+
+// Migrator is implemented by the storage backends of which existing data needs
+// a one-off migration.
+type Migrator interface {
+	Migrate() error
+}
+
+// NewStorageFromContext creates the reserve rates storage selected by cli flags.
+func NewStorageFromContext(c *cli.Context, sugar *zap.SugaredLogger, dbName string) (ReserveRatesStorage, error) {
+	switch backend := c.String(storageFlag); backend {
+	case InfluxDBBackend:
+		influxClient, err := influxdb.NewClientFromContext(c)
+		if err != nil {
+			return nil, err
+		}
+		return influx.NewRateInfluxDBStorage(sugar, influxClient, dbName,
+			influx.WithRawRetention(c.Duration(rawRetentionFlag)))
+	case MemoryBackend:
+		sugar.Warnw("reserve rates are stored in memory and lost on exit", "storage", backend)
+		return memory.NewRateMemoryStorage(sugar), nil
+	default:
+		return nil, fmt.Errorf("invalid storage backend: %q", backend)
+	}
+}
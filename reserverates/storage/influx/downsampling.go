@@ -0,0 +1,586 @@
+package influx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	influxClient "github.com/influxdata/influxdb/client/v2"
+
+	"github.com/KyberNetwork/reserve-stats/lib/influxdb"
+	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
+	"github.com/KyberNetwork/reserve-stats/reserverates/common"
+	"github.com/KyberNetwork/reserve-stats/reserverates/storage/influx/schema"
+)
+
+const (
+	// downsampledRetentionPolicy keeps the downsampled rates forever.
+	downsampledRetentionPolicy = "downsampled"
+	// blockField is the field storing block number of raw and downsampled
+	// rates, as the block_number tag of raw rates can not be aggregated. It is
+	// the last block of downsampled periods.
+	blockField = "block"
+	// firstBlockField is the field storing the first block of downsampled
+	// periods, to merge rates out of raw retention into them.
+	firstBlockField = "first_block"
+
+	// rawMaxSpan is the longest time range that raw rates are returned for.
+	rawMaxSpan = 7 * 24 * time.Hour
+	// hourlyMaxSpan is the longest time range that hourly rates are returned for.
+	hourlyMaxSpan = 90 * 24 * time.Hour
+	// downsampleWindow is the time range of each downsampling query, it must
+	// be a multiple of the intervals of all tiers.
+	downsampleWindow = 24 * time.Hour
+)
+
+// rateFields are the fields of raw rates that are downsampled to OHLC values.
+var rateFields = []schema.RateSchemaFieldName{
+	schema.BuyRate,
+	schema.SellRate,
+	schema.BuySanityRate,
+	schema.SellSanityRate,
+}
+
+// downsampledTier is a measurement of rates downsampled to OHLC values of
+// fixed interval periods.
+type downsampledTier struct {
+	// name is the measurement name without retention policy.
+	name        string
+	measurement string
+	interval    time.Duration
+	// source is the measurement that the tier is downsampled from.
+	source string
+	// ohlcSource is true if the source measurement is also downsampled.
+	ohlcSource bool
+}
+
+var (
+	hourlyTier = downsampledTier{
+		name:        RateTableName + "_1h",
+		measurement: fmt.Sprintf(`"%s"."%s_1h"`, downsampledRetentionPolicy, RateTableName),
+		interval:    time.Hour,
+		source:      fmt.Sprintf(`"%s"."%s"`, influxdb.DefaultRetentionPolicy, RateTableName),
+	}
+	dailyTier = downsampledTier{
+		name:        RateTableName + "_1d",
+		measurement: fmt.Sprintf(`"%s"."%s_1d"`, downsampledRetentionPolicy, RateTableName),
+		interval:    24 * time.Hour,
+		source:      hourlyTier.measurement,
+		ohlcSource:  true,
+	}
+	// downsampledTiers are ordered so a tier is after the tier it is downsampled from.
+	downsampledTiers = []downsampledTier{hourlyTier, dailyTier}
+)
+
+// downsampleStatement returns the statement downsampling the source rates in
+// given time range, from inclusive and to exclusive, into the tier.
+func (dt downsampledTier) downsampleStatement(from, to time.Time) string {
+	var selects []string
+	for _, field := range rateFields {
+		name := field.String()
+		source := func(ohlc string) string {
+			if dt.ohlcSource {
+				return name + "_" + ohlc
+			}
+			return name
+		}
+		selects = append(selects,
+			fmt.Sprintf(`FIRST("%s") AS "%s_open"`, source("open"), name),
+			fmt.Sprintf(`MAX("%s") AS "%s_high"`, source("high"), name),
+			fmt.Sprintf(`MIN("%s") AS "%s_low"`, source("low"), name),
+			fmt.Sprintf(`LAST("%s") AS "%s_close"`, source("close"), name),
+		)
+	}
+	firstBlockSource := blockField
+	if dt.ohlcSource {
+		firstBlockSource = firstBlockField
+	}
+	selects = append(selects,
+		fmt.Sprintf(`LAST("%[1]s") AS "%[1]s"`, blockField),
+		fmt.Sprintf(`FIRST("%s") AS "%s"`, firstBlockSource, firstBlockField),
+	)
+
+	return fmt.Sprintf(`SELECT %s INTO %s FROM %s WHERE time >= '%s' AND time < '%s' GROUP BY "%s", "%s", time(%s)`,
+		strings.Join(selects, ", "),
+		dt.measurement,
+		dt.source,
+		from.UTC().Format(time.RFC3339),
+		to.UTC().Format(time.RFC3339),
+		schema.Reserve.String(),
+		schema.Pair.String(),
+		influxdb.FormatDuration(dt.interval))
+}
+
+func (rs *RateStorage) query(cmd string) ([]influxClient.Result, error) {
+	response, err := rs.client.Query(influxClient.NewQuery(cmd, rs.dbName, timePrecision))
+	if err != nil {
+		return nil, err
+	}
+	if response.Error() != nil {
+		return nil, response.Error()
+	}
+	return response.Results, nil
+}
+
+// downsample downsamples the raw rates of the days of given time range to all
+// tiers, replacing the downsampled rates of these days. The days are processed
+// from the last one, so an interrupted downsampling leaves no gap before the
+// earliest downsampled period.
+func (rs *RateStorage) downsample(from, to time.Time) error {
+	from = from.UTC().Truncate(downsampleWindow)
+	to = to.UTC().Truncate(downsampleWindow).Add(downsampleWindow)
+
+	for end := to; end.After(from); end = end.Add(-downsampleWindow) {
+		start := end.Add(-downsampleWindow)
+		for _, tier := range downsampledTiers {
+			if _, err := rs.query(tier.downsampleStatement(start, end)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// downsamplePeriods downsamples the periods of given tiers that contain given
+// times, replacing the downsampled rates of these periods.
+func (rs *RateStorage) downsamplePeriods(times []time.Time, tiers ...downsampledTier) error {
+	for _, tier := range tiers {
+		var done = make(map[time.Time]bool)
+		for _, t := range times {
+			start := t.UTC().Truncate(tier.interval)
+			if done[start] {
+				continue
+			}
+			done[start] = true
+			if _, err := rs.query(tier.downsampleStatement(start, start.Add(tier.interval))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// selectTime returns the time of the single point result of given selector
+// query, or false if there is no result.
+func (rs *RateStorage) selectTime(cmd string) (time.Time, bool, error) {
+	res, err := rs.query(cmd)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if len(res) == 0 || len(res[0].Series) == 0 || len(res[0].Series[0].Values) == 0 {
+		return time.Time{}, false, nil
+	}
+	ms, err := influxdb.GetInt64FromInterface(res[0].Series[0].Values[0][0])
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return timeutil.TimestampMsToTime(uint64(ms)).UTC(), true, nil
+}
+
+// Migrate prepares the raw rates written before downsampling is introduced,
+// then applies the raw retention: the block field is added to the raw rates
+// that have only block number tag, and the raw rates that are not downsampled
+// yet are downsampled. It rewrites the whole history on first run, so it is
+// run once by an explicit command rather than on start up. An interrupted
+// migration is resumed on next run.
+func (rs *RateStorage) Migrate() error {
+	if err := rs.migrate(); err != nil {
+		return err
+	}
+	return influxdb.EnsureRetentionPolicy(rs.sugar, rs.client, rs.dbName, influxdb.RetentionPolicy{
+		Name:     influxdb.DefaultRetentionPolicy,
+		Duration: rs.rawRetention,
+		Default:  true,
+	})
+}
+
+func (rs *RateStorage) migrate() error {
+	logger := rs.sugar.With(
+		"func", "reserverates/storage/influx/RateStorage.migrate",
+		"db", rs.dbName,
+	)
+
+	firstRaw, ok, err := rs.selectTime(fmt.Sprintf(`SELECT FIRST("%s") FROM %s`, schema.BuyRate, hourlyTier.source))
+	if err != nil || !ok {
+		return err
+	}
+	lastRaw, _, err := rs.selectTime(fmt.Sprintf(`SELECT LAST("%s") FROM %s`, schema.BuyRate, hourlyTier.source))
+	if err != nil {
+		return err
+	}
+
+	// the block field is added from the last rates backward, so all rates
+	// after the first rate with block field are migrated
+	legacyEnd := lastRaw.Add(time.Millisecond)
+	firstBlock, ok, err := rs.selectTime(fmt.Sprintf(`SELECT FIRST("%s") FROM %s`, blockField, hourlyTier.source))
+	if err != nil {
+		return err
+	}
+	if ok {
+		legacyEnd = firstBlock
+	}
+	for end := legacyEnd; end.After(firstRaw); end = end.Add(-downsampleWindow) {
+		if err = rs.addBlockField(end.Add(-downsampleWindow), end); err != nil {
+			return err
+		}
+		logger.Infow("added block field to raw rates", "window_end", end)
+	}
+
+	// the raw rates are downsampled backward as well, so all raw rates after
+	// the first downsampled period are downsampled
+	downsampleEnd := lastRaw
+	firstDownsampled, ok, err := rs.selectTime(fmt.Sprintf(`SELECT FIRST("%s_close") FROM %s`, schema.BuyRate, hourlyTier.measurement))
+	if err != nil {
+		return err
+	}
+	if ok {
+		if !firstDownsampled.After(firstRaw.Truncate(hourlyTier.interval)) {
+			return nil
+		}
+		downsampleEnd = firstDownsampled
+	}
+	logger.Infow("downsampling raw rates", "from", firstRaw, "to", downsampleEnd)
+	return rs.downsample(firstRaw, downsampleEnd)
+}
+
+// addBlockField adds the block field of raw rates in given time range, from
+// inclusive and to exclusive, from their block number tag. The points are
+// written again with only the block field, which is merged with their
+// existing fields.
+func (rs *RateStorage) addBlockField(from, to time.Time) error {
+	res, err := rs.query(fmt.Sprintf(`SELECT "%s", "%s", "%s", "%s" FROM %s WHERE time >= %d%s AND time < %d%s`,
+		schema.BuyRate, schema.BlockNumber, schema.Reserve, schema.Pair,
+		hourlyTier.source,
+		timeutil.TimeToTimestampMs(from), timePrecision,
+		timeutil.TimeToTimestampMs(to), timePrecision))
+	if err != nil {
+		return err
+	}
+	if len(res) == 0 || len(res[0].Series) == 0 {
+		return nil
+	}
+
+	bp, err := influxClient.NewBatchPoints(influxClient.BatchPointsConfig{
+		Database:        rs.dbName,
+		RetentionPolicy: influxdb.DefaultRetentionPolicy,
+		Precision:       timePrecision,
+	})
+	if err != nil {
+		return err
+	}
+	for _, row := range res[0].Series[0].Values {
+		ms, err := influxdb.GetInt64FromInterface(row[0])
+		if err != nil {
+			return err
+		}
+		var tags = make(map[string]string)
+		for i, name := range []schema.RateSchemaFieldName{schema.BlockNumber, schema.Reserve, schema.Pair} {
+			val, ok := row[i+2].(string)
+			if !ok {
+				return fmt.Errorf("invalid %s tag value %v", name, row[i+2])
+			}
+			tags[name.String()] = val
+		}
+		blockNumber, err := strconv.ParseUint(tags[schema.BlockNumber.String()], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		pt, err := influxClient.NewPoint(RateTableName, tags,
+			map[string]interface{}{blockField: int64(blockNumber)},
+			timeutil.TimestampMsToTime(uint64(ms)))
+		if err != nil {
+			return err
+		}
+		bp.AddPoint(pt)
+	}
+	return rs.client.Write(bp)
+}
+
+// tier returns the downsampled tier to query rates in given time range, or nil
+// to query raw rates.
+func (rs *RateStorage) tier(from, to time.Time) *downsampledTier {
+	var (
+		span         = to.Sub(from)
+		rawAvailable = rs.rawRetention == 0 || from.After(time.Now().Add(-rs.rawRetention))
+	)
+	switch {
+	case rawAvailable && span <= rawMaxSpan:
+		return nil
+	case span <= hourlyMaxSpan:
+		return &hourlyTier
+	default:
+		return &dailyTier
+	}
+}
+
+// getDownsampledRates returns the close rates of the periods of given tier
+// that start in given time range.
+func (rs *RateStorage) getDownsampledRates(tier downsampledTier, addrs []ethereum.Address, fromTime, toTime uint64) (map[string]map[uint64]common.ReserveRates, error) {
+	logger := rs.sugar.With(
+		"func", "reserverates/storage/influx/RateStorage.getDownsampledRates",
+		"tier", tier.measurement,
+		"reserves", len(addrs),
+		"from", fromTime,
+		"to", toTime,
+	)
+
+	var (
+		fields = []string{blockField, schema.Reserve.String(), schema.Pair.String()}
+		conds  []string
+	)
+	for _, field := range rateFields {
+		fields = append(fields, field.String()+"_close")
+	}
+	for _, addr := range addrs {
+		conds = append(conds, fmt.Sprintf(`"%s" = '%s'`, schema.Reserve, addr.Hex()))
+	}
+	stmt := fmt.Sprintf(`SELECT "%s" FROM %s WHERE time >= %d%s AND time <= %d%s`,
+		strings.Join(fields, `", "`), tier.measurement, fromTime, timePrecision, toTime, timePrecision)
+	if len(conds) != 0 {
+		stmt += " AND (" + strings.Join(conds, " OR ") + ")"
+	}
+	logger.Debugw("querying downsampled rates", "query", stmt)
+
+	res, err := rs.query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 || len(res[0].Series) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]map[uint64]common.ReserveRates)
+	for _, row := range res[0].Series[0].Values {
+		// the periods of rates written before block field is introduced and
+		// not migrated have no block number to be keyed by
+		if row[1] == nil {
+			continue
+		}
+		ms, err := influxdb.GetInt64FromInterface(row[0])
+		if err != nil {
+			return nil, err
+		}
+		blockNumber, err := influxdb.GetInt64FromInterface(row[1])
+		if err != nil {
+			return nil, err
+		}
+		reserve, ok := row[2].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid reserve tag value %v", row[2])
+		}
+		pair, ok := row[3].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid pair tag value %v", row[3])
+		}
+
+		var values []float64
+		for i := range rateFields {
+			value, err := influxdb.GetFloat64FromInterface(row[4+i])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+
+		if _, ok := result[reserve]; !ok {
+			result[reserve] = make(map[uint64]common.ReserveRates)
+		}
+		rate, ok := result[reserve][uint64(blockNumber)]
+		if !ok {
+			rate = common.ReserveRates{
+				Timestamp:   timeutil.TimestampMsToTime(uint64(ms)),
+				BlockNumber: uint64(blockNumber),
+				Data:        make(map[string]common.ReserveRateEntry),
+				Reserve:     reserve,
+			}
+		}
+		rate.Data[pair] = common.ReserveRateEntry{
+			BuyReserveRate:  values[0],
+			SellReserveRate: values[1],
+			BuySanityRate:   values[2],
+			SellSanityRate:  values[3],
+		}
+		result[reserve][uint64(blockNumber)] = rate
+	}
+	return result, nil
+}
+
+// ohlcPeriod is the OHLC values of the rate fields, in the order of
+// rateFields, of a downsampled period.
+type ohlcPeriod struct {
+	open, high, low, close []float64
+	firstBlock, lastBlock  int64
+}
+
+func newOHLCPeriod(block int64, values []float64) *ohlcPeriod {
+	return &ohlcPeriod{
+		open:       append([]float64(nil), values...),
+		high:       append([]float64(nil), values...),
+		low:        append([]float64(nil), values...),
+		close:      append([]float64(nil), values...),
+		firstBlock: block,
+		lastBlock:  block,
+	}
+}
+
+// merge merges the rates of given block into the period. The open and close
+// values are replaced by the rates of blocks before the first block or after
+// the last block of the period.
+func (p *ohlcPeriod) merge(block int64, values []float64) {
+	for i, value := range values {
+		if value > p.high[i] {
+			p.high[i] = value
+		}
+		if value < p.low[i] {
+			p.low[i] = value
+		}
+	}
+	if block < p.firstBlock {
+		p.open = append([]float64(nil), values...)
+		p.firstBlock = block
+	}
+	if block >= p.lastBlock {
+		p.close = append([]float64(nil), values...)
+		p.lastBlock = block
+	}
+}
+
+// periodKey identifies the downsampled period of a pair of a reserve.
+type periodKey struct {
+	reserve string
+	pair    string
+	start   time.Time
+}
+
+// mergeHourly merges given rates, which are out of raw retention and can not
+// be written as raw rates, into their hourly periods.
+func (rs *RateStorage) mergeHourly(rateRecords map[string]common.ReserveRates) error {
+	var (
+		logger = rs.sugar.With(
+			"func", "reserverates/storage/influx/RateStorage.mergeHourly",
+			"reserves", len(rateRecords),
+		)
+		periods  = make(map[periodKey]*ohlcPeriod)
+		from, to time.Time
+	)
+	for _, rateRecord := range rateRecords {
+		start := rateRecord.Timestamp.UTC().Truncate(hourlyTier.interval)
+		if from.IsZero() || start.Before(from) {
+			from = start
+		}
+		if start.After(to) {
+			to = start
+		}
+	}
+	to = to.Add(hourlyTier.interval)
+
+	var columns []string
+	for _, field := range rateFields {
+		for _, ohlc := range []string{"open", "high", "low", "close"} {
+			columns = append(columns, field.String()+"_"+ohlc)
+		}
+	}
+	columns = append(columns, blockField, firstBlockField)
+	res, err := rs.query(fmt.Sprintf(`SELECT "%s" FROM %s WHERE time >= %d%s AND time < %d%s GROUP BY "%s", "%s"`,
+		strings.Join(columns, `", "`), hourlyTier.measurement,
+		timeutil.TimeToTimestampMs(from), timePrecision,
+		timeutil.TimeToTimestampMs(to), timePrecision,
+		schema.Reserve, schema.Pair))
+	if err != nil {
+		return err
+	}
+	if len(res) != 0 {
+		for _, series := range res[0].Series {
+			for _, row := range series.Values {
+				ms, err := influxdb.GetInt64FromInterface(row[0])
+				if err != nil {
+					return err
+				}
+				p := &ohlcPeriod{}
+				for i := range rateFields {
+					var values [4]float64
+					for j := range values {
+						if values[j], err = influxdb.GetFloat64FromInterface(row[1+4*i+j]); err != nil {
+							return err
+						}
+					}
+					p.open = append(p.open, values[0])
+					p.high = append(p.high, values[1])
+					p.low = append(p.low, values[2])
+					p.close = append(p.close, values[3])
+				}
+				// the periods downsampled before the first block field is
+				// introduced keep their open values
+				blockIdx := 1 + 4*len(rateFields)
+				if row[blockIdx] != nil {
+					if p.lastBlock, err = influxdb.GetInt64FromInterface(row[blockIdx]); err != nil {
+						return err
+					}
+				}
+				if row[blockIdx+1] != nil {
+					if p.firstBlock, err = influxdb.GetInt64FromInterface(row[blockIdx+1]); err != nil {
+						return err
+					}
+				}
+				periods[periodKey{
+					reserve: series.Tags[schema.Reserve.String()],
+					pair:    series.Tags[schema.Pair.String()],
+					start:   timeutil.TimestampMsToTime(uint64(ms)).UTC(),
+				}] = p
+			}
+		}
+	}
+
+	bp, err := influxClient.NewBatchPoints(influxClient.BatchPointsConfig{
+		Database:        rs.dbName,
+		RetentionPolicy: downsampledRetentionPolicy,
+		Precision:       timePrecision,
+	})
+	if err != nil {
+		return err
+	}
+	for rsvAddr, rateRecord := range rateRecords {
+		block := int64(rateRecord.BlockNumber)
+		for pair, rate := range rateRecord.Data {
+			values := []float64{rate.BuyReserveRate, rate.SellReserveRate, rate.BuySanityRate, rate.SellSanityRate}
+			key := periodKey{
+				reserve: rsvAddr,
+				pair:    pair,
+				start:   rateRecord.Timestamp.UTC().Truncate(hourlyTier.interval),
+			}
+			p, ok := periods[key]
+			if !ok {
+				p = newOHLCPeriod(block, values)
+			} else {
+				p.merge(block, values)
+			}
+
+			fields := map[string]interface{}{
+				blockField:      p.lastBlock,
+				firstBlockField: p.firstBlock,
+			}
+			for i, field := range rateFields {
+				fields[field.String()+"_open"] = p.open[i]
+				fields[field.String()+"_high"] = p.high[i]
+				fields[field.String()+"_low"] = p.low[i]
+				fields[field.String()+"_close"] = p.close[i]
+			}
+			pt, err := influxClient.NewPoint(hourlyTier.name, map[string]string{
+				schema.Reserve.String(): key.reserve,
+				schema.Pair.String():    key.pair,
+			}, fields, key.start)
+			if err != nil {
+				return err
+			}
+			// the latest values of the period are written as the points
+			// of same series and time replace each other
+			periods[key] = p
+			bp.AddPoint(pt)
+		}
+	}
+	logger.Debugw("merging rates out of raw retention into hourly rates", "from", from, "to", to, "points", len(bp.Points()))
+	return rs.client.Write(bp)
+}
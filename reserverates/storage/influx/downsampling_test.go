@@ -0,0 +1,57 @@
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownsampleStatement(t *testing.T) {
+	from := time.Date(2018, 10, 8, 0, 0, 0, 0, time.UTC)
+	stmt := dailyTier.downsampleStatement(from, from.Add(24*time.Hour))
+
+	assert.Contains(t, stmt, `FIRST("buy_rate_open") AS "buy_rate_open", MAX("buy_rate_high") AS "buy_rate_high"`)
+	assert.Contains(t, stmt, `LAST("block") AS "block", FIRST("first_block") AS "first_block"`)
+	assert.Contains(t, stmt, `INTO "downsampled"."reserve_rate_1d" FROM "downsampled"."reserve_rate_1h"`)
+	assert.Contains(t, stmt, `WHERE time >= '2018-10-08T00:00:00Z' AND time < '2018-10-09T00:00:00Z' GROUP BY "reserve", "pair", time(1d)`)
+
+	stmt = hourlyTier.downsampleStatement(from, from.Add(24*time.Hour))
+	assert.Contains(t, stmt, `MIN("sell_rate") AS "sell_rate_low", LAST("sell_rate") AS "sell_rate_close"`)
+	assert.Contains(t, stmt, `INTO "downsampled"."reserve_rate_1h" FROM "autogen"."reserve_rate"`)
+	assert.Contains(t, stmt, `LAST("block") AS "block", FIRST("block") AS "first_block"`)
+	assert.Contains(t, stmt, `time(1h)`)
+}
+
+func TestOHLCPeriodMerge(t *testing.T) {
+	p := newOHLCPeriod(100, []float64{1, 2, 3, 4})
+
+	p.merge(90, []float64{2, 1, 3, 4})
+	p.merge(110, []float64{0.5, 3, 3, 4})
+	p.merge(105, []float64{5, 2, 3, 4})
+	assert.Equal(t, &ohlcPeriod{
+		open:       []float64{2, 1, 3, 4},
+		high:       []float64{5, 3, 3, 4},
+		low:        []float64{0.5, 1, 3, 4},
+		close:      []float64{0.5, 3, 3, 4},
+		firstBlock: 90,
+		lastBlock:  110,
+	}, p)
+}
+
+func TestRateStorageTier(t *testing.T) {
+	var (
+		now = time.Now()
+		rs  = &RateStorage{rawRetention: 30 * 24 * time.Hour}
+	)
+
+	assert.Nil(t, rs.tier(now.Add(-time.Hour), now))
+	assert.Equal(t, &hourlyTier, rs.tier(now.Add(-8*24*time.Hour), now))
+	assert.Equal(t, &dailyTier, rs.tier(now.Add(-91*24*time.Hour), now))
+	// short time ranges out of raw retention are returned from hourly rates
+	assert.Equal(t, &hourlyTier, rs.tier(now.Add(-31*24*time.Hour), now.Add(-30*24*time.Hour)))
+
+	// raw rates are returned for short time ranges if they are kept forever
+	rs.rawRetention = 0
+	assert.Nil(t, rs.tier(now.Add(-365*24*time.Hour), now.Add(-364*24*time.Hour)))
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"strconv"
 	"text/template"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -24,15 +25,35 @@ const (
 	timePrecision = "ms"
 )
 
-// RateStorage is the implementation of influxclient to serve as ReserveRate storage
+// RateStorage is the implementation of influxclient to serve as ReserveRate storage.
+// The raw rates are written to the default retention policy, which keeps them
+// for the configured raw retention. They are downsampled on write to hourly and
+// daily OHLC rates, which are kept forever. The rates out of raw retention, for
+// example written by backfilling, are merged into the downsampled rates only.
 type RateStorage struct {
 	sugar  *zap.SugaredLogger
 	client influxClient.Client
 	dbName string
+	// rawRetention is how long the raw rates are kept, 0 to keep forever.
+	rawRetention time.Duration
 }
 
-// NewRateInfluxDBStorage return an instance of influx client to store ReserveRate
-func NewRateInfluxDBStorage(sugar *zap.SugaredLogger, client influxClient.Client, dbName string) (*RateStorage, error) {
+// RateStorageOption configures the optional parameters of RateStorage.
+type RateStorageOption func(*RateStorage)
+
+// WithRawRetention sets how long the raw rates are kept, they are kept forever
+// by default. The downsampled rates are always kept forever. The retention
+// is applied to the database by Migrate.
+func WithRawRetention(retention time.Duration) RateStorageOption {
+	return func(rs *RateStorage) {
+		rs.rawRetention = retention
+	}
+}
+
+// NewRateInfluxDBStorage return an instance of influx client to store ReserveRate.
+// It creates the database and the retention policy of downsampled rates, the
+// existing raw rates are prepared for downsampling by Migrate.
+func NewRateInfluxDBStorage(sugar *zap.SugaredLogger, client influxClient.Client, dbName string, options ...RateStorageOption) (*RateStorage, error) {
 	q := influxClient.NewQuery("CREATE DATABASE "+dbName, "", timePrecision)
 	response, err := client.Query(q)
 	if err != nil {
@@ -41,11 +62,23 @@ func NewRateInfluxDBStorage(sugar *zap.SugaredLogger, client influxClient.Client
 	if response.Error() != nil {
 		return nil, response.Error()
 	}
-	return &RateStorage{sugar: sugar, client: client, dbName: dbName}, nil
+
+	rs := &RateStorage{sugar: sugar, client: client, dbName: dbName}
+	for _, option := range options {
+		option(rs)
+	}
+
+	if err = influxdb.EnsureRetentionPolicy(sugar, client, dbName, influxdb.RetentionPolicy{
+		Name: downsampledRetentionPolicy,
+	}); err != nil {
+		return nil, err
+	}
+	return rs, nil
 }
 
 // UpdateRatesRecords update all the rate records from different reserve to influxDB in one go.
 // It take a map[reserveAddress] ReserveRates and return error if occurs.
+// Only the downsampled periods containing the given rates are downsampled again.
 func (rs *RateStorage) UpdateRatesRecords(rateRecords map[string]common.ReserveRates) error {
 	bp, err := influxClient.NewBatchPoints(
 		influxClient.BatchPointsConfig{
@@ -57,7 +90,21 @@ func (rs *RateStorage) UpdateRatesRecords(rateRecords map[string]common.ReserveR
 		return err
 	}
 
+	var (
+		rawTimes, allTimes []time.Time
+		outdated           = make(map[string]common.ReserveRates)
+	)
 	for rsvAddr, rateRecord := range rateRecords {
+		allTimes = append(allTimes, rateRecord.Timestamp)
+		// InfluxDB drops the points out of retention, the rates of periods
+		// which raw rates may be partly dropped are merged into the hourly
+		// rates instead of downsampled from raw rates
+		if rs.rawRetention != 0 && rateRecord.Timestamp.Truncate(hourlyTier.interval).Before(time.Now().Add(-rs.rawRetention)) {
+			outdated[rsvAddr] = rateRecord
+			continue
+		}
+		rawTimes = append(rawTimes, rateRecord.Timestamp)
+
 		for pair, rate := range rateRecord.Data {
 			tags := map[string]string{
 				schema.Reserve.String():     rsvAddr,
@@ -69,6 +116,8 @@ func (rs *RateStorage) UpdateRatesRecords(rateRecords map[string]common.ReserveR
 				schema.SellRate.String():       rate.SellReserveRate,
 				schema.BuySanityRate.String():  rate.BuySanityRate,
 				schema.SellSanityRate.String(): rate.SellSanityRate,
+				// block number is also stored as field to be aggregated by downsampling
+				blockField: int64(rateRecord.BlockNumber),
 			}
 			pt, err := influxClient.NewPoint(RateTableName, tags, fields, rateRecord.Timestamp)
 			if err != nil {
@@ -77,11 +126,32 @@ func (rs *RateStorage) UpdateRatesRecords(rateRecords map[string]common.ReserveR
 			bp.AddPoint(pt)
 		}
 	}
-	return rs.client.Write(bp)
+	if len(rawTimes) != 0 {
+		if err = rs.client.Write(bp); err != nil {
+			return err
+		}
+		if err = rs.downsamplePeriods(rawTimes, hourlyTier); err != nil {
+			return err
+		}
+	}
+	if len(outdated) != 0 {
+		if err = rs.mergeHourly(outdated); err != nil {
+			return err
+		}
+	}
+	return rs.downsamplePeriods(allTimes, dailyTier)
 }
 
-// GetRatesByTimePoint returns all the rate record in a period of time of a reserve
+// GetRatesByTimePoint returns all the rate record in a period of time of a reserve.
+// The raw rates are returned for short time ranges in raw retention, otherwise
+// the close rates of hourly or daily downsampled rates that start in the time
+// range are returned, keyed by the last block of each period.
 func (rs *RateStorage) GetRatesByTimePoint(addrs []ethereum.Address, fromTime, toTime uint64) (map[string]map[uint64]common.ReserveRates, error) {
+	tier := rs.tier(timeutil.TimestampMsToTime(fromTime), timeutil.TimestampMsToTime(toTime))
+	if tier != nil {
+		return rs.getDownsampledRates(*tier, addrs, fromTime, toTime)
+	}
+
 	const queryTmpl = `SELECT * FROM "{{.TableName}}" WHERE {{.FromTime }}{{.TimePrecision}} <= time AND time <= {{.ToTime}}{{.TimePrecision}} ` +
 		`{{if len .Addrs}}AND ({{range $index, $element := .Addrs}}"reserve" = '{{$element}}'{{if ne $index $.AddrsLastIndex}} OR {{end}}{{end}}){{end}}`
 	var (
@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"sync"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
+	"github.com/KyberNetwork/reserve-stats/reserverates/common"
+)
+
+// RateStorage stores reserve rates in memory, it is meant for development and
+// tests, where no database is available.
+type RateStorage struct {
+	sugar *zap.SugaredLogger
+
+	mu *sync.RWMutex
+	// rates stores the rates of all pairs: reserve address --> block number --> rates
+	rates map[string]map[uint64]common.ReserveRates
+}
+
+// NewRateMemoryStorage returns an empty in memory storage of ReserveRate.
+func NewRateMemoryStorage(sugar *zap.SugaredLogger) *RateStorage {
+	return &RateStorage{
+		sugar: sugar,
+		mu:    &sync.RWMutex{},
+		rates: make(map[string]map[uint64]common.ReserveRates),
+	}
+}
+
+// UpdateRatesRecords stores the rate records of different reserves.
+// It take a map[reserveAddress] ReserveRates. The rates of pairs of the same
+// reserve and block are merged with the stored ones.
+func (rs *RateStorage) UpdateRatesRecords(rateRecords map[string]common.ReserveRates) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for rsvAddr, rateRecord := range rateRecords {
+		if _, ok := rs.rates[rsvAddr]; !ok {
+			rs.rates[rsvAddr] = make(map[uint64]common.ReserveRates)
+		}
+		stored, ok := rs.rates[rsvAddr][rateRecord.BlockNumber]
+		if !ok {
+			stored = common.ReserveRates{
+				BlockNumber: rateRecord.BlockNumber,
+				Data:        make(map[string]common.ReserveRateEntry),
+				Reserve:     rsvAddr,
+			}
+		}
+		stored.Timestamp = rateRecord.Timestamp
+		for pair, rate := range rateRecord.Data {
+			stored.Data[pair] = rate
+		}
+		rs.rates[rsvAddr][rateRecord.BlockNumber] = stored
+	}
+	return nil
+}
+
+// GetRatesByTimePoint returns all the rate records in a period of time of given
+// reserves, all reserves are returned if no reserve address is given.
+func (rs *RateStorage) GetRatesByTimePoint(addrs []ethereum.Address, fromTime, toTime uint64) (map[string]map[uint64]common.ReserveRates, error) {
+	logger := rs.sugar.With(
+		"func", "reserverates/storage/memory/RateStorage.GetRatesByTimePoint",
+		"reserves", len(addrs),
+		"from", fromTime,
+		"to", toTime,
+	)
+
+	reserves := make(map[string]bool)
+	for _, rsvAddr := range addrs {
+		reserves[rsvAddr.Hex()] = true
+	}
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	result := make(map[string]map[uint64]common.ReserveRates)
+	for rsvAddr, rates := range rs.rates {
+		if len(reserves) != 0 && !reserves[rsvAddr] {
+			continue
+		}
+		for blockNumber, rate := range rates {
+			ts := timeutil.TimeToTimestampMs(rate.Timestamp)
+			if ts < fromTime || ts > toTime {
+				continue
+			}
+			if _, ok := result[rsvAddr]; !ok {
+				result[rsvAddr] = make(map[uint64]common.ReserveRates)
+			}
+			data := make(map[string]common.ReserveRateEntry, len(rate.Data))
+			for pair, entry := range rate.Data {
+				data[pair] = entry
+			}
+			rate.Data = data
+			result[rsvAddr][blockNumber] = rate
+		}
+	}
+
+	logger.Debugw("got rates from memory", "result_reserves", len(result))
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
+	"github.com/KyberNetwork/reserve-stats/reserverates/common"
+)
+
+func TestRateMemoryStorage(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	var (
+		rs       = NewRateMemoryStorage(sugar)
+		ts       = time.Date(2018, 10, 8, 12, 0, 0, 0, time.UTC)
+		reserve1 = ethereum.HexToAddress("0x63825c174ab367968EC60f061753D3bbD36A0D8F")
+		reserve2 = ethereum.HexToAddress("0x21433Dec9Cb634A23c6A4BbcCe08c83f5aC2EC18")
+		kncEntry = common.ReserveRateEntry{BuyReserveRate: 1, SellReserveRate: 2, BuySanityRate: 3, SellSanityRate: 4}
+		omgEntry = common.ReserveRateEntry{BuyReserveRate: 5, SellReserveRate: 6, BuySanityRate: 7, SellSanityRate: 8}
+	)
+
+	// the pairs of the same reserve and block are merged
+	assert.NoError(t, rs.UpdateRatesRecords(map[string]common.ReserveRates{
+		reserve1.Hex(): {Timestamp: ts, BlockNumber: 100, Data: map[string]common.ReserveRateEntry{"ETH-KNC": kncEntry}},
+		reserve2.Hex(): {Timestamp: ts.Add(time.Hour), BlockNumber: 340, Data: map[string]common.ReserveRateEntry{"ETH-KNC": kncEntry}},
+	}))
+	assert.NoError(t, rs.UpdateRatesRecords(map[string]common.ReserveRates{
+		reserve1.Hex(): {Timestamp: ts, BlockNumber: 100, Data: map[string]common.ReserveRateEntry{"ETH-OMG": omgEntry}},
+	}))
+
+	fromTime := timeutil.TimeToTimestampMs(ts)
+	rates, err := rs.GetRatesByTimePoint([]ethereum.Address{reserve1}, fromTime, fromTime)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[uint64]common.ReserveRates{
+		reserve1.Hex(): {
+			100: {
+				Timestamp:   ts,
+				BlockNumber: 100,
+				Data:        map[string]common.ReserveRateEntry{"ETH-KNC": kncEntry, "ETH-OMG": omgEntry},
+				Reserve:     reserve1.Hex(),
+			},
+		},
+	}, rates)
+
+	rates, err = rs.GetRatesByTimePoint(nil, fromTime, timeutil.TimeToTimestampMs(ts.Add(time.Hour)))
+	assert.NoError(t, err)
+	assert.Len(t, rates, 2)
+
+	rates, err = rs.GetRatesByTimePoint(nil, fromTime+1, fromTime+1000)
+	assert.NoError(t, err)
+	assert.Nil(t, rates)
+}
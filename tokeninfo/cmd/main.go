@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/KyberNetwork/reserve-stats/lib/app"
+	"github.com/KyberNetwork/reserve-stats/lib/deployment"
 	"github.com/KyberNetwork/reserve-stats/tokeninfo"
 	"github.com/urfave/cli"
 )
@@ -28,6 +29,7 @@ func main() {
 			Aliases: []string{"r"},
 			Usage:   "report which reserves provides which token",
 			Action:  reserve,
+			Flags:   deployment.NewCliFlags(),
 		},
 	}
 
@@ -58,9 +60,15 @@ func reserve(c *cli.Context) error {
 
 	sugar := logger.Sugar()
 
+	registry, err := deployment.NewRegistryFromContext(c)
+	if err != nil {
+		return err
+	}
+
 	f, err := tokeninfo.NewReserveCrawler(
 		sugar,
-		c.GlobalString(nodeURLFlag))
+		c.GlobalString(nodeURLFlag),
+		registry)
 	if err != nil {
 		return err
 	}
@@ -75,5 +83,5 @@ func reserve(c *cli.Context) error {
 		return err
 	}
 
-	return json.NewDecoder(output).Decode(result)
+	return json.NewEncoder(output).Encode(result)
 }
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"math/big"
 
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
 	"github.com/KyberNetwork/reserve-stats/lib/contracts"
+	"github.com/KyberNetwork/reserve-stats/lib/deployment"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"go.uber.org/zap"
@@ -41,19 +43,21 @@ type ReserveInfo struct {
 
 // ReserveCrawler gets the tokeninfo reserve mapping information from blockchain.
 type ReserveCrawler struct {
-	sugar  *zap.SugaredLogger
-	client *ethclient.Client
+	sugar    *zap.SugaredLogger
+	client   *ethclient.Client
+	registry *deployment.Registry
 }
 
 // NewReserveCrawler creates a new ReserveCrawler instance.
-func NewReserveCrawler(sugar *zap.SugaredLogger, nodeURL string) (*ReserveCrawler, error) {
-	client, err := ethclient.Dial(nodeURL)
+func NewReserveCrawler(sugar *zap.SugaredLogger, nodeURL string, registry *deployment.Registry) (*ReserveCrawler, error) {
+	client, err := blockchain.Dial(nodeURL)
 	if err != nil {
 		return nil, err
 	}
 	return &ReserveCrawler{
-		sugar:  sugar,
-		client: client,
+		sugar:    sugar,
+		client:   client,
+		registry: registry,
 	}, nil
 }
 
@@ -69,9 +73,12 @@ func (f *ReserveCrawler) Fetch() (map[string][]*ReserveInfo, error) {
 		return nil, err
 	}
 
-	internalNetworkClient, err := contracts.NewInternalNetwork(
-		common.HexToAddress(contracts.InternalNetworkContractAddress),
-		f.client)
+	internalNetworkAddr, err := f.registry.Address(deployment.InternalNetworkContract, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	internalNetworkClient, err := contracts.NewInternalNetwork(internalNetworkAddr, f.client)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,151 @@
+package checkpoint
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/boltutil"
+)
+
+const (
+	checkpointBucket = "checkpoint"
+	lastBlockKey     = "last_block"
+	// blocksBucket stores the processed blocks: block number --> block hash + timestamp.
+	blocksBucket = "blocks"
+	// maxStoredBlocks is the maximum number of processed blocks to keep for
+	// reorg detection, older blocks are purged.
+	maxStoredBlocks = 1024
+)
+
+// BoltStorage is the BoltDB implementation of checkpoint storage.
+type BoltStorage struct {
+	sugar *zap.SugaredLogger
+	db    *bolt.DB
+}
+
+// NewBoltStorage opens the BoltDB file at given path and creates the buckets
+// required to store crawler progress.
+func NewBoltStorage(sugar *zap.SugaredLogger, path string) (*BoltStorage, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{checkpointBucket, blocksBucket} {
+			if _, cErr := tx.CreateBucketIfNotExists([]byte(bucket)); cErr != nil {
+				return cErr
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &BoltStorage{sugar: sugar, db: db}, nil
+}
+
+// LastBlock returns the last fully processed block number.
+func (bs *BoltStorage) LastBlock() (uint64, error) {
+	var block uint64
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(checkpointBucket))
+		block = boltutil.BytesToUint64(b.Get([]byte(lastBlockKey)))
+		return nil
+	})
+	return block, err
+}
+
+func encodeBlock(block Block) []byte {
+	return append(block.Hash.Bytes(), boltutil.Uint64ToBytes(uint64(block.Timestamp.Unix()))...)
+}
+
+func decodeBlock(number uint64, v []byte) (Block, error) {
+	if len(v) != ethereum.HashLength+8 {
+		return Block{}, fmt.Errorf("invalid stored block %d", number)
+	}
+	return Block{
+		Number:    number,
+		Hash:      ethereum.BytesToHash(v[:ethereum.HashLength]),
+		Timestamp: time.Unix(int64(boltutil.BytesToUint64(v[ethereum.HashLength:])), 0).UTC(),
+	}, nil
+}
+
+// SaveLastBlock persists the given block as the last fully processed block.
+// Only the latest maxStoredBlocks processed blocks are kept.
+func (bs *BoltStorage) SaveLastBlock(block Block) error {
+	bs.sugar.Debugw("saving checkpoint",
+		"last_block", block.Number,
+		"hash", block.Hash.Hex())
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte(checkpointBucket)).Put(
+			[]byte(lastBlockKey),
+			boltutil.Uint64ToBytes(block.Number)); err != nil {
+			return err
+		}
+
+		b := tx.Bucket([]byte(blocksBucket))
+		if err := b.Put(boltutil.Uint64ToBytes(block.Number), encodeBlock(block)); err != nil {
+			return err
+		}
+
+		var stored int
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			stored++
+		}
+		for k, _ := c.First(); k != nil && stored > maxStoredBlocks; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			stored--
+		}
+		return nil
+	})
+}
+
+// Blocks returns the recently processed blocks, sorted by block number in ascending order.
+func (bs *BoltStorage) Blocks() ([]Block, error) {
+	var blocks []Block
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(blocksBucket)).ForEach(func(k, v []byte) error {
+			block, err := decodeBlock(boltutil.BytesToUint64(k), v)
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, block)
+			return nil
+		})
+	})
+	return blocks, err
+}
+
+// Rollback resets the last fully processed block to the given block number,
+// discarding all processed blocks after it.
+func (bs *BoltStorage) Rollback(blockNumber uint64) error {
+	bs.sugar.Infow("rolling back checkpoint", "last_block", blockNumber)
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte(checkpointBucket)).Put(
+			[]byte(lastBlockKey),
+			boltutil.Uint64ToBytes(blockNumber)); err != nil {
+			return err
+		}
+
+		c := tx.Bucket([]byte(blocksBucket)).Cursor()
+		for k, _ := c.Seek(boltutil.Uint64ToBytes(blockNumber + 1)); k != nil; k, _ = c.Seek(boltutil.Uint64ToBytes(blockNumber + 1)) {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the underlying BoltDB file.
+func (bs *BoltStorage) Close() error {
+	return bs.db.Close()
+}
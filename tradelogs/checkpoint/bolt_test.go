@@ -0,0 +1,101 @@
+package checkpoint
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newTestBoltStorage(t *testing.T) (*BoltStorage, func()) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := NewBoltStorage(sugar, filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bs, func() {
+		assert.NoError(t, bs.Close())
+		assert.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+func testBlock(number uint64) Block {
+	return Block{
+		Number:    number,
+		Hash:      ethereum.BigToHash(big.NewInt(0).SetUint64(number)),
+		Timestamp: time.Unix(int64(1539000000+number*15), 0).UTC(),
+	}
+}
+
+func TestBoltStorageLastBlock(t *testing.T) {
+	bs, tearDown := newTestBoltStorage(t)
+	defer tearDown()
+
+	block, err := bs.LastBlock()
+	assert.NoError(t, err)
+	assert.Zero(t, block, "last block should be zero for a fresh database")
+
+	assert.NoError(t, bs.SaveLastBlock(testBlock(6500000)))
+	block, err = bs.LastBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6500000), block)
+
+	assert.NoError(t, bs.SaveLastBlock(testBlock(6500100)))
+	block, err = bs.LastBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6500100), block)
+
+	blocks, err := bs.Blocks()
+	assert.NoError(t, err)
+	assert.Equal(t, []Block{testBlock(6500000), testBlock(6500100)}, blocks)
+}
+
+func TestBoltStorageRollback(t *testing.T) {
+	bs, tearDown := newTestBoltStorage(t)
+	defer tearDown()
+
+	for _, number := range []uint64{100, 200, 300, 400} {
+		assert.NoError(t, bs.SaveLastBlock(testBlock(number)))
+	}
+
+	assert.NoError(t, bs.Rollback(200))
+
+	block, err := bs.LastBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(200), block)
+
+	blocks, err := bs.Blocks()
+	assert.NoError(t, err)
+	assert.Equal(t, []Block{testBlock(100), testBlock(200)}, blocks)
+}
+
+func TestBoltStoragePurgeOldBlocks(t *testing.T) {
+	bs, tearDown := newTestBoltStorage(t)
+	defer tearDown()
+
+	for number := uint64(1); number <= maxStoredBlocks+10; number++ {
+		assert.NoError(t, bs.SaveLastBlock(testBlock(number)))
+	}
+
+	blocks, err := bs.Blocks()
+	assert.NoError(t, err)
+	assert.Len(t, blocks, maxStoredBlocks)
+	assert.Equal(t, uint64(11), blocks[0].Number)
+}
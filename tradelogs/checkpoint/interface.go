@@ -0,0 +1,28 @@
+package checkpoint
+
+import (
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// Block is the identity of a processed block, used to detect chain reorganisation.
+type Block struct {
+	Number    uint64
+	Hash      ethereum.Hash
+	Timestamp time.Time
+}
+
+// Interface represents a persistent storage of trade logs crawler progress.
+type Interface interface {
+	// LastBlock returns the last fully processed block number.
+	// It returns 0 if no block has been processed yet.
+	LastBlock() (uint64, error)
+	// SaveLastBlock persists the given block as the last fully processed block.
+	SaveLastBlock(block Block) error
+	// Blocks returns the recently processed blocks, sorted by block number in ascending order.
+	Blocks() ([]Block, error)
+	// Rollback resets the last fully processed block to the given block number,
+	// discarding all processed blocks after it.
+	Rollback(blockNumber uint64) error
+}
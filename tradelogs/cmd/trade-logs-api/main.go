@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/lib/deployment"
 	"github.com/KyberNetwork/reserve-stats/lib/httputil"
 	"log"
 	"os"
@@ -28,6 +29,11 @@ func main() {
 
 		sugar := logger.Sugar()
 
+		registry, err := deployment.NewRegistryFromContext(c)
+		if err != nil {
+			return err
+		}
+
 		coreClient, err := core.NewClientFromContext(sugar, c)
 		if err != nil {
 			return err
@@ -38,17 +44,16 @@ func main() {
 			return err
 		}
 
-		influxStorage, err := storage.NewInfluxStorage(
-			sugar,
-			"trade_logs",
-			influxClient,
-			coreCachedClient,
-		)
+		tokens, err := registry.TokenAddresses()
+		if err != nil {
+			return err
+		}
+		st, err := storage.NewStorageFromContext(c, sugar, influxClient, coreCachedClient, tokens)
 		if err != nil {
 			return err
 		}
 
-		api := http.NewServer(influxStorage, httputil.NewHTTPAddressFromContext(c), sugar, coreCachedClient)
+		api := http.NewServer(st, httputil.NewHTTPAddressFromContext(c), sugar, coreCachedClient)
 		err = api.Start()
 		if err != nil {
 			return err
@@ -59,7 +64,9 @@ func main() {
 
 	app.Flags = append(app.Flags, httputil.NewHTTPCliFlags(httputil.TradeLogsPort)...)
 	app.Flags = append(app.Flags, influxdb.NewCliFlags()...)
+	app.Flags = append(app.Flags, storage.NewCliFlags()...)
 	app.Flags = append(app.Flags, core.NewCliFlags()...)
+	app.Flags = append(app.Flags, deployment.NewCliFlags()...)
 
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/tradelogs"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/checkpoint"
+)
+
+var headLag = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "trade_logs_crawler_head_lag_blocks",
+	Help: "Number of blocks between the chain head and the last processed block.",
+})
+
+func init() {
+	prometheus.MustRegister(headLag)
+}
+
+// setHeadLag records the lag of last processed block behind chain head.
+func setHeadLag(head, lastBlock uint64) {
+	if lastBlock > head {
+		headLag.Set(0)
+		return
+	}
+	headLag.Set(float64(head - lastBlock))
+}
+
+// daemon keeps crawling trade logs in fixed size block windows, following
+// the chain head. The last fully processed block is persisted after every
+// window so a restarted daemon resumes exactly where it stopped.
+// Only blocks with enough confirmations are crawled, the processed blocks
+// are checked against the canonical chain before every window to roll back
+// trade logs of blocks invalidated by chain reorganisation.
+type daemon struct {
+	sugar         *zap.SugaredLogger
+	worker        *worker
+	ethClient     blockchain.HeaderReader
+	checkpoint    checkpoint.Interface
+	reorgHandler  *tradelogs.ReorgHandler
+	startBlock    uint64
+	blockWindow   uint64
+	confirmations uint64
+	pollInterval  time.Duration
+}
+
+// run processes block windows until an unrecoverable error occurs.
+// Errors while crawling a window are logged and the window is retried
+// after poll interval, the checkpoint is only advanced on success.
+func (d *daemon) run() error {
+	logger := d.sugar.With(
+		"func", "tradelogs/cmd/trade-logs-crawler/daemon.run",
+		"block_window", d.blockWindow,
+		"confirmations", d.confirmations,
+		"poll_interval", d.pollInterval,
+	)
+
+	if err := d.checkStart(); err != nil {
+		return err
+	}
+
+	logger.Info("starting trade logs crawler daemon")
+	for {
+		caughtUp, err := d.step()
+		if err == tradelogs.ErrReorgTooDeep {
+			return err
+		}
+		if err != nil {
+			logger.Errorw("failed to process block window", "err", err)
+			time.Sleep(d.pollInterval)
+			continue
+		}
+
+		if caughtUp {
+			time.Sleep(d.pollInterval)
+		}
+	}
+}
+
+// checkStart validates that the daemon knows where to start crawling, from
+// the checkpoint or from block.
+func (d *daemon) checkStart() error {
+	lastBlock, err := d.checkpoint.LastBlock()
+	if err != nil {
+		return err
+	}
+
+	if lastBlock == 0 && d.startBlock == 0 {
+		return errors.New("no checkpoint found, from block is required to start daemon")
+	}
+
+	if lastBlock != 0 && d.startBlock != 0 {
+		d.sugar.Warnw("checkpoint found, ignoring from block",
+			"last_block", lastBlock,
+			"from_block", d.startBlock)
+	}
+	return nil
+}
+
+// lastBlock returns the last processed block, or the block before start
+// block if nothing is processed yet.
+func (d *daemon) lastBlock() (uint64, error) {
+	lastBlock, err := d.checkpoint.LastBlock()
+	if err != nil {
+		return 0, err
+	}
+	if lastBlock == 0 {
+		return d.startBlock - 1, nil
+	}
+	return lastBlock, nil
+}
+
+// step processes the next block window. It returns true if there is no more
+// block to process until a new block is mined.
+func (d *daemon) step() (bool, error) {
+	if _, err := d.reorgHandler.Handle(); err != nil {
+		return false, err
+	}
+
+	lastBlock, err := d.lastBlock()
+	if err != nil {
+		return false, err
+	}
+
+	fromBlock := lastBlock + 1
+
+	currentBlock, err := d.confirmedBlock()
+	if err != nil {
+		return false, err
+	}
+
+	if fromBlock > currentBlock {
+		setHeadLag(currentBlock+d.confirmations, lastBlock)
+		d.sugar.Debugw("waiting for new blocks",
+			"from_block", fromBlock,
+			"current_block", currentBlock)
+		return true, nil
+	}
+
+	toBlock := fromBlock + d.blockWindow - 1
+	if toBlock > currentBlock {
+		toBlock = currentBlock
+	}
+
+	// the identity of last block in window is read before crawling, so if a
+	// reorg happens while crawling, it will be detected in next iteration.
+	block, err := d.reorgHandler.Block(toBlock)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err = d.worker.processBlocks(
+		big.NewInt(0).SetUint64(fromBlock),
+		big.NewInt(0).SetUint64(toBlock),
+	); err != nil {
+		return false, err
+	}
+
+	if err = d.checkpoint.SaveLastBlock(block); err != nil {
+		return false, err
+	}
+
+	setHeadLag(currentBlock+d.confirmations, toBlock)
+	return toBlock == currentBlock, nil
+}
+
+// confirmedBlock returns the latest block number of the chain that has
+// enough confirmations.
+func (d *daemon) confirmedBlock() (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.worker.timeout)
+	defer cancel()
+
+	// parity and geth are not compatible in mix hash, the header is still usable
+	// when error is returned together with a non nil header
+	header, err := d.ethClient.HeaderByNumber(ctx, nil)
+	if err != nil && header == nil {
+		return 0, err
+	}
+
+	currentBlock := header.Number.Uint64()
+	if currentBlock < d.confirmations {
+		return 0, nil
+	}
+	return currentBlock - d.confirmations, nil
+}
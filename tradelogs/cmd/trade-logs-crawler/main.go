@@ -2,26 +2,29 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
 	"log"
 	"math/big"
 	"os"
 	"time"
 
-	"github.com/go-ozzo/ozzo-validation"
-	"github.com/go-ozzo/ozzo-validation/is"
 	"github.com/urfave/cli"
 
 	libapp "github.com/KyberNetwork/reserve-stats/lib/app"
+	"github.com/KyberNetwork/reserve-stats/lib/backfill"
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
 	"github.com/KyberNetwork/reserve-stats/lib/broadcast"
 	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/lib/deployment"
 	"github.com/KyberNetwork/reserve-stats/lib/influxdb"
+	"github.com/KyberNetwork/reserve-stats/lib/metrics"
 	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
 	"github.com/KyberNetwork/reserve-stats/tradelogs"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/checkpoint"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
 	"github.com/KyberNetwork/reserve-stats/tradelogs/storage"
-	"github.com/KyberNetwork/tokenrate/coingecko"
+	"github.com/influxdata/influxdb/client/v2"
+	"go.uber.org/zap"
 )
 
 const (
@@ -29,6 +32,38 @@ const (
 	nodeURLDefaultValue = "https://mainnet.infura.io"
 	fromBlockFlag       = "from-block"
 	toBlockFlag         = "to-block"
+	fromTimeFlag        = "from-time"
+	toTimeFlag          = "to-time"
+
+	daemonFlag                = "daemon"
+	blockWindowFlag           = "block-window"
+	blockWindowDefaultValue   = 100
+	pollIntervalFlag          = "poll-interval"
+	pollIntervalDefaultValue  = 15 * time.Second
+	checkpointDBFlag          = "checkpoint-db"
+	checkpointDBDefaultValue  = "trade-logs-crawler.db"
+	confirmationsFlag         = "confirmations"
+	confirmationsDefaultValue = 12
+	chunkSizeFlag             = "chunk-size"
+	maxWorkersFlag            = "max-workers"
+	fetchTimeoutFlag          = "fetch-timeout"
+	fetchTimeoutDefaultValue  = 5 * time.Second
+	enrichGeoFlag             = "enrich-geo"
+	enrichFiatRateFlag        = "enrich-fiat-rate"
+	enrichReceiptsFlag        = "enrich-receipts"
+	labelsFileFlag            = "labels-file"
+	streamNodeFlag            = "stream-node"
+
+	countWindowFlag = "count-window"
+	repairFlag      = "repair"
+
+	verifyWindowFlag         = "window"
+	verifyWindowDefaultValue = 1000
+	samplesFlag              = "samples"
+	amountToleranceFlag      = "amount-tolerance"
+
+	backfillJobDBDefaultValue   = "trade-logs-backfill.db"
+	backfillJobSizeDefaultValue = 10000
 )
 
 func main() {
@@ -39,12 +74,6 @@ func main() {
 	app.Action = getTradeLogs
 
 	app.Flags = append(app.Flags,
-		cli.StringFlag{
-			Name:   nodeURLFlag,
-			Usage:  "Ethereum node provider URL",
-			Value:  nodeURLDefaultValue,
-			EnvVar: "NODE",
-		},
 		cli.StringFlag{
 			Name:   fromBlockFlag,
 			Usage:  "Fetch trade logs from block",
@@ -55,27 +84,303 @@ func main() {
 			Usage:  "Fetch trade logs to block",
 			EnvVar: "TO_BLOCK",
 		},
+		cli.StringFlag{
+			Name:   fromTimeFlag,
+			Usage:  "Fetch trade logs from the first block at or after given time, example: 2018-10-01 or 2018-10-01T00:00:00Z",
+			EnvVar: "FROM_TIME",
+		},
+		cli.StringFlag{
+			Name:   toTimeFlag,
+			Usage:  "Fetch trade logs to the last block before given time, example: 2018-10-02 or 2018-10-02T00:00:00Z",
+			EnvVar: "TO_TIME",
+		},
+		cli.BoolFlag{
+			Name:   daemonFlag,
+			Usage:  "Keep following the chain head, resuming from the persisted checkpoint",
+			EnvVar: "DAEMON",
+		},
+		cli.Uint64Flag{
+			Name:   blockWindowFlag,
+			Usage:  "Number of blocks to crawl in each iteration of daemon mode",
+			Value:  blockWindowDefaultValue,
+			EnvVar: "BLOCK_WINDOW",
+		},
+		cli.DurationFlag{
+			Name:   pollIntervalFlag,
+			Usage:  "Waiting duration before polling for new blocks in daemon mode",
+			Value:  pollIntervalDefaultValue,
+			EnvVar: "POLL_INTERVAL",
+		},
+		cli.Uint64Flag{
+			Name:   confirmationsFlag,
+			Usage:  "Number of confirmations required before a block is crawled in daemon mode",
+			Value:  confirmationsDefaultValue,
+			EnvVar: "CONFIRMATIONS",
+		},
+		cli.StringFlag{
+			Name:   checkpointDBFlag,
+			Usage:  "Path to BoltDB file to store the last processed block in daemon mode",
+			Value:  checkpointDBDefaultValue,
+			EnvVar: "CHECKPOINT_DB",
+		},
+		cli.StringFlag{
+			Name:   streamNodeFlag,
+			Usage:  "Websocket or IPC endpoint of Ethereum node, example: ws://localhost:8546. If set, daemon mode streams trade logs using log subscriptions, falling back to polling when the subscription drops",
+			EnvVar: "STREAM_NODE",
+		},
 	)
-	app.Flags = append(app.Flags, influxdb.NewCliFlags()...)
-	app.Flags = append(app.Flags, core.NewCliFlags()...)
-	app.Flags = append(app.Flags, broadcast.NewCliFlags()...)
+	app.Flags = append(app.Flags, newCrawlerFlags()...)
+
+	app.Commands = []cli.Command{
+		{
+			Name:   "backfill",
+			Usage:  "Crawl trade logs of a block or date range in jobs, an interrupted run is resumed from the job store",
+			Action: backfillTradeLogs,
+			Flags:  append(newCrawlerFlags(), backfill.NewCliFlags(backfillJobDBDefaultValue, backfillJobSizeDefaultValue)...),
+		},
+		{
+			Name:   "gaps",
+			Usage:  "Report block ranges that trade logs are not crawled, and optionally crawl them again",
+			Action: checkGaps,
+			Flags: append(newCrawlerFlags(),
+				cli.StringFlag{
+					Name:  fromBlockFlag,
+					Usage: "Check gaps from block",
+				},
+				cli.StringFlag{
+					Name:  toBlockFlag,
+					Usage: "Check gaps to block, inclusive",
+				},
+				cli.StringFlag{
+					Name:  fromTimeFlag,
+					Usage: "Check gaps from the first block at or after given time, example: 2018-10-01 or 2018-10-01T00:00:00Z",
+				},
+				cli.StringFlag{
+					Name:  toTimeFlag,
+					Usage: "Check gaps to the last block before given time, example: 2018-10-02 or 2018-10-02T00:00:00Z",
+				},
+				cli.Uint64Flag{
+					Name:  countWindowFlag,
+					Usage: "Number of blocks of each window to compare the number of ExecuteTrade events on chain with stored trades, 0 to disable",
+				},
+				cli.BoolFlag{
+					Name:  repairFlag,
+					Usage: "Crawl the missing and mismatched block ranges again",
+				},
+			),
+		},
+		{
+			Name:   "verify",
+			Usage:  "Compare trade logs decoded from chain with stored trade logs field by field, reporting mismatched, missing and extra trades",
+			Action: verifyTradeLogs,
+			Flags: append(newCrawlerFlags(),
+				cli.StringFlag{
+					Name:  fromBlockFlag,
+					Usage: "Verify from block",
+				},
+				cli.StringFlag{
+					Name:  toBlockFlag,
+					Usage: "Verify to block, inclusive",
+				},
+				cli.StringFlag{
+					Name:  fromTimeFlag,
+					Usage: "Verify from the first block at or after given time, example: 2018-10-01 or 2018-10-01T00:00:00Z",
+				},
+				cli.StringFlag{
+					Name:  toTimeFlag,
+					Usage: "Verify to the last block before given time, example: 2018-10-02 or 2018-10-02T00:00:00Z",
+				},
+				cli.Uint64Flag{
+					Name:  verifyWindowFlag,
+					Usage: "Number of blocks of each window to compare",
+					Value: verifyWindowDefaultValue,
+				},
+				cli.IntFlag{
+					Name:  samplesFlag,
+					Usage: "Number of evenly spread windows to compare, 0 to scan the whole range",
+				},
+				cli.Float64Flag{
+					Name:  amountToleranceFlag,
+					Usage: "Maximum relative difference between amounts on chain and stored amounts, as amounts are stored as floats",
+					Value: tradelogs.DefaultAmountTolerance,
+				},
+			),
+		},
+		{
+			Name:   "aggregate",
+			Usage:  "Backfill the burn fee, volume and wallet aggregates of InfluxDB storage, which are computed by continuous queries for new trades only",
+			Action: aggregateTradeLogs,
+			Flags: append(append(append(append(influxdb.NewCliFlags(), storage.NewCliFlags()...), core.NewCliFlags()...), deployment.NewCliFlags()...),
+				cli.StringFlag{
+					Name:  fromTimeFlag,
+					Usage: "Aggregate from the start of the day of given time, example: 2018-10-01 or 2018-10-01T00:00:00Z",
+				},
+				cli.StringFlag{
+					Name:  toTimeFlag,
+					Usage: "Aggregate to the end of the day of given time, example: 2018-10-02 or 2018-10-02T00:00:00Z",
+				},
+			),
+		},
+	}
 
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func parseBigIntFlag(c *cli.Context, flag string) (*big.Int, error) {
-	val := c.String(flag)
-	if err := validation.Validate(val, validation.Required, is.Digit); err != nil {
-		return nil, err
+// newCrawlerFlags returns the flags to configure trade logs crawler and its
+// storage, shared by all commands.
+func newCrawlerFlags() []cli.Flag {
+	flags := []cli.Flag{
+		cli.StringFlag{
+			Name:   nodeURLFlag,
+			Usage:  "Ethereum node provider URL, multiple nodes are separated by comma and calls are failed over between them",
+			Value:  nodeURLDefaultValue,
+			EnvVar: "NODE",
+		},
+		cli.Uint64Flag{
+			Name:   chunkSizeFlag,
+			Usage:  "Number of blocks to fetch logs in a single request, a rejected chunk is split automatically",
+			Value:  tradelogs.DefaultChunkSize,
+			EnvVar: "CHUNK_SIZE",
+		},
+		cli.IntFlag{
+			Name:   maxWorkersFlag,
+			Usage:  "Maximum number of concurrent log fetching requests",
+			Value:  tradelogs.DefaultMaxWorkers,
+			EnvVar: "MAX_WORKERS",
+		},
+		cli.DurationFlag{
+			Name:   fetchTimeoutFlag,
+			Usage:  "Timeout of each log fetching request sent to node",
+			Value:  fetchTimeoutDefaultValue,
+			EnvVar: "FETCH_TIMEOUT",
+		},
+		cli.BoolTFlag{
+			Name:   enrichGeoFlag,
+			Usage:  "Enrich trade logs with IP and country of transaction from broadcast API",
+			EnvVar: "ENRICH_GEO",
+		},
+		cli.BoolTFlag{
+			Name:   enrichFiatRateFlag,
+			Usage:  "Enrich trade logs with ETH/USD rate at the time of trade",
+			EnvVar: "ENRICH_FIAT_RATE",
+		},
+		cli.BoolFlag{
+			Name:   enrichReceiptsFlag,
+			Usage:  "Enrich trade logs with tx sender and gas information from transaction receipt",
+			EnvVar: "ENRICH_RECEIPTS",
+		},
+		cli.StringFlag{
+			Name:   labelsFileFlag,
+			Usage:  "Path to JSON file of address to name mapping, enriches trade logs with reserve and wallet names if provided",
+			EnvVar: "LABELS_FILE",
+		},
 	}
+	flags = append(flags, influxdb.NewCliFlags()...)
+	flags = append(flags, storage.NewCliFlags()...)
+	flags = append(flags, core.NewCliFlags()...)
+	flags = append(flags, broadcast.NewCliFlags()...)
+	flags = append(flags, deployment.NewCliFlags()...)
+	flags = append(flags, tokenrate.NewCliFlags()...)
+	flags = append(flags, metrics.NewCliFlags()...)
+	flags = append(flags, blockchain.NewMultiClientCliFlags()...)
+	flags = append(flags, blockchain.NewBlockTimeResolverCliFlags()...)
+	return flags
+}
 
-	result, ok := big.NewInt(0).SetString(val, 0)
+// blockRangeFromContext returns the block range given by block flags or time
+// flags, the time flags are converted to block numbers using given resolver.
+func blockRangeFromContext(c *cli.Context, resolver *blockchain.BlockTimeResolver) (*big.Int, *big.Int, error) {
+	fromBlock, ok, err := blockchain.BlockFromContext(c, resolver, fromBlockFlag, fromTimeFlag, 0)
+	if err != nil {
+		return nil, nil, err
+	}
 	if !ok {
-		return nil, fmt.Errorf("invalid number %s", c.String(flag))
+		return nil, nil, fmt.Errorf("one of --%s and --%s is required", fromBlockFlag, fromTimeFlag)
+	}
+
+	toBlock, ok, err := blockchain.BlockFromContext(c, resolver, toBlockFlag, toTimeFlag, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("one of --%s and --%s is required", toBlockFlag, toTimeFlag)
+	}
+
+	if fromBlock > toBlock {
+		return nil, nil, fmt.Errorf("invalid block range: from %d to %d", fromBlock, toBlock)
+	}
+	return big.NewInt(0).SetUint64(fromBlock), big.NewInt(0).SetUint64(toBlock), nil
+}
+
+// newWorkerFromContext creates the trade logs crawler worker configured by cli flags.
+func newWorkerFromContext(c *cli.Context, sugar *zap.SugaredLogger) (*worker, error) {
+	coreClient, err := core.NewClientFromContext(sugar, c)
+	if err != nil {
+		return nil, err
+	}
+
+	ethClient, err := blockchain.NewMultiClientFromContext(c, sugar, c.String(nodeURLFlag))
+	if err != nil {
+		return nil, err
+	}
+
+	registry, err := deployment.NewRegistryFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+
+	influxClient, err := influxdb.NewClientFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+
+	enrichers, err := newEnrichersFromContext(c, sugar, ethClient, influxClient)
+	if err != nil {
+		return nil, err
 	}
-	return result, nil
+
+	resolver, err := blockchain.NewBlockTimeResolverFromContext(c, sugar, ethClient)
+	if err != nil {
+		return nil, err
+	}
+
+	crawler, err := tradelogs.NewTradeLogCrawler(
+		sugar,
+		ethClient,
+		resolver,
+		registry,
+		c.Uint64(chunkSizeFlag),
+		c.Int(maxWorkersFlag),
+		enrichers...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := registry.TokenAddresses()
+	if err != nil {
+		return nil, err
+	}
+	st, err := storage.NewStorageFromContext(c, sugar, influxClient, core.NewCachedClient(coreClient), tokens)
+	if err != nil {
+		return nil, err
+	}
+	if creator, ok := st.(storage.ContinuousQueriesCreator); ok {
+		if err = creator.CreateContinuousQueries(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &worker{
+		sugar:     sugar,
+		ethClient: ethClient,
+		resolver:  resolver,
+		crawler:   crawler,
+		storage:   st,
+		timeout:   c.Duration(fetchTimeoutFlag),
+	}, nil
 }
 
 func getTradeLogs(c *cli.Context) error {
@@ -87,83 +392,264 @@ func getTradeLogs(c *cli.Context) error {
 
 	sugar := logger.Sugar()
 
-	coreClient, err := core.NewClientFromContext(sugar, c)
+	w, err := newWorkerFromContext(c, sugar)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool(daemonFlag) {
+		metrics.ServeFromContext(c, sugar)
+		return runDaemon(c, sugar, w)
+	}
+
+	fromBlock, toBlock, err := blockRangeFromContext(c, w.resolver)
+	if err != nil {
+		return err
+	}
+
+	tradeLogs, err := w.processBlocks(fromBlock, toBlock)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(tradeLogs)
+}
+
+func backfillTradeLogs(c *cli.Context) error {
+	logger, err := libapp.NewLogger(c)
 	if err != nil {
 		return err
 	}
+	defer logger.Sync()
 
-	// Crawl trade logs from blockchain
-	fromBlock, err := parseBigIntFlag(c, fromBlockFlag)
+	sugar := logger.Sugar()
+
+	w, err := newWorkerFromContext(c, sugar)
 	if err != nil {
-		return fmt.Errorf("invalid from block: %q, error: %s", c.String(fromBlockFlag), err)
+		return err
 	}
 
-	toBlock, err := parseBigIntFlag(c, toBlockFlag)
+	rng, err := backfill.NewRangeFromContext(c, w.resolver)
 	if err != nil {
-		return fmt.Errorf("invalid to block: %q, error: %s", c.String(toBlockFlag), err)
+		return err
 	}
 
-	nodeURL := c.String(nodeURLFlag)
-	if err = validation.Validate(nodeURL, validation.Required, is.URL); err != nil {
-		return fmt.Errorf("invalid node url: %q, error: %s", nodeURL, err)
+	metrics.ServeFromContext(c, sugar)
+
+	runner, store, err := backfill.NewRunnerFromContext(c, sugar, func(fromBlock, toBlock uint64) error {
+		_, pErr := w.processBlocks(big.NewInt(0).SetUint64(fromBlock), big.NewInt(0).SetUint64(toBlock))
+		return pErr
+	})
+	if err != nil {
+		return err
 	}
+	defer store.Close()
 
-	geoClient, err := broadcast.NewClientFromContext(sugar, c)
+	return runner.Run(rng)
+}
+
+func checkGaps(c *cli.Context) error {
+	logger, err := libapp.NewLogger(c)
 	if err != nil {
 		return err
 	}
+	defer logger.Sync()
 
-	crawler, err := tradelogs.NewTradeLogCrawler(
-		sugar,
-		nodeURL,
-		geoClient,
-	)
+	sugar := logger.Sugar()
+
+	w, err := newWorkerFromContext(c, sugar)
 	if err != nil {
 		return err
 	}
 
-	tradeLogs, err := crawler.GetTradeLogs(fromBlock, toBlock, time.Second*5)
+	fromBlock, toBlock, err := blockRangeFromContext(c, w.resolver)
 	if err != nil {
 		return err
 	}
 
-	// Store trade logs into influx DB
-	influxClient, err := influxdb.NewClientFromContext(c)
+	checker := tradelogs.NewGapChecker(sugar, w.crawler, w.storage, w.timeout)
+	report, err := checker.Check(fromBlock.Uint64(), toBlock.Uint64(), c.Uint64(countWindowFlag))
 	if err != nil {
 		return err
 	}
 
-	influxStorage, err := storage.NewInfluxStorage(
-		sugar,
-		common.DatabaseName,
-		influxClient,
-		core.NewCachedClient(coreClient),
-	)
+	if c.Bool(repairFlag) {
+		ranges := report.MissingRanges
+		for _, mismatch := range report.CountMismatches {
+			ranges = append(ranges, mismatch.BlockRange)
+		}
+		for _, r := range ranges {
+			sugar.Infow("repairing block range", "from_block", r.FromBlock, "to_block", r.ToBlock)
+			if _, err = w.processBlocks(big.NewInt(0).SetUint64(r.FromBlock), big.NewInt(0).SetUint64(r.ToBlock)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(report)
+}
+
+func verifyTradeLogs(c *cli.Context) error {
+	logger, err := libapp.NewLogger(c)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	sugar := logger.Sugar()
+
+	w, err := newWorkerFromContext(c, sugar)
+	if err != nil {
+		return err
+	}
+
+	fromBlock, toBlock, err := blockRangeFromContext(c, w.resolver)
+	if err != nil {
+		return err
+	}
+
+	verifier := tradelogs.NewVerifier(sugar, w.crawler, w.storage, w.timeout, c.Float64(amountToleranceFlag))
+	report, err := verifier.Verify(fromBlock.Uint64(), toBlock.Uint64(), c.Uint64(verifyWindowFlag), c.Int(samplesFlag))
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(report)
+}
+
+// aggregateTradeLogs backfills the aggregated measurements of stored trade logs
+// in InfluxDB for the time range given by time flags.
+func aggregateTradeLogs(c *cli.Context) error {
+	logger, err := libapp.NewLogger(c)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	sugar := logger.Sugar()
+
+	var times []time.Time
+	for _, flag := range []string{fromTimeFlag, toTimeFlag} {
+		val := c.String(flag)
+		if val == "" {
+			return fmt.Errorf("--%s is required", flag)
+		}
+		t, pErr := blockchain.ParseTime(val)
+		if pErr != nil {
+			return pErr
+		}
+		times = append(times, t)
+	}
+	if times[0].After(times[1]) {
+		return fmt.Errorf("invalid time range: from %s to %s", times[0], times[1])
+	}
+
+	coreClient, err := core.NewClientFromContext(sugar, c)
+	if err != nil {
+		return err
+	}
+
+	registry, err := deployment.NewRegistryFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	influxClient, err := influxdb.NewClientFromContext(c)
 	if err != nil {
 		return err
 	}
 
-	// fetch eth usd rate
-	ethUSDRateFetcher, err := tokenrate.NewETHUSDRateFetcher(sugar, common.DatabaseName, influxClient, coingecko.New())
+	tokens, err := registry.TokenAddresses()
+	if err != nil {
+		return err
+	}
+	is, err := storage.NewInfluxStorageFromContext(c, sugar, influxClient, core.NewCachedClient(coreClient), tokens)
 	if err != nil {
 		return err
 	}
-	rates := []tokenrate.ETHUSDRate{}
-	for _, tradelog := range tradeLogs {
-		rate, err := ethUSDRateFetcher.FetchRates(tradelog.BlockNumber, tradelog.Timestamp)
+	// the backfill uses the same definitions as the continuous queries
+	if err = is.CreateContinuousQueries(); err != nil {
+		return err
+	}
+	return is.Aggregate(times[0], times[1])
+}
+
+// newEnrichersFromContext returns the trade logs enrichers enabled by cli flags.
+func newEnrichersFromContext(c *cli.Context, sugar *zap.SugaredLogger, ethClient blockchain.Client, influxClient client.Client) ([]tradelogs.Enricher, error) {
+	var enrichers []tradelogs.Enricher
+
+	if c.BoolT(enrichGeoFlag) {
+		geoClient, err := broadcast.NewClientFromContext(sugar, c)
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, tradelogs.NewGeoEnricher(sugar, geoClient))
+	}
+
+	if c.BoolT(enrichFiatRateFlag) {
+		rateProvider, err := tokenrate.NewProviderFromContext(sugar, c)
+		if err != nil {
+			return nil, err
+		}
+		ethUSDRateFetcher, err := tokenrate.NewETHUSDRateFetcher(sugar, common.DatabaseName, influxClient, rateProvider)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if rate.Rate > 0 {
-			rates = append(rates, rate)
-		} else {
-			return errors.New("eth usd is zero")
+		enrichers = append(enrichers, tradelogs.NewFiatRateEnricher(sugar, ethUSDRateFetcher))
+	}
+
+	if path := c.String(labelsFileFlag); path != "" {
+		labelEnricher, err := tradelogs.NewLabelEnricherFromFile(path)
+		if err != nil {
+			return nil, err
 		}
+		enrichers = append(enrichers, labelEnricher)
 	}
 
-	if err = influxStorage.SaveTradeLogs(tradeLogs, rates); err != nil {
+	if c.Bool(enrichReceiptsFlag) {
+		enrichers = append(enrichers, tradelogs.NewReceiptEnricher(sugar, ethClient, c.Duration(fetchTimeoutFlag)))
+	}
+
+	return enrichers, nil
+}
+
+func runDaemon(c *cli.Context, sugar *zap.SugaredLogger, w *worker) error {
+	// the start block is optional, crawling is resumed from checkpoint if not given
+	startBlock, _, err := blockchain.BlockFromContext(c, w.resolver, fromBlockFlag, fromTimeFlag, 0)
+	if err != nil {
 		return err
 	}
 
-	return json.NewEncoder(os.Stdout).Encode(tradeLogs)
+	blockWindow := c.Uint64(blockWindowFlag)
+	if blockWindow == 0 {
+		return fmt.Errorf("invalid block window: %d", blockWindow)
+	}
+
+	cp, err := checkpoint.NewBoltStorage(sugar, c.String(checkpointDBFlag))
+	if err != nil {
+		return err
+	}
+	defer cp.Close()
+
+	d := &daemon{
+		sugar:         sugar,
+		worker:        w,
+		ethClient:     w.ethClient,
+		checkpoint:    cp,
+		reorgHandler:  tradelogs.NewReorgHandler(sugar, w.ethClient, cp, w.storage, w.timeout),
+		startBlock:    startBlock,
+		blockWindow:   blockWindow,
+		confirmations: c.Uint64(confirmationsFlag),
+		pollInterval:  c.Duration(pollIntervalFlag),
+	}
+
+	if streamNode := c.String(streamNodeFlag); streamNode != "" {
+		s := &streamer{
+			daemon: d,
+			dial:   dialStreamNode(streamNode),
+			buffer: tradelogs.NewLogBuffer(),
+		}
+		return s.run()
+	}
+	return d.run()
 }
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	ether "github.com/ethereum/go-ethereum"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/KyberNetwork/reserve-stats/tradelogs"
+)
+
+var (
+	// errResync is returned when the streamed logs can not be trusted anymore
+	// and the subscription must be restarted.
+	errResync = errors.New("checkpoint rolled back by chain reorganisation, resubscribing")
+	// errDeploymentChanged is returned when a trade log contract is deployed
+	// or retired, the subscription must be restarted with new contracts
+	// addresses.
+	errDeploymentChanged = errors.New("trade log contracts deployment changed, resubscribing")
+)
+
+// logSubscriber is the subset of Ethereum client methods to subscribe to
+// new logs and new blocks, it requires a websocket or IPC node connection.
+type logSubscriber interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SubscribeFilterLogs(ctx context.Context, q ether.FilterQuery, ch chan<- types.Log) (ether.Subscription, error)
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ether.Subscription, error)
+	Close()
+}
+
+// streamer ingests trade logs in real time using log subscriptions of a
+// streaming node. The received logs are buffered until their blocks have
+// enough confirmations, then stored and checkpointed the same way as the
+// polling daemon. The blocks mined before the subscription started, including
+// the ones missed while the subscription was down, are crawled by polling.
+type streamer struct {
+	*daemon
+	dial func() (logSubscriber, error)
+	// subscribedAt is the head block when current subscription started, logs
+	// of blocks after it are delivered by subscription.
+	subscribedAt uint64
+	buffer       *tradelogs.LogBuffer
+}
+
+func dialStreamNode(url string) func() (logSubscriber, error) {
+	return func() (logSubscriber, error) {
+		return ethclient.Dial(url)
+	}
+}
+
+// run streams trade logs until an unrecoverable error occurs. When the
+// subscription drops or the trade log contracts change, a block window is
+// crawled by polling before subscribing again.
+func (s *streamer) run() error {
+	logger := s.sugar.With(
+		"func", "tradelogs/cmd/trade-logs-crawler/streamer.run",
+		"confirmations", s.confirmations,
+		"poll_interval", s.pollInterval,
+	)
+
+	if err := s.checkStart(); err != nil {
+		return err
+	}
+
+	logger.Info("starting trade logs crawler in streaming mode")
+	for {
+		err := s.stream()
+		switch err {
+		case tradelogs.ErrReorgTooDeep:
+			return err
+		case errDeploymentChanged:
+			logger.Infow("renewing log subscription", "reason", err)
+		default:
+			logger.Warnw("log subscription stopped, falling back to polling", "err", err)
+		}
+
+		caughtUp, err := s.step()
+		if err == tradelogs.ErrReorgTooDeep {
+			return err
+		}
+		if err != nil {
+			logger.Errorw("failed to process block window", "err", err)
+		}
+		if err != nil || caughtUp {
+			time.Sleep(s.pollInterval)
+		}
+	}
+}
+
+// stream subscribes to trade logs and new blocks, processing confirmed
+// blocks on every new block until the subscription fails.
+func (s *streamer) stream() error {
+	client, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.worker.timeout)
+	defer cancel()
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil && header == nil {
+		return err
+	}
+	head := header.Number.Uint64()
+
+	query, ok := s.worker.crawler.FilterQuery(head, head)
+	if !ok {
+		return errors.New("no trade logs contract deployed at current block")
+	}
+
+	// logs of head block might be emitted before the subscription started,
+	// so the blocks up to head block are crawled by polling.
+	s.subscribedAt = head
+	s.buffer.Prune(head)
+
+	logs := make(chan types.Log)
+	logSub, err := client.SubscribeFilterLogs(context.Background(), query, logs)
+	if err != nil {
+		return err
+	}
+	defer logSub.Unsubscribe()
+
+	heads := make(chan *types.Header)
+	headSub, err := client.SubscribeNewHead(context.Background(), heads)
+	if err != nil {
+		return err
+	}
+	defer headSub.Unsubscribe()
+
+	// logs are received in a separate goroutine, so the subscription is not
+	// blocked while confirmed blocks are processed.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case log := <-logs:
+				s.buffer.Add(log)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	nextChange, hasNextChange := s.worker.crawler.NextDeploymentChange(head)
+	s.sugar.Infow("subscribed to trade logs",
+		"subscribed_at", head,
+		"addresses", len(query.Addresses))
+
+	for {
+		select {
+		case header := <-heads:
+			number := header.Number.Uint64()
+			if hasNextChange && number >= nextChange {
+				return errDeploymentChanged
+			}
+			if err = s.flush(number); err != nil {
+				return err
+			}
+		case err = <-logSub.Err():
+			return err
+		case err = <-headSub.Err():
+			return err
+		}
+	}
+}
+
+// flush processes the blocks that have enough confirmations at given head
+// block. The blocks up to subscribedAt are crawled by polling, the later ones
+// are built from buffered logs.
+func (s *streamer) flush(head uint64) error {
+	logger := s.sugar.With(
+		"func", "tradelogs/cmd/trade-logs-crawler/streamer.flush",
+		"head", head,
+	)
+
+	rolledBack, err := s.reorgHandler.Handle()
+	if err != nil {
+		return err
+	}
+	if rolledBack {
+		return errResync
+	}
+
+	if head < s.confirmations {
+		return nil
+	}
+	confirmed := head - s.confirmations
+
+	lastBlock, err := s.lastBlock()
+	if err != nil {
+		return err
+	}
+
+	for lastBlock < s.subscribedAt && lastBlock < confirmed {
+		caughtUp, sErr := s.step()
+		if sErr != nil {
+			return sErr
+		}
+		if lastBlock, err = s.lastBlock(); err != nil {
+			return err
+		}
+		if caughtUp {
+			break
+		}
+	}
+
+	setHeadLag(head, lastBlock)
+
+	// the buffered logs of blocks crawled by polling are not needed
+	s.buffer.Prune(lastBlock)
+	if lastBlock >= confirmed || lastBlock < s.subscribedAt {
+		return nil
+	}
+
+	// the identity of last block is read before building trade logs, so if a
+	// reorg happens meanwhile, it will be detected at next head.
+	block, err := s.reorgHandler.Block(confirmed)
+	if err != nil {
+		return err
+	}
+
+	logs, err := s.buffer.Take(confirmed, func(number uint64) (ethereum.Hash, error) {
+		if number == confirmed {
+			return block.Hash, nil
+		}
+		canonical, bErr := s.reorgHandler.Block(number)
+		return canonical.Hash, bErr
+	})
+	if err != nil {
+		return err
+	}
+
+	tradeLogs, err := s.worker.crawler.BuildTradeLogs(logs)
+	if err != nil {
+		return err
+	}
+
+	if err = s.worker.save(tradeLogs, lastBlock+1, confirmed); err != nil {
+		return err
+	}
+
+	if err = s.checkpoint.SaveLastBlock(block); err != nil {
+		return err
+	}
+	setHeadLag(head, confirmed)
+
+	logger.Infow("streamed trade logs processed",
+		"from_block", lastBlock+1,
+		"to_block", confirmed,
+		"trade_logs", len(tradeLogs),
+		"buffered_logs", s.buffer.Len())
+	return nil
+}
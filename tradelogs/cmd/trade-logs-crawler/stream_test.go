@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ether "github.com/ethereum/go-ethereum"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/lib/deployment"
+	"github.com/KyberNetwork/reserve-stats/tradelogs"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/checkpoint"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/storage"
+)
+
+var (
+	testOldNetwork = ethereum.HexToAddress("0x91a502c678605fbce581eae053319747482276b9")
+	testNewNetwork = ethereum.HexToAddress("0x818e6fecd516ecc3849daf6845e3ec868087b755")
+)
+
+// fakeChain is a fake chain returning headers of given fork. Calling the
+// methods of blockchain.Client that are not implemented panics.
+type fakeChain struct {
+	blockchain.Client
+	head uint64
+	fork string
+	// forkBlock is the first block that header is different on fork, no
+	// block is forked if fork is empty
+	forkBlock uint64
+}
+
+func (fc *fakeChain) header(number uint64) *types.Header {
+	header := &types.Header{
+		Number: big.NewInt(0).SetUint64(number),
+		Time:   big.NewInt(int64(1539000000 + number*15)),
+	}
+	if fc.fork != "" && number >= fc.forkBlock {
+		header.Extra = []byte(fc.fork)
+	}
+	return header
+}
+
+func (fc *fakeChain) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return fc.header(fc.head), nil
+	}
+	return fc.header(number.Uint64()), nil
+}
+
+// fakeSubscription is a subscription that fails when an error is sent to it.
+type fakeSubscription struct {
+	err chan error
+}
+
+func newFakeSubscription() *fakeSubscription {
+	return &fakeSubscription{err: make(chan error, 1)}
+}
+
+func (fs *fakeSubscription) Err() <-chan error {
+	return fs.err
+}
+
+func (fs *fakeSubscription) Unsubscribe() {}
+
+// fakeSubscriber delivers the logs and new heads sent by test.
+type fakeSubscriber struct {
+	*fakeChain
+	query   ether.FilterQuery
+	logs    chan<- types.Log
+	heads   chan<- *types.Header
+	logSub  *fakeSubscription
+	headSub *fakeSubscription
+	// subscribed is closed when both subscriptions are created
+	subscribed chan struct{}
+}
+
+func newFakeSubscriber(chain *fakeChain) *fakeSubscriber {
+	return &fakeSubscriber{
+		fakeChain:  chain,
+		logSub:     newFakeSubscription(),
+		headSub:    newFakeSubscription(),
+		subscribed: make(chan struct{}),
+	}
+}
+
+func (fs *fakeSubscriber) SubscribeFilterLogs(_ context.Context, q ether.FilterQuery, ch chan<- types.Log) (ether.Subscription, error) {
+	fs.query = q
+	fs.logs = ch
+	return fs.logSub, nil
+}
+
+func (fs *fakeSubscriber) SubscribeNewHead(_ context.Context, ch chan<- *types.Header) (ether.Subscription, error) {
+	fs.heads = ch
+	close(fs.subscribed)
+	return fs.headSub, nil
+}
+
+func (fs *fakeSubscriber) Close() {}
+
+// fakeEnricher sets the same ETH/USD rate to all trade logs.
+type fakeEnricher struct {
+	rate float64
+}
+
+func (fe fakeEnricher) Name() string {
+	return "fake"
+}
+
+func (fe fakeEnricher) Enrich(tradeLogs []common.TradeLog) ([]common.TradeLog, error) {
+	for i := range tradeLogs {
+		tradeLogs[i].ETHUSDRate = fe.rate
+	}
+	return tradeLogs, nil
+}
+
+// executeTradeLog returns an ExecuteTrade log of given network contract.
+func executeTradeLog(network ethereum.Address, number uint64, hash ethereum.Hash, index uint) types.Log {
+	var (
+		trader = ethereum.HexToAddress("0x8fa07f46353a2b17e92645592a94a0fc1ceb783f")
+		eth    = ethereum.HexToAddress("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee")
+		knc    = ethereum.HexToAddress("0xdd974d5c2e2928dea5f71b9825b8b646686bd200")
+		data   []byte
+	)
+	for _, word := range [][]byte{eth.Bytes(), knc.Bytes(), big.NewInt(100).Bytes(), big.NewInt(200).Bytes()} {
+		data = append(data, ethereum.LeftPadBytes(word, 32)...)
+	}
+	return types.Log{
+		Address: network,
+		Topics: []ethereum.Hash{
+			ethereum.HexToHash("0x1849bd6a030a1bca28b83437fd3de96f3d27a5d172fa7e9c78e7b61468928a39"),
+			ethereum.BytesToHash(trader.Bytes()),
+		},
+		Data:        data,
+		BlockNumber: number,
+		BlockHash:   hash,
+		TxHash:      ethereum.BigToHash(big.NewInt(int64(number*100 + uint64(index)))),
+		Index:       index,
+	}
+}
+
+func newTestStreamer(t *testing.T, chain *fakeChain) (*streamer, *checkpoint.BoltStorage, *storage.MemoryStorage, func()) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	dir, err := ioutil.TempDir("", "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := checkpoint.NewBoltStorage(sugar, filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := deployment.NewRegistry(deployment.Ropsten, deployment.Config{
+		Contracts: map[deployment.Contract][]deployment.Deployment{
+			deployment.NetworkContract: {
+				{Address: testOldNetwork, StartBlock: 1, EndBlock: 104},
+				{Address: testNewNetwork, StartBlock: 105},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := blockchain.NewBlockTimeResolver(sugar, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawler, err := tradelogs.NewTradeLogCrawler(sugar, chain, resolver, registry, 100, 1, fakeEnricher{rate: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := storage.NewMemoryStorage(sugar, core.NewMockClient(), blockchain.MainnetTokenAddresses())
+
+	s := &streamer{
+		daemon: &daemon{
+			sugar: sugar,
+			worker: &worker{
+				sugar:     sugar,
+				ethClient: chain,
+				resolver:  resolver,
+				crawler:   crawler,
+				storage:   st,
+				timeout:   time.Second,
+			},
+			ethClient:     chain,
+			checkpoint:    cp,
+			reorgHandler:  tradelogs.NewReorgHandler(sugar, chain, cp, st, time.Second),
+			blockWindow:   100,
+			confirmations: 2,
+			pollInterval:  time.Millisecond,
+		},
+		buffer: tradelogs.NewLogBuffer(),
+	}
+
+	// blocks up to 100 are processed
+	block, err := s.reorgHandler.Block(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cp.SaveLastBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	return s, cp, st, func() {
+		assert.NoError(t, cp.Close())
+		assert.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+func loadTestTradeLogs(t *testing.T, st *storage.MemoryStorage) []common.TradeLog {
+	tradeLogs, err := st.LoadTradeLogs(time.Unix(1539000000, 0), time.Unix(1539000000, 0).Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tradeLogs
+}
+
+func TestStreamerFlush(t *testing.T) {
+	chain := &fakeChain{head: 104}
+	s, cp, st, tearDown := newTestStreamer(t, chain)
+	defer tearDown()
+	s.subscribedAt = 100
+
+	s.buffer.Add(executeTradeLog(testOldNetwork, 101, chain.header(101).Hash(), 0))
+	// the log of an uncle block is dropped
+	s.buffer.Add(executeTradeLog(testOldNetwork, 101, ethereum.HexToHash("0x01"), 1))
+	s.buffer.Add(executeTradeLog(testOldNetwork, 102, chain.header(102).Hash(), 0))
+	// not confirmed yet
+	s.buffer.Add(executeTradeLog(testOldNetwork, 104, chain.header(104).Hash(), 0))
+
+	assert.NoError(t, s.flush(104))
+
+	tradeLogs := loadTestTradeLogs(t, st)
+	if assert.Len(t, tradeLogs, 2) {
+		for _, tradeLog := range tradeLogs {
+			assert.Equal(t, float64(200), tradeLog.ETHUSDRate, "trade logs should be enriched")
+		}
+		assert.Equal(t, uint64(101), tradeLogs[0].BlockNumber)
+		assert.Equal(t, uint64(102), tradeLogs[1].BlockNumber)
+	}
+	lastBlock, err := cp.LastBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(102), lastBlock)
+	assert.Equal(t, 1, s.buffer.Len())
+
+	// blocks from 102 are replaced
+	chain.fork = "b"
+	chain.forkBlock = 102
+	assert.Equal(t, errResync, s.flush(105))
+
+	tradeLogs = loadTestTradeLogs(t, st)
+	assert.Len(t, tradeLogs, 0, "trade logs after rollback block should be deleted")
+	lastBlock, err = cp.LastBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), lastBlock)
+}
+
+// waitFor waits until given condition is true or fails the test on timeout.
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStreamerStream(t *testing.T) {
+	chain := &fakeChain{head: 100}
+	s, cp, st, tearDown := newTestStreamer(t, chain)
+	defer tearDown()
+
+	sub := newFakeSubscriber(chain)
+	s.dial = func() (logSubscriber, error) { return sub, nil }
+	result := make(chan error)
+	go func() { result <- s.stream() }()
+
+	<-sub.subscribed
+	assert.Equal(t, []ethereum.Address{testOldNetwork}, sub.query.Addresses)
+
+	sub.logs <- executeTradeLog(testOldNetwork, 101, chain.header(101).Hash(), 0)
+	waitFor(t, func() bool { return s.buffer.Len() == 1 })
+
+	sub.heads <- chain.header(103)
+	// the new network contract is deployed at block 105, the subscription
+	// is renewed even though the block is not confirmed yet
+	sub.heads <- chain.header(105)
+	assert.Equal(t, errDeploymentChanged, <-result)
+
+	assert.Len(t, loadTestTradeLogs(t, st), 1)
+	lastBlock, err := cp.LastBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(101), lastBlock)
+
+	chain.head = 105
+	sub = newFakeSubscriber(chain)
+	go func() { result <- s.stream() }()
+
+	<-sub.subscribed
+	assert.Equal(t, []ethereum.Address{testNewNetwork}, sub.query.Addresses)
+	assert.Equal(t, uint64(105), s.subscribedAt)
+
+	subErr := errors.New("connection lost")
+	sub.logSub.err <- subErr
+	assert.Equal(t, subErr, <-result)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
+	"github.com/KyberNetwork/reserve-stats/tradelogs"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/storage"
+)
+
+var blocksProcessed = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "trade_logs_crawler_blocks_processed_total",
+	Help: "Number of blocks which trade logs are crawled and stored.",
+})
+
+func init() {
+	prometheus.MustRegister(blocksProcessed)
+}
+
+// worker crawls enriched trade logs of a block range and persists them to storage.
+type worker struct {
+	sugar     *zap.SugaredLogger
+	ethClient blockchain.Client
+	resolver  *blockchain.BlockTimeResolver
+	crawler   *tradelogs.TradeLogCrawler
+	storage   storage.Interface
+	timeout   time.Duration
+}
+
+// processBlocks crawls and stores all trade logs from fromBlock to toBlock, inclusive.
+func (w *worker) processBlocks(fromBlock, toBlock *big.Int) ([]common.TradeLog, error) {
+	logger := w.sugar.With(
+		"func", "tradelogs/cmd/trade-logs-crawler/worker.processBlocks",
+		"from_block", fromBlock,
+		"to_block", toBlock,
+	)
+
+	tradeLogs, err := w.crawler.GetTradeLogs(fromBlock, toBlock, w.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = w.save(tradeLogs, fromBlock.Uint64(), toBlock.Uint64()); err != nil {
+		return nil, err
+	}
+
+	logger.Infow("trade logs processed", "trade_logs", len(tradeLogs))
+	return tradeLogs, nil
+}
+
+// save stores the given trade logs, which are all trade logs from fromBlock
+// to toBlock, and records that the block range is crawled.
+func (w *worker) save(tradeLogs []common.TradeLog, fromBlock, toBlock uint64) error {
+	if len(tradeLogs) != 0 {
+		// the rates are zero if fiat rate enricher is disabled, the trades are
+		// then stored without USD amounts
+		var (
+			rates       []tokenrate.ETHUSDRate
+			missingRate int
+		)
+		for _, tradeLog := range tradeLogs {
+			if tradeLog.ETHUSDRate == 0 {
+				missingRate++
+			}
+			rates = append(rates, tokenrate.ETHUSDRate{
+				Timestamp:   tradeLog.Timestamp,
+				Rate:        tradeLog.ETHUSDRate,
+				Provider:    tradeLog.ETHUSDProvider,
+				BlockNumber: tradeLog.BlockNumber,
+			})
+		}
+		if missingRate != 0 {
+			w.sugar.Warnw("storing trade logs without ETH/USD rate",
+				"func", "tradelogs/cmd/trade-logs-crawler/worker.save",
+				"from_block", fromBlock,
+				"to_block", toBlock,
+				"trade_logs", missingRate)
+		}
+
+		if err := w.storage.SaveTradeLogs(tradeLogs, rates); err != nil {
+			return err
+		}
+	}
+
+	// recorded to detect the gaps of block ranges that are never crawled
+	toBlockTime, err := w.resolver.Resolve(toBlock)
+	if err != nil {
+		return err
+	}
+	if err = w.storage.SaveCrawledRange(common.BlockRange{FromBlock: fromBlock, ToBlock: toBlock}, toBlockTime); err != nil {
+		return err
+	}
+
+	blocksProcessed.Add(float64(toBlock - fromBlock + 1))
+	return nil
+}
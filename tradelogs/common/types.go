@@ -10,13 +10,16 @@ import (
 // BurnFee represent burnFee event on KyberNetwork
 type BurnFee struct {
 	ReserveAddress ethereum.Address `json:"reserve_addr"`
+	ReserveName    string           `json:"reserve_name,omitempty"`
 	Amount         *big.Int         `json:"amount"`
 }
 
 // WalletFee represent feeToWallet event on KyberNetwork
 type WalletFee struct {
 	ReserveAddress ethereum.Address `json:"reserve_addr"`
+	ReserveName    string           `json:"reserve_name,omitempty"`
 	WalletAddress  ethereum.Address `json:"wallet_addr"`
+	WalletName     string           `json:"wallet_name,omitempty"`
 	Amount         *big.Int         `json:"amount"`
 }
 
@@ -25,6 +28,9 @@ type TradeLog struct {
 	Timestamp       time.Time     `json:"timestamp"`
 	BlockNumber     uint64        `json:"block_number"`
 	TransactionHash ethereum.Hash `json:"tx_hash"`
+	// LogIndex is the index in block of the log of trade event, a transaction
+	// may contain multiple trades.
+	LogIndex uint `json:"log_index"`
 
 	EtherReceivalSender ethereum.Address `json:"eth_receival_sender"`
 	EtherReceivalAmount *big.Int         `json:"eth_receival_amount"`
@@ -36,11 +42,26 @@ type TradeLog struct {
 	DestAmount  *big.Int         `json:"dst_amount"`
 	FiatAmount  float64          `json:"fiat_amount"`
 
+	// ETHUSDRate is the ETH/USD rate at the time of trade, given by ETHUSDProvider.
+	ETHUSDRate     float64 `json:"eth_usd_rate"`
+	ETHUSDProvider string  `json:"eth_usd_provider"`
+
 	BurnFees   []BurnFee   `json:"burn_fees"`
 	WalletFees []WalletFee `json:"wallet_fees"`
 
 	IP      string `json:"ip"`
 	Country string `json:"country"`
+
+	// TxIndex is the index of transaction in block.
+	TxIndex uint `json:"tx_index"`
+	// The following fields are read from the transaction and its receipt, they
+	// are only available if the receipt enricher is enabled.
+	TxSender    ethereum.Address `json:"tx_sender"`
+	TxRecipient ethereum.Address `json:"tx_recipient"`
+	GasPrice    *big.Int         `json:"gas_price"`
+	GasUsed     uint64           `json:"gas_used"`
+	// GasCost is the ETH amount paid for gas, in wei.
+	GasCost *big.Int `json:"gas_cost"`
 }
 
 // VolumeStats struct holds all the volume fields of volume in a specfic time
@@ -49,3 +70,18 @@ type VolumeStats struct {
 	USDAmount float64 `json:"usd_amount"`
 	Volume    float64 `json:"volume"`
 }
+
+// WalletStats holds the statistics of the trades routed through a wallet in a specific time.
+type WalletStats struct {
+	TradeCount uint64  `json:"trade_count"`
+	ETHVolume  float64 `json:"eth_volume"`
+	USDVolume  float64 `json:"usd_volume"`
+	// Fee is the amount of KNC earned by the wallet.
+	Fee float64 `json:"fee"`
+}
+
+// BlockRange is a range of blocks from FromBlock to ToBlock, inclusive.
+type BlockRange struct {
+	FromBlock uint64 `json:"from_block"`
+	ToBlock   uint64 `json:"to_block"`
+}
@@ -1,184 +1,121 @@
 package tradelogs
 
 import (
-	"context"
-	"errors"
+	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
-	"github.com/KyberNetwork/reserve-stats/lib/broadcast"
+	"github.com/KyberNetwork/reserve-stats/lib/contracts"
+	"github.com/KyberNetwork/reserve-stats/lib/deployment"
 	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
 	ether "github.com/ethereum/go-ethereum"
 	ethereum "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
-const (
-	// feeToWalletEvent is the topic of event AssignFeeToWallet(address reserve, address wallet, uint walletFee).
-	feeToWalletEvent = "0x366bc34352215bf0bd3b527cfd6718605e1f5938777e42bcd8ed92f578368f52"
-	// burnFeeEvent is the topic of event AssignBurnFees(address reserve, uint burnFee).
-	burnFeeEvent = "0xf838f6ddc89706878e3c3e698e9b5cbfbf2c0e3d3dcd0bd2e00f1ccf313e0185"
-	// tradeEvent is the topic of event
-	// ExecuteTrade(address indexed sender, ERC20 src, ERC20 dest, uint actualSrcAmount, uint actualDestAmount).
-	// tradeEvent is the topic of event
-	// ExecuteTrade(address indexed sender, ERC20 src, ERC20 dest, uint actualSrcAmount, uint actualDestAmount).
-	tradeEvent = "0x1849bd6a030a1bca28b83437fd3de96f3d27a5d172fa7e9c78e7b61468928a39"
-	// etherReceivalEvent is the topic of event EtherReceival(address indexed sender, uint amount).
-	etherReceivalEvent = "0x75f33ed68675112c77094e7c5b073890598be1d23e27cd7f6907b4a7d98ac619"
-
-	// address of pricing contract
-	pricingAddr = "0x798AbDA6Cc246D0EDbA912092A2a3dBd3d11191B"
-	// address of network contract
-	networkAddr = "0x818E6FECD516Ecc3849DAf6845e3EC868087B755"
-	// address of bunner contract
-	burnerAddr = "0xed4f53268bfdFF39B36E8786247bA3A02Cf34B04"
-	// address of internal network contract
-	internalNetworkAddr = "0x91a502C678605fbCe581eae053319747482276b9"
-)
+// tradeLogContracts is the contracts that emit trade log events.
+var tradeLogContracts = []deployment.Contract{
+	deployment.PricingContract,
+	deployment.NetworkContract,
+	deployment.BurnerContract,
+	deployment.InternalNetworkContract,
+}
 
 // TradeLogCrawler gets trade logs on KyberNetwork on blockchain, adding the
 // information about USD equivalent on each trade.
 type TradeLogCrawler struct {
-	sugar           *zap.SugaredLogger
-	ethClient       *ethclient.Client
-	txTime          *blockchain.BlockTimeResolver
-	broadcastClient broadcast.Interface
-}
-
-func logDataToTradeParams(data []byte) (ethereum.Address, ethereum.Address, ethereum.Hash, ethereum.Hash, error) {
-	var srcAddr, desAddr ethereum.Address
-	var srcAmount, desAmount ethereum.Hash
-
-	if len(data) != 128 {
-		err := errors.New("invalid trade data")
-		return srcAddr, desAddr, srcAmount, desAmount, err
-	}
-
-	srcAddr = ethereum.BytesToAddress(data[0:32])
-	desAddr = ethereum.BytesToAddress(data[32:64])
-	srcAmount = ethereum.BytesToHash(data[64:96])
-	desAmount = ethereum.BytesToHash(data[96:128])
-	return srcAddr, desAddr, srcAmount, desAmount, nil
-}
-
-func logDataToEtherReceivalParams(data []byte) (ethereum.Hash, error) {
-	var amount ethereum.Hash
-
-	if len(data) != 32 {
-		err := errors.New("invalid eth receival data")
-		return amount, err
-	}
-
-	amount = ethereum.BytesToHash(data[0:32])
-	return amount, nil
+	sugar      *zap.SugaredLogger
+	ethClient  blockchain.Client
+	registry   *deployment.Registry
+	decoder    *eventDecoder
+	logFetcher *logFetcher
+	txTime     blockchain.BlockTimeResolverInterface
+	enrichers  []Enricher
 }
 
-func logDataToFeeWalletParams(data []byte) (ethereum.Address, ethereum.Address, ethereum.Hash, error) {
-	var reserveAddr, walletAddr ethereum.Address
-	var walletFee ethereum.Hash
-
-	if len(data) != 96 {
-		err := errors.New("invalid fee wallet data")
-		return reserveAddr, walletAddr, walletFee, err
-	}
-
-	reserveAddr = ethereum.BytesToAddress(data[0:32])
-	walletAddr = ethereum.BytesToAddress(data[32:64])
-	walletFee = ethereum.BytesToHash(data[64:96])
-	return reserveAddr, walletAddr, walletFee, nil
+// tradeLogBuilder assembles trade logs from the decoded events of logs sorted
+// by block number and log index. A transaction may contain multiple trades, the
+// events of a trade are emitted in order: EtherReceival (token to token trades
+// only), fee events, KyberTrade of internal network and ExecuteTrade of network
+// proxy. A fee or EtherReceival event after a trade event starts a new trade.
+type tradeLogBuilder struct {
+	tradeLogs []common.TradeLog
+	// kyberTrade and executeTrade are true if the last trade log already
+	// received the corresponding trade event.
+	kyberTrade   bool
+	executeTrade bool
 }
 
-func logDataToBurnFeeParams(data []byte) (ethereum.Address, ethereum.Hash, error) {
-	var reserveAddr ethereum.Address
-	var burnFees ethereum.Hash
-
-	if len(data) != 64 {
-		err := errors.New("invalid burn fee data")
-		return reserveAddr, burnFees, err
+// current returns the trade log that the given log belongs to, creating a new
+// one if the given log starts a new trade.
+func (b *tradeLogBuilder) current(logItem types.Log, newTrade bool, ts time.Time) *common.TradeLog {
+	if len(b.tradeLogs) > 0 && !newTrade {
+		last := &b.tradeLogs[len(b.tradeLogs)-1]
+		if last.TransactionHash == logItem.TxHash {
+			return last
+		}
 	}
 
-	reserveAddr = ethereum.BytesToAddress(data[0:32])
-	burnFees = ethereum.BytesToHash(data[32:64])
-	return reserveAddr, burnFees, nil
+	b.tradeLogs = append(b.tradeLogs, common.TradeLog{
+		BlockNumber:     logItem.BlockNumber,
+		TransactionHash: logItem.TxHash,
+		LogIndex:        logItem.Index,
+		TxIndex:         logItem.TxIndex,
+		Timestamp:       ts,
+	})
+	b.kyberTrade = false
+	b.executeTrade = false
+	return &b.tradeLogs[len(b.tradeLogs)-1]
 }
 
-func updateTradeLogs(allLogs []common.TradeLog, logItem types.Log, ts time.Time) ([]common.TradeLog, error) {
-	var (
-		tradeLog      common.TradeLog
-		updateLastLog = false
-	)
-
-	if len(logItem.Topics) == 0 {
-		return allLogs, errors.New("log item has no topic")
-	}
-
-	// if the transaction hash is the same with last log is a TradeLog, update it,
-	// otherwise append new one
-	if len(allLogs) > 0 && allLogs[len(allLogs)-1].TransactionHash == logItem.TxHash {
-		tradeLog = allLogs[len(allLogs)-1]
-		updateLastLog = true
-	}
-
-	if !updateLastLog {
-		tradeLog = common.TradeLog{
-			BlockNumber:     logItem.BlockNumber,
-			TransactionHash: logItem.TxHash,
-			Timestamp:       ts,
-		}
-	}
-
-	switch logItem.Topics[0].Hex() {
-	case feeToWalletEvent:
-		reserveAddr, walletAddr, fee, err := logDataToFeeWalletParams(logItem.Data)
-		if err != nil {
-			return allLogs, err
-		}
+// add updates the trade logs with the given decoded event.
+func (b *tradeLogBuilder) add(logItem types.Log, event interface{}, ts time.Time) {
+	var tradeDone = b.kyberTrade || b.executeTrade
 
+	switch e := event.(type) {
+	case *assignFeeToWallet:
+		tradeLog := b.current(logItem, tradeDone, ts)
 		walletFee := common.WalletFee{
-			ReserveAddress: reserveAddr,
-			WalletAddress:  walletAddr,
-			Amount:         fee.Big(),
+			ReserveAddress: e.Reserve,
+			WalletAddress:  e.Wallet,
+			Amount:         e.WalletFee,
 		}
 		tradeLog.WalletFees = append(tradeLog.WalletFees, walletFee)
-	case burnFeeEvent:
-		reserveAddr, fee, err := logDataToBurnFeeParams(logItem.Data)
-		if err != nil {
-			return allLogs, err
-		}
+	case *assignBurnFees:
+		tradeLog := b.current(logItem, tradeDone, ts)
 		burnFee := common.BurnFee{
-			ReserveAddress: reserveAddr,
-			Amount:         fee.Big(),
+			ReserveAddress: e.Reserve,
+			Amount:         e.BurnFee,
 		}
 		tradeLog.BurnFees = append(tradeLog.BurnFees, burnFee)
-	case etherReceivalEvent:
-		amount, err := logDataToEtherReceivalParams(logItem.Data)
-		if err != nil {
-			return allLogs, err
-		}
-		tradeLog.EtherReceivalSender = ethereum.BytesToAddress(logItem.Topics[1].Bytes())
-		tradeLog.EtherReceivalAmount = amount.Big()
-	case tradeEvent:
-		srcAddr, destAddr, srcAmount, destAmount, err := logDataToTradeParams(logItem.Data)
-		if err != nil {
-			return allLogs, err
-		}
-		tradeLog.SrcAddress = srcAddr
-		tradeLog.DestAddress = destAddr
-		tradeLog.SrcAmount = srcAmount.Big()
-		tradeLog.DestAmount = destAmount.Big()
-		tradeLog.UserAddress = ethereum.BytesToAddress(logItem.Topics[1].Bytes())
+	case *contracts.InternalNetworkEtherReceival:
+		tradeLog := b.current(logItem, tradeDone, ts)
+		tradeLog.EtherReceivalSender = e.Sender
+		tradeLog.EtherReceivalAmount = e.Amount
+	case *contracts.InternalNetworkKyberTrade:
+		// KyberTrade is followed by ExecuteTrade of the same trade if the
+		// trade goes through network proxy.
+		tradeLog := b.current(logItem, tradeDone, ts)
+		tradeLog.LogIndex = logItem.Index
+		tradeLog.SrcAddress = e.SrcToken
+		tradeLog.DestAddress = e.DestToken
+		tradeLog.SrcAmount = e.SrcAmount
+		tradeLog.DestAmount = e.DestAmount
+		tradeLog.UserAddress = e.SrcAddress
+		b.kyberTrade = true
+	case *executeTrade:
+		tradeLog := b.current(logItem, b.executeTrade, ts)
+		tradeLog.LogIndex = logItem.Index
+		tradeLog.SrcAddress = e.Src
+		tradeLog.DestAddress = e.Dest
+		tradeLog.SrcAmount = e.ActualSrcAmount
+		tradeLog.DestAmount = e.ActualDestAmount
+		tradeLog.UserAddress = e.Trader
+		b.executeTrade = true
 	}
-
-	if updateLastLog {
-		allLogs[len(allLogs)-1] = tradeLog
-	} else {
-		allLogs = append(allLogs, tradeLog)
-	}
-
-	return allLogs, nil
 }
 
 // TODO: this function now belongs to reporting API
@@ -208,48 +145,127 @@ func updateTradeLogs(allLogs []common.TradeLog, logItem types.Log, ts time.Time)
 //}
 
 // NewTradeLogCrawler create a new TradeLogCrawler instance.
-func NewTradeLogCrawler(sugar *zap.SugaredLogger, nodeURL string, broadcastClient broadcast.Interface) (*TradeLogCrawler, error) {
-	client, err := ethclient.Dial(nodeURL)
+// The logs are read from given Ethereum client, the timestamps of trades are
+// resolved by given block time resolver.
+// The contracts addresses are resolved from registry by block range.
+// The logs of a block range are fetched in chunks of chunkSize blocks, using
+// at most maxWorkers concurrent requests. The crawled trade logs are passed
+// through given enrichers in order.
+func NewTradeLogCrawler(sugar *zap.SugaredLogger, client blockchain.Client, resolver blockchain.BlockTimeResolverInterface,
+	registry *deployment.Registry, chunkSize uint64, maxWorkers int, enrichers ...Enricher) (*TradeLogCrawler, error) {
+	decoder, err := newEventDecoder()
 	if err != nil {
 		return nil, err
 	}
-	resolver, err := blockchain.NewBlockTimeResolver(sugar, client)
-	return &TradeLogCrawler{sugar, client, resolver, broadcastClient}, nil
+	return &TradeLogCrawler{
+		sugar:      sugar,
+		ethClient:  client,
+		registry:   registry,
+		decoder:    decoder,
+		logFetcher: newLogFetcher(sugar, client, chunkSize, maxWorkers),
+		txTime:     resolver,
+		enrichers:  enrichers,
+	}, nil
 }
 
-// GetTradeLogs returns trade logs from KyberNetwork.
-func (crawler *TradeLogCrawler) GetTradeLogs(fromBlock, toBlock *big.Int, timeout time.Duration) ([]common.TradeLog, error) {
+// FilterQuery returns the query to filter logs of trade log events emitted by
+// the contracts deployed in given block range. It returns false if there is
+// no contract deployed in the range, as an empty addresses filter matches logs
+// of all contracts. The query matches the logs of a contract in the whole
+// range, the logs emitted outside of its deployment are dropped on decoding.
+func (crawler *TradeLogCrawler) FilterQuery(fromBlock, toBlock uint64) (ether.FilterQuery, bool) {
+	var addresses []ethereum.Address
+	for _, contract := range tradeLogContracts {
+		addresses = append(addresses, crawler.registry.Addresses(contract, fromBlock, toBlock)...)
+	}
+	if len(addresses) == 0 {
+		return ether.FilterQuery{}, false
+	}
+
+	return ether.FilterQuery{
+		Addresses: addresses,
+		Topics:    [][]ethereum.Hash{crawler.decoder.topics()},
+	}, true
+}
+
+// NextDeploymentChange returns the first block after given block at which a
+// trade log contract is deployed or retired, so the logs filter of a long
+// running subscription has to be renewed. It returns false if no change is
+// configured after given block.
+func (crawler *TradeLogCrawler) NextDeploymentChange(block uint64) (uint64, bool) {
 	var (
-		result []common.TradeLog
+		next  uint64
+		found bool
 	)
+	update := func(candidate uint64) {
+		if candidate > block && (!found || candidate < next) {
+			next, found = candidate, true
+		}
+	}
+	for _, contract := range tradeLogContracts {
+		for _, d := range crawler.registry.Deployments(contract) {
+			update(d.StartBlock)
+			if d.EndBlock != 0 {
+				update(d.EndBlock + 1)
+			}
+		}
+	}
+	return next, found
+}
 
-	addresses := []ethereum.Address{
-		ethereum.HexToAddress(pricingAddr),         // pricing
-		ethereum.HexToAddress(networkAddr),         // network
-		ethereum.HexToAddress(burnerAddr),          // burner
-		ethereum.HexToAddress(internalNetworkAddr), // internal network
+// GetTradeLogs returns trade logs from KyberNetwork.
+// The timeout is applied to each log fetching request sent to node.
+func (crawler *TradeLogCrawler) GetTradeLogs(fromBlock, toBlock *big.Int, timeout time.Duration) ([]common.TradeLog, error) {
+	query, ok := crawler.FilterQuery(fromBlock.Uint64(), toBlock.Uint64())
+	if !ok {
+		return nil, nil
 	}
 
-	topics := [][]ethereum.Hash{
-		{
-			ethereum.HexToHash(tradeEvent),
-			ethereum.HexToHash(burnFeeEvent),
-			ethereum.HexToHash(feeToWalletEvent),
-			ethereum.HexToHash(etherReceivalEvent),
-		},
+	logs, err := crawler.logFetcher.fetch(query, fromBlock.Uint64(), toBlock.Uint64(), timeout)
+	if err != nil {
+		return nil, err
 	}
+	return crawler.BuildTradeLogs(logs)
+}
 
-	query := ether.FilterQuery{
-		FromBlock: fromBlock,
-		ToBlock:   toBlock,
-		Addresses: addresses,
-		Topics:    topics,
+// BuildTradeLogs assembles the enriched trade logs from given logs, which
+// must be sorted by block number and log index.
+func (crawler *TradeLogCrawler) BuildTradeLogs(logs []types.Log) ([]common.TradeLog, error) {
+	result, err := crawler.decodeTradeLogs(logs, decodedLogs)
+	if err != nil || len(result) == 0 {
+		return result, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+	for _, enricher := range crawler.enrichers {
+		crawler.sugar.Debugw("enriching trade logs", "enricher", enricher.Name(), "trade_logs", len(result))
+		if result, err = enricher.Enrich(result); err != nil {
+			return nil, fmt.Errorf("%s enricher: %s", enricher.Name(), err)
+		}
+	}
 
-	logs, err := crawler.ethClient.FilterLogs(ctx, query)
+	return result, nil
+}
+
+// decodeTradeLogs assembles the trade logs from given logs without enrichment,
+// the logs must be sorted by block number and log index. The decoded logs are
+// counted by event in given counter if it is not nil, so the logs decoded
+// again for verification are not counted twice.
+func (crawler *TradeLogCrawler) decodeTradeLogs(logs []types.Log, decoded *prometheus.CounterVec) ([]common.TradeLog, error) {
+	var (
+		result       []common.TradeLog
+		builder      = &tradeLogBuilder{}
+		blockNumbers []uint64
+	)
+
+	for _, logItem := range logs {
+		if !logItem.Removed && crawler.isDeployed(logItem) {
+			blockNumbers = append(blockNumbers, logItem.BlockNumber)
+		}
+	}
+	if len(blockNumbers) == 0 {
+		return result, nil
+	}
+	timestamps, err := crawler.txTime.ResolveMany(blockNumbers)
 	if err != nil {
 		return result, err
 	}
@@ -258,37 +274,33 @@ func (crawler *TradeLogCrawler) GetTradeLogs(fromBlock, toBlock *big.Int, timeou
 		if logItem.Removed {
 			continue // Removed due to chain reorg
 		}
-
-		ts, err := crawler.txTime.Resolve(logItem.BlockNumber)
-
-		if err != nil {
-			return result, err
+		if !crawler.isDeployed(logItem) {
+			continue // emitted by a contract out of its deployment
 		}
+		ts := timestamps[logItem.BlockNumber]
 
-		topic := logItem.Topics[0]
-		switch topic.Hex() {
-		case feeToWalletEvent, burnFeeEvent, etherReceivalEvent, tradeEvent:
-			// add logItem to result
-			if result, err = updateTradeLogs(result, logItem, ts); err != nil {
-				return result, err
-			}
-		default:
+		event, err := crawler.decoder.decode(logItem)
+		if err == errUnknownEvent {
 			crawler.sugar.Info("Unknown log topic.")
+			continue
+		} else if err != nil {
+			return result, err
 		}
+		if decoded != nil {
+			decoded.WithLabelValues(crawler.decoder.eventName(logItem)).Inc()
+		}
+		builder.add(logItem, event, ts)
 	}
+	return builder.tradeLogs, nil
+}
 
-	for i, tradeLog := range result {
-		var (
-			ip, country string
-		)
-
-		ip, country, err = crawler.broadcastClient.GetTxInfo(tradeLog.TransactionHash.Hex())
-		if err != nil {
-			return result, err
+// isDeployed returns true if given log is emitted by a trade log contract at a
+// block that the contract is used by KyberNetwork.
+func (crawler *TradeLogCrawler) isDeployed(logItem types.Log) bool {
+	for _, contract := range tradeLogContracts {
+		if crawler.registry.IsActive(contract, logItem.Address, logItem.BlockNumber) {
+			return true
 		}
-		result[i].IP = ip
-		result[i].Country = country
 	}
-
-	return result, nil
+	return false
 }
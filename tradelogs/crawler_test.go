@@ -0,0 +1,223 @@
+package tradelogs
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/KyberNetwork/reserve-stats/lib/contracts"
+	"github.com/KyberNetwork/reserve-stats/lib/deployment"
+)
+
+func TestTradeLogBuilderMultipleTrades(t *testing.T) {
+	var (
+		ts       = time.Unix(1539000000, 0).UTC()
+		txHash   = ethereum.HexToHash("0x1")
+		otherTx  = ethereum.HexToHash("0x2")
+		reserve1 = ethereum.HexToAddress("0x63825c174ab367968ec60f061753d3bbd36a0d8f")
+		reserve2 = ethereum.HexToAddress("0x21433dec9cb634a23c6a4bbcce08c83f5ac2ec18")
+		eth      = ethereum.HexToAddress("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee")
+		knc      = ethereum.HexToAddress("0xdd974d5c2e2928dea5f71b9825b8b646686bd200")
+		builder  = &tradeLogBuilder{}
+	)
+
+	events := []struct {
+		log   types.Log
+		event interface{}
+	}{
+		// token to token trade through network proxy
+		{types.Log{TxHash: txHash, Index: 0}, &contracts.InternalNetworkEtherReceival{Sender: reserve1, Amount: big.NewInt(10)}},
+		{types.Log{TxHash: txHash, Index: 1}, &assignBurnFees{Reserve: reserve1, BurnFee: big.NewInt(1)}},
+		{types.Log{TxHash: txHash, Index: 2}, &assignBurnFees{Reserve: reserve2, BurnFee: big.NewInt(2)}},
+		{types.Log{TxHash: txHash, Index: 3}, &contracts.InternalNetworkKyberTrade{SrcToken: knc, DestToken: knc}},
+		{types.Log{TxHash: txHash, Index: 4}, &executeTrade{Src: knc, Dest: knc}},
+		// ETH to token trade in the same transaction
+		{types.Log{TxHash: txHash, Index: 5}, &assignFeeToWallet{Reserve: reserve2, WalletFee: big.NewInt(3)}},
+		{types.Log{TxHash: txHash, Index: 6}, &assignBurnFees{Reserve: reserve2, BurnFee: big.NewInt(4)}},
+		{types.Log{TxHash: txHash, Index: 7}, &contracts.InternalNetworkKyberTrade{SrcToken: eth, DestToken: knc}},
+		{types.Log{TxHash: txHash, Index: 8}, &executeTrade{Src: eth, Dest: knc}},
+		// trade without fees in the same transaction
+		{types.Log{TxHash: txHash, Index: 9}, &executeTrade{Src: knc, Dest: eth}},
+		// trade of another transaction
+		{types.Log{TxHash: otherTx, Index: 10}, &assignBurnFees{Reserve: reserve1, BurnFee: big.NewInt(5)}},
+		{types.Log{TxHash: otherTx, Index: 11}, &executeTrade{Src: eth, Dest: knc}},
+	}
+
+	for _, e := range events {
+		builder.add(e.log, e.event, ts)
+	}
+
+	tradeLogs := builder.tradeLogs
+	if !assert.Len(t, tradeLogs, 4) {
+		return
+	}
+
+	assert.Equal(t, uint(4), tradeLogs[0].LogIndex)
+	assert.Equal(t, big.NewInt(10), tradeLogs[0].EtherReceivalAmount)
+	assert.Len(t, tradeLogs[0].BurnFees, 2)
+	assert.Len(t, tradeLogs[0].WalletFees, 0)
+
+	assert.Equal(t, uint(8), tradeLogs[1].LogIndex)
+	assert.Equal(t, eth, tradeLogs[1].SrcAddress)
+	assert.Nil(t, tradeLogs[1].EtherReceivalAmount)
+	assert.Len(t, tradeLogs[1].BurnFees, 1)
+	assert.Len(t, tradeLogs[1].WalletFees, 1)
+
+	assert.Equal(t, uint(9), tradeLogs[2].LogIndex)
+	assert.Equal(t, eth, tradeLogs[2].DestAddress)
+	assert.Len(t, tradeLogs[2].BurnFees, 0)
+
+	assert.Equal(t, otherTx, tradeLogs[3].TransactionHash)
+	assert.Equal(t, uint(11), tradeLogs[3].LogIndex)
+	assert.Len(t, tradeLogs[3].BurnFees, 1)
+}
+
+func TestCrawlerIsDeployed(t *testing.T) {
+	var (
+		oldNetwork = ethereum.HexToAddress("0x91a502c678605fbce581eae053319747482276b9")
+		newNetwork = ethereum.HexToAddress("0x818e6fecd516ecc3849daf6845e3ec868087b755")
+		other      = ethereum.HexToAddress("0x63825c174ab367968ec60f061753d3bbd36a0d8f")
+	)
+	registry, err := deployment.NewRegistry(deployment.Ropsten, deployment.Config{
+		Contracts: map[deployment.Contract][]deployment.Deployment{
+			deployment.NetworkContract: {
+				{Address: oldNetwork, StartBlock: 100, EndBlock: 199},
+				{Address: newNetwork, StartBlock: 200},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := newEventDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawler := &TradeLogCrawler{registry: registry, decoder: decoder}
+
+	query, ok := crawler.FilterQuery(150, 250)
+	assert.True(t, ok)
+	assert.Equal(t, []ethereum.Address{oldNetwork, newNetwork}, query.Addresses)
+
+	// the old contract is queried in the whole range, its logs after the end
+	// block are dropped
+	assert.True(t, crawler.isDeployed(types.Log{Address: oldNetwork, BlockNumber: 199}))
+	assert.False(t, crawler.isDeployed(types.Log{Address: oldNetwork, BlockNumber: 200}))
+	assert.False(t, crawler.isDeployed(types.Log{Address: newNetwork, BlockNumber: 199}))
+	assert.True(t, crawler.isDeployed(types.Log{Address: newNetwork, BlockNumber: 200}))
+	assert.False(t, crawler.isDeployed(types.Log{Address: other, BlockNumber: 200}))
+}
+
+func TestCrawlerNextDeploymentChange(t *testing.T) {
+	registry, err := deployment.NewRegistry(deployment.Ropsten, deployment.Config{
+		Contracts: map[deployment.Contract][]deployment.Deployment{
+			deployment.NetworkContract: {
+				{Address: ethereum.HexToAddress("0x91a502c678605fbce581eae053319747482276b9"), StartBlock: 100, EndBlock: 199},
+				{Address: ethereum.HexToAddress("0x818e6fecd516ecc3849daf6845e3ec868087b755"), StartBlock: 250},
+			},
+			deployment.BurnerContract: {
+				{Address: ethereum.HexToAddress("0x63825c174ab367968ec60f061753d3bbd36a0d8f"), StartBlock: 150},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawler := &TradeLogCrawler{registry: registry}
+
+	var tests = []struct {
+		block uint64
+		next  uint64
+		found bool
+	}{
+		{block: 0, next: 100, found: true},
+		{block: 100, next: 150, found: true},
+		{block: 150, next: 200, found: true},
+		{block: 200, next: 250, found: true},
+		{block: 250, found: false},
+	}
+	for _, tc := range tests {
+		next, found := crawler.NextDeploymentChange(tc.block)
+		assert.Equal(t, tc.found, found, "block %d", tc.block)
+		assert.Equal(t, tc.next, next, "block %d", tc.block)
+	}
+}
+
+// fixedTimeResolver resolves all blocks to the same time.
+type fixedTimeResolver struct {
+	ts time.Time
+}
+
+func (r fixedTimeResolver) Resolve(uint64) (time.Time, error) {
+	return r.ts, nil
+}
+
+func (r fixedTimeResolver) ResolveMany(blockNumbers []uint64) (map[uint64]time.Time, error) {
+	result := make(map[uint64]time.Time)
+	for _, block := range blockNumbers {
+		result[block] = r.ts
+	}
+	return result, nil
+}
+
+func TestDecodeTradeLogsCountsDecodedLogs(t *testing.T) {
+	var (
+		network = ethereum.HexToAddress("0x818e6fecd516ecc3849daf6845e3ec868087b755")
+		trader  = ethereum.HexToAddress("0x8fa07f46353a2b17e92645592a94a0fc1ceb783f")
+		eth     = ethereum.HexToAddress("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee")
+		knc     = ethereum.HexToAddress("0xdd974d5c2e2928dea5f71b9825b8b646686bd200")
+	)
+	registry, err := deployment.NewRegistry(deployment.Mainnet, deployment.Config{
+		Contracts: map[deployment.Contract][]deployment.Deployment{
+			deployment.NetworkContract: {{Address: network}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := newEventDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawler := &TradeLogCrawler{
+		registry: registry,
+		decoder:  decoder,
+		txTime:   fixedTimeResolver{ts: time.Unix(1539000000, 0).UTC()},
+	}
+
+	logs := []types.Log{{
+		Address:     network,
+		BlockNumber: 100,
+		Topics: []ethereum.Hash{
+			ethereum.HexToHash("0x1849bd6a030a1bca28b83437fd3de96f3d27a5d172fa7e9c78e7b61468928a39"),
+			ethereum.BytesToHash(trader.Bytes()),
+		},
+		Data: packEventData(t, networkProxyEventsABI, "ExecuteTrade", eth, knc, big.NewInt(100), big.NewInt(200)),
+	}}
+
+	decoded := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_decoded_logs_total"}, []string{"event"})
+	count := func() float64 {
+		var m dto.Metric
+		if err := decoded.WithLabelValues("ExecuteTrade").Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		return m.GetCounter().GetValue()
+	}
+
+	tradeLogs, err := crawler.decodeTradeLogs(logs, decoded)
+	assert.NoError(t, err)
+	assert.Len(t, tradeLogs, 1)
+	assert.Equal(t, float64(1), count())
+
+	// decoding again for verification is not counted
+	tradeLogs, err = crawler.decodeTradeLogs(logs, nil)
+	assert.NoError(t, err)
+	assert.Len(t, tradeLogs, 1)
+	assert.Equal(t, float64(1), count())
+}
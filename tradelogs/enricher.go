@@ -0,0 +1,20 @@
+package tradelogs
+
+import (
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+// Enricher adds information that is not available in event logs to trade logs,
+// like geo information of the transaction or ETH/USD rate at the time of trade.
+type Enricher interface {
+	// Name returns the name of enricher, used in logging.
+	Name() string
+	// Enrich returns the given trade logs with enriched information.
+	Enrich(tradeLogs []common.TradeLog) ([]common.TradeLog, error)
+}
+
+// sameTx returns true if the trade log at index i belongs to the same transaction
+// as the previous one, the transaction information of those trade logs are identical.
+func sameTx(tradeLogs []common.TradeLog, i int) bool {
+	return i > 0 && tradeLogs[i-1].TransactionHash == tradeLogs[i].TransactionHash
+}
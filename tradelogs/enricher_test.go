@@ -0,0 +1,150 @@
+package tradelogs
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+type mockBroadcast struct {
+	mu    sync.Mutex
+	calls int
+	// active and maxActive are the current and maximum number of concurrent calls.
+	active    int
+	maxActive int
+	delay     time.Duration
+}
+
+func (mb *mockBroadcast) GetTxInfo(_ context.Context, tx string) (string, string, error) {
+	mb.mu.Lock()
+	mb.calls++
+	mb.active++
+	if mb.active > mb.maxActive {
+		mb.maxActive = mb.active
+	}
+	mb.mu.Unlock()
+
+	time.Sleep(mb.delay)
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.active--
+	return "127.0.0.1", "VN", nil
+}
+
+type mockRateFetcher struct {
+	rate float64
+	err  error
+}
+
+func (mf *mockRateFetcher) FetchRates(blockNumber uint64, timestamp time.Time) (tokenrate.ETHUSDRate, error) {
+	return tokenrate.ETHUSDRate{
+		Timestamp:   timestamp,
+		Rate:        mf.rate,
+		Provider:    "mock",
+		BlockNumber: blockNumber,
+	}, mf.err
+}
+
+func newTestSugar(t *testing.T) *zap.SugaredLogger {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger.Sugar()
+}
+
+func TestGeoEnricher(t *testing.T) {
+	var (
+		client    = &mockBroadcast{}
+		ge        = NewGeoEnricher(newTestSugar(t), client)
+		tradeLogs = []common.TradeLog{
+			{TransactionHash: ethereum.HexToHash("0x01")},
+			{TransactionHash: ethereum.HexToHash("0x01")},
+			{TransactionHash: ethereum.HexToHash("0x02")},
+		}
+	)
+
+	tradeLogs, err := ge.Enrich(tradeLogs)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, client.calls, "trades of the same transaction should be looked up once")
+	for _, tradeLog := range tradeLogs {
+		assert.Equal(t, "127.0.0.1", tradeLog.IP)
+		assert.Equal(t, "VN", tradeLog.Country)
+	}
+}
+
+func TestGeoEnricherMaxConcurrency(t *testing.T) {
+	var (
+		client    = &mockBroadcast{delay: 5 * time.Millisecond}
+		ge        = NewGeoEnricher(newTestSugar(t), client, WithGeoMaxConcurrency(2))
+		tradeLogs []common.TradeLog
+	)
+	for i := 0; i < 10; i++ {
+		tradeLogs = append(tradeLogs, common.TradeLog{TransactionHash: ethereum.BigToHash(big.NewInt(int64(i)))})
+	}
+
+	_, err := ge.Enrich(tradeLogs)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, client.calls)
+	assert.Equal(t, 2, client.maxActive)
+}
+
+func TestFiatRateEnricher(t *testing.T) {
+	var (
+		fetcher   = &mockRateFetcher{rate: 200.5}
+		fe        = NewFiatRateEnricher(newTestSugar(t), fetcher)
+		tradeLogs = []common.TradeLog{{BlockNumber: 1}, {BlockNumber: 2}}
+	)
+
+	tradeLogs, err := fe.Enrich(tradeLogs)
+	assert.NoError(t, err)
+	for _, tradeLog := range tradeLogs {
+		assert.Equal(t, 200.5, tradeLog.ETHUSDRate)
+		assert.Equal(t, "mock", tradeLog.ETHUSDProvider)
+	}
+
+	fetcher.rate = 0
+	_, err = fe.Enrich(tradeLogs)
+	assert.EqualError(t, err, "eth usd is zero")
+
+	fetcher.err = errors.New("provider unavailable")
+	_, err = fe.Enrich(tradeLogs)
+	assert.EqualError(t, err, "provider unavailable")
+}
+
+func TestLabelEnricher(t *testing.T) {
+	var (
+		reserve = ethereum.HexToAddress("0x63825c174ab367968EC60f061753D3bbD36A0D8F")
+		wallet  = ethereum.HexToAddress("0xb9E29984Fe50602E7A619662EBED4F90D93824C7")
+		other   = ethereum.HexToAddress("0x0000000000000000000000000000000000000001")
+		le      = NewLabelEnricher(map[ethereum.Address]string{
+			reserve: "KN",
+			wallet:  "Partner",
+		})
+		tradeLogs = []common.TradeLog{
+			{
+				BurnFees:   []common.BurnFee{{ReserveAddress: reserve}},
+				WalletFees: []common.WalletFee{{ReserveAddress: reserve, WalletAddress: wallet}, {ReserveAddress: other, WalletAddress: other}},
+			},
+		}
+	)
+
+	tradeLogs, err := le.Enrich(tradeLogs)
+	assert.NoError(t, err)
+	assert.Equal(t, "KN", tradeLogs[0].BurnFees[0].ReserveName)
+	assert.Equal(t, "KN", tradeLogs[0].WalletFees[0].ReserveName)
+	assert.Equal(t, "Partner", tradeLogs[0].WalletFees[0].WalletName)
+	assert.Empty(t, tradeLogs[0].WalletFees[1].ReserveName)
+	assert.Empty(t, tradeLogs[0].WalletFees[1].WalletName)
+}
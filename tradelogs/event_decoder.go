@@ -0,0 +1,164 @@
+package tradelogs
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/KyberNetwork/reserve-stats/lib/contracts"
+)
+
+const (
+	// networkProxyEventsABI is the ABI of events emitted by KyberNetworkProxy contract.
+	networkProxyEventsABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"trader","type":"address"},{"indexed":false,"name":"src","type":"address"},{"indexed":false,"name":"dest","type":"address"},{"indexed":false,"name":"actualSrcAmount","type":"uint256"},{"indexed":false,"name":"actualDestAmount","type":"uint256"}],"name":"ExecuteTrade","type":"event"}]`
+
+	// feeBurnerEventsABI is the ABI of events emitted by FeeBurner contract.
+	feeBurnerEventsABI = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"reserve","type":"address"},{"indexed":false,"name":"wallet","type":"address"},{"indexed":false,"name":"walletFee","type":"uint256"}],"name":"AssignFeeToWallet","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"name":"reserve","type":"address"},{"indexed":false,"name":"burnFee","type":"uint256"}],"name":"AssignBurnFees","type":"event"}]`
+)
+
+// errUnknownEvent is returned when decoding a log which topic is not a known event.
+var errUnknownEvent = errors.New("unknown event")
+
+var decodedLogs = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "trade_logs_decoded_logs_total",
+	Help: "Number of crawled logs decoded by event type.",
+}, []string{"event"})
+
+func init() {
+	prometheus.MustRegister(decodedLogs)
+}
+
+// executeTrade is the event ExecuteTrade(address indexed trader, ERC20 src, ERC20 dest, uint actualSrcAmount, uint actualDestAmount).
+type executeTrade struct {
+	Trader           ethereum.Address
+	Src              ethereum.Address
+	Dest             ethereum.Address
+	ActualSrcAmount  *big.Int
+	ActualDestAmount *big.Int
+}
+
+// assignFeeToWallet is the event AssignFeeToWallet(address reserve, address wallet, uint walletFee).
+type assignFeeToWallet struct {
+	Reserve   ethereum.Address
+	Wallet    ethereum.Address
+	WalletFee *big.Int
+}
+
+// assignBurnFees is the event AssignBurnFees(address reserve, uint burnFee).
+type assignBurnFees struct {
+	Reserve ethereum.Address
+	BurnFee *big.Int
+}
+
+// tradeLogEvents is the events to build trade logs from. To support a new event
+// or a new version of an existing event, add its contract ABI and the struct to
+// decode it to here.
+var tradeLogEvents = []struct {
+	abi      string
+	name     string
+	newEvent func() interface{}
+}{
+	{networkProxyEventsABI, "ExecuteTrade", func() interface{} { return new(executeTrade) }},
+	{feeBurnerEventsABI, "AssignFeeToWallet", func() interface{} { return new(assignFeeToWallet) }},
+	{feeBurnerEventsABI, "AssignBurnFees", func() interface{} { return new(assignBurnFees) }},
+	{contracts.InternalNetworkABI, "EtherReceival", func() interface{} { return new(contracts.InternalNetworkEtherReceival) }},
+	{contracts.InternalNetworkABI, "KyberTrade", func() interface{} { return new(contracts.InternalNetworkKyberTrade) }},
+}
+
+// eventSpec is a known event and the contract ABI to decode it with.
+type eventSpec struct {
+	name     string
+	contract *bind.BoundContract
+	newEvent func() interface{}
+}
+
+// eventDecoder decodes logs of known events to typed structs using contracts ABI.
+type eventDecoder struct {
+	events map[ethereum.Hash]eventSpec
+}
+
+func newEventDecoder() (*eventDecoder, error) {
+	type parsedABI struct {
+		abi      abi.ABI
+		contract *bind.BoundContract
+	}
+
+	var (
+		parsed = make(map[string]parsedABI)
+		events = make(map[ethereum.Hash]eventSpec)
+	)
+
+	for _, e := range tradeLogEvents {
+		p, ok := parsed[e.abi]
+		if !ok {
+			contractABI, err := abi.JSON(strings.NewReader(e.abi))
+			if err != nil {
+				return nil, err
+			}
+			p = parsedABI{
+				abi: contractABI,
+				// the contract is only used to unpack logs, no backend is needed
+				contract: bind.NewBoundContract(ethereum.Address{}, contractABI, nil, nil, nil),
+			}
+			parsed[e.abi] = p
+		}
+
+		event, ok := p.abi.Events[e.name]
+		if !ok {
+			return nil, fmt.Errorf("event %s is not found in ABI", e.name)
+		}
+		if _, ok = events[event.Id()]; ok {
+			return nil, fmt.Errorf("duplicated event %s", event.String())
+		}
+		events[event.Id()] = eventSpec{
+			name:     e.name,
+			contract: p.contract,
+			newEvent: e.newEvent,
+		}
+	}
+	return &eventDecoder{events: events}, nil
+}
+
+// topics returns the topics of all known events.
+func (d *eventDecoder) topics() []ethereum.Hash {
+	var topics []ethereum.Hash
+	for topic := range d.events {
+		topics = append(topics, topic)
+	}
+	sort.Slice(topics, func(i, j int) bool {
+		return topics[i].Hex() < topics[j].Hex()
+	})
+	return topics
+}
+
+// decode returns the typed struct of the event of given log.
+// errUnknownEvent is returned if the log topic is not a known event.
+func (d *eventDecoder) decode(log types.Log) (interface{}, error) {
+	if len(log.Topics) == 0 {
+		return nil, errors.New("log item has no topic")
+	}
+
+	spec, ok := d.events[log.Topics[0]]
+	if !ok {
+		return nil, errUnknownEvent
+	}
+
+	event := spec.newEvent()
+	if err := spec.contract.UnpackLog(event, spec.name, log); err != nil {
+		return nil, fmt.Errorf("invalid %s event data: %s", spec.name, err)
+	}
+	return event, nil
+}
+
+// eventName returns the name of the event of given log, which must be decoded.
+func (d *eventDecoder) eventName(log types.Log) string {
+	return d.events[log.Topics[0]].name
+}
@@ -0,0 +1,105 @@
+package tradelogs
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/KyberNetwork/reserve-stats/lib/contracts"
+)
+
+// packEventData returns the log data of given event with non indexed arguments.
+func packEventData(t *testing.T, contractABI, name string, args ...interface{}) []byte {
+	parsed, err := abi.JSON(strings.NewReader(contractABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parsed.Events[name].Inputs.NonIndexed().Pack(args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEventDecoderTopics(t *testing.T) {
+	decoder, err := newEventDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var topics []string
+	for _, topic := range decoder.topics() {
+		topics = append(topics, topic.Hex())
+	}
+	assert.ElementsMatch(t, []string{
+		// ExecuteTrade
+		"0x1849bd6a030a1bca28b83437fd3de96f3d27a5d172fa7e9c78e7b61468928a39",
+		// AssignFeeToWallet
+		"0x366bc34352215bf0bd3b527cfd6718605e1f5938777e42bcd8ed92f578368f52",
+		// AssignBurnFees
+		"0xf838f6ddc89706878e3c3e698e9b5cbfbf2c0e3d3dcd0bd2e00f1ccf313e0185",
+		// EtherReceival
+		"0x75f33ed68675112c77094e7c5b073890598be1d23e27cd7f6907b4a7d98ac619",
+		// KyberTrade
+		"0x1c8399ecc5c956b9cb18c820248b10b634cca4af308755e07cd467655e8ec3c7",
+	}, topics)
+}
+
+func TestEventDecoderDecode(t *testing.T) {
+	decoder, err := newEventDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		trader = ethereum.HexToAddress("0x8fa07f46353a2b17e92645592a94a0fc1ceb783f")
+		src    = ethereum.HexToAddress("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee")
+		dest   = ethereum.HexToAddress("0xdd974d5c2e2928dea5f71b9825b8b646686bd200")
+	)
+
+	executeTradeLog := types.Log{
+		Topics: []ethereum.Hash{
+			ethereum.HexToHash("0x1849bd6a030a1bca28b83437fd3de96f3d27a5d172fa7e9c78e7b61468928a39"),
+			ethereum.BytesToHash(trader.Bytes()),
+		},
+		Data: packEventData(t, networkProxyEventsABI, "ExecuteTrade", src, dest, big.NewInt(100), big.NewInt(200)),
+	}
+	event, err := decoder.decode(executeTradeLog)
+	assert.NoError(t, err)
+	assert.Equal(t, &executeTrade{
+		Trader:           trader,
+		Src:              src,
+		Dest:             dest,
+		ActualSrcAmount:  big.NewInt(100),
+		ActualDestAmount: big.NewInt(200),
+	}, event)
+
+	kyberTradeLog := types.Log{
+		Topics: []ethereum.Hash{
+			ethereum.HexToHash("0x1c8399ecc5c956b9cb18c820248b10b634cca4af308755e07cd467655e8ec3c7"),
+		},
+		Data: packEventData(t, contracts.InternalNetworkABI, "KyberTrade", trader, src, big.NewInt(100), trader, dest, big.NewInt(200)),
+	}
+	event, err = decoder.decode(kyberTradeLog)
+	assert.NoError(t, err)
+	kyberTrade, ok := event.(*contracts.InternalNetworkKyberTrade)
+	if !ok {
+		t.Fatalf("unexpected event type: %T", event)
+	}
+	assert.Equal(t, trader, kyberTrade.SrcAddress)
+	assert.Equal(t, dest, kyberTrade.DestToken)
+	assert.Equal(t, big.NewInt(200), kyberTrade.DestAmount)
+
+	// truncated data
+	executeTradeLog.Data = executeTradeLog.Data[:64]
+	_, err = decoder.decode(executeTradeLog)
+	assert.Error(t, err)
+
+	_, err = decoder.decode(types.Log{Topics: []ethereum.Hash{ethereum.HexToHash("0x01")}})
+	assert.Equal(t, errUnknownEvent, err)
+}
@@ -0,0 +1,48 @@
+package tradelogs
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+// ethUSDRateFetcher is the interface to get ETH/USD rate at the time of a block.
+type ethUSDRateFetcher interface {
+	FetchRates(blockNumber uint64, timestamp time.Time) (tokenrate.ETHUSDRate, error)
+}
+
+// FiatRateEnricher adds the ETH/USD rate at the time of trade to trade logs.
+type FiatRateEnricher struct {
+	sugar       *zap.SugaredLogger
+	rateFetcher ethUSDRateFetcher
+}
+
+// NewFiatRateEnricher creates a new FiatRateEnricher instance.
+func NewFiatRateEnricher(sugar *zap.SugaredLogger, rateFetcher ethUSDRateFetcher) *FiatRateEnricher {
+	return &FiatRateEnricher{sugar: sugar, rateFetcher: rateFetcher}
+}
+
+// Name returns the name of enricher.
+func (fe *FiatRateEnricher) Name() string {
+	return "fiat_rate"
+}
+
+// Enrich adds ETH/USD rate and its provider to trade logs.
+func (fe *FiatRateEnricher) Enrich(tradeLogs []common.TradeLog) ([]common.TradeLog, error) {
+	for i, tradeLog := range tradeLogs {
+		rate, err := fe.rateFetcher.FetchRates(tradeLog.BlockNumber, tradeLog.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		if rate.Rate <= 0 {
+			return nil, errors.New("eth usd is zero")
+		}
+		tradeLogs[i].ETHUSDRate = rate.Rate
+		tradeLogs[i].ETHUSDProvider = rate.Provider
+	}
+	return tradeLogs, nil
+}
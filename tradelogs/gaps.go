@@ -0,0 +1,169 @@
+package tradelogs
+
+import (
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/storage"
+)
+
+// CountMismatch is a block range which number of trades on chain is different
+// from the number of stored trades.
+type CountMismatch struct {
+	common.BlockRange
+	ChainTrades  uint64 `json:"chain_trades"`
+	StoredTrades uint64 `json:"stored_trades"`
+}
+
+// GapReport is the result of checking stored trade logs of a block range.
+type GapReport struct {
+	common.BlockRange
+	MissingRanges   []common.BlockRange `json:"missing_ranges"`
+	CountMismatches []CountMismatch     `json:"count_mismatches,omitempty"`
+}
+
+// MissingRanges returns the sub ranges of the block range from fromBlock to
+// toBlock, inclusive, that are not covered by any of given crawled ranges.
+func MissingRanges(crawled []common.BlockRange, fromBlock, toBlock uint64) []common.BlockRange {
+	var (
+		missing []common.BlockRange
+		sorted  = make([]common.BlockRange, len(crawled))
+		next    = fromBlock
+	)
+	copy(sorted, crawled)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FromBlock < sorted[j].FromBlock })
+
+	for _, r := range sorted {
+		if next > toBlock {
+			break
+		}
+		if r.ToBlock < next {
+			continue
+		}
+		if r.FromBlock > next {
+			end := r.FromBlock - 1
+			if end > toBlock {
+				end = toBlock
+			}
+			missing = append(missing, common.BlockRange{FromBlock: next, ToBlock: end})
+		}
+		next = r.ToBlock + 1
+		if next == 0 {
+			// overflow, the crawled range covers all remaining blocks
+			return missing
+		}
+	}
+	if next <= toBlock {
+		missing = append(missing, common.BlockRange{FromBlock: next, ToBlock: toBlock})
+	}
+	return missing
+}
+
+// GapChecker finds the block ranges that trade logs are not stored, using the
+// crawled block ranges recorded in storage.
+type GapChecker struct {
+	sugar   *zap.SugaredLogger
+	crawler *TradeLogCrawler
+	storage storage.Interface
+	timeout time.Duration
+}
+
+// NewGapChecker creates a new GapChecker instance.
+func NewGapChecker(sugar *zap.SugaredLogger, crawler *TradeLogCrawler, st storage.Interface, timeout time.Duration) *GapChecker {
+	return &GapChecker{
+		sugar:   sugar,
+		crawler: crawler,
+		storage: st,
+		timeout: timeout,
+	}
+}
+
+// Check returns the missing block ranges from fromBlock to toBlock, inclusive.
+// If countWindow is not zero, the crawled block ranges are also split to windows
+// of countWindow blocks to compare the number of trades on chain with the
+// number of stored trades.
+func (gc *GapChecker) Check(fromBlock, toBlock, countWindow uint64) (GapReport, error) {
+	logger := gc.sugar.With(
+		"func", "tradelogs/GapChecker.Check",
+		"from_block", fromBlock,
+		"to_block", toBlock,
+	)
+
+	report := GapReport{BlockRange: common.BlockRange{FromBlock: fromBlock, ToBlock: toBlock}}
+
+	crawled, err := gc.storage.LoadCrawledRanges(fromBlock, toBlock)
+	if err != nil {
+		return report, err
+	}
+	report.MissingRanges = MissingRanges(crawled, fromBlock, toBlock)
+	logger.Infow("checked crawled ranges", "crawled_ranges", len(crawled), "missing_ranges", len(report.MissingRanges))
+
+	if countWindow == 0 {
+		return report, nil
+	}
+
+	// the crawled sub ranges are the complement of missing ranges
+	for _, r := range MissingRanges(report.MissingRanges, fromBlock, toBlock) {
+		for from := r.FromBlock; from <= r.ToBlock; from += countWindow {
+			to := from + countWindow - 1
+			if to > r.ToBlock {
+				to = r.ToBlock
+			}
+			mismatch, err := gc.compareCounts(from, to)
+			if err != nil {
+				return report, err
+			}
+			if mismatch != nil {
+				logger.Warnw("trades count mismatch",
+					"window_from_block", from,
+					"window_to_block", to,
+					"chain_trades", mismatch.ChainTrades,
+					"stored_trades", mismatch.StoredTrades)
+				report.CountMismatches = append(report.CountMismatches, *mismatch)
+			}
+		}
+	}
+	return report, nil
+}
+
+// compareCounts returns a CountMismatch if the number of trades on chain in
+// given block range is different from the number of stored trades. The trades
+// on chain are assembled from the logs the same way as crawling, so trades
+// that do not go through network proxy are counted on both sides.
+func (gc *GapChecker) compareCounts(fromBlock, toBlock uint64) (*CountMismatch, error) {
+	query, ok := gc.crawler.FilterQuery(fromBlock, toBlock)
+	if !ok {
+		return nil, nil
+	}
+
+	logs, err := gc.crawler.logFetcher.fetch(query, fromBlock, toBlock, gc.timeout)
+	if err != nil {
+		return nil, err
+	}
+	tradeLogs, err := gc.crawler.decodeTradeLogs(logs, nil)
+	if err != nil {
+		return nil, err
+	}
+	chainTrades := uint64(len(tradeLogs))
+
+	times, err := gc.crawler.txTime.ResolveMany([]uint64{fromBlock, toBlock})
+	if err != nil {
+		return nil, err
+	}
+	storedTrades, err := gc.storage.CountTrades(times[fromBlock], times[toBlock])
+	if err != nil {
+		return nil, err
+	}
+
+	if chainTrades == storedTrades {
+		return nil, nil
+	}
+	return &CountMismatch{
+		BlockRange:   common.BlockRange{FromBlock: fromBlock, ToBlock: toBlock},
+		ChainTrades:  chainTrades,
+		StoredTrades: storedTrades,
+	}, nil
+}
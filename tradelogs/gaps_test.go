@@ -0,0 +1,51 @@
+package tradelogs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+func TestMissingRanges(t *testing.T) {
+	var tests = []struct {
+		name    string
+		crawled []common.BlockRange
+		missing []common.BlockRange
+	}{
+		{
+			name:    "nothing crawled",
+			missing: []common.BlockRange{{FromBlock: 100, ToBlock: 199}},
+		},
+		{
+			name:    "fully crawled",
+			crawled: []common.BlockRange{{FromBlock: 0, ToBlock: 150}, {FromBlock: 151, ToBlock: 300}},
+		},
+		{
+			name: "gaps in the middle and at both ends",
+			crawled: []common.BlockRange{
+				{FromBlock: 160, ToBlock: 179},
+				{FromBlock: 110, ToBlock: 129},
+				// overlapping range
+				{FromBlock: 120, ToBlock: 139},
+			},
+			missing: []common.BlockRange{
+				{FromBlock: 100, ToBlock: 109},
+				{FromBlock: 140, ToBlock: 159},
+				{FromBlock: 180, ToBlock: 199},
+			},
+		},
+		{
+			name:    "crawled ranges outside of checked range",
+			crawled: []common.BlockRange{{FromBlock: 0, ToBlock: 99}, {FromBlock: 200, ToBlock: 299}},
+			missing: []common.BlockRange{{FromBlock: 100, ToBlock: 199}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.missing, MissingRanges(tc.crawled, 100, 199))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package tradelogs
+
+import (
+	"context"
+	"sync"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/KyberNetwork/reserve-stats/lib/broadcast"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+// defaultGeoMaxConcurrency is the default maximum number of transactions
+// looked up concurrently. It is higher than the concurrency of broadcast
+// client, as the cached transactions are not sent to broadcast API.
+const defaultGeoMaxConcurrency = 16
+
+// GeoEnricher adds the IP and country of the transaction of trade logs
+// using broadcast API.
+type GeoEnricher struct {
+	sugar          *zap.SugaredLogger
+	client         broadcast.Interface
+	maxConcurrency int
+}
+
+// GeoEnricherOption configures the optional parameters of GeoEnricher.
+type GeoEnricherOption func(*GeoEnricher)
+
+// WithGeoMaxConcurrency sets the maximum number of transactions looked up concurrently.
+func WithGeoMaxConcurrency(n int) GeoEnricherOption {
+	return func(ge *GeoEnricher) {
+		if n > 0 {
+			ge.maxConcurrency = n
+		}
+	}
+}
+
+// NewGeoEnricher creates a new GeoEnricher instance.
+func NewGeoEnricher(sugar *zap.SugaredLogger, client broadcast.Interface, options ...GeoEnricherOption) *GeoEnricher {
+	ge := &GeoEnricher{sugar: sugar, client: client, maxConcurrency: defaultGeoMaxConcurrency}
+	for _, option := range options {
+		option(ge)
+	}
+	return ge
+}
+
+// Name returns the name of enricher.
+func (ge *GeoEnricher) Name() string {
+	return "geo"
+}
+
+// Enrich adds IP and country to trade logs. The transactions are looked up
+// concurrently by at most maxConcurrency goroutines.
+func (ge *GeoEnricher) Enrich(tradeLogs []common.TradeLog) ([]common.TradeLog, error) {
+	type geoInfo struct {
+		ip      string
+		country string
+	}
+
+	var (
+		mu      sync.Mutex
+		results = make(map[ethereum.Hash]geoInfo)
+		sem     = make(chan struct{}, ge.maxConcurrency)
+	)
+
+	g, ctx := errgroup.WithContext(context.Background())
+lookup:
+	for i, tradeLog := range tradeLogs {
+		// trades of the same transaction share the same tx info
+		if sameTx(tradeLogs, i) {
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			// a lookup failed, the error is returned by Wait
+			break lookup
+		}
+		txHash := tradeLog.TransactionHash
+		g.Go(func() error {
+			defer func() { <-sem }()
+			ip, country, err := ge.client.GetTxInfo(ctx, txHash.Hex())
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			results[txHash] = geoInfo{ip: ip, country: country}
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	for i, tradeLog := range tradeLogs {
+		info := results[tradeLog.TransactionHash]
+		tradeLogs[i].IP = info.ip
+		tradeLogs[i].Country = info.country
+	}
+	return tradeLogs, nil
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/KyberNetwork/reserve-stats/lib/core"
 	_ "github.com/KyberNetwork/reserve-stats/lib/httputil/validators" // import custom validator functions
+	"github.com/KyberNetwork/reserve-stats/lib/metrics"
 	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
 	"github.com/KyberNetwork/reserve-stats/tradelogs/storage"
 )
@@ -60,6 +61,26 @@ func validateTimeWindow(fromTime, toTime time.Time, freq string) error {
 	return nil
 }
 
+// queryTimeWindow validates the time window of query and returns it, the
+// default time window is the last hour.
+func queryTimeWindow(from, to uint64, freq string) (time.Time, time.Time, error) {
+	fromTime := timeutil.TimestampMsToTime(from)
+	toTime := timeutil.TimestampMsToTime(to)
+
+	if err := validateTimeWindow(fromTime, toTime, freq); err != nil {
+		return fromTime, toTime, err
+	}
+
+	if toTime.IsZero() {
+		toTime = time.Now()
+	}
+
+	if fromTime.IsZero() {
+		fromTime = toTime.Add(-time.Hour)
+	}
+	return fromTime, toTime, nil
+}
+
 func (sv *Server) getTradeLogs(c *gin.Context) {
 	var query tradeLogsQuery
 	if err := c.ShouldBindQuery(&query); err != nil {
@@ -116,10 +137,8 @@ func (sv *Server) getBurnFee(c *gin.Context) {
 		return
 	}
 
-	fromTime := timeutil.TimestampMsToTime(query.From)
-	toTime := timeutil.TimestampMsToTime(query.To)
-
-	if err := validateTimeWindow(fromTime, toTime, query.Freq); err != nil {
+	fromTime, toTime, err := queryTimeWindow(query.From, query.To, query.Freq)
+	if err != nil {
 		c.JSON(
 			http.StatusBadRequest,
 			gin.H{"error": err.Error()},
@@ -127,14 +146,6 @@ func (sv *Server) getBurnFee(c *gin.Context) {
 		return
 	}
 
-	if toTime.IsZero() {
-		toTime = time.Now()
-	}
-
-	if fromTime.IsZero() {
-		fromTime = toTime.Add(-time.Hour)
-	}
-
 	for _, rsvAddr := range query.ReserveAddrs {
 		rsvAddrs = append(rsvAddrs, ethereum.HexToAddress(rsvAddr))
 	}
@@ -157,9 +168,13 @@ func (sv *Server) getBurnFee(c *gin.Context) {
 
 func (sv *Server) setupRouter() *gin.Engine {
 	r := gin.Default()
+	metrics.Instrument(r)
 	r.GET("/trade-logs", sv.getTradeLogs)
 	r.GET("/burn-fee", sv.getBurnFee)
 	r.GET("/asset-volume", sv.getAssetVolume)
+	r.GET("/reserve-volume", sv.getReserveVolume)
+	r.GET("/wallet-fee", sv.getWalletFee)
+	r.GET("/wallet-stats", sv.getWalletStats)
 	return r
 }
 
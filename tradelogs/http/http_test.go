@@ -13,15 +13,14 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/KyberNetwork/reserve-stats/lib/httputil"
-	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
 	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/storage"
 )
 
+// mockStorage is a fake storage answering the queries of HTTP handlers,
+// calling any other method of storage.Interface panics.
 type mockStorage struct {
-}
-
-func (s *mockStorage) SaveTradeLogs(logs []common.TradeLog, rates []tokenrate.ETHUSDRate) error {
-	return nil
+	storage.Interface
 }
 
 func (s *mockStorage) LoadTradeLogs(from, to time.Time) ([]common.TradeLog, error) {
@@ -32,6 +31,14 @@ func (s *mockStorage) GetAggregatedBurnFee(from, to time.Time, freq string, rese
 	return nil, nil
 }
 
+func (s *mockStorage) GetAggregatedWalletFee(from, to time.Time, freq string, walletAddr ethereum.Address, reserveAddrs []ethereum.Address) (map[ethereum.Address]map[string]float64, error) {
+	return nil, nil
+}
+
+func (s *mockStorage) GetWalletStats(from, to time.Time, freq string, walletAddr ethereum.Address) (map[uint64]*common.WalletStats, error) {
+	return nil, nil
+}
+
 func newTestServer() (*Server, error) {
 	logger, err := zap.NewDevelopment()
 	if err != nil {
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KyberNetwork/reserve-stats/lib/core"
+	_ "github.com/KyberNetwork/reserve-stats/lib/httputil/validators" // import custom validator functions
+	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
+)
+
+type reserveVolumeQuery struct {
+	From    uint64 `form:"from"`
+	To      uint64 `form:"to"`
+	Reserve string `form:"reserve" binding:"required,isAddress"`
+	Asset   string `form:"asset"`
+	Freq    string `form:"freq"`
+}
+
+// getReserveVolume returns the volume handled by a reserve. If asset is given,
+// the volume of the asset is returned keyed by timestamp, otherwise the volume
+// of all traded assets is returned keyed by asset address and timestamp.
+func (sv *Server) getReserveVolume(c *gin.Context) {
+	var (
+		query     reserveVolumeQuery
+		logger    = sv.sugar.With("func", "tradelogs/http/Server.getReserveVolume")
+		tokenAddr ethereum.Address
+		tokens    []ethereum.Address
+	)
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+	switch strings.ToLower(query.Freq) {
+	case "":
+		logger.Debugw("using default frequency", "freq", hourlyFreq)
+		query.Freq = hourlyFreq
+	case hourlyFreq, dailyFreq:
+	default:
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": fmt.Sprintf("your query frequency is not supported, use %s or %s", hourlyFreq, dailyFreq)},
+		)
+		return
+	}
+
+	fromTime, toTime, err := queryTimeWindow(query.From, query.To, query.Freq)
+	if err != nil {
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	if query.Asset != "" {
+		token, err := core.LookupToken(sv.coreSetting, query.Asset)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if _, ok := err.(core.TokenNotFoundError); ok {
+				status = http.StatusBadRequest
+			}
+			c.JSON(
+				status,
+				gin.H{"error": err.Error()},
+			)
+			return
+		}
+		tokenAddr = ethereum.HexToAddress(token.Address)
+		tokens = append(tokens, tokenAddr)
+	}
+
+	result, err := sv.storage.GetReserveVolume(ethereum.HexToAddress(query.Reserve), tokens,
+		timeutil.TimeToTimestampMs(fromTime), timeutil.TimeToTimestampMs(toTime), query.Freq)
+	if err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	if query.Asset != "" {
+		c.JSON(http.StatusOK, result[tokenAddr])
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/lib/httputil"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+var testTokenAddr = ethereum.HexToAddress("0xdd974D5C2e2928deA5F71b9825b8b646686BD200")
+
+func (s *mockStorage) GetReserveVolume(rsvAddr ethereum.Address, tokenAddrs []ethereum.Address, fromTime, toTime uint64, frequency string) (map[ethereum.Address]map[uint64]*common.VolumeStats, error) {
+	mockVolumeStat := common.VolumeStats{
+		ETHAmount: testETHAmount,
+		USDAmount: testUSDAmount,
+		Volume:    testVolAmount,
+	}
+	result := make(map[ethereum.Address]map[uint64]*common.VolumeStats)
+	for _, tokenAddr := range []ethereum.Address{ethereum.HexToAddress(core.ETHToken.Address), testTokenAddr} {
+		if len(tokenAddrs) != 0 && tokenAddrs[0] != tokenAddr {
+			continue
+		}
+		result[tokenAddr] = map[uint64]*common.VolumeStats{
+			fromTime: &mockVolumeStat,
+			toTime:   &mockVolumeStat,
+		}
+	}
+	return result, nil
+}
+
+func TestReserveVolumeHttp(t *testing.T) {
+	var (
+		endpoint  = "/reserve-volume"
+		validFrom = 1539129600000
+		validTo   = 1539302400000
+		reserve   = "0x63825c174ab367968EC60f061753D3bbD36A0D8F"
+	)
+	s, err := newTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := s.setupRouter()
+
+	var tests = []httputil.HTTPTestCase{
+		{
+			Msg:      "Test missing reserve",
+			Endpoint: fmt.Sprintf("%s?from=%d&to=%d", endpoint, validFrom, validTo),
+			Method:   http.MethodGet,
+			Assert:   expectInvalidInput,
+		},
+		{
+			Msg:      "Test invalid reserve",
+			Endpoint: fmt.Sprintf("%s?from=%d&to=%d&reserve=%s", endpoint, validFrom, validTo, "xxxx"),
+			Method:   http.MethodGet,
+			Assert:   expectInvalidInput,
+		},
+		{
+			Msg:      "Test invalid frequency",
+			Endpoint: fmt.Sprintf("%s?from=%d&to=%d&reserve=%s&freq=%s", endpoint, validFrom, validTo, reserve, "m"),
+			Method:   http.MethodGet,
+			Assert:   expectInvalidInput,
+		},
+		{
+			Msg:      "Test unknown asset",
+			Endpoint: fmt.Sprintf("%s?from=%d&to=%d&reserve=%s&asset=%s", endpoint, validFrom, validTo, reserve, "XXX"),
+			Method:   http.MethodGet,
+			Assert:   expectInvalidInput,
+		},
+		{
+			Msg:      "Test time range exceeding hourly limit",
+			Endpoint: fmt.Sprintf("%s?from=%d&to=%d&reserve=%s&freq=h", endpoint, 0, validTo, reserve),
+			Method:   http.MethodGet,
+			Assert:   expectInvalidInput,
+		},
+		{
+			Msg:      "Test valid asset",
+			Endpoint: fmt.Sprintf("%s?from=%d&to=%d&reserve=%s&asset=%s", endpoint, validFrom, validTo, reserve, "ETH"),
+			Method:   http.MethodGet,
+			Assert:   expectCorrectVolume,
+		},
+		{
+			Msg:      "Test volume of all assets",
+			Endpoint: fmt.Sprintf("%s?from=%d&to=%d&reserve=%s&freq=d", endpoint, validFrom, validTo, reserve),
+			Method:   http.MethodGet,
+			Assert: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code)
+				var result map[ethereum.Address]map[uint64]common.VolumeStats
+				if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+					t.Fatal(err)
+				}
+				assert.Len(t, result, 2)
+				assert.Len(t, result[testTokenAddr], 2)
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Msg, func(t *testing.T) { httputil.RunHTTPTestCase(t, tc, router) })
+	}
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"net/http"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+
+	_ "github.com/KyberNetwork/reserve-stats/lib/httputil/validators" // import custom validator functions
+)
+
+type walletFeeQuery struct {
+	From         uint64   `form:"from"`
+	To           uint64   `form:"to"`
+	Freq         string   `form:"freq"`
+	WalletAddr   string   `form:"wallet" binding:"required,isAddress"`
+	ReserveAddrs []string `form:"reserve" binding:"dive,isAddress"`
+}
+
+type walletStatsQuery struct {
+	From       uint64 `form:"from"`
+	To         uint64 `form:"to"`
+	Freq       string `form:"freq"`
+	WalletAddr string `form:"wallet" binding:"required,isAddress"`
+}
+
+func (sv *Server) getWalletFee(c *gin.Context) {
+	var (
+		query    walletFeeQuery
+		rsvAddrs []ethereum.Address
+	)
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	fromTime, toTime, err := queryTimeWindow(query.From, query.To, query.Freq)
+	if err != nil {
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	for _, rsvAddr := range query.ReserveAddrs {
+		rsvAddrs = append(rsvAddrs, ethereum.HexToAddress(rsvAddr))
+	}
+
+	walletFee, err := sv.storage.GetAggregatedWalletFee(fromTime, toTime, query.Freq, ethereum.HexToAddress(query.WalletAddr), rsvAddrs)
+	if err != nil {
+		sv.sugar.Errorw(err.Error(), "parameter", query)
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		walletFee,
+	)
+}
+
+func (sv *Server) getWalletStats(c *gin.Context) {
+	var query walletStatsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	fromTime, toTime, err := queryTimeWindow(query.From, query.To, query.Freq)
+	if err != nil {
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	stats, err := sv.storage.GetWalletStats(fromTime, toTime, query.Freq, ethereum.HexToAddress(query.WalletAddr))
+	if err != nil {
+		sv.sugar.Errorw(err.Error(), "parameter", query)
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		stats,
+	)
+}
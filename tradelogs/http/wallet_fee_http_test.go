@@ -0,0 +1,96 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/KyberNetwork/reserve-stats/lib/httputil"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+func TestWalletFeeRoute(t *testing.T) {
+	const wallet = "0xb9E29984Fe50602E7A619662EBED4F90D93824C7"
+
+	s, err := newTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := s.setupRouter()
+
+	expectError := func(errMsg string) func(t *testing.T, resp *httptest.ResponseRecorder) {
+		return func(t *testing.T, resp *httptest.ResponseRecorder) {
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+			var result struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Error("Could not decode result", "err", err)
+			}
+			assert.Contains(t, result.Error, errMsg)
+		}
+	}
+
+	var tests = []httputil.HTTPTestCase{
+		{
+			Msg:      "Test valid wallet fee request",
+			Endpoint: fmt.Sprintf("/wallet-fee?freq=h&wallet=%s&reserve=0x63825c174ab367968EC60f061753D3bbD36A0D8F", wallet),
+			Method:   http.MethodGet,
+			Assert: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code)
+			},
+		},
+		{
+			Msg:      "Test missing wallet address",
+			Endpoint: "/wallet-fee?freq=h",
+			Method:   http.MethodGet,
+			Assert:   expectError("Field validation for 'WalletAddr' failed on the 'required' tag"),
+		},
+		{
+			Msg:      "Test invalid reserve address",
+			Endpoint: fmt.Sprintf("/wallet-fee?freq=h&wallet=%s&reserve=invalidAddress", wallet),
+			Method:   http.MethodGet,
+			Assert:   expectError("Field validation for 'ReserveAddrs[0]' failed on the 'isAddress' tag"),
+		},
+		{
+			Msg:      "Test invalid wallet fee frequency",
+			Endpoint: fmt.Sprintf("/wallet-fee?freq=invalid&wallet=%s", wallet),
+			Method:   http.MethodGet,
+			Assert:   expectError("your query frequency is not supported"),
+		},
+		{
+			Msg:      "Test valid wallet stats request",
+			Endpoint: fmt.Sprintf("/wallet-stats?freq=d&wallet=%s", wallet),
+			Method:   http.MethodGet,
+			Assert: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code)
+
+				var result map[uint64]*common.WalletStats
+				if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+					t.Error("Could not decode result", "err", err)
+				}
+			},
+		},
+		{
+			Msg:      "Test invalid wallet address",
+			Endpoint: "/wallet-stats?freq=d&wallet=invalidAddress",
+			Method:   http.MethodGet,
+			Assert:   expectError("Field validation for 'WalletAddr' failed on the 'isAddress' tag"),
+		},
+		{
+			Msg:      "Test wallet stats time range too broad",
+			Endpoint: fmt.Sprintf("/wallet-stats?from=0&to=%d&freq=d&wallet=%s", dailyBurnFeeMaxDuration/time.Millisecond+1, wallet),
+			Method:   http.MethodGet,
+			Assert:   expectError(fmt.Sprintf("your query time range exceeds the duration limit %s", dailyBurnFeeMaxDuration)),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Msg, func(t *testing.T) { httputil.RunHTTPTestCase(t, tc, router) })
+	}
+}
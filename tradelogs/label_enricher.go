@@ -0,0 +1,56 @@
+package tradelogs
+
+import (
+	"encoding/json"
+	"os"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+// LabelEnricher adds the names of reserves and wallets to the fees of trade logs.
+type LabelEnricher struct {
+	labels map[ethereum.Address]string
+}
+
+// NewLabelEnricher creates a new LabelEnricher instance with given address to name mapping.
+func NewLabelEnricher(labels map[ethereum.Address]string) *LabelEnricher {
+	return &LabelEnricher{labels: labels}
+}
+
+// NewLabelEnricherFromFile creates a new LabelEnricher instance with the labels
+// read from given JSON file. The file is a JSON object of address to name, example:
+// {"0x63825c174ab367968EC60f061753D3bbD36A0D8F": "KN"}
+func NewLabelEnricherFromFile(path string) (*LabelEnricher, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var labels map[ethereum.Address]string
+	if err = json.NewDecoder(f).Decode(&labels); err != nil {
+		return nil, err
+	}
+	return NewLabelEnricher(labels), nil
+}
+
+// Name returns the name of enricher.
+func (le *LabelEnricher) Name() string {
+	return "label"
+}
+
+// Enrich adds names of reserves and wallets to trade logs.
+func (le *LabelEnricher) Enrich(tradeLogs []common.TradeLog) ([]common.TradeLog, error) {
+	for i := range tradeLogs {
+		for j, burnFee := range tradeLogs[i].BurnFees {
+			tradeLogs[i].BurnFees[j].ReserveName = le.labels[burnFee.ReserveAddress]
+		}
+		for j, walletFee := range tradeLogs[i].WalletFees {
+			tradeLogs[i].WalletFees[j].ReserveName = le.labels[walletFee.ReserveAddress]
+			tradeLogs[i].WalletFees[j].WalletName = le.labels[walletFee.WalletAddress]
+		}
+	}
+	return tradeLogs, nil
+}
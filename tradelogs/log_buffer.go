@@ -0,0 +1,138 @@
+package tradelogs
+
+import (
+	"sort"
+	"sync"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// LogBuffer holds the logs received from a log subscription until their
+// blocks have enough confirmations. As the subscription delivers logs of
+// blocks that are later invalidated by chain reorganisation, the buffered
+// logs are checked against the canonical block hashes when taken out.
+// LogBuffer is safe for concurrent use, so logs can be received while the
+// confirmed ones are being processed.
+type LogBuffer struct {
+	mu   *sync.Mutex
+	logs map[uint64][]types.Log
+}
+
+// NewLogBuffer creates a new empty LogBuffer instance.
+func NewLogBuffer() *LogBuffer {
+	return &LogBuffer{
+		mu:   &sync.Mutex{},
+		logs: make(map[uint64][]types.Log),
+	}
+}
+
+// Add buffers given log. A log marked as removed deletes the buffered log
+// of the same block hash and index.
+func (lb *LogBuffer) Add(log types.Log) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	lb.add(log)
+}
+
+func (lb *LogBuffer) add(log types.Log) {
+	var kept []types.Log
+	for _, buffered := range lb.logs[log.BlockNumber] {
+		if buffered.BlockHash != log.BlockHash || buffered.Index != log.Index {
+			kept = append(kept, buffered)
+		}
+	}
+	if !log.Removed {
+		kept = append(kept, log)
+	}
+
+	if len(kept) == 0 {
+		delete(lb.logs, log.BlockNumber)
+		return
+	}
+	lb.logs[log.BlockNumber] = kept
+}
+
+// Len returns the number of buffered logs.
+func (lb *LogBuffer) Len() int {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	var n int
+	for _, logs := range lb.logs {
+		n += len(logs)
+	}
+	return n
+}
+
+// Prune drops the buffered logs of blocks up to toBlock, inclusive.
+func (lb *LogBuffer) Prune(toBlock uint64) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	lb.prune(toBlock)
+}
+
+func (lb *LogBuffer) prune(toBlock uint64) {
+	for number := range lb.logs {
+		if number <= toBlock {
+			delete(lb.logs, number)
+		}
+	}
+}
+
+// Take removes the buffered logs of blocks up to toBlock, inclusive, and
+// returns the ones belonging to canonical chain, sorted by block number and
+// log index. The canonical function returns the hash of canonical block at
+// given number, it is called without holding the buffer lock. If it fails,
+// the taken logs are buffered again.
+func (lb *LogBuffer) Take(toBlock uint64, canonical func(number uint64) (ethereum.Hash, error)) ([]types.Log, error) {
+	lb.mu.Lock()
+	var numbers []uint64
+	taken := make(map[uint64][]types.Log)
+	for number, logs := range lb.logs {
+		if number <= toBlock {
+			numbers = append(numbers, number)
+			taken[number] = logs
+		}
+	}
+	lb.prune(toBlock)
+	lb.mu.Unlock()
+
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	var result []types.Log
+	for _, number := range numbers {
+		hash, err := canonical(number)
+		if err != nil {
+			lb.restore(taken)
+			return nil, err
+		}
+
+		var logs []types.Log
+		for _, log := range taken[number] {
+			if log.BlockHash == hash {
+				logs = append(logs, log)
+			}
+		}
+		sort.Slice(logs, func(i, j int) bool { return logs[i].Index < logs[j].Index })
+		result = append(result, logs...)
+	}
+	return result, nil
+}
+
+// restore buffers again the logs taken out, keeping the ones received
+// meanwhile.
+func (lb *LogBuffer) restore(taken map[uint64][]types.Log) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	for number, logs := range taken {
+		received := lb.logs[number]
+		lb.logs[number] = logs
+		for _, log := range received {
+			lb.add(log)
+		}
+	}
+}
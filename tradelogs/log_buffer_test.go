@@ -0,0 +1,49 @@
+package tradelogs
+
+import (
+	"errors"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogBuffer(t *testing.T) {
+	var (
+		canonical = ethereum.HexToHash("0x01")
+		uncle     = ethereum.HexToHash("0x02")
+		hashes    = func(number uint64) (ethereum.Hash, error) { return canonical, nil }
+	)
+
+	lb := NewLogBuffer()
+	lb.Add(types.Log{BlockNumber: 11, BlockHash: canonical, Index: 3})
+	lb.Add(types.Log{BlockNumber: 10, BlockHash: canonical, Index: 1})
+	lb.Add(types.Log{BlockNumber: 10, BlockHash: canonical, Index: 0})
+	lb.Add(types.Log{BlockNumber: 10, BlockHash: uncle, Index: 0})
+	lb.Add(types.Log{BlockNumber: 12, BlockHash: canonical, Index: 0})
+	// duplicated delivery is buffered once
+	lb.Add(types.Log{BlockNumber: 12, BlockHash: canonical, Index: 0})
+	lb.Add(types.Log{BlockNumber: 13, BlockHash: canonical, Index: 0})
+	assert.Equal(t, 6, lb.Len())
+
+	// removed log deletes the buffered one
+	lb.Add(types.Log{BlockNumber: 13, BlockHash: canonical, Index: 0, Removed: true})
+	assert.Equal(t, 5, lb.Len())
+
+	_, err := lb.Take(11, func(uint64) (ethereum.Hash, error) { return ethereum.Hash{}, errors.New("node error") })
+	assert.Error(t, err)
+	assert.Equal(t, 5, lb.Len())
+
+	logs, err := lb.Take(11, hashes)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Log{
+		{BlockNumber: 10, BlockHash: canonical, Index: 0},
+		{BlockNumber: 10, BlockHash: canonical, Index: 1},
+		{BlockNumber: 11, BlockHash: canonical, Index: 3},
+	}, logs)
+	assert.Equal(t, 1, lb.Len())
+
+	lb.Prune(12)
+	assert.Equal(t, 0, lb.Len())
+}
@@ -0,0 +1,169 @@
+package tradelogs
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	ether "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// DefaultChunkSize is the default number of blocks to fetch logs in a single request.
+	DefaultChunkSize = 1000
+	// DefaultMaxWorkers is the default number of concurrent log fetching requests.
+	DefaultMaxWorkers = 4
+)
+
+// logFilterer is the subset of Ethereum client methods to fetch logs.
+type logFilterer interface {
+	FilterLogs(ctx context.Context, q ether.FilterQuery) ([]types.Log, error)
+}
+
+// logFetcher fetches logs of a large block range by splitting it to chunks
+// and fetching them with a bounded number of concurrent workers. If a chunk is
+// rejected by node because of too many results or timeout, it is bisected
+// until the request succeeds or the chunk contains a single block.
+type logFetcher struct {
+	sugar      *zap.SugaredLogger
+	filterer   logFilterer
+	chunkSize  uint64
+	maxWorkers int
+}
+
+func newLogFetcher(sugar *zap.SugaredLogger, filterer logFilterer, chunkSize uint64, maxWorkers int) *logFetcher {
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if maxWorkers <= 0 {
+		maxWorkers = DefaultMaxWorkers
+	}
+	return &logFetcher{
+		sugar:      sugar,
+		filterer:   filterer,
+		chunkSize:  chunkSize,
+		maxWorkers: maxWorkers,
+	}
+}
+
+// isRangeRejectedError returns true if the given error is returned because the
+// requested block range is too large to be served by node. Only the messages
+// of nodes rejecting the range are matched, a rate limited request, for example
+// HTTP 429 Too Many Requests, is not retried with smaller ranges.
+func isRangeRejectedError(err error) bool {
+	if err == context.DeadlineExceeded {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, pattern := range []string{
+		"query returned more than",
+		"log response size exceeded",
+		"block range too large",
+	} {
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchRange fetches logs of the given block range, bisecting it if rejected by node.
+func (lf *logFetcher) fetchRange(query ether.FilterQuery, fromBlock, toBlock uint64, timeout time.Duration) ([]types.Log, error) {
+	logger := lf.sugar.With(
+		"func", "tradelogs/logFetcher.fetchRange",
+		"from_block", fromBlock,
+		"to_block", toBlock,
+	)
+
+	query.FromBlock = big.NewInt(0).SetUint64(fromBlock)
+	query.ToBlock = big.NewInt(0).SetUint64(toBlock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	logs, err := lf.filterer.FilterLogs(ctx, query)
+	if err == nil {
+		logger.Debugw("fetched logs", "logs", len(logs))
+		return logs, nil
+	}
+
+	if fromBlock == toBlock || !isRangeRejectedError(err) {
+		return nil, err
+	}
+
+	mid := fromBlock + (toBlock-fromBlock)/2
+	logger.Infow("block range rejected by node, bisecting", "err", err, "mid_block", mid)
+
+	left, err := lf.fetchRange(query, fromBlock, mid, timeout)
+	if err != nil {
+		return nil, err
+	}
+	right, err := lf.fetchRange(query, mid+1, toBlock, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// fetch returns all logs matching the given query from fromBlock to toBlock, inclusive,
+// sorted by block number and log index.
+func (lf *logFetcher) fetch(query ether.FilterQuery, fromBlock, toBlock uint64, timeout time.Duration) ([]types.Log, error) {
+	var (
+		g        errgroup.Group
+		mu       sync.Mutex
+		result   []types.Log
+		chunks   = make(chan [2]uint64)
+		done     = make(chan struct{})
+		doneOnce sync.Once
+	)
+
+	for i := 0; i < lf.maxWorkers; i++ {
+		g.Go(func() error {
+			for chunk := range chunks {
+				logs, err := lf.fetchRange(query, chunk[0], chunk[1], timeout)
+				if err != nil {
+					// stop producing new chunks, other workers finish their current chunk
+					doneOnce.Do(func() { close(done) })
+					return err
+				}
+				mu.Lock()
+				result = append(result, logs...)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+
+	go func() {
+		defer close(chunks)
+		for from := fromBlock; from <= toBlock; from += lf.chunkSize {
+			to := from + lf.chunkSize - 1
+			if to > toBlock {
+				to = toBlock
+			}
+			select {
+			case chunks <- [2]uint64{from, to}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].BlockNumber != result[j].BlockNumber {
+			return result[i].BlockNumber < result[j].BlockNumber
+		}
+		return result[i].Index < result[j].Index
+	})
+	return result, nil
+}
@@ -0,0 +1,114 @@
+package tradelogs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	ether "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// mockFilterer returns two logs per block, in reversed order, and rejects
+// requests that contains more than maxResults logs.
+type mockFilterer struct {
+	mu         sync.Mutex
+	maxResults int
+	failBlock  uint64
+	limited    bool
+	requests   int
+}
+
+func (mf *mockFilterer) FilterLogs(_ context.Context, q ether.FilterQuery) ([]types.Log, error) {
+	mf.mu.Lock()
+	mf.requests++
+	mf.mu.Unlock()
+
+	var (
+		from = q.FromBlock.Uint64()
+		to   = q.ToBlock.Uint64()
+		logs []types.Log
+	)
+
+	if mf.limited {
+		return nil, errors.New("429 Too Many Requests")
+	}
+
+	if mf.failBlock >= from && mf.failBlock <= to {
+		return nil, errors.New("connection refused")
+	}
+
+	if int(to-from+1)*2 > mf.maxResults {
+		return nil, fmt.Errorf("query returned more than %d results", mf.maxResults)
+	}
+
+	for block := to; block >= from; block-- {
+		logs = append(logs,
+			types.Log{BlockNumber: block, Index: 1},
+			types.Log{BlockNumber: block, Index: 0},
+		)
+	}
+	return logs, nil
+}
+
+func newTestLogFetcher(t *testing.T, filterer logFilterer, chunkSize uint64, maxWorkers int) *logFetcher {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newLogFetcher(logger.Sugar(), filterer, chunkSize, maxWorkers)
+}
+
+func TestLogFetcherFetch(t *testing.T) {
+	var (
+		filterer = &mockFilterer{maxResults: 20}
+		lf       = newTestLogFetcher(t, filterer, 25, 3)
+	)
+
+	logs, err := lf.fetch(ether.FilterQuery{}, 1000, 1099, time.Second)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 200)
+
+	for i, log := range logs {
+		assert.Equal(t, uint64(1000+i/2), log.BlockNumber)
+		assert.Equal(t, uint(i%2), log.Index)
+	}
+	// 4 chunks of 25 blocks, each chunk is bisected to 13 + 12 and then 7 + 6, 6 + 6 blocks
+	assert.True(t, filterer.requests > 4, "rejected chunks should be bisected")
+}
+
+func TestLogFetcherFetchError(t *testing.T) {
+	var (
+		filterer = &mockFilterer{maxResults: 1000, failBlock: 1050}
+		lf       = newTestLogFetcher(t, filterer, 10, 2)
+	)
+
+	_, err := lf.fetch(ether.FilterQuery{}, 1000, 1099, time.Second)
+	assert.EqualError(t, err, "connection refused")
+}
+
+func TestLogFetcherFetchSingleBlockRejected(t *testing.T) {
+	var (
+		filterer = &mockFilterer{maxResults: 1}
+		lf       = newTestLogFetcher(t, filterer, 10, 2)
+	)
+
+	_, err := lf.fetch(ether.FilterQuery{}, 1000, 1009, time.Second)
+	assert.EqualError(t, err, "query returned more than 1 results")
+}
+
+func TestLogFetcherFetchRateLimited(t *testing.T) {
+	var (
+		filterer = &mockFilterer{maxResults: 1000, limited: true}
+		lf       = newTestLogFetcher(t, filterer, 10, 1)
+	)
+
+	_, err := lf.fetch(ether.FilterQuery{}, 1000, 1009, time.Second)
+	assert.EqualError(t, err, "429 Too Many Requests")
+	assert.Equal(t, 1, filterer.requests, "rate limited range should not be bisected")
+}
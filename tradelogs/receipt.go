@@ -0,0 +1,102 @@
+package tradelogs
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+// txReceiptReader is the subset of Ethereum client methods to read transactions and receipts.
+type txReceiptReader interface {
+	TransactionByHash(ctx context.Context, hash ethereum.Hash) (*types.Transaction, bool, error)
+	TransactionReceipt(ctx context.Context, txHash ethereum.Hash) (*types.Receipt, error)
+}
+
+// txSender returns the sender of given transaction.
+func txSender(tx *types.Transaction) (ethereum.Address, error) {
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		signer = types.NewEIP155Signer(tx.ChainId())
+	}
+	return types.Sender(signer, tx)
+}
+
+// enrichWithReceipt fills the transaction information of given trade log from
+// its transaction and receipt.
+func enrichWithReceipt(reader txReceiptReader, tradeLog *common.TradeLog, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	tx, _, err := reader.TransactionByHash(ctx, tradeLog.TransactionHash)
+	if err != nil {
+		return err
+	}
+
+	receipt, err := reader.TransactionReceipt(ctx, tradeLog.TransactionHash)
+	if err != nil {
+		return err
+	}
+
+	sender, err := txSender(tx)
+	if err != nil {
+		return err
+	}
+
+	if tx.To() == nil {
+		return errors.New("trade transaction is a contract creation")
+	}
+
+	tradeLog.TxSender = sender
+	tradeLog.TxRecipient = *tx.To()
+	tradeLog.GasPrice = tx.GasPrice()
+	tradeLog.GasUsed = receipt.GasUsed
+	tradeLog.GasCost = big.NewInt(0).Mul(tx.GasPrice(), big.NewInt(0).SetUint64(receipt.GasUsed))
+	return nil
+}
+
+// ReceiptEnricher adds the transaction sender, recipient and gas information
+// to trade logs from their transactions and receipts.
+type ReceiptEnricher struct {
+	sugar   *zap.SugaredLogger
+	reader  txReceiptReader
+	timeout time.Duration
+}
+
+// NewReceiptEnricher creates a new ReceiptEnricher instance.
+// The timeout is applied to each request sent to node.
+func NewReceiptEnricher(sugar *zap.SugaredLogger, reader txReceiptReader, timeout time.Duration) *ReceiptEnricher {
+	return &ReceiptEnricher{sugar: sugar, reader: reader, timeout: timeout}
+}
+
+// Name returns the name of enricher.
+func (re *ReceiptEnricher) Name() string {
+	return "receipt"
+}
+
+// Enrich adds transaction information to trade logs.
+func (re *ReceiptEnricher) Enrich(tradeLogs []common.TradeLog) ([]common.TradeLog, error) {
+	for i := range tradeLogs {
+		// trades of the same transaction share the same tx info
+		if sameTx(tradeLogs, i) {
+			prev := tradeLogs[i-1]
+			tradeLogs[i].TxSender = prev.TxSender
+			tradeLogs[i].TxRecipient = prev.TxRecipient
+			tradeLogs[i].GasPrice = prev.GasPrice
+			tradeLogs[i].GasUsed = prev.GasUsed
+			tradeLogs[i].GasCost = prev.GasCost
+			continue
+		}
+
+		if err := enrichWithReceipt(re.reader, &tradeLogs[i], re.timeout); err != nil {
+			return nil, err
+		}
+	}
+	return tradeLogs, nil
+}
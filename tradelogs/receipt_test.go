@@ -0,0 +1,58 @@
+package tradelogs
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+type mockTxReceiptReader struct {
+	tx      *types.Transaction
+	receipt *types.Receipt
+}
+
+func (m *mockTxReceiptReader) TransactionByHash(_ context.Context, _ ethereum.Hash) (*types.Transaction, bool, error) {
+	return m.tx, false, nil
+}
+
+func (m *mockTxReceiptReader) TransactionReceipt(_ context.Context, _ ethereum.Hash) (*types.Receipt, error) {
+	return m.receipt, nil
+}
+
+func TestEnrichWithReceipt(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		proxy    = ethereum.HexToAddress("0x818E6FECD516Ecc3849DAf6845e3EC868087B755")
+		gasPrice = big.NewInt(10000000000)
+		tx       = types.NewTransaction(0, proxy, big.NewInt(0), 300000, gasPrice, nil)
+	)
+
+	tx, err = types.SignTx(tx, types.NewEIP155Signer(big.NewInt(1)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		reader   = &mockTxReceiptReader{tx: tx, receipt: &types.Receipt{GasUsed: 150000}}
+		tradeLog = &common.TradeLog{TransactionHash: tx.Hash()}
+	)
+
+	assert.NoError(t, enrichWithReceipt(reader, tradeLog, time.Second))
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), tradeLog.TxSender)
+	assert.Equal(t, proxy, tradeLog.TxRecipient)
+	assert.Equal(t, gasPrice, tradeLog.GasPrice)
+	assert.Equal(t, uint64(150000), tradeLog.GasUsed)
+	assert.Equal(t, big.NewInt(1500000000000000), tradeLog.GasCost)
+}
@@ -0,0 +1,112 @@
+package tradelogs
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/tradelogs/checkpoint"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/storage"
+)
+
+// ErrReorgTooDeep is returned when none of the stored processed blocks belongs
+// to the canonical chain anymore, the crawler can not find where to roll back to.
+var ErrReorgTooDeep = errors.New("chain reorganisation is deeper than stored block history")
+
+// headerReader is the subset of Ethereum client methods to read block headers.
+type headerReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// ReorgHandler detects chain reorganisation by comparing the hashes of processed
+// blocks stored in checkpoint with the canonical chain. When a reorg is detected,
+// the trade logs of invalidated blocks are removed from storage and the checkpoint
+// is rolled back, so the invalidated blocks are crawled again.
+type ReorgHandler struct {
+	sugar      *zap.SugaredLogger
+	headers    headerReader
+	checkpoint checkpoint.Interface
+	storage    storage.Interface
+	timeout    time.Duration
+}
+
+// NewReorgHandler creates a new ReorgHandler instance.
+func NewReorgHandler(sugar *zap.SugaredLogger, headers headerReader, cp checkpoint.Interface, st storage.Interface, timeout time.Duration) *ReorgHandler {
+	return &ReorgHandler{
+		sugar:      sugar,
+		headers:    headers,
+		checkpoint: cp,
+		storage:    st,
+		timeout:    timeout,
+	}
+}
+
+// Block returns the block of canonical chain at given block number.
+func (rh *ReorgHandler) Block(number uint64) (checkpoint.Block, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rh.timeout)
+	defer cancel()
+
+	// parity and geth are not compatible in mix hash, the header is still usable
+	// when error is returned together with a non nil header
+	header, err := rh.headers.HeaderByNumber(ctx, big.NewInt(0).SetUint64(number))
+	if err != nil && header == nil {
+		return checkpoint.Block{}, err
+	}
+
+	return checkpoint.Block{
+		Number:    number,
+		Hash:      header.Hash(),
+		Timestamp: time.Unix(header.Time.Int64(), 0).UTC(),
+	}, nil
+}
+
+// Handle checks the processed blocks from the latest one backward until
+// finding a block that is still in canonical chain. If the latest processed
+// block is not in canonical chain anymore, all trade logs after the found
+// block are deleted and the checkpoint is rolled back to it.
+// It returns true if a rollback happened.
+func (rh *ReorgHandler) Handle() (bool, error) {
+	logger := rh.sugar.With("func", "tradelogs/ReorgHandler.Handle")
+
+	blocks, err := rh.checkpoint.Blocks()
+	if err != nil {
+		return false, err
+	}
+
+	for i := len(blocks) - 1; i >= 0; i-- {
+		stored := blocks[i]
+		canonical, err := rh.Block(stored.Number)
+		if err != nil {
+			return false, err
+		}
+
+		if canonical.Hash != stored.Hash {
+			logger.Warnw("processed block is not in canonical chain",
+				"block_number", stored.Number,
+				"stored_hash", stored.Hash.Hex(),
+				"canonical_hash", canonical.Hash.Hex())
+			continue
+		}
+
+		if i == len(blocks)-1 {
+			return false, nil
+		}
+
+		logger.Warnw("chain reorganisation detected, rolling back",
+			"last_valid_block", stored.Number,
+			"invalidated_blocks", len(blocks)-1-i)
+		if err = rh.storage.DeleteTradeLogsAfter(stored.Timestamp); err != nil {
+			return false, err
+		}
+		return true, rh.checkpoint.Rollback(stored.Number)
+	}
+
+	if len(blocks) == 0 {
+		return false, nil
+	}
+	return false, ErrReorgTooDeep
+}
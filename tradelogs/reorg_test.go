@@ -0,0 +1,128 @@
+package tradelogs
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/checkpoint"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/storage"
+)
+
+// mockChain is a fake chain returning headers of given fork.
+type mockChain struct {
+	fork string
+	// forkBlock is the first block that header is different on fork
+	forkBlock uint64
+}
+
+func (mc *mockChain) header(number uint64) *types.Header {
+	header := &types.Header{
+		Number: big.NewInt(0).SetUint64(number),
+		Time:   big.NewInt(int64(1539000000 + number*15)),
+	}
+	if number >= mc.forkBlock {
+		header.Extra = []byte(mc.fork)
+	}
+	return header
+}
+
+func (mc *mockChain) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	return mc.header(number.Uint64()), nil
+}
+
+// mockStorage is a MemoryStorage recording the time trade logs are deleted after.
+type mockStorage struct {
+	*storage.MemoryStorage
+	deletedAfter time.Time
+}
+
+func newMockStorage(t *testing.T) *mockStorage {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &mockStorage{
+		MemoryStorage: storage.NewMemoryStorage(logger.Sugar(), core.NewMockClient(), blockchain.MainnetTokenAddresses()),
+	}
+}
+
+func (ms *mockStorage) DeleteTradeLogsAfter(t time.Time) error {
+	ms.deletedAfter = t
+	return ms.MemoryStorage.DeleteTradeLogsAfter(t)
+}
+
+func newTestReorgHandler(t *testing.T, chain *mockChain, st *mockStorage) (*ReorgHandler, *checkpoint.BoltStorage, func()) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	dir, err := ioutil.TempDir("", "reorg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := checkpoint.NewBoltStorage(sugar, filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewReorgHandler(sugar, chain, cp, st, time.Second), cp, func() {
+		assert.NoError(t, cp.Close())
+		assert.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+func TestReorgHandler(t *testing.T) {
+	var (
+		chain = &mockChain{fork: "a", forkBlock: 250}
+		st    = newMockStorage(t)
+	)
+
+	rh, cp, tearDown := newTestReorgHandler(t, chain, st)
+	defer tearDown()
+
+	rolledBack, err := rh.Handle()
+	assert.NoError(t, err)
+	assert.False(t, rolledBack, "should not roll back without processed blocks")
+
+	for _, number := range []uint64{100, 200, 300, 400} {
+		block, err := rh.Block(number)
+		assert.NoError(t, err)
+		assert.NoError(t, cp.SaveLastBlock(block))
+	}
+
+	rolledBack, err = rh.Handle()
+	assert.NoError(t, err)
+	assert.False(t, rolledBack, "should not roll back when chain is not changed")
+	assert.True(t, st.deletedAfter.IsZero())
+
+	// blocks from 250 are replaced
+	chain.fork = "b"
+	rolledBack, err = rh.Handle()
+	assert.NoError(t, err)
+	assert.True(t, rolledBack)
+	assert.Equal(t, time.Unix(1539000000+200*15, 0).UTC(), st.deletedAfter)
+
+	lastBlock, err := cp.LastBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(200), lastBlock)
+
+	// all processed blocks are replaced
+	chain.forkBlock = 0
+	chain.fork = "c"
+	_, err = rh.Handle()
+	assert.Equal(t, ErrReorgTooDeep, err)
+}
@@ -0,0 +1,346 @@
+package storage
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/influxdb"
+)
+
+const (
+	// cqFingerprintLength is the length of the fingerprint suffix of
+	// continuous query names.
+	cqFingerprintLength = 8
+	// aggregateWindow is the time range of each aggregate query of a backfill,
+	// it must be a multiple of the intervals of all continuous queries.
+	aggregateWindow = 24 * time.Hour
+)
+
+// intoPattern matches the measurement that a query writes into.
+var intoPattern = regexp.MustCompile(`INTO "([^"]+)"`)
+
+// ethWETHFilter returns the condition of the trades counted in volume. It is
+// kept exactly as in the continuous queries which produced the published
+// volumes: despite its intent, it only excludes the trades from ETH to ETH and
+// from WETH to WETH, the trades between ETH and WETH are counted. Changing it
+// requires backfilling the volume measurements with the aggregate command.
+func ethWETHFilter(tokens blockchain.TokenAddresses) string {
+	return fmt.Sprintf(
+		`("src_addr" != '%[1]s' AND "dst_addr" != '%[2]s') OR ("src_addr" != '%[2]s' AND "dst_addr" != '%[1]s')`,
+		blockchain.ETHAddr.Hex(),
+		tokens.WETH.Hex(),
+	)
+}
+
+// continuousQuery is an aggregation of trade logs measurements, which is run
+// periodically by InfluxDB and can be run on demand to backfill historical data.
+type continuousQuery struct {
+	name string
+	// interval is the GROUP BY time interval of aggregation.
+	interval      time.Duration
+	resampleEvery time.Duration
+	resampleFor   time.Duration
+	// selectInto is the SELECT ... INTO ... FROM ... clause of the query.
+	selectInto string
+	// where is the optional condition of the query, without time condition.
+	where   string
+	groupBy string
+}
+
+// newContinuousQueries returns the continuous queries of trade logs database,
+// a query is declared after the queries that it reads from. The volume queries
+// filter trades by the given token addresses.
+func newContinuousQueries(tokens blockchain.TokenAddresses) []continuousQuery {
+	return []continuousQuery{
+		{
+			name:          "burn_fee_1h",
+			interval:      time.Hour,
+			resampleEvery: time.Hour,
+			resampleFor:   24 * time.Hour,
+			selectInto:    `SELECT SUM("amount") AS "sum_amount" INTO "hourly_burn_fees" FROM "burn_fees"`,
+			groupBy:       `"reserve_addr"`,
+		},
+		{
+			name:          "burn_fee_1d",
+			interval:      24 * time.Hour,
+			resampleEvery: 24 * time.Hour,
+			resampleFor:   3 * 24 * time.Hour,
+			selectInto:    `SELECT SUM("amount") AS "sum_amount" INTO "daily_burn_fees" FROM "burn_fees"`,
+			groupBy:       `"reserve_addr"`,
+		},
+		{
+			name:          "wallet_fee_1h",
+			interval:      time.Hour,
+			resampleEvery: time.Hour,
+			resampleFor:   24 * time.Hour,
+			selectInto:    `SELECT SUM("amount") AS "sum_amount" INTO "hourly_wallet_fees" FROM "wallet_fees"`,
+			groupBy:       `"wallet_addr", "reserve_addr"`,
+		},
+		{
+			name:          "wallet_fee_1d",
+			interval:      24 * time.Hour,
+			resampleEvery: 24 * time.Hour,
+			resampleFor:   3 * 24 * time.Hour,
+			selectInto:    `SELECT SUM("amount") AS "sum_amount" INTO "daily_wallet_fees" FROM "wallet_fees"`,
+			groupBy:       `"wallet_addr", "reserve_addr"`,
+		},
+		{
+			// a trade is counted once by its first wallet fee, as the wallet
+			// fees of both sides of a trade are of the same wallet
+			name:          "wallet_stats_1h",
+			interval:      time.Hour,
+			resampleEvery: time.Hour,
+			resampleFor:   24 * time.Hour,
+			selectInto: `SELECT COUNT("eth_amount") AS "trade_count", SUM("eth_amount") AS "eth_volume", SUM("usd_amount") AS "usd_volume" ` +
+				`INTO "hourly_wallet_stats" FROM (SELECT "eth_amount", "eth_amount" * "eth_usd_rate" AS "usd_amount" FROM "wallet_fees" WHERE "ordinal" = '0')`,
+			groupBy: `"wallet_addr"`,
+		},
+		{
+			name:          "wallet_stats_1d",
+			interval:      24 * time.Hour,
+			resampleEvery: time.Hour,
+			resampleFor:   2 * 24 * time.Hour,
+			selectInto:    `SELECT SUM("trade_count") AS "trade_count", SUM("eth_volume") AS "eth_volume", SUM("usd_volume") AS "usd_volume" INTO "daily_wallet_stats" FROM "hourly_wallet_stats"`,
+			groupBy:       `"wallet_addr"`,
+		},
+		{
+			name:          "dst_volume_hour",
+			interval:      time.Hour,
+			resampleEvery: time.Hour,
+			resampleFor:   3 * time.Hour,
+			selectInto: `SELECT SUM("dst_amount") AS "token_volume", SUM("eth_amount") AS "eth_volume", SUM("usd_amount") AS "usd_volume" ` +
+				`INTO "volume_hour" FROM (SELECT "dst_amount", "eth_amount", "eth_amount" * "eth_usd_rate" AS "usd_amount" FROM "trades" WHERE ` + ethWETHFilter(tokens) + `)`,
+			groupBy: `"dst_addr"`,
+		},
+		{
+			name:          "src_volume_hour",
+			interval:      time.Hour,
+			resampleEvery: time.Hour,
+			resampleFor:   3 * time.Hour,
+			selectInto: `SELECT SUM("src_amount") AS "token_volume", SUM("eth_amount") AS "eth_volume", SUM("usd_amount") AS "usd_volume" ` +
+				`INTO "volume_hour" FROM (SELECT "src_amount", "eth_amount", "eth_amount" * "eth_usd_rate" AS "usd_amount" FROM "trades" WHERE ` + ethWETHFilter(tokens) + `)`,
+			groupBy: `"src_addr"`,
+		},
+		{
+			name:          "dst_volume_day",
+			interval:      24 * time.Hour,
+			resampleEvery: time.Hour,
+			resampleFor:   2 * 24 * time.Hour,
+			selectInto:    `SELECT SUM("token_volume") AS "token_volume", SUM("eth_volume") AS "eth_volume", SUM("usd_volume") AS "usd_volume" INTO "volume_day" FROM "volume_hour"`,
+			where:         `"dst_addr" != ''`,
+			groupBy:       `"dst_addr"`,
+		},
+		{
+			name:          "src_volume_day",
+			interval:      24 * time.Hour,
+			resampleEvery: time.Hour,
+			resampleFor:   2 * 24 * time.Hour,
+			selectInto:    `SELECT SUM("token_volume") AS "token_volume", SUM("eth_volume") AS "eth_volume", SUM("usd_volume") AS "usd_volume" INTO "volume_day" FROM "volume_hour"`,
+			where:         `"src_addr" != ''`,
+			groupBy:       `"src_addr"`,
+		},
+		{
+			name:          "dst_rsv_volume_hour",
+			interval:      time.Hour,
+			resampleEvery: time.Hour,
+			resampleFor:   3 * time.Hour,
+			selectInto: `SELECT SUM("dst_amount") AS "token_volume", SUM("eth_amount") AS "eth_volume", SUM("usd_amount") AS "usd_volume" ` +
+				`INTO "rsv_volume_hour" FROM (SELECT "dst_amount", "eth_amount", "eth_amount" * "eth_usd_rate" AS "usd_amount" FROM "trades" WHERE "dst_rsv_addr" != '' AND (` + ethWETHFilter(tokens) + `))`,
+			groupBy: `"dst_rsv_addr", "dst_addr"`,
+		},
+		{
+			name:          "src_rsv_volume_hour",
+			interval:      time.Hour,
+			resampleEvery: time.Hour,
+			resampleFor:   3 * time.Hour,
+			selectInto: `SELECT SUM("src_amount") AS "token_volume", SUM("eth_amount") AS "eth_volume", SUM("usd_amount") AS "usd_volume" ` +
+				`INTO "rsv_volume_hour" FROM (SELECT "src_amount", "eth_amount", "eth_amount" * "eth_usd_rate" AS "usd_amount" FROM "trades" WHERE "src_rsv_addr" != '' AND (` + ethWETHFilter(tokens) + `))`,
+			groupBy: `"src_rsv_addr", "src_addr"`,
+		},
+		{
+			name:          "dst_rsv_volume_day",
+			interval:      24 * time.Hour,
+			resampleEvery: time.Hour,
+			resampleFor:   2 * 24 * time.Hour,
+			selectInto:    `SELECT SUM("token_volume") AS "token_volume", SUM("eth_volume") AS "eth_volume", SUM("usd_volume") AS "usd_volume" INTO "rsv_volume_day" FROM "rsv_volume_hour"`,
+			where:         `"dst_rsv_addr" != ''`,
+			groupBy:       `"dst_rsv_addr", "dst_addr"`,
+		},
+		{
+			name:          "src_rsv_volume_day",
+			interval:      24 * time.Hour,
+			resampleEvery: time.Hour,
+			resampleFor:   2 * 24 * time.Hour,
+			selectInto:    `SELECT SUM("token_volume") AS "token_volume", SUM("eth_volume") AS "eth_volume", SUM("usd_volume") AS "usd_volume" INTO "rsv_volume_day" FROM "rsv_volume_hour"`,
+			where:         `"src_rsv_addr" != ''`,
+			groupBy:       `"src_rsv_addr", "src_addr"`,
+		},
+	}
+}
+
+// selectStatement returns the SELECT INTO statement of the query with
+// optional time condition.
+func (cq continuousQuery) selectStatement(timeCond string) string {
+	var conds []string
+	if cq.where != "" {
+		conds = append(conds, cq.where)
+	}
+	if timeCond != "" {
+		conds = append(conds, timeCond)
+	}
+
+	stmt := cq.selectInto
+	if len(conds) != 0 {
+		stmt += " WHERE " + strings.Join(conds, " AND ")
+	}
+	return fmt.Sprintf("%s GROUP BY %s, time(%s)", stmt, cq.groupBy, influxdb.FormatDuration(cq.interval))
+}
+
+// into returns the measurement that the continuous query writes into.
+func (cq continuousQuery) into() string {
+	match := intoPattern.FindStringSubmatch(cq.selectInto)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// fingerprintedName returns the name of the continuous query suffixed by the
+// fingerprint of its definition, so a changed definition is detected by name.
+func (cq continuousQuery) fingerprintedName() string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%s",
+		influxdb.FormatDuration(cq.resampleEvery),
+		influxdb.FormatDuration(cq.resampleFor),
+		cq.selectStatement(""))))
+	return cq.name + "_" + hex.EncodeToString(sum[:])[:cqFingerprintLength]
+}
+
+// isVersionOf returns true if given continuous query name is of any version of
+// the query, including the unversioned one created manually.
+func (cq continuousQuery) isVersionOf(name string) bool {
+	if name == cq.name {
+		return true
+	}
+	suffix := strings.TrimPrefix(name, cq.name+"_")
+	if suffix == name || len(suffix) != cqFingerprintLength {
+		return false
+	}
+	_, err := hex.DecodeString(suffix)
+	return err == nil
+}
+
+// createStatement returns the statement to create the continuous query on given database.
+func (cq continuousQuery) createStatement(dbName string) string {
+	return fmt.Sprintf(`CREATE CONTINUOUS QUERY "%s" ON "%s" RESAMPLE EVERY %s FOR %s BEGIN %s END`,
+		cq.fingerprintedName(),
+		dbName,
+		influxdb.FormatDuration(cq.resampleEvery),
+		influxdb.FormatDuration(cq.resampleFor),
+		cq.selectStatement(""))
+}
+
+// aggregateStatement returns the statement to run the aggregation of the
+// continuous query in given time range, from inclusive and to exclusive.
+func (cq continuousQuery) aggregateStatement(from, to time.Time) string {
+	return cq.selectStatement(fmt.Sprintf("time >= '%s' AND time < '%s'",
+		from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339)))
+}
+
+// CreateContinuousQueries creates the continuous queries of trade logs database.
+// The existing queries of the same definitions are kept, the queries of outdated
+// definitions are replaced. It requires admin privileges, so it is run by the
+// crawler rather than on creation of the storage.
+func (is *InfluxStorage) CreateContinuousQueries() error {
+	logger := is.sugar.With(
+		"func", "tradelogs/storage/InfluxStorage.CreateContinuousQueries",
+		"db", is.dbName,
+	)
+
+	res, err := is.queryDB(is.influxClient, "SHOW CONTINUOUS QUERIES")
+	if err != nil {
+		return err
+	}
+
+	var existing []string
+	for _, result := range res {
+		for _, series := range result.Series {
+			if series.Name != is.dbName {
+				continue
+			}
+			for _, row := range series.Values {
+				name, ok := row[0].(string)
+				if !ok {
+					return fmt.Errorf("invalid continuous query name %v", row[0])
+				}
+				existing = append(existing, name)
+			}
+		}
+	}
+
+	for _, cq := range newContinuousQueries(is.tokens) {
+		var (
+			name     = cq.fingerprintedName()
+			upToDate bool
+		)
+		for _, existingName := range existing {
+			if !cq.isVersionOf(existingName) {
+				continue
+			}
+			if existingName == name {
+				upToDate = true
+				continue
+			}
+			logger.Infow("dropping outdated continuous query", "name", existingName)
+			if _, err = is.queryDB(is.influxClient,
+				fmt.Sprintf(`DROP CONTINUOUS QUERY "%s" ON "%s"`, existingName, is.dbName)); err != nil {
+				return err
+			}
+		}
+		if upToDate {
+			continue
+		}
+
+		logger.Infow("creating continuous query", "name", name)
+		if _, err = is.queryDB(is.influxClient, cq.createStatement(is.dbName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Aggregate runs the aggregations of all continuous queries on the trade logs
+// in given time range, to backfill the aggregated measurements of historical
+// data. The time range is extended from the start of the day of from time to
+// the end of the day of to time. The wallet fees stored without the trade
+// fields of wallet statistics are backfilled before aggregating.
+func (is *InfluxStorage) Aggregate(from, to time.Time) error {
+	logger := is.sugar.With(
+		"func", "tradelogs/storage/InfluxStorage.Aggregate",
+		"from", from,
+		"to", to,
+	)
+
+	from = from.UTC().Truncate(aggregateWindow)
+	to = to.UTC().Truncate(aggregateWindow).Add(aggregateWindow)
+
+	// the aggregations of each window run in declaration order, so the
+	// aggregated measurements are complete before read by other queries
+	for start := from; start.Before(to); start = start.Add(aggregateWindow) {
+		end := start.Add(aggregateWindow)
+		if err := is.backfillWalletFeeFields(start, end); err != nil {
+			return err
+		}
+		for _, cq := range newContinuousQueries(is.tokens) {
+			if _, err := is.queryDB(is.influxClient, cq.aggregateStatement(start, end)); err != nil {
+				return err
+			}
+		}
+		logger.Infow("aggregated trade logs", "window_start", start, "window_end", end)
+	}
+	return nil
+}
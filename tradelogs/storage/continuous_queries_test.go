@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/core"
+)
+
+func TestContinuousQueryStatements(t *testing.T) {
+	cq := continuousQuery{
+		name:          "burn_fee_1d",
+		interval:      24 * time.Hour,
+		resampleEvery: time.Hour,
+		resampleFor:   3 * 24 * time.Hour,
+		selectInto:    `SELECT SUM("amount") AS "sum_amount" INTO "daily_burn_fees" FROM "burn_fees"`,
+		where:         `"reserve_addr" != ''`,
+		groupBy:       `"reserve_addr"`,
+	}
+
+	assert.Equal(t, "daily_burn_fees", cq.into())
+	for _, cq := range newContinuousQueries(blockchain.MainnetTokenAddresses()) {
+		assert.NotEmpty(t, cq.into(), cq.name)
+	}
+
+	name := cq.fingerprintedName()
+	assert.Len(t, name, len(cq.name)+1+cqFingerprintLength)
+	assert.Equal(t,
+		`CREATE CONTINUOUS QUERY "`+name+`" ON "trade_logs" RESAMPLE EVERY 1h FOR 3d BEGIN `+
+			`SELECT SUM("amount") AS "sum_amount" INTO "daily_burn_fees" FROM "burn_fees" WHERE "reserve_addr" != '' GROUP BY "reserve_addr", time(1d) END`,
+		cq.createStatement("trade_logs"))
+
+	from := time.Date(2018, 10, 8, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t,
+		`SELECT SUM("amount") AS "sum_amount" INTO "daily_burn_fees" FROM "burn_fees" WHERE "reserve_addr" != '' `+
+			`AND time >= '2018-10-08T00:00:00Z' AND time < '2018-10-09T00:00:00Z' GROUP BY "reserve_addr", time(1d)`,
+		cq.aggregateStatement(from, from.Add(24*time.Hour)))
+
+	assert.True(t, cq.isVersionOf("burn_fee_1d"))
+	assert.True(t, cq.isVersionOf(name))
+	assert.True(t, cq.isVersionOf("burn_fee_1d_0123abcd"))
+	assert.False(t, cq.isVersionOf("burn_fee_1h"))
+	assert.False(t, cq.isVersionOf("burn_fee_1d_extra"))
+
+	// a changed definition has a different name
+	changed := cq
+	changed.resampleFor = 2 * 24 * time.Hour
+	assert.NotEqual(t, name, changed.fingerprintedName())
+}
+
+func TestCreateContinuousQueries(t *testing.T) {
+	testStorage := newTestInfluxStorage(t, "test_cq_db", core.NewMockClient())
+	defer testStorage.tearDown()
+
+	assert.NoError(t, testStorage.CreateContinuousQueries())
+	// creating again keeps the existing queries
+	assert.NoError(t, testStorage.CreateContinuousQueries())
+
+	res, err := testStorage.queryDB(testStorage.influxClient, "SHOW CONTINUOUS QUERIES")
+	assert.NoError(t, err)
+
+	var names []string
+	for _, series := range res[0].Series {
+		if series.Name != testStorage.dbName {
+			continue
+		}
+		for _, row := range series.Values {
+			names = append(names, row[0].(string))
+		}
+	}
+
+	var expected []string
+	for _, cq := range newContinuousQueries(blockchain.MainnetTokenAddresses()) {
+		expected = append(expected, cq.fingerprintedName())
+	}
+	assert.ElementsMatch(t, expected, names)
+}
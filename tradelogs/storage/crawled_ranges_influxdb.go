@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+
+	"github.com/KyberNetwork/reserve-stats/lib/influxdb"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+// crawledRangesMeasurement stores the block ranges successfully crawled. The
+// time of each point is the timestamp of the last block of the range, so the
+// points of different ranges crawled at the same time do not replace each
+// other, and the ranges are removed with the trade logs of their blocks.
+const crawledRangesMeasurement = "crawled_ranges"
+
+// SaveCrawledRange records that trade logs of given block range are crawled and stored.
+func (is *InfluxStorage) SaveCrawledRange(r common.BlockRange, toBlockTime time.Time) error {
+	bp, err := client.NewBatchPoints(client.BatchPointsConfig{
+		Database:  is.dbName,
+		Precision: timePrecision,
+	})
+	if err != nil {
+		return err
+	}
+
+	pt, err := client.NewPoint(crawledRangesMeasurement, nil, map[string]interface{}{
+		"from_block": int64(r.FromBlock),
+		"to_block":   int64(r.ToBlock),
+	}, toBlockTime)
+	if err != nil {
+		return err
+	}
+	bp.AddPoint(pt)
+	return is.influxClient.Write(bp)
+}
+
+// LoadCrawledRanges returns the recorded crawled block ranges that overlap with
+// the given block range.
+func (is *InfluxStorage) LoadCrawledRanges(fromBlock, toBlock uint64) ([]common.BlockRange, error) {
+	logger := is.sugar.With(
+		"func", "tradelogs/storage/InfluxStorage.LoadCrawledRanges",
+		"from_block", fromBlock,
+		"to_block", toBlock,
+	)
+
+	q := fmt.Sprintf(`SELECT from_block, to_block FROM "%s" WHERE to_block >= %d AND from_block <= %d`,
+		crawledRangesMeasurement, fromBlock, toBlock)
+	logger.Debugw("querying crawled ranges", "query", q)
+
+	res, err := is.queryDB(is.influxClient, q)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 || len(res[0].Series) == 0 {
+		return nil, nil
+	}
+
+	var ranges []common.BlockRange
+	for _, row := range res[0].Series[0].Values {
+		// columns: time, from_block, to_block
+		if len(row) != 3 {
+			return nil, fmt.Errorf("invalid crawled range row %v", row)
+		}
+		from, err := influxdb.GetInt64FromInterface(row[1])
+		if err != nil {
+			return nil, err
+		}
+		to, err := influxdb.GetInt64FromInterface(row[2])
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, common.BlockRange{FromBlock: uint64(from), ToBlock: uint64(to)})
+	}
+	return ranges, nil
+}
+
+// CountTrades returns the number of stored trades in given time range, inclusive.
+func (is *InfluxStorage) CountTrades(from, to time.Time) (uint64, error) {
+	q := fmt.Sprintf(`SELECT COUNT(eth_amount) FROM trades WHERE time >= '%s' AND time <= '%s'`,
+		from.UTC().Format(time.RFC3339Nano), to.UTC().Format(time.RFC3339Nano))
+
+	res, err := is.queryDB(is.influxClient, q)
+	if err != nil {
+		return 0, err
+	}
+	if len(res) == 0 || len(res[0].Series) == 0 || len(res[0].Series[0].Values) == 0 {
+		return 0, nil
+	}
+
+	// columns: time, count
+	row := res[0].Series[0].Values[0]
+	if len(row) != 2 {
+		return 0, fmt.Errorf("invalid trades count row %v", row)
+	}
+	count, err := influxdb.GetInt64FromInterface(row[1])
+	if err != nil {
+		return 0, err
+	}
+	return uint64(count), nil
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+// maxPendingWrites is the maximum number of failed writes to secondary
+// storage kept for replay, the oldest ones are dropped beyond it.
+const maxPendingWrites = 1000
+
+// pendingWrite is a write to secondary storage that is not applied yet.
+type pendingWrite struct {
+	op    string
+	write func(st Interface) error
+}
+
+// DualWriteStorage writes trade logs to both primary and secondary storage
+// and reads from primary storage only. It is used to populate a new storage
+// backend while migrating from the old one.
+//
+// The primary storage is the source of truth: a write fails only if the
+// primary storage fails. The writes failed on secondary storage are kept in
+// memory and replayed in order before the next write, until they succeed.
+// The pending writes are lost if the process stops or more than
+// maxPendingWrites are pending, the storages are then diverged and the
+// missing block ranges have to be crawled again into secondary storage.
+type DualWriteStorage struct {
+	sugar     *zap.SugaredLogger
+	primary   Interface
+	secondary Interface
+
+	mu      *sync.Mutex
+	pending []pendingWrite
+}
+
+// NewDualWriteStorage creates a new DualWriteStorage instance.
+func NewDualWriteStorage(sugar *zap.SugaredLogger, primary, secondary Interface) *DualWriteStorage {
+	return &DualWriteStorage{
+		sugar:     sugar,
+		primary:   primary,
+		secondary: secondary,
+		mu:        &sync.Mutex{},
+	}
+}
+
+// writeSecondary replays the pending writes to secondary storage, then
+// applies the given one. The writes are applied in order, a failed write is
+// kept for replay with all writes after it.
+func (ds *DualWriteStorage) writeSecondary(op string, write func(st Interface) error) {
+	logger := ds.sugar.With(
+		"func", "tradelogs/storage/DualWriteStorage.writeSecondary",
+		"op", op,
+	)
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.pending = append(ds.pending, pendingWrite{op: op, write: write})
+	if dropped := len(ds.pending) - maxPendingWrites; dropped > 0 {
+		for _, pw := range ds.pending[:dropped] {
+			logger.Errorw("dropping pending write to secondary storage, storages diverged",
+				"dropped_op", pw.op)
+		}
+		ds.pending = ds.pending[dropped:]
+	}
+
+	for len(ds.pending) != 0 {
+		if err := ds.pending[0].write(ds.secondary); err != nil {
+			logger.Errorw("failed to write to secondary storage, keeping for replay",
+				"failed_op", ds.pending[0].op,
+				"pending", len(ds.pending),
+				"err", err)
+			return
+		}
+		ds.pending = ds.pending[1:]
+	}
+}
+
+// Pending returns the number of writes to secondary storage waiting for
+// replay.
+func (ds *DualWriteStorage) Pending() int {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return len(ds.pending)
+}
+
+// SaveTradeLogs persists trade logs to primary storage, then to secondary
+// storage or records them for replay if it fails.
+func (ds *DualWriteStorage) SaveTradeLogs(logs []common.TradeLog, rates []tokenrate.ETHUSDRate) error {
+	if err := ds.primary.SaveTradeLogs(logs, rates); err != nil {
+		return err
+	}
+	ds.writeSecondary("SaveTradeLogs", func(st Interface) error {
+		return st.SaveTradeLogs(logs, rates)
+	})
+	return nil
+}
+
+// CreateContinuousQueries creates the continuous queries of the storages which
+// aggregate trade logs with them.
+func (ds *DualWriteStorage) CreateContinuousQueries() error {
+	for _, st := range []Interface{ds.primary, ds.secondary} {
+		if creator, ok := st.(ContinuousQueriesCreator); ok {
+			if err := creator.CreateContinuousQueries(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LoadTradeLogs returns trade logs from primary storage.
+func (ds *DualWriteStorage) LoadTradeLogs(from, to time.Time) ([]common.TradeLog, error) {
+	return ds.primary.LoadTradeLogs(from, to)
+}
+
+// DeleteTradeLogsAfter removes trade logs after given time from both storages.
+func (ds *DualWriteStorage) DeleteTradeLogsAfter(t time.Time) error {
+	if err := ds.primary.DeleteTradeLogsAfter(t); err != nil {
+		return err
+	}
+	ds.writeSecondary("DeleteTradeLogsAfter", func(st Interface) error {
+		return st.DeleteTradeLogsAfter(t)
+	})
+	return nil
+}
+
+// GetAggregatedBurnFee returns aggregated burn fees from primary storage.
+func (ds *DualWriteStorage) GetAggregatedBurnFee(from, to time.Time, freq string, reserveAddrs []ethereum.Address) (map[ethereum.Address]map[string]float64, error) {
+	return ds.primary.GetAggregatedBurnFee(from, to, freq, reserveAddrs)
+}
+
+// GetAssetVolume returns asset volume from primary storage.
+func (ds *DualWriteStorage) GetAssetVolume(token core.Token, fromTime, toTime uint64, frequency string) (map[uint64]*common.VolumeStats, error) {
+	return ds.primary.GetAssetVolume(token, fromTime, toTime, frequency)
+}
+
+// GetReserveVolume returns reserve volume from primary storage.
+func (ds *DualWriteStorage) GetReserveVolume(rsvAddr ethereum.Address, tokenAddrs []ethereum.Address, fromTime, toTime uint64, frequency string) (map[ethereum.Address]map[uint64]*common.VolumeStats, error) {
+	return ds.primary.GetReserveVolume(rsvAddr, tokenAddrs, fromTime, toTime, frequency)
+}
+
+// GetAggregatedWalletFee returns aggregated wallet fees from primary storage.
+func (ds *DualWriteStorage) GetAggregatedWalletFee(from, to time.Time, freq string, walletAddr ethereum.Address, reserveAddrs []ethereum.Address) (map[ethereum.Address]map[string]float64, error) {
+	return ds.primary.GetAggregatedWalletFee(from, to, freq, walletAddr, reserveAddrs)
+}
+
+// GetWalletStats returns wallet statistics from primary storage.
+func (ds *DualWriteStorage) GetWalletStats(from, to time.Time, freq string, walletAddr ethereum.Address) (map[uint64]*common.WalletStats, error) {
+	return ds.primary.GetWalletStats(from, to, freq, walletAddr)
+}
+
+// SaveCrawledRange records the crawled block range in both storages.
+func (ds *DualWriteStorage) SaveCrawledRange(r common.BlockRange, toBlockTime time.Time) error {
+	if err := ds.primary.SaveCrawledRange(r, toBlockTime); err != nil {
+		return err
+	}
+	ds.writeSecondary("SaveCrawledRange", func(st Interface) error {
+		return st.SaveCrawledRange(r, toBlockTime)
+	})
+	return nil
+}
+
+// LoadCrawledRanges returns crawled block ranges from primary storage.
+func (ds *DualWriteStorage) LoadCrawledRanges(fromBlock, toBlock uint64) ([]common.BlockRange, error) {
+	return ds.primary.LoadCrawledRanges(fromBlock, toBlock)
+}
+
+// CountTrades returns the number of trades in primary storage.
+func (ds *DualWriteStorage) CountTrades(from, to time.Time) (uint64, error) {
+	return ds.primary.CountTrades(from, to)
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+// failingStorage is a MemoryStorage of which writes fail while fail is set.
+type failingStorage struct {
+	*MemoryStorage
+	fail bool
+}
+
+func (fs *failingStorage) SaveTradeLogs(logs []common.TradeLog, rates []tokenrate.ETHUSDRate) error {
+	if fs.fail {
+		return errors.New("storage unavailable")
+	}
+	return fs.MemoryStorage.SaveTradeLogs(logs, rates)
+}
+
+func (fs *failingStorage) DeleteTradeLogsAfter(t time.Time) error {
+	if fs.fail {
+		return errors.New("storage unavailable")
+	}
+	return fs.MemoryStorage.DeleteTradeLogsAfter(t)
+}
+
+func TestDualWriteStorageReplay(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	var (
+		primary   = NewMemoryStorage(sugar, core.NewMockClient(), blockchain.MainnetTokenAddresses())
+		secondary = &failingStorage{
+			MemoryStorage: NewMemoryStorage(sugar, core.NewMockClient(), blockchain.MainnetTokenAddresses()),
+		}
+		ds = NewDualWriteStorage(sugar, primary, secondary)
+		ts = time.Date(2018, 10, 8, 12, 30, 0, 0, time.UTC)
+	)
+
+	save := func(txHash string, timestamp time.Time) {
+		assert.NoError(t, ds.SaveTradeLogs(
+			[]common.TradeLog{{Timestamp: timestamp, TransactionHash: ethereum.HexToHash(txHash)}},
+			[]tokenrate.ETHUSDRate{{Rate: 200}}))
+	}
+	count := func(st Interface) uint64 {
+		n, err := st.CountTrades(ts.Add(-time.Hour), ts.Add(time.Hour))
+		assert.NoError(t, err)
+		return n
+	}
+
+	save("0x01", ts)
+	assert.Equal(t, uint64(1), count(secondary))
+
+	// the failed writes to secondary storage do not fail the dual write
+	secondary.fail = true
+	save("0x02", ts.Add(time.Minute))
+	save("0x03", ts.Add(2*time.Minute))
+	assert.NoError(t, ds.DeleteTradeLogsAfter(ts.Add(time.Minute)))
+	assert.Equal(t, 3, ds.Pending())
+	assert.Equal(t, uint64(2), count(primary))
+	assert.Equal(t, uint64(1), count(secondary))
+
+	// the pending writes are replayed in order on next write
+	secondary.fail = false
+	save("0x04", ts.Add(-time.Minute))
+	assert.Equal(t, 0, ds.Pending())
+	assert.Equal(t, uint64(3), count(primary))
+	assert.Equal(t, uint64(3), count(secondary))
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/influxdata/influxdb/client/v2"
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+
+	libapp "github.com/KyberNetwork/reserve-stats/lib/app"
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+const (
+	storageFlag          = "storage"
+	dualWriteStorageFlag = "dual-write-storage"
+	retentionFlag        = "trade-logs-retention"
+
+	// InfluxDBBackend stores trade logs in InfluxDB.
+	InfluxDBBackend = "influxdb"
+	// PostgresBackend stores trade logs in PostgreSQL.
+	PostgresBackend = "postgres"
+	// MemoryBackend stores trade logs in memory of the process, for development only.
+	MemoryBackend = "memory"
+)
+
+// NewCliFlags returns cli flags to select and configure the trade logs storage backend.
+func NewCliFlags() []cli.Flag {
+	flags := []cli.Flag{
+		cli.StringFlag{
+			Name:   storageFlag,
+			Usage:  fmt.Sprintf("Storage backend of trade logs, one of: %s, %s, %s", InfluxDBBackend, PostgresBackend, MemoryBackend),
+			EnvVar: "STORAGE",
+			Value:  InfluxDBBackend,
+		},
+		cli.StringFlag{
+			Name:   dualWriteStorageFlag,
+			Usage:  "If set, trade logs are also written to given storage backend, which is not read from. It is used to populate a new backend during migration",
+			EnvVar: "DUAL_WRITE_STORAGE",
+		},
+		cli.DurationFlag{
+			Name:   retentionFlag,
+			Usage:  "How long trade logs and their aggregates are kept in InfluxDB storage, example: 8760h. They are kept forever if not set",
+			EnvVar: "TRADE_LOGS_RETENTION",
+		},
+	}
+	return append(flags, libapp.NewPostgreSQLFlags(common.DatabaseName)...)
+}
+
+// NewStorageFromContext creates the trade logs storage selected by cli flags.
+// The given InfluxDB client is used if InfluxDB backend is selected, the token
+// addresses are the ones deployed on the network of stored trade logs.
+func NewStorageFromContext(c *cli.Context, sugar *zap.SugaredLogger, influxClient client.Client, coreClient core.Interface,
+	tokens blockchain.TokenAddresses) (Interface, error) {
+	primaryBackend, secondaryBackend := c.String(storageFlag), c.String(dualWriteStorageFlag)
+	if primaryBackend == secondaryBackend {
+		return nil, fmt.Errorf("dual write storage must be different from storage: %s", primaryBackend)
+	}
+
+	primary, err := newBackend(c, sugar, primaryBackend, influxClient, coreClient, tokens)
+	if err != nil {
+		return nil, err
+	}
+	if secondaryBackend == "" {
+		return primary, nil
+	}
+
+	secondary, err := newBackend(c, sugar, secondaryBackend, influxClient, coreClient, tokens)
+	if err != nil {
+		return nil, err
+	}
+	sugar.Infow("dual writing trade logs", "storage", primaryBackend, "dual_write_storage", secondaryBackend)
+	return NewDualWriteStorage(sugar, primary, secondary), nil
+}
+
+// NewInfluxStorageFromContext creates the InfluxDB trade logs storage configured by cli flags.
+func NewInfluxStorageFromContext(c *cli.Context, sugar *zap.SugaredLogger, influxClient client.Client, coreClient core.Interface,
+	tokens blockchain.TokenAddresses) (*InfluxStorage, error) {
+	return NewInfluxStorage(sugar, common.DatabaseName, influxClient, coreClient, tokens, WithRetention(c.Duration(retentionFlag)))
+}
+
+func newBackend(c *cli.Context, sugar *zap.SugaredLogger, backend string, influxClient client.Client, coreClient core.Interface,
+	tokens blockchain.TokenAddresses) (Interface, error) {
+	switch backend {
+	case InfluxDBBackend:
+		return NewInfluxStorageFromContext(c, sugar, influxClient, coreClient, tokens)
+	case PostgresBackend:
+		db, err := libapp.NewDBFromContext(c)
+		if err != nil {
+			return nil, err
+		}
+		return NewPostgresStorage(sugar, db, coreClient, tokens)
+	case MemoryBackend:
+		sugar.Warnw("trade logs are stored in memory and lost on exit", "storage", backend)
+		return NewMemoryStorage(sugar, coreClient, tokens), nil
+	default:
+		return nil, fmt.Errorf("invalid storage backend: %q", backend)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/influxdb"
 	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
 	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
 	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
@@ -34,21 +35,48 @@ type InfluxStorage struct {
 	dbName       string
 	influxClient client.Client
 	coreClient   core.Interface
+	tokens       blockchain.TokenAddresses
 	sugar        *zap.SugaredLogger
+	// retention is how long trade logs and their aggregates are kept, 0 to keep forever.
+	retention time.Duration
 }
 
-// NewInfluxStorage init an instance of InfluxStorage
-func NewInfluxStorage(sugar *zap.SugaredLogger, dbName string, influxClient client.Client, coreClient core.Interface) (*InfluxStorage, error) {
+// InfluxStorageOption configures the optional parameters of InfluxStorage.
+type InfluxStorageOption func(*InfluxStorage)
+
+// WithRetention sets how long trade logs and their aggregates are kept, they
+// are kept forever by default.
+func WithRetention(retention time.Duration) InfluxStorageOption {
+	return func(is *InfluxStorage) {
+		is.retention = retention
+	}
+}
+
+// NewInfluxStorage init an instance of InfluxStorage, the token addresses are
+// the ones deployed on the network of stored trade logs.
+func NewInfluxStorage(sugar *zap.SugaredLogger, dbName string, influxClient client.Client, coreClient core.Interface,
+	tokens blockchain.TokenAddresses, options ...InfluxStorageOption) (*InfluxStorage, error) {
 	storage := &InfluxStorage{
 		dbName:       dbName,
 		influxClient: influxClient,
 		coreClient:   coreClient,
+		tokens:       tokens,
 		sugar:        sugar,
 	}
+	for _, option := range options {
+		option(storage)
+	}
 	err := storage.createDB()
 	if err != nil {
 		return nil, err
 	}
+	if err = influxdb.EnsureRetentionPolicy(sugar, influxClient, dbName, influxdb.RetentionPolicy{
+		Name:     influxdb.DefaultRetentionPolicy,
+		Duration: storage.retention,
+		Default:  true,
+	}); err != nil {
+		return nil, err
+	}
 	return storage, nil
 }
 
@@ -163,13 +191,14 @@ func (is *InfluxStorage) LoadTradeLogs(from, to time.Time) ([]common.TradeLog, e
 		SELECT %[2]s FROM wallet_fees WHERE time >= '%[4]s' AND time <= '%[5]s';
 		SELECT %[3]s FROM trades WHERE time >= '%[4]s' AND time <= '%[5]s';
 		`,
-		"time, tx_hash, reserve_addr, amount",
-		"time, tx_hash, reserve_addr, wallet_addr, amount",
+		"time, tx_hash, reserve_addr, amount, log_index",
+		"time, tx_hash, reserve_addr, wallet_addr, amount, log_index",
 		`
 		time, block_number, tx_hash, 
 		eth_receival_sender, eth_receival_amount, 
 		user_addr, src_addr, dst_addr, src_amount, dst_amount, (eth_amount * eth_usd_rate) as fiat_amount, 		
-		ip, country
+		ip, country, log_index,
+		tx_index, tx_sender, tx_recipient, gas_price, gas_used, gas_cost
 		`,
 		from.Format(time.RFC3339),
 		to.Format(time.RFC3339),
@@ -184,7 +213,7 @@ func (is *InfluxStorage) LoadTradeLogs(from, to time.Time) ([]common.TradeLog, e
 	}
 
 	// Get BurnFees
-	burnFeesByTxHash := make(map[ethereum.Hash][]common.BurnFee)
+	burnFeesByTrade := make(map[tradeLogKey][]common.BurnFee)
 
 	if len(res[0].Series) == 0 {
 		is.sugar.Debug("empty burn fee in query result")
@@ -192,25 +221,25 @@ func (is *InfluxStorage) LoadTradeLogs(from, to time.Time) ([]common.TradeLog, e
 	}
 
 	for _, row := range res[0].Series[0].Values {
-		txHash, burnFee, err := is.rowToBurnFee(row)
+		key, burnFee, err := is.rowToBurnFee(row)
 		if err != nil {
 			return nil, err
 		}
-		burnFeesByTxHash[txHash] = append(burnFeesByTxHash[txHash], burnFee)
+		burnFeesByTrade[key] = append(burnFeesByTrade[key], burnFee)
 	}
 
 	// Get WalletFees
-	walletFeesByTxHash := make(map[ethereum.Hash][]common.WalletFee)
+	walletFeesByTrade := make(map[tradeLogKey][]common.WalletFee)
 
 	if len(res[1].Series) == 0 {
 		is.sugar.Debug("empty wallet fee in query result")
 	} else {
 		for _, row := range res[1].Series[0].Values {
-			txHash, walletFee, err := is.rowToWalletFee(row)
+			key, walletFee, err := is.rowToWalletFee(row)
 			if err != nil {
 				return nil, err
 			}
-			walletFeesByTxHash[txHash] = append(walletFeesByTxHash[txHash], walletFee)
+			walletFeesByTrade[key] = append(walletFeesByTrade[key], walletFee)
 		}
 	}
 
@@ -223,7 +252,7 @@ func (is *InfluxStorage) LoadTradeLogs(from, to time.Time) ([]common.TradeLog, e
 	}
 
 	for _, row := range res[2].Series[0].Values {
-		tradeLog, err := is.rowToTradeLog(row, burnFeesByTxHash, walletFeesByTxHash)
+		tradeLog, err := is.rowToTradeLog(row, burnFeesByTrade, walletFeesByTrade)
 		if err != nil {
 			return nil, err
 		}
@@ -233,6 +262,43 @@ func (is *InfluxStorage) LoadTradeLogs(from, to time.Time) ([]common.TradeLog, e
 	return result, nil
 }
 
+// DeleteTradeLogsAfter removes all trade logs with timestamp after the given time.
+// As block timestamps are strictly increasing, this removes exactly the trade logs
+// of all blocks after the block with given timestamp. The crawled ranges ending
+// after the given time are removed as well. The aggregated periods from the one
+// containing the given time are removed and aggregated again from the remaining
+// trade logs, so they are not left with the removed trade logs counted.
+func (is *InfluxStorage) DeleteTradeLogsAfter(t time.Time) error {
+	var (
+		logger = is.sugar.With(
+			"func", "tradelogs/storage/InfluxStorage.DeleteTradeLogsAfter",
+			"time", t,
+		)
+		stmts   []string
+		deleted = make(map[string]bool)
+	)
+
+	for _, measurement := range []string{"trades", "burn_fees", "wallet_fees", crawledRangesMeasurement} {
+		stmts = append(stmts, fmt.Sprintf(`DELETE FROM "%s" WHERE time > '%s'`, measurement, t.UTC().Format(time.RFC3339Nano)))
+	}
+	for _, cq := range newContinuousQueries(is.tokens) {
+		measurement := cq.into()
+		if deleted[measurement] {
+			continue
+		}
+		deleted[measurement] = true
+		stmts = append(stmts, fmt.Sprintf(`DELETE FROM "%s" WHERE time >= '%s'`,
+			measurement, t.UTC().Truncate(cq.interval).Format(time.RFC3339)))
+	}
+
+	q := strings.Join(stmts, "; ")
+	logger.Infow("deleting trade logs", "query", q)
+	if _, err := is.queryDB(is.influxClient, q); err != nil {
+		return err
+	}
+	return is.Aggregate(t, t)
+}
+
 // createDB creates the database will be used for storing trade logs measurements.
 func (is *InfluxStorage) createDB() error {
 	_, err := is.queryDB(is.influxClient, fmt.Sprintf("CREATE DATABASE %s", is.dbName))
@@ -262,6 +328,7 @@ func (is *InfluxStorage) tradeLogToPoint(log common.TradeLog, rate tokenrate.ETH
 	tags := map[string]string{
 		"block_number": strconv.FormatUint(log.BlockNumber, 10),
 		"tx_hash":      log.TransactionHash.String(),
+		"log_index":    strconv.FormatUint(uint64(log.LogIndex), 10),
 
 		"eth_receival_sender": log.EtherReceivalSender.String(),
 
@@ -276,8 +343,8 @@ func (is *InfluxStorage) tradeLogToPoint(log common.TradeLog, rate tokenrate.ETH
 		"eth_rate_provider": rate.Provider,
 	}
 
-	if blockchain.IsBurnable(log.SrcAddress) {
-		if blockchain.IsBurnable(log.DestAddress) {
+	if is.tokens.IsBurnable(log.SrcAddress) {
+		if is.tokens.IsBurnable(log.DestAddress) {
 			if len(log.BurnFees) != 2 {
 				return nil, fmt.Errorf("unexpected burn fees %v", log.BurnFees)
 			}
@@ -289,7 +356,7 @@ func (is *InfluxStorage) tradeLogToPoint(log common.TradeLog, rate tokenrate.ETH
 			}
 			tags["src_rsv_addr"] = log.BurnFees[0].ReserveAddress.String()
 		}
-	} else if blockchain.IsBurnable(log.DestAddress) {
+	} else if is.tokens.IsBurnable(log.DestAddress) {
 		if len(log.BurnFees) != 1 {
 			return nil, fmt.Errorf("unexpected burn fees %v", log.BurnFees)
 		}
@@ -324,11 +391,29 @@ func (is *InfluxStorage) tradeLogToPoint(log common.TradeLog, rate tokenrate.ETH
 	fields := map[string]interface{}{
 		"eth_receival_amount": ethReceivalAmount,
 
-		"src_amount":   srcAmount,
-		"dst_amount":   dstAmount,
-		"eth_usd_rate": rate.Rate,
+		"src_amount": srcAmount,
+		"dst_amount": dstAmount,
 
 		"eth_amount": ethAmount,
+
+		"tx_index": int64(log.TxIndex),
+	}
+	// the USD amounts of trades without ETH/USD rate are left out of
+	// aggregations instead of counted as zero
+	if hasETHUSDRate(rate) {
+		fields["eth_usd_rate"] = rate.Rate
+	}
+
+	if log.GasPrice != nil {
+		gasCost, err := is.coreClient.FromWei(blockchain.ETHAddr, log.GasCost)
+		if err != nil {
+			return nil, err
+		}
+		fields["tx_sender"] = log.TxSender.String()
+		fields["tx_recipient"] = log.TxRecipient.String()
+		fields["gas_price"] = log.GasPrice.Int64()
+		fields["gas_used"] = int64(log.GasUsed)
+		fields["gas_cost"] = gasCost
 	}
 
 	tradePoint, err := client.NewPoint("trades", tags, fields, log.Timestamp)
@@ -342,11 +427,15 @@ func (is *InfluxStorage) tradeLogToPoint(log common.TradeLog, rate tokenrate.ETH
 	for idx, burn := range log.BurnFees {
 		tags := map[string]string{
 			"tx_hash":      log.TransactionHash.String(),
+			"log_index":    strconv.FormatUint(uint64(log.LogIndex), 10),
 			"reserve_addr": burn.ReserveAddress.String(),
 			"ordinal":      strconv.Itoa(idx), // prevent overwrite by other event belong to same trade log
 		}
+		if burn.ReserveName != "" {
+			tags["reserve_name"] = burn.ReserveName
+		}
 
-		burnAmount, err := is.coreClient.FromWei(blockchain.KNCAddr, burn.Amount)
+		burnAmount, err := is.coreClient.FromWei(is.tokens.KNC, burn.Amount)
 		if err != nil {
 			return nil, err
 		}
@@ -367,18 +456,31 @@ func (is *InfluxStorage) tradeLogToPoint(log common.TradeLog, rate tokenrate.ETH
 	for idx, walletFee := range log.WalletFees {
 		tags := map[string]string{
 			"tx_hash":      log.TransactionHash.String(),
+			"log_index":    strconv.FormatUint(uint64(log.LogIndex), 10),
 			"reserve_addr": walletFee.ReserveAddress.String(),
 			"wallet_addr":  walletFee.WalletAddress.String(),
 			"ordinal":      strconv.Itoa(idx), // prevent overwrite by other event belong to same trade log
 		}
+		if walletFee.ReserveName != "" {
+			tags["reserve_name"] = walletFee.ReserveName
+		}
+		if walletFee.WalletName != "" {
+			tags["wallet_name"] = walletFee.WalletName
+		}
 
-		amount, err := is.coreClient.FromWei(blockchain.KNCAddr, walletFee.Amount)
+		amount, err := is.coreClient.FromWei(is.tokens.KNC, walletFee.Amount)
 		if err != nil {
 			return nil, err
 		}
 
+		// the ETH amount of trade is stored with wallet fees to aggregate
+		// the trades routed through each wallet
 		fields := map[string]interface{}{
-			"amount": amount,
+			"amount":     amount,
+			"eth_amount": ethAmount,
+		}
+		if hasETHUSDRate(rate) {
+			fields["eth_usd_rate"] = rate.Rate
 		}
 
 		walletFeePoint, err := client.NewPoint("wallet_fees", tags, fields, log.Timestamp)
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
 	"time"
 
@@ -34,33 +35,60 @@ func (is *InfluxStorage) rowToAggregatedBurnFee(row []interface{}) (time.Time, f
 	return ts, burnFee, reserve, nil
 }
 
+// tradeLogKey identifies a trade log by its transaction hash and the log index
+// of its trade event, as a transaction may contain multiple trades.
+type tradeLogKey struct {
+	txHash   ethereum.Hash
+	logIndex uint
+}
+
+// getLogIndexFromInterface returns the log index from the log_index tag. The points
+// stored before log index was recorded do not have the tag, they are considered to
+// have log index 0, as there is only one trade per transaction of those points.
+func getLogIndexFromInterface(v interface{}) (uint, error) {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return 0, nil
+	}
+	logIndex, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid log index: %s", s)
+	}
+	return uint(logIndex), nil
+}
+
 // rowToBurnFee converts the result of InfluxDB query to BurnFee event
 // The query is:
-// SELECT time, tx_hash, reserve_addr, amount FROM burn_fees WHERE_clause
-func (is *InfluxStorage) rowToBurnFee(row []interface{}) (ethereum.Hash, common.BurnFee, error) {
+// SELECT time, tx_hash, reserve_addr, amount, log_index FROM burn_fees WHERE_clause
+func (is *InfluxStorage) rowToBurnFee(row []interface{}) (tradeLogKey, common.BurnFee, error) {
 	var (
 		burnFee common.BurnFee
-		txHash  ethereum.Hash
+		key     tradeLogKey
 	)
 
 	txHash, err := influxdb.GetTxHashFromInterface(row[1])
 	if err != nil {
-		return txHash, burnFee, err
+		return key, burnFee, err
 	}
 
 	reserveAddr, err := influxdb.GetAddressFromInterface(row[2])
 	if err != nil {
-		return txHash, burnFee, err
+		return key, burnFee, err
 	}
 
 	humanizedAmount, err := influxdb.GetFloat64FromInterface(row[3])
 	if err != nil {
-		return txHash, burnFee, err
+		return key, burnFee, err
 	}
 
-	weiAmount, err := is.coreClient.ToWei(blockchain.KNCAddr, humanizedAmount)
+	logIndex, err := getLogIndexFromInterface(row[4])
 	if err != nil {
-		return txHash, burnFee, err
+		return key, burnFee, err
+	}
+
+	weiAmount, err := is.coreClient.ToWei(is.tokens.KNC, humanizedAmount)
+	if err != nil {
+		return key, burnFee, err
 	}
 
 	burnFee = common.BurnFee{
@@ -68,41 +96,46 @@ func (is *InfluxStorage) rowToBurnFee(row []interface{}) (ethereum.Hash, common.
 		Amount:         weiAmount,
 	}
 
-	return txHash, burnFee, nil
+	return tradeLogKey{txHash: txHash, logIndex: logIndex}, burnFee, nil
 }
 
 // rowToWalletFee converts the result of InfluxDB query to FeeToWallet event
 // The query is:
-// SELECT time, tx_hash, reserve_addr, wallet_addr, amount FROM wallet_fees WHERE_clause
-func (is *InfluxStorage) rowToWalletFee(row []interface{}) (ethereum.Hash, common.WalletFee, error) {
+// SELECT time, tx_hash, reserve_addr, wallet_addr, amount, log_index FROM wallet_fees WHERE_clause
+func (is *InfluxStorage) rowToWalletFee(row []interface{}) (tradeLogKey, common.WalletFee, error) {
 	var (
 		walletFee common.WalletFee
-		txHash    ethereum.Hash
+		key       tradeLogKey
 	)
 
 	txHash, err := influxdb.GetTxHashFromInterface(row[1])
 	if err != nil {
-		return txHash, walletFee, err
+		return key, walletFee, err
 	}
 
 	reserveAddr, err := influxdb.GetAddressFromInterface(row[2])
 	if err != nil {
-		return txHash, walletFee, err
+		return key, walletFee, err
 	}
 
 	walletAddr, err := influxdb.GetAddressFromInterface(row[3])
 	if err != nil {
-		return txHash, walletFee, err
+		return key, walletFee, err
 	}
 
 	humanizedAmount, err := influxdb.GetFloat64FromInterface(row[4])
 	if err != nil {
-		return txHash, walletFee, err
+		return key, walletFee, err
+	}
+
+	logIndex, err := getLogIndexFromInterface(row[5])
+	if err != nil {
+		return key, walletFee, err
 	}
 
-	weiAmount, err := is.coreClient.ToWei(blockchain.KNCAddr, humanizedAmount)
+	weiAmount, err := is.coreClient.ToWei(is.tokens.KNC, humanizedAmount)
 	if err != nil {
-		return txHash, walletFee, err
+		return key, walletFee, err
 	}
 
 	walletFee = common.WalletFee{
@@ -111,7 +144,7 @@ func (is *InfluxStorage) rowToWalletFee(row []interface{}) (ethereum.Hash, commo
 		Amount:         weiAmount,
 	}
 
-	return txHash, walletFee, nil
+	return tradeLogKey{txHash: txHash, logIndex: logIndex}, walletFee, nil
 }
 
 // rowToTradeLog converts the result of InfluxDB query from to TradeLog event.
@@ -119,10 +152,11 @@ func (is *InfluxStorage) rowToWalletFee(row []interface{}) (ethereum.Hash, commo
 // SELECT time, block_number, tx_hash,
 // eth_receival_sender, eth_receival_amount,
 // user_addr, src_addr, dst_addr, src_amount, dst_amount, (eth_amount * eth_usd_rate) as fiat_amount,
-// ip, country FROM trades WHERE_clause
+// ip, country, log_index,
+// tx_index, tx_sender, tx_recipient, gas_price, gas_used, gas_cost FROM trades WHERE_clause
 func (is *InfluxStorage) rowToTradeLog(row []interface{},
-	burnFeesByTxHash map[ethereum.Hash][]common.BurnFee,
-	walletFeesByTxHash map[ethereum.Hash][]common.WalletFee) (common.TradeLog, error) {
+	burnFeesByTrade map[tradeLogKey][]common.BurnFee,
+	walletFeesByTrade map[tradeLogKey][]common.WalletFee) (common.TradeLog, error) {
 
 	var tradeLog common.TradeLog
 
@@ -188,9 +222,12 @@ func (is *InfluxStorage) rowToTradeLog(row []interface{},
 		return tradeLog, fmt.Errorf("failed to convert dst_amount: %s", err)
 	}
 
-	fiatAmount, err := influxdb.GetFloat64FromInterface(row[10])
-	if err != nil {
-		return tradeLog, fmt.Errorf("failed to get fiat_amount: %s", err)
+	// fiat_amount is null for the trades stored without ETH/USD rate
+	var fiatAmount float64
+	if row[10] != nil {
+		if fiatAmount, err = influxdb.GetFloat64FromInterface(row[10]); err != nil {
+			return tradeLog, fmt.Errorf("failed to get fiat_amount: %s", err)
+		}
 	}
 
 	ip, ok := row[11].(string)
@@ -203,10 +240,17 @@ func (is *InfluxStorage) rowToTradeLog(row []interface{},
 		country = ""
 	}
 
+	logIndex, err := getLogIndexFromInterface(row[13])
+	if err != nil {
+		return tradeLog, fmt.Errorf("failed to get log_index: %s", err)
+	}
+	key := tradeLogKey{txHash: txHash, logIndex: logIndex}
+
 	tradeLog = common.TradeLog{
 		Timestamp:       timestamp,
 		BlockNumber:     blockNumber,
 		TransactionHash: txHash,
+		LogIndex:        logIndex,
 
 		EtherReceivalSender: ethReceivalAddr,
 		EtherReceivalAmount: ethReceivalAmountInWei,
@@ -218,12 +262,70 @@ func (is *InfluxStorage) rowToTradeLog(row []interface{},
 		DestAmount:  dstAmountInWei,
 		FiatAmount:  fiatAmount,
 
-		BurnFees:   burnFeesByTxHash[txHash],
-		WalletFees: walletFeesByTxHash[txHash],
+		BurnFees:   burnFeesByTrade[key],
+		WalletFees: walletFeesByTrade[key],
 
 		IP:      ip,
 		Country: country,
 	}
 
+	if err = is.rowToTxInfo(row[14:], &tradeLog); err != nil {
+		return tradeLog, err
+	}
+
 	return tradeLog, nil
 }
+
+// rowToTxInfo reads the transaction information of trade log from the result of query:
+// SELECT tx_index, tx_sender, tx_recipient, gas_price, gas_used, gas_cost FROM trades WHERE_clause
+// The fields are not available in points stored without fetching receipts.
+func (is *InfluxStorage) rowToTxInfo(row []interface{}, tradeLog *common.TradeLog) error {
+	if row[0] != nil {
+		txIndex, err := influxdb.GetInt64FromInterface(row[0])
+		if err != nil {
+			return fmt.Errorf("failed to get tx_index: %s", err)
+		}
+		tradeLog.TxIndex = uint(txIndex)
+	}
+
+	if row[3] == nil {
+		return nil
+	}
+
+	txSender, err := influxdb.GetAddressFromInterface(row[1])
+	if err != nil {
+		return fmt.Errorf("failed to get tx_sender: %s", err)
+	}
+
+	txRecipient, err := influxdb.GetAddressFromInterface(row[2])
+	if err != nil {
+		return fmt.Errorf("failed to get tx_recipient: %s", err)
+	}
+
+	gasPrice, err := influxdb.GetInt64FromInterface(row[3])
+	if err != nil {
+		return fmt.Errorf("failed to get gas_price: %s", err)
+	}
+
+	gasUsed, err := influxdb.GetInt64FromInterface(row[4])
+	if err != nil {
+		return fmt.Errorf("failed to get gas_used: %s", err)
+	}
+
+	humanizedGasCost, err := influxdb.GetFloat64FromInterface(row[5])
+	if err != nil {
+		return fmt.Errorf("failed to get gas_cost: %s", err)
+	}
+
+	gasCost, err := is.coreClient.ToWei(blockchain.ETHAddr, humanizedGasCost)
+	if err != nil {
+		return fmt.Errorf("failed to convert gas_cost: %s", err)
+	}
+
+	tradeLog.TxSender = txSender
+	tradeLog.TxRecipient = txRecipient
+	tradeLog.GasPrice = big.NewInt(gasPrice)
+	tradeLog.GasUsed = uint64(gasUsed)
+	tradeLog.GasCost = gasCost
+	return nil
+}
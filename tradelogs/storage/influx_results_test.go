@@ -6,6 +6,8 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/KyberNetwork/reserve-stats/lib/core"
 )
 
 func loadTestData(db string) error {
@@ -65,16 +67,13 @@ func TestLoadTradeLogs(t *testing.T) {
 		expectedLen = 11
 	)
 
-	is, err := newTestInfluxStorage(dbName)
-	if err != nil {
-		t.Fatal(err)
-	}
+	is := newTestInfluxStorage(t, dbName, core.NewMockClient())
 	defer func() {
-		if err = is.tearDown(); err != nil {
+		if err := is.tearDown(); err != nil {
 			t.Fatal(err)
 		}
 	}()
-	if err = loadTestData(dbName); err != nil {
+	if err := loadTestData(dbName); err != nil {
 		t.Fatal(err)
 	}
 
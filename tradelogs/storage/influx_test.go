@@ -4,45 +4,55 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/KyberNetwork/reserve-stats/lib/core"
-	"log"
 	"os"
 	"testing"
+	"time"
 
+	ethereum "github.com/ethereum/go-ethereum/common"
 	"github.com/influxdata/influxdb/client/v2"
+	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
 	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
 	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
 )
 
-var testStorage *InfluxStorage
-
-func newTestInfluxStorage(db string) (*InfluxStorage, error) {
+// newTestInfluxStorage returns an InfluxStorage of given database with its
+// continuous queries created. The test is skipped if InfluxDB is not available.
+func newTestInfluxStorage(t *testing.T, db string, coreClient core.Interface) *InfluxStorage {
 	logger, err := zap.NewDevelopment()
 	if err != nil {
-		return nil, err
+		t.Fatal(err)
 	}
-	defer logger.Sync()
 	sugar := logger.Sugar()
 
 	influxClient, err := client.NewHTTPClient(client.HTTPConfig{
 		Addr: "http://127.0.0.1:8086",
 	})
 	if err != nil {
-		return nil, err
+		t.Fatal(err)
+	}
+	if _, _, err = influxClient.Ping(time.Second); err != nil {
+		t.Skipf("InfluxDB is not available: %v", err)
 	}
 
 	storage, err := NewInfluxStorage(
 		sugar,
 		db,
 		influxClient,
-		core.NewMockClient(),
+		coreClient,
+		blockchain.MainnetTokenAddresses(),
 	)
 	if err != nil {
-		return nil, err
+		t.Fatal(err)
+	}
+	if err = storage.CreateContinuousQueries(); err != nil {
+		t.Fatal(err)
 	}
 
-	return storage, nil
+	return storage
 }
 
 // tearDown remove the database that storing trade logs measurements.
@@ -77,28 +87,166 @@ func getSampleRates(tradeLogs []common.TradeLog) ([]tokenrate.ETHUSDRate, error)
 }
 
 func TestSaveTradeLogs(t *testing.T) {
+	is := newTestInfluxStorage(t, "test_db", core.NewMockClient())
+	defer is.tearDown()
+
 	tradeLogs, err := getSampleTradeLogs("testdata/trade_logs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
 	rates, err := getSampleRates(tradeLogs)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err = testStorage.SaveTradeLogs(tradeLogs, rates); err != nil {
+	if err = is.SaveTradeLogs(tradeLogs, rates); err != nil {
 		t.Error("get unexpected error when save trade logs", "err", err.Error())
 	}
 }
 
-func TestMain(m *testing.M) {
-	var err error
-	if testStorage, err = newTestInfluxStorage("test_db"); err != nil {
-		log.Fatal("get unexpected error when create storage", "err", err.Error())
+func TestTradeLogToPointWithoutETHUSDRate(t *testing.T) {
+	tradeLogs, err := getSampleTradeLogs("testdata/trade_logs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// converting trade logs to points does not require InfluxDB
+	is := &InfluxStorage{
+		coreClient: core.NewMockClient(),
+		tokens:     blockchain.MainnetTokenAddresses(),
+	}
+	for _, tradeLog := range tradeLogs {
+		points, err := is.tradeLogToPoint(tradeLog, tokenrate.ETHUSDRate{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, point := range points {
+			fields, err := point.Fields()
+			assert.NoError(t, err)
+			assert.NotContains(t, fields, "eth_usd_rate", point.Name())
+		}
+	}
+}
+
+func TestDeleteTradeLogsAfter(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	is := newTestInfluxStorage(t, "test_reorg_db", weiCoreClient{MockClient: core.NewMockClient()})
+	defer is.tearDown()
+
+	tradeLogs, err := getSampleTradeLogs("testdata/trade_logs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates, err := getSampleRates(tradeLogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, is.SaveTradeLogs(tradeLogs, rates))
+	for _, tradeLog := range tradeLogs {
+		assert.NoError(t, is.SaveCrawledRange(common.BlockRange{FromBlock: tradeLog.BlockNumber, ToBlock: tradeLog.BlockNumber}, tradeLog.Timestamp))
 	}
-	defer testStorage.tearDown()
 
-	ret := m.Run()
+	var (
+		from = tradeLogs[0].Timestamp.Truncate(24 * time.Hour)
+		to   = from.Add(24 * time.Hour)
+		// the trade logs after the third one are invalidated by reorg
+		lastValid = tradeLogs[2]
+	)
+	assert.NoError(t, is.Aggregate(from, from))
+	assert.NoError(t, is.DeleteTradeLogsAfter(lastValid.Timestamp))
+
+	// the aggregates equal the aggregates of the remaining trade logs
+	ms := NewMemoryStorage(sugar, weiCoreClient{MockClient: core.NewMockClient()}, blockchain.MainnetTokenAddresses())
+	assert.NoError(t, ms.SaveTradeLogs(tradeLogs[:3], rates[:3]))
+	for _, freq := range []string{"h", "d"} {
+		expected, err := ms.GetAggregatedBurnFee(from, to, freq, nil)
+		assert.NoError(t, err)
+		burnFees, err := is.GetAggregatedBurnFee(from, to, freq, nil)
+		assert.NoError(t, err)
+		if assert.Len(t, burnFees, len(expected)) {
+			for reserve, sums := range expected {
+				if assert.Len(t, burnFees[reserve], len(sums), "freq %s", freq) {
+					for ts, sum := range sums {
+						assert.InDelta(t, sum, burnFees[reserve][ts], 1e-9, "freq %s", freq)
+					}
+				}
+			}
+		}
+
+		for _, token := range []core.Token{{Address: tradeLogs[0].SrcAddress.Hex()}, {Address: tradeLogs[0].DestAddress.Hex()}} {
+			expectedVolume, err := ms.GetAssetVolume(token, timeutil.TimeToTimestampMs(from), timeutil.TimeToTimestampMs(to), freq)
+			assert.NoError(t, err)
+			volume, err := is.GetAssetVolume(token, timeutil.TimeToTimestampMs(from), timeutil.TimeToTimestampMs(to), freq)
+			assert.NoError(t, err)
+			for ts, stats := range expectedVolume {
+				// the periods without trades are filled with zero by memory storage
+				if stats.ETHAmount == 0 {
+					continue
+				}
+				if assert.Contains(t, volume, ts) {
+					assert.InDelta(t, stats.ETHAmount, volume[ts].ETHAmount, 1e-9, "freq %s", freq)
+				}
+			}
+		}
+	}
+
+	count, err := is.CountTrades(from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), count)
+
+	ranges, err := is.LoadCrawledRanges(0, tradeLogs[len(tradeLogs)-1].BlockNumber)
+	assert.NoError(t, err)
+	assert.Len(t, ranges, 3)
+}
+
+func TestBackfillWalletFeeFields(t *testing.T) {
+	const (
+		txHash = "0x33dcdbed63556a1d90b7e0f626bfaf20f6f532d2ae8bf24c22abb15c4e1fff01"
+		wallet = "0xb9E29984Fe50602E7A619662EBED4F90D93824C7"
+	)
+	is := newTestInfluxStorage(t, "test_wallet_fee_db", core.NewMockClient())
+	defer is.tearDown()
 
-	if err = testStorage.tearDown(); err != nil {
-		log.Fatal(err)
+	// a trade and its wallet fee stored before the trade fields of wallet fees
+	ts := time.Date(2018, 8, 6, 18, 5, 48, 0, time.UTC)
+	bp, err := client.NewBatchPoints(client.BatchPointsConfig{Database: is.dbName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pt := range []struct {
+		measurement string
+		tags        map[string]string
+		fields      map[string]interface{}
+	}{
+		{
+			measurement: "trades",
+			tags:        map[string]string{"tx_hash": txHash, "log_index": "1"},
+			fields:      map[string]interface{}{"eth_amount": 2.0, "eth_usd_rate": 300.0},
+		},
+		{
+			measurement: "wallet_fees",
+			tags:        map[string]string{"tx_hash": txHash, "log_index": "1", "wallet_addr": wallet, "reserve_addr": wallet, "ordinal": "0"},
+			fields:      map[string]interface{}{"amount": 0.5},
+		},
+	} {
+		p, err := client.NewPoint(pt.measurement, pt.tags, pt.fields, ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bp.AddPoint(p)
+	}
+	if err = is.influxClient.Write(bp); err != nil {
+		t.Fatal(err)
 	}
 
-	os.Exit(ret)
+	assert.NoError(t, is.Aggregate(ts, ts))
+	stats, err := is.GetWalletStats(ts.Truncate(24*time.Hour), ts, "d", ethereum.HexToAddress(wallet))
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint64]*common.WalletStats{
+		timeutil.TimeToTimestampMs(ts.Truncate(24 * time.Hour)): {TradeCount: 1, ETHVolume: 2, USDVolume: 600, Fee: 0.5},
+	}, stats)
 }
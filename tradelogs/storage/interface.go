@@ -11,8 +11,44 @@ import (
 
 // Interface represent a storage for TradeLogs data
 type Interface interface {
+	// SaveTradeLogs persists given trade logs with their ETH/USD rates. A zero
+	// rate means the rate is unknown, the trade is then left out of USD
+	// aggregations.
 	SaveTradeLogs(logs []common.TradeLog, rates []tokenrate.ETHUSDRate) error
 	LoadTradeLogs(from, to time.Time) ([]common.TradeLog, error)
+	// DeleteTradeLogsAfter removes all trade logs with timestamp after the given time,
+	// their aggregates and the crawled ranges ending after the given time.
+	// It is used to roll back trades of blocks invalidated by chain reorganisation.
+	DeleteTradeLogsAfter(t time.Time) error
 	GetAggregatedBurnFee(from, to time.Time, freq string, reserveAddrs []ethereum.Address) (map[ethereum.Address]map[string]float64, error)
 	GetAssetVolume(token core.Token, fromTime, toTime uint64, frequency string) (map[uint64]*common.VolumeStats, error)
+	// GetReserveVolume returns the volume handled by given reserve by hour or day, keyed by
+	// token address and timestamp in milliseconds. All tokens are returned if no token address is given.
+	GetReserveVolume(rsvAddr ethereum.Address, tokenAddrs []ethereum.Address, fromTime, toTime uint64, frequency string) (map[ethereum.Address]map[uint64]*common.VolumeStats, error)
+	// GetAggregatedWalletFee returns the sum of wallet fees earned by given wallet by hour or day, keyed by
+	// reserve address and timestamp in milliseconds. All reserves are returned if no reserve address is given.
+	GetAggregatedWalletFee(from, to time.Time, freq string, walletAddr ethereum.Address, reserveAddrs []ethereum.Address) (map[ethereum.Address]map[string]float64, error)
+	// GetWalletStats returns the statistics of trades routed through given wallet by hour or day, keyed by
+	// timestamp in milliseconds.
+	GetWalletStats(from, to time.Time, freq string, walletAddr ethereum.Address) (map[uint64]*common.WalletStats, error)
+
+	// SaveCrawledRange records that trade logs of given block range are crawled and stored,
+	// toBlockTime is the timestamp of the last block of the range.
+	SaveCrawledRange(r common.BlockRange, toBlockTime time.Time) error
+	// LoadCrawledRanges returns the recorded crawled block ranges that overlap with given block range.
+	LoadCrawledRanges(fromBlock, toBlock uint64) ([]common.BlockRange, error)
+	// CountTrades returns the number of stored trades in given time range, inclusive.
+	CountTrades(from, to time.Time) (uint64, error)
+}
+
+// ContinuousQueriesCreator is implemented by the storages which aggregate trade
+// logs with continuous queries that have to be created before crawling.
+type ContinuousQueriesCreator interface {
+	CreateContinuousQueries() error
+}
+
+// hasETHUSDRate returns false if the ETH/USD rate of a trade is unknown, as
+// the trade logs are crawled without fiat rate enricher.
+func hasETHUSDRate(rate tokenrate.ETHUSDRate) bool {
+	return rate.Rate != 0
 }
@@ -0,0 +1,516 @@
+package storage
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
+	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+// freqToDuration is the period of the supported aggregation frequencies.
+var freqToDuration = map[string]time.Duration{
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+}
+
+// tradeKey identifies a trade by its transaction hash and log index.
+type tradeKey struct {
+	txHash   ethereum.Hash
+	logIndex uint
+}
+
+// crawledRange is a crawled block range with the timestamp of its last block.
+type crawledRange struct {
+	common.BlockRange
+	toBlockTime time.Time
+}
+
+// MemoryStorage stores trade logs in memory. The aggregations are computed on
+// query with the same semantics as the continuous queries of InfluxDB storage.
+// It is meant for development and tests, where no database is available.
+type MemoryStorage struct {
+	sugar      *zap.SugaredLogger
+	coreClient core.Interface
+	tokens     blockchain.TokenAddresses
+
+	mu            *sync.RWMutex
+	tradeLogs     map[tradeKey]common.TradeLog
+	crawledRanges []crawledRange
+}
+
+// NewMemoryStorage creates a new empty MemoryStorage instance, the token
+// addresses are the ones deployed on the network of stored trade logs.
+func NewMemoryStorage(sugar *zap.SugaredLogger, coreClient core.Interface, tokens blockchain.TokenAddresses) *MemoryStorage {
+	return &MemoryStorage{
+		sugar:      sugar,
+		coreClient: coreClient,
+		tokens:     tokens,
+		mu:         &sync.RWMutex{},
+		tradeLogs:  make(map[tradeKey]common.TradeLog),
+	}
+}
+
+// copyTradeLog returns a copy of given trade log that does not share the fee
+// slices with it.
+func copyTradeLog(log common.TradeLog) common.TradeLog {
+	log.BurnFees = append([]common.BurnFee(nil), log.BurnFees...)
+	log.WalletFees = append([]common.WalletFee(nil), log.WalletFees...)
+	return log
+}
+
+// SaveTradeLogs stores trade logs, replacing the stored trade logs with the
+// same transaction hash and log index.
+func (ms *MemoryStorage) SaveTradeLogs(logs []common.TradeLog, rates []tokenrate.ETHUSDRate) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for index, log := range logs {
+		log = copyTradeLog(log)
+		log.ETHUSDRate = rates[index].Rate
+		log.ETHUSDProvider = rates[index].Provider
+		ms.tradeLogs[tradeKey{txHash: log.TransactionHash, logIndex: log.LogIndex}] = log
+	}
+	ms.sugar.Debugw("saved trade logs into memory",
+		"func", "tradelogs/storage/MemoryStorage.SaveTradeLogs",
+		"trade_logs", len(logs))
+	return nil
+}
+
+// inRange returns the stored trade logs in given time range, inclusive. The
+// caller must hold the lock.
+func (ms *MemoryStorage) inRange(from, to time.Time) []common.TradeLog {
+	var result []common.TradeLog
+	for _, log := range ms.tradeLogs {
+		if log.Timestamp.Before(from) || log.Timestamp.After(to) {
+			continue
+		}
+		result = append(result, log)
+	}
+	return result
+}
+
+// LoadTradeLogs returns trade logs in given time range, inclusive, ordered by
+// block number and log index.
+func (ms *MemoryStorage) LoadTradeLogs(from, to time.Time) ([]common.TradeLog, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var result []common.TradeLog
+	for _, log := range ms.inRange(from, to) {
+		log = copyTradeLog(log)
+		ethAmountInETH, err := ms.coreClient.FromWei(blockchain.ETHAddr, ethAmount(log))
+		if err != nil {
+			return nil, err
+		}
+		log.FiatAmount = ethAmountInETH * log.ETHUSDRate
+		result = append(result, log)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].BlockNumber != result[j].BlockNumber {
+			return result[i].BlockNumber < result[j].BlockNumber
+		}
+		return result[i].LogIndex < result[j].LogIndex
+	})
+	return result, nil
+}
+
+// DeleteTradeLogsAfter removes all trade logs with timestamp after the given
+// time, and the crawled ranges ending after it.
+func (ms *MemoryStorage) DeleteTradeLogsAfter(t time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var deleted int
+	for key, log := range ms.tradeLogs {
+		if log.Timestamp.After(t) {
+			delete(ms.tradeLogs, key)
+			deleted++
+		}
+	}
+
+	var crawledRanges []crawledRange
+	for _, r := range ms.crawledRanges {
+		if !r.toBlockTime.After(t) {
+			crawledRanges = append(crawledRanges, r)
+		}
+	}
+	ms.crawledRanges = crawledRanges
+	ms.sugar.Infow("deleted trade logs",
+		"func", "tradelogs/storage/MemoryStorage.DeleteTradeLogsAfter",
+		"time", t,
+		"trade_logs", deleted)
+	return nil
+}
+
+// GetAggregatedBurnFee returns the sum of burn fees of given reserves by hour
+// or day, keyed by reserve address and timestamp in milliseconds. All reserves
+// are returned if no reserve address is given.
+func (ms *MemoryStorage) GetAggregatedBurnFee(from, to time.Time, freq string, reserveAddrs []ethereum.Address) (map[ethereum.Address]map[string]float64, error) {
+	period, ok := freqToDuration[strings.ToLower(freq)]
+	if !ok {
+		return nil, fmt.Errorf("invalid burn fee frequency %s", freq)
+	}
+
+	reserves := make(map[ethereum.Address]bool)
+	for _, rsvAddr := range reserveAddrs {
+		reserves[rsvAddr] = true
+	}
+
+	ms.mu.RLock()
+	sums := make(map[ethereum.Address]map[time.Time]*big.Int)
+	for _, log := range ms.tradeLogs {
+		bucket := log.Timestamp.UTC().Truncate(period)
+		if bucket.Before(from) || bucket.After(to) {
+			continue
+		}
+		for _, fee := range log.BurnFees {
+			if len(reserves) != 0 && !reserves[fee.ReserveAddress] {
+				continue
+			}
+			if fee.Amount == nil {
+				continue
+			}
+			if _, ok := sums[fee.ReserveAddress]; !ok {
+				sums[fee.ReserveAddress] = make(map[time.Time]*big.Int)
+			}
+			if _, ok := sums[fee.ReserveAddress][bucket]; !ok {
+				sums[fee.ReserveAddress][bucket] = big.NewInt(0)
+			}
+			sums[fee.ReserveAddress][bucket].Add(sums[fee.ReserveAddress][bucket], fee.Amount)
+		}
+	}
+	ms.mu.RUnlock()
+
+	if len(sums) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[ethereum.Address]map[string]float64)
+	for reserve, buckets := range sums {
+		result[reserve] = make(map[string]float64)
+		for bucket, amountInWei := range buckets {
+			amount, err := ms.coreClient.FromWei(ms.tokens.KNC, amountInWei)
+			if err != nil {
+				return nil, err
+			}
+			result[reserve][strconv.FormatUint(timeutil.TimeToTimestampMs(bucket), 10)] = amount
+		}
+	}
+	return result, nil
+}
+
+// GetAggregatedWalletFee returns the sum of wallet fees earned by given wallet
+// by hour or day, keyed by reserve address and timestamp in milliseconds. All
+// reserves are returned if no reserve address is given.
+func (ms *MemoryStorage) GetAggregatedWalletFee(from, to time.Time, freq string, walletAddr ethereum.Address, reserveAddrs []ethereum.Address) (map[ethereum.Address]map[string]float64, error) {
+	period, ok := freqToDuration[strings.ToLower(freq)]
+	if !ok {
+		return nil, fmt.Errorf("invalid wallet fee frequency %s", freq)
+	}
+
+	reserves := make(map[ethereum.Address]bool)
+	for _, rsvAddr := range reserveAddrs {
+		reserves[rsvAddr] = true
+	}
+
+	ms.mu.RLock()
+	sums := make(map[ethereum.Address]map[time.Time]*big.Int)
+	for _, log := range ms.tradeLogs {
+		bucket := log.Timestamp.UTC().Truncate(period)
+		if bucket.Before(from) || bucket.After(to) {
+			continue
+		}
+		for _, fee := range log.WalletFees {
+			if fee.WalletAddress != walletAddr || (len(reserves) != 0 && !reserves[fee.ReserveAddress]) {
+				continue
+			}
+			if fee.Amount == nil {
+				continue
+			}
+			if _, ok := sums[fee.ReserveAddress]; !ok {
+				sums[fee.ReserveAddress] = make(map[time.Time]*big.Int)
+			}
+			if _, ok := sums[fee.ReserveAddress][bucket]; !ok {
+				sums[fee.ReserveAddress][bucket] = big.NewInt(0)
+			}
+			sums[fee.ReserveAddress][bucket].Add(sums[fee.ReserveAddress][bucket], fee.Amount)
+		}
+	}
+	ms.mu.RUnlock()
+
+	if len(sums) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[ethereum.Address]map[string]float64)
+	for reserve, buckets := range sums {
+		result[reserve] = make(map[string]float64)
+		for bucket, amountInWei := range buckets {
+			amount, err := ms.coreClient.FromWei(ms.tokens.KNC, amountInWei)
+			if err != nil {
+				return nil, err
+			}
+			result[reserve][strconv.FormatUint(timeutil.TimeToTimestampMs(bucket), 10)] = amount
+		}
+	}
+	return result, nil
+}
+
+// GetWalletStats returns the statistics of trades routed through given wallet
+// by hour or day, keyed by timestamp in milliseconds. A trade is routed through
+// the wallet of its first wallet fee.
+func (ms *MemoryStorage) GetWalletStats(from, to time.Time, freq string, walletAddr ethereum.Address) (map[uint64]*common.WalletStats, error) {
+	period, ok := freqToDuration[strings.ToLower(freq)]
+	if !ok {
+		return nil, fmt.Errorf("invalid wallet stats frequency %s", freq)
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	result := make(map[uint64]*common.WalletStats)
+	for _, log := range ms.tradeLogs {
+		bucket := log.Timestamp.UTC().Truncate(period)
+		if bucket.Before(from) || bucket.After(to) {
+			continue
+		}
+		if len(log.WalletFees) == 0 || log.WalletFees[0].WalletAddress != walletAddr {
+			continue
+		}
+
+		key := timeutil.TimeToTimestampMs(bucket)
+		stats, ok := result[key]
+		if !ok {
+			stats = &common.WalletStats{}
+			result[key] = stats
+		}
+
+		ethAmountInETH, err := ms.coreClient.FromWei(blockchain.ETHAddr, ethAmount(log))
+		if err != nil {
+			return nil, err
+		}
+		stats.TradeCount++
+		stats.ETHVolume += ethAmountInETH
+		stats.USDVolume += ethAmountInETH * log.ETHUSDRate
+
+		for _, fee := range log.WalletFees {
+			if fee.WalletAddress != walletAddr || fee.Amount == nil {
+				continue
+			}
+			amount, err := ms.coreClient.FromWei(ms.tokens.KNC, fee.Amount)
+			if err != nil {
+				return nil, err
+			}
+			stats.Fee += amount
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// isExcludedFromVolume returns true if given trade is not counted in volume,
+// matching the filter of the volume continuous queries: only the trades from
+// ETH to ETH and from WETH to WETH are excluded.
+func isExcludedFromVolume(tokens blockchain.TokenAddresses, log common.TradeLog) bool {
+	return (log.SrcAddress == blockchain.ETHAddr || log.DestAddress == tokens.WETH) &&
+		(log.SrcAddress == tokens.WETH || log.DestAddress == blockchain.ETHAddr)
+}
+
+// GetAssetVolume returns the volume of given token by hour or day, keyed by
+// timestamp in milliseconds. The trades from ETH to ETH and from WETH to WETH
+// are not counted.
+func (ms *MemoryStorage) GetAssetVolume(token core.Token, fromTime, toTime uint64, frequency string) (map[uint64]*common.VolumeStats, error) {
+	period, ok := freqToDuration[strings.ToLower(frequency)]
+	if !ok {
+		return nil, fmt.Errorf("frequency %s is not supported", frequency)
+	}
+
+	var (
+		tokenAddr = ethereum.HexToAddress(token.Address)
+		from      = timeutil.TimestampMsToTime(fromTime).UTC()
+		to        = timeutil.TimestampMsToTime(toTime).UTC()
+		result    = make(map[uint64]*common.VolumeStats)
+	)
+
+	// all periods in time range are returned, filled with zero volume
+	for ts := from.Truncate(period); !ts.After(to); ts = ts.Add(period) {
+		result[timeutil.TimeToTimestampMs(ts)] = &common.VolumeStats{}
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	for _, log := range ms.inRange(from, to) {
+		if isExcludedFromVolume(ms.tokens, log) {
+			continue
+		}
+
+		// a trade is counted once for each side that is the given token
+		var tokenAmounts []*big.Int
+		if log.SrcAddress == tokenAddr {
+			tokenAmounts = append(tokenAmounts, log.SrcAmount)
+		}
+		if log.DestAddress == tokenAddr {
+			tokenAmounts = append(tokenAmounts, log.DestAmount)
+		}
+		if len(tokenAmounts) == 0 {
+			continue
+		}
+
+		ethAmountInETH, err := ms.coreClient.FromWei(blockchain.ETHAddr, ethAmount(log))
+		if err != nil {
+			return nil, err
+		}
+		stats := result[timeutil.TimeToTimestampMs(log.Timestamp.UTC().Truncate(period))]
+		for _, tokenAmount := range tokenAmounts {
+			volume, err := ms.coreClient.FromWei(tokenAddr, tokenAmount)
+			if err != nil {
+				return nil, err
+			}
+			stats.Volume += volume
+			stats.ETHAmount += ethAmountInETH
+			stats.USDAmount += ethAmountInETH * log.ETHUSDRate
+		}
+	}
+	return result, nil
+}
+
+// reserveSide is a side of a trade handled by a reserve, which trades the
+// token of the side with ETH.
+type reserveSide struct {
+	reserve ethereum.Address
+	token   ethereum.Address
+	amount  *big.Int
+}
+
+// reserveSides returns the sides of given trade handled by reserves. The
+// reserves are given by burn fees in the same way as the src_rsv_addr and
+// dst_rsv_addr tags of InfluxDB storage.
+func reserveSides(tokens blockchain.TokenAddresses, log common.TradeLog) []reserveSide {
+	var (
+		sides   []reserveSide
+		feeIdx  int
+		addSide = func(token ethereum.Address, amount *big.Int) {
+			if tokens.IsBurnable(token) {
+				if feeIdx < len(log.BurnFees) {
+					sides = append(sides, reserveSide{
+						reserve: log.BurnFees[feeIdx].ReserveAddress,
+						token:   token,
+						amount:  amount,
+					})
+				}
+				feeIdx++
+			}
+		}
+	)
+	addSide(log.SrcAddress, log.SrcAmount)
+	addSide(log.DestAddress, log.DestAmount)
+	return sides
+}
+
+// GetReserveVolume returns the volume handled by given reserve by hour or day,
+// keyed by token address and timestamp in milliseconds. All periods in time
+// range are returned for the tokens traded by the reserve. The trades from ETH
+// to ETH and from WETH to WETH are not counted.
+func (ms *MemoryStorage) GetReserveVolume(rsvAddr ethereum.Address, tokenAddrs []ethereum.Address, fromTime, toTime uint64, frequency string) (map[ethereum.Address]map[uint64]*common.VolumeStats, error) {
+	period, ok := freqToDuration[strings.ToLower(frequency)]
+	if !ok {
+		return nil, fmt.Errorf("frequency %s is not supported", frequency)
+	}
+
+	var (
+		from   = timeutil.TimestampMsToTime(fromTime).UTC()
+		to     = timeutil.TimestampMsToTime(toTime).UTC()
+		tokens = make(map[ethereum.Address]bool)
+		result = make(map[ethereum.Address]map[uint64]*common.VolumeStats)
+	)
+	for _, tokenAddr := range tokenAddrs {
+		tokens[tokenAddr] = true
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	for _, log := range ms.inRange(from, to) {
+		if isExcludedFromVolume(ms.tokens, log) {
+			continue
+		}
+
+		for _, side := range reserveSides(ms.tokens, log) {
+			if side.reserve != rsvAddr || (len(tokens) != 0 && !tokens[side.token]) {
+				continue
+			}
+
+			if _, ok := result[side.token]; !ok {
+				result[side.token] = make(map[uint64]*common.VolumeStats)
+				for ts := from.Truncate(period); !ts.After(to); ts = ts.Add(period) {
+					result[side.token][timeutil.TimeToTimestampMs(ts)] = &common.VolumeStats{}
+				}
+			}
+
+			volume, err := ms.coreClient.FromWei(side.token, side.amount)
+			if err != nil {
+				return nil, err
+			}
+			ethAmountInETH, err := ms.coreClient.FromWei(blockchain.ETHAddr, ethAmount(log))
+			if err != nil {
+				return nil, err
+			}
+			stats := result[side.token][timeutil.TimeToTimestampMs(log.Timestamp.UTC().Truncate(period))]
+			stats.Volume += volume
+			stats.ETHAmount += ethAmountInETH
+			stats.USDAmount += ethAmountInETH * log.ETHUSDRate
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// SaveCrawledRange records that trade logs of given block range are crawled and stored.
+func (ms *MemoryStorage) SaveCrawledRange(r common.BlockRange, toBlockTime time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.crawledRanges = append(ms.crawledRanges, crawledRange{BlockRange: r, toBlockTime: toBlockTime})
+	return nil
+}
+
+// LoadCrawledRanges returns the recorded crawled block ranges that overlap with
+// the given block range.
+func (ms *MemoryStorage) LoadCrawledRanges(fromBlock, toBlock uint64) ([]common.BlockRange, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var ranges []common.BlockRange
+	for _, r := range ms.crawledRanges {
+		if r.ToBlock >= fromBlock && r.FromBlock <= toBlock {
+			ranges = append(ranges, r.BlockRange)
+		}
+	}
+	return ranges, nil
+}
+
+// CountTrades returns the number of stored trades in given time range, inclusive.
+func (ms *MemoryStorage) CountTrades(from, to time.Time) (uint64, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return uint64(len(ms.inRange(from, to))), nil
+}
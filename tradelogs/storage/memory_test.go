@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
+	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+// weiCoreClient is a mock core client of which all tokens have 18 decimals.
+type weiCoreClient struct {
+	*core.MockClient
+}
+
+func (c weiCoreClient) FromWei(_ ethereum.Address, amount *big.Int) (float64, error) {
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), big.NewFloat(1e18)).Float64()
+	return f, nil
+}
+
+func ether(amount int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(amount), big.NewInt(1e18))
+}
+
+func TestMemoryStorage(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	var (
+		ms       = NewMemoryStorage(sugar, weiCoreClient{MockClient: core.NewMockClient()}, blockchain.MainnetTokenAddresses())
+		ts       = time.Date(2018, 10, 8, 12, 30, 0, 0, time.UTC)
+		reserve1 = ethereum.HexToAddress("0x63825c174ab367968EC60f061753D3bbD36A0D8F")
+		reserve2 = ethereum.HexToAddress("0x21433Dec9Cb634A23c6A4BbcCe08c83f5aC2EC18")
+		token    = ethereum.HexToAddress("0xdd974D5C2e2928deA5F71b9825b8b646686BD200")
+		wallet   = ethereum.HexToAddress("0xb9E29984Fe50602E7A619662EBED4F90D93824C7")
+	)
+
+	newTradeLog := func(txHash string, timestamp time.Time, blockNumber uint64, src, dst ethereum.Address) common.TradeLog {
+		return common.TradeLog{
+			Timestamp:       timestamp,
+			BlockNumber:     blockNumber,
+			TransactionHash: ethereum.HexToHash(txHash),
+			SrcAddress:      src,
+			DestAddress:     dst,
+			SrcAmount:       ether(1),
+			DestAmount:      ether(300),
+			BurnFees: []common.BurnFee{
+				{ReserveAddress: reserve1, Amount: ether(2)},
+				{ReserveAddress: reserve2, Amount: ether(3)},
+			},
+		}
+	}
+
+	tradeLogs := []common.TradeLog{
+		newTradeLog("0x02", ts.Add(10*time.Minute), 102, blockchain.ETHAddr, token),
+		newTradeLog("0x01", ts, 101, blockchain.ETHAddr, token),
+		newTradeLog("0x03", ts.Add(24*time.Hour), 200, blockchain.ETHAddr, token),
+		newTradeLog("0x04", ts.Add(24*time.Hour), 201, blockchain.ETHAddr, blockchain.WETHAddr),
+	}
+	tradeLogs[1].WalletFees = []common.WalletFee{
+		{ReserveAddress: reserve1, WalletAddress: wallet, Amount: ether(1)},
+		{ReserveAddress: reserve2, WalletAddress: wallet, Amount: ether(2)},
+	}
+	var rates []tokenrate.ETHUSDRate
+	for range tradeLogs {
+		rates = append(rates, tokenrate.ETHUSDRate{Rate: 200, Provider: "test"})
+	}
+
+	// saving the same trade logs again replaces the stored ones
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, ms.SaveTradeLogs(tradeLogs, rates))
+	}
+
+	from, to := ts.Add(-time.Hour), ts.Add(48*time.Hour)
+	loaded, err := ms.LoadTradeLogs(from, to)
+	assert.NoError(t, err)
+	if assert.Len(t, loaded, len(tradeLogs)) {
+		assert.Equal(t, uint64(101), loaded[0].BlockNumber)
+		assert.Equal(t, uint64(102), loaded[1].BlockNumber)
+		assert.Equal(t, 200.0, loaded[0].FiatAmount)
+	}
+
+	burnFees, err := ms.GetAggregatedBurnFee(from, to, "h", []ethereum.Address{reserve1})
+	assert.NoError(t, err)
+	assert.Equal(t, map[ethereum.Address]map[string]float64{
+		reserve1: {
+			"1539000000000": 4,
+			"1539086400000": 4,
+		},
+	}, burnFees)
+
+	// the aggregated periods must start in time range
+	burnFees, err = ms.GetAggregatedBurnFee(from, to, "d", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[ethereum.Address]map[string]float64{
+		reserve1: {"1539043200000": 4},
+		reserve2: {"1539043200000": 6},
+	}, burnFees)
+
+	burnFees, err = ms.GetAggregatedBurnFee(ts.Truncate(24*time.Hour), to, "d", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[ethereum.Address]map[string]float64{
+		reserve1: {"1538956800000": 4, "1539043200000": 4},
+		reserve2: {"1538956800000": 6, "1539043200000": 6},
+	}, burnFees)
+
+	walletFees, err := ms.GetAggregatedWalletFee(from, to, "h", wallet, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[ethereum.Address]map[string]float64{
+		reserve1: {"1539000000000": 1},
+		reserve2: {"1539000000000": 2},
+	}, walletFees)
+
+	// the aggregated periods must start in time range
+	walletFees, err = ms.GetAggregatedWalletFee(from, to, "d", wallet, []ethereum.Address{reserve2})
+	assert.NoError(t, err)
+	assert.Nil(t, walletFees)
+
+	walletFees, err = ms.GetAggregatedWalletFee(ts.Truncate(24*time.Hour), to, "d", wallet, []ethereum.Address{reserve2})
+	assert.NoError(t, err)
+	assert.Equal(t, map[ethereum.Address]map[string]float64{
+		reserve2: {"1538956800000": 2},
+	}, walletFees)
+
+	// the trade is counted once for both wallet fees
+	walletStats, err := ms.GetWalletStats(from, to, "h", wallet)
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint64]*common.WalletStats{
+		1539000000000: {TradeCount: 1, ETHVolume: 1, USDVolume: 200, Fee: 3},
+	}, walletStats)
+
+	walletStats, err = ms.GetWalletStats(from, to, "h", reserve1)
+	assert.NoError(t, err)
+	assert.Nil(t, walletStats)
+
+	volumes, err := ms.GetAssetVolume(core.Token{Address: token.Hex()},
+		timeutil.TimeToTimestampMs(ts), timeutil.TimeToTimestampMs(ts.Add(2*time.Hour)), "h")
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint64]*common.VolumeStats{
+		1539000000000: {ETHAmount: 2, USDAmount: 400, Volume: 600},
+		1539003600000: {},
+		1539007200000: {},
+	}, volumes)
+
+	// the trade from ETH to WETH is counted, as by the volume continuous queries
+	volumes, err = ms.GetAssetVolume(core.Token{Address: blockchain.ETHAddr.Hex()},
+		timeutil.TimeToTimestampMs(ts), timeutil.TimeToTimestampMs(ts.Add(24*time.Hour)), "d")
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint64]*common.VolumeStats{
+		1538956800000: {ETHAmount: 2, USDAmount: 400, Volume: 2},
+		1539043200000: {ETHAmount: 2, USDAmount: 400, Volume: 2},
+	}, volumes)
+
+	// the destination token of trades from ETH is handled by the reserve of the first burn fee
+	rsvVolumes, err := ms.GetReserveVolume(reserve1, nil,
+		timeutil.TimeToTimestampMs(ts), timeutil.TimeToTimestampMs(ts.Add(2*time.Hour)), "h")
+	assert.NoError(t, err)
+	assert.Equal(t, map[ethereum.Address]map[uint64]*common.VolumeStats{
+		token: {
+			1539000000000: {ETHAmount: 2, USDAmount: 400, Volume: 600},
+			1539003600000: {},
+			1539007200000: {},
+		},
+	}, rsvVolumes)
+
+	rsvVolumes, err = ms.GetReserveVolume(reserve1, []ethereum.Address{blockchain.ETHAddr},
+		timeutil.TimeToTimestampMs(ts), timeutil.TimeToTimestampMs(ts.Add(2*time.Hour)), "h")
+	assert.NoError(t, err)
+	assert.Nil(t, rsvVolumes)
+
+	rsvVolumes, err = ms.GetReserveVolume(reserve2, nil,
+		timeutil.TimeToTimestampMs(ts), timeutil.TimeToTimestampMs(ts.Add(2*time.Hour)), "h")
+	assert.NoError(t, err)
+	assert.Nil(t, rsvVolumes)
+
+	assert.NoError(t, ms.SaveCrawledRange(common.BlockRange{FromBlock: 100, ToBlock: 199}, ts))
+	assert.NoError(t, ms.SaveCrawledRange(common.BlockRange{FromBlock: 200, ToBlock: 299}, ts.Add(24*time.Hour)))
+	ranges, err := ms.LoadCrawledRanges(150, 300)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.BlockRange{{FromBlock: 100, ToBlock: 199}, {FromBlock: 200, ToBlock: 299}}, ranges)
+
+	// the trades, aggregates and crawled ranges after reorg block are removed
+	assert.NoError(t, ms.DeleteTradeLogsAfter(ts))
+	count, err := ms.CountTrades(from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+
+	burnFees, err = ms.GetAggregatedBurnFee(from, to, "h", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[ethereum.Address]map[string]float64{
+		reserve1: {"1539000000000": 2},
+		reserve2: {"1539000000000": 3},
+	}, burnFees)
+
+	ranges, err = ms.LoadCrawledRanges(150, 300)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.BlockRange{{FromBlock: 100, ToBlock: 199}}, ranges)
+}
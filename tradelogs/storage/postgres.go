@@ -0,0 +1,926 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
+	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+const (
+	tradeLogsTableName     = "tradelogs"
+	burnFeesTableName      = "burn_fees"
+	walletFeesTableName    = "wallet_fees"
+	crawledRangesTableName = "crawled_ranges"
+)
+
+// freqToTruncField is the date_trunc field of the supported aggregation frequencies.
+var freqToTruncField = map[string]string{
+	"h": "hour",
+	"d": "day",
+}
+
+// PostgresStorage stores trade logs in PostgreSQL with a normalised schema:
+// the burn fees and wallet fees of a trade reference the trade by id. The
+// amounts are stored in wei as NUMERIC, so they are loaded back without loss.
+type PostgresStorage struct {
+	sugar      *zap.SugaredLogger
+	db         *sqlx.DB
+	coreClient core.Interface
+	tokens     blockchain.TokenAddresses
+}
+
+// NewPostgresStorage creates a new PostgresStorage instance, initializing the
+// database schema if it does not exist. The token addresses are the ones
+// deployed on the network of stored trade logs.
+func NewPostgresStorage(sugar *zap.SugaredLogger, db *sqlx.DB, coreClient core.Interface, tokens blockchain.TokenAddresses) (*PostgresStorage, error) {
+	const schemaFmt = `
+CREATE TABLE IF NOT EXISTS "%[1]s" (
+  id                  SERIAL PRIMARY KEY,
+  timestamp           TIMESTAMP        NOT NULL,
+  block_number        BIGINT           NOT NULL,
+  tx_hash             TEXT             NOT NULL,
+  log_index           INTEGER          NOT NULL,
+  eth_receival_sender TEXT             NOT NULL,
+  eth_receival_amount NUMERIC          NOT NULL,
+  user_addr           TEXT             NOT NULL,
+  src_addr            TEXT             NOT NULL,
+  dst_addr            TEXT             NOT NULL,
+  src_amount          NUMERIC          NOT NULL,
+  dst_amount          NUMERIC          NOT NULL,
+  eth_amount          NUMERIC          NOT NULL,
+  eth_usd_rate        DOUBLE PRECISION,
+  eth_usd_provider    TEXT             NOT NULL,
+  ip                  TEXT             NOT NULL,
+  country             TEXT             NOT NULL,
+  tx_index            INTEGER          NOT NULL,
+  tx_sender           TEXT,
+  tx_recipient        TEXT,
+  gas_price           NUMERIC,
+  gas_used            BIGINT,
+  gas_cost            NUMERIC,
+  UNIQUE (tx_hash, log_index)
+);
+CREATE INDEX IF NOT EXISTS "%[1]s_timestamp_idx" ON "%[1]s" (timestamp);
+CREATE INDEX IF NOT EXISTS "%[1]s_block_number_idx" ON "%[1]s" (block_number);
+CREATE INDEX IF NOT EXISTS "%[1]s_user_addr_idx" ON "%[1]s" (user_addr);
+CREATE INDEX IF NOT EXISTS "%[1]s_src_addr_idx" ON "%[1]s" (src_addr);
+CREATE INDEX IF NOT EXISTS "%[1]s_dst_addr_idx" ON "%[1]s" (dst_addr);
+
+CREATE TABLE IF NOT EXISTS "%[2]s" (
+  id           SERIAL PRIMARY KEY,
+  trade_id     INTEGER NOT NULL REFERENCES "%[1]s" (id) ON DELETE CASCADE,
+  ordinal      INTEGER NOT NULL,
+  reserve_addr TEXT    NOT NULL,
+  reserve_name TEXT    NOT NULL,
+  amount       NUMERIC NOT NULL
+);
+CREATE INDEX IF NOT EXISTS "%[2]s_trade_id_idx" ON "%[2]s" (trade_id);
+CREATE INDEX IF NOT EXISTS "%[2]s_reserve_addr_idx" ON "%[2]s" (reserve_addr);
+
+CREATE TABLE IF NOT EXISTS "%[3]s" (
+  id           SERIAL PRIMARY KEY,
+  trade_id     INTEGER NOT NULL REFERENCES "%[1]s" (id) ON DELETE CASCADE,
+  ordinal      INTEGER NOT NULL,
+  reserve_addr TEXT    NOT NULL,
+  reserve_name TEXT    NOT NULL,
+  wallet_addr  TEXT    NOT NULL,
+  wallet_name  TEXT    NOT NULL,
+  amount       NUMERIC NOT NULL
+);
+CREATE INDEX IF NOT EXISTS "%[3]s_trade_id_idx" ON "%[3]s" (trade_id);
+CREATE INDEX IF NOT EXISTS "%[3]s_reserve_addr_idx" ON "%[3]s" (reserve_addr);
+CREATE INDEX IF NOT EXISTS "%[3]s_wallet_addr_idx" ON "%[3]s" (wallet_addr);
+
+CREATE TABLE IF NOT EXISTS "%[4]s" (
+  id            SERIAL PRIMARY KEY,
+  from_block    BIGINT    NOT NULL,
+  to_block      BIGINT    NOT NULL,
+  crawled_at    TIMESTAMP NOT NULL,
+  to_block_time TIMESTAMP
+);
+`
+	var logger = sugar.With("func", "tradelogs/storage.NewPostgresStorage")
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer rollbackUnlessCommitted(tx)
+
+	logger.Debug("initializing database schema")
+	if _, err = tx.Exec(fmt.Sprintf(schemaFmt,
+		tradeLogsTableName,
+		burnFeesTableName,
+		walletFeesTableName,
+		crawledRangesTableName,
+	)); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	logger.Debug("database schema initialized successfully")
+
+	return &PostgresStorage{
+		sugar:      sugar,
+		db:         db,
+		coreClient: coreClient,
+		tokens:     tokens,
+	}, nil
+}
+
+// rollbackUnlessCommitted rolls back given transaction, it is a no-op if the
+// transaction is already committed.
+func rollbackUnlessCommitted(tx *sqlx.Tx) {
+	_ = tx.Rollback()
+}
+
+// DeleteAllTables removes all tables of trade logs, it is used in tests only.
+func (ps *PostgresStorage) DeleteAllTables() error {
+	_, err := ps.db.Exec(fmt.Sprintf(`DROP TABLE "%s", "%s", "%s", "%s"`,
+		walletFeesTableName, burnFeesTableName, tradeLogsTableName, crawledRangesTableName))
+	return err
+}
+
+// Close closes the database connection.
+func (ps *PostgresStorage) Close() error {
+	return ps.db.Close()
+}
+
+// numeric returns the value of given amount to store in a NUMERIC column, a nil amount is zero.
+func numeric(amount *big.Int) string {
+	if amount == nil {
+		return "0"
+	}
+	return amount.String()
+}
+
+// parseNumeric parses the value of a NUMERIC column of integer.
+func parseNumeric(val string) (*big.Int, error) {
+	// SUM of integers may be formatted with a fractional part of zeros
+	if i := strings.IndexByte(val, '.'); i >= 0 {
+		val = val[:i]
+	}
+	amount, ok := big.NewInt(0).SetString(val, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid numeric value %q", val)
+	}
+	return amount, nil
+}
+
+// ethAmount returns the ETH amount of given trade in wei.
+func ethAmount(log common.TradeLog) *big.Int {
+	switch {
+	case log.SrcAddress == blockchain.ETHAddr:
+		return log.SrcAmount
+	case log.DestAddress == blockchain.ETHAddr:
+		return log.DestAmount
+	default:
+		return log.EtherReceivalAmount
+	}
+}
+
+// SaveTradeLogs persists trade logs to database, replacing the stored trade
+// logs with the same transaction hash and log index.
+func (ps *PostgresStorage) SaveTradeLogs(logs []common.TradeLog, rates []tokenrate.ETHUSDRate) error {
+	logger := ps.sugar.With(
+		"func", "tradelogs/storage/PostgresStorage.SaveTradeLogs",
+		"trade_logs", len(logs),
+	)
+
+	tx, err := ps.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer rollbackUnlessCommitted(tx)
+
+	for index, log := range logs {
+		if err = ps.saveTradeLog(tx, log, rates[index]); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	logger.Debug("saved trade logs into postgres")
+	return nil
+}
+
+func (ps *PostgresStorage) saveTradeLog(tx *sqlx.Tx, log common.TradeLog, rate tokenrate.ETHUSDRate) error {
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM "%s" WHERE tx_hash = $1 AND log_index = $2`, tradeLogsTableName),
+		log.TransactionHash.Hex(), log.LogIndex); err != nil {
+		return err
+	}
+
+	var (
+		txSender, txRecipient, gasPrice, gasCost sql.NullString
+		gasUsed                                  sql.NullInt64
+		// the USD amounts of trades without ETH/USD rate are left out of
+		// aggregations instead of counted as zero
+		ethUSDRate = sql.NullFloat64{Float64: rate.Rate, Valid: hasETHUSDRate(rate)}
+	)
+	if log.GasPrice != nil {
+		txSender = sql.NullString{String: log.TxSender.Hex(), Valid: true}
+		txRecipient = sql.NullString{String: log.TxRecipient.Hex(), Valid: true}
+		gasPrice = sql.NullString{String: numeric(log.GasPrice), Valid: true}
+		gasUsed = sql.NullInt64{Int64: int64(log.GasUsed), Valid: true}
+		gasCost = sql.NullString{String: numeric(log.GasCost), Valid: true}
+	}
+
+	var tradeID int64
+	if err := tx.Get(&tradeID, fmt.Sprintf(`
+INSERT INTO "%s" (timestamp, block_number, tx_hash, log_index,
+                  eth_receival_sender, eth_receival_amount,
+                  user_addr, src_addr, dst_addr, src_amount, dst_amount, eth_amount,
+                  eth_usd_rate, eth_usd_provider, ip, country,
+                  tx_index, tx_sender, tx_recipient, gas_price, gas_used, gas_cost)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
+RETURNING id;
+`, tradeLogsTableName),
+		log.Timestamp.UTC(),
+		int64(log.BlockNumber),
+		log.TransactionHash.Hex(),
+		log.LogIndex,
+		log.EtherReceivalSender.Hex(),
+		numeric(log.EtherReceivalAmount),
+		log.UserAddress.Hex(),
+		log.SrcAddress.Hex(),
+		log.DestAddress.Hex(),
+		numeric(log.SrcAmount),
+		numeric(log.DestAmount),
+		numeric(ethAmount(log)),
+		ethUSDRate,
+		rate.Provider,
+		log.IP,
+		log.Country,
+		log.TxIndex,
+		txSender,
+		txRecipient,
+		gasPrice,
+		gasUsed,
+		gasCost,
+	); err != nil {
+		return err
+	}
+
+	for idx, burn := range log.BurnFees {
+		if _, err := tx.Exec(fmt.Sprintf(`
+INSERT INTO "%s" (trade_id, ordinal, reserve_addr, reserve_name, amount)
+VALUES ($1, $2, $3, $4, $5);
+`, burnFeesTableName),
+			tradeID, idx, burn.ReserveAddress.Hex(), burn.ReserveName, numeric(burn.Amount)); err != nil {
+			return err
+		}
+	}
+
+	for idx, walletFee := range log.WalletFees {
+		if _, err := tx.Exec(fmt.Sprintf(`
+INSERT INTO "%s" (trade_id, ordinal, reserve_addr, reserve_name, wallet_addr, wallet_name, amount)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+`, walletFeesTableName),
+			tradeID, idx, walletFee.ReserveAddress.Hex(), walletFee.ReserveName,
+			walletFee.WalletAddress.Hex(), walletFee.WalletName, numeric(walletFee.Amount)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tradeLogRecord is a row of trade logs table.
+type tradeLogRecord struct {
+	ID                  int64           `db:"id"`
+	Timestamp           time.Time       `db:"timestamp"`
+	BlockNumber         uint64          `db:"block_number"`
+	TxHash              string          `db:"tx_hash"`
+	LogIndex            uint            `db:"log_index"`
+	EtherReceivalSender string          `db:"eth_receival_sender"`
+	EtherReceivalAmount string          `db:"eth_receival_amount"`
+	UserAddress         string          `db:"user_addr"`
+	SrcAddress          string          `db:"src_addr"`
+	DestAddress         string          `db:"dst_addr"`
+	SrcAmount           string          `db:"src_amount"`
+	DestAmount          string          `db:"dst_amount"`
+	ETHAmount           string          `db:"eth_amount"`
+	ETHUSDRate          sql.NullFloat64 `db:"eth_usd_rate"`
+	ETHUSDProvider      string          `db:"eth_usd_provider"`
+	IP                  string          `db:"ip"`
+	Country             string          `db:"country"`
+	TxIndex             uint            `db:"tx_index"`
+	TxSender            sql.NullString  `db:"tx_sender"`
+	TxRecipient         sql.NullString  `db:"tx_recipient"`
+	GasPrice            sql.NullString  `db:"gas_price"`
+	GasUsed             sql.NullInt64   `db:"gas_used"`
+	GasCost             sql.NullString  `db:"gas_cost"`
+}
+
+// feeRecord is a row of burn fees or wallet fees table.
+type feeRecord struct {
+	TradeID     int64  `db:"trade_id"`
+	ReserveAddr string `db:"reserve_addr"`
+	ReserveName string `db:"reserve_name"`
+	WalletAddr  string `db:"wallet_addr"`
+	WalletName  string `db:"wallet_name"`
+	Amount      string `db:"amount"`
+}
+
+func (ps *PostgresStorage) recordToTradeLog(record tradeLogRecord) (common.TradeLog, error) {
+	var (
+		tradeLog = common.TradeLog{
+			Timestamp:           record.Timestamp.UTC(),
+			BlockNumber:         record.BlockNumber,
+			TransactionHash:     ethereum.HexToHash(record.TxHash),
+			LogIndex:            record.LogIndex,
+			EtherReceivalSender: ethereum.HexToAddress(record.EtherReceivalSender),
+			UserAddress:         ethereum.HexToAddress(record.UserAddress),
+			SrcAddress:          ethereum.HexToAddress(record.SrcAddress),
+			DestAddress:         ethereum.HexToAddress(record.DestAddress),
+			ETHUSDRate:          record.ETHUSDRate.Float64,
+			ETHUSDProvider:      record.ETHUSDProvider,
+			IP:                  record.IP,
+			Country:             record.Country,
+			TxIndex:             record.TxIndex,
+		}
+		err error
+	)
+
+	if tradeLog.EtherReceivalAmount, err = parseNumeric(record.EtherReceivalAmount); err != nil {
+		return tradeLog, err
+	}
+	if tradeLog.SrcAmount, err = parseNumeric(record.SrcAmount); err != nil {
+		return tradeLog, err
+	}
+	if tradeLog.DestAmount, err = parseNumeric(record.DestAmount); err != nil {
+		return tradeLog, err
+	}
+
+	ethAmountInWei, err := parseNumeric(record.ETHAmount)
+	if err != nil {
+		return tradeLog, err
+	}
+	ethAmount, err := ps.coreClient.FromWei(blockchain.ETHAddr, ethAmountInWei)
+	if err != nil {
+		return tradeLog, err
+	}
+	tradeLog.FiatAmount = ethAmount * record.ETHUSDRate.Float64
+
+	if record.GasPrice.Valid {
+		tradeLog.TxSender = ethereum.HexToAddress(record.TxSender.String)
+		tradeLog.TxRecipient = ethereum.HexToAddress(record.TxRecipient.String)
+		tradeLog.GasUsed = uint64(record.GasUsed.Int64)
+		if tradeLog.GasPrice, err = parseNumeric(record.GasPrice.String); err != nil {
+			return tradeLog, err
+		}
+		if tradeLog.GasCost, err = parseNumeric(record.GasCost.String); err != nil {
+			return tradeLog, err
+		}
+	}
+	return tradeLog, nil
+}
+
+// LoadTradeLogs returns trade logs in given time range, inclusive, ordered by
+// block number and log index.
+func (ps *PostgresStorage) LoadTradeLogs(from, to time.Time) ([]common.TradeLog, error) {
+	logger := ps.sugar.With(
+		"func", "tradelogs/storage/PostgresStorage.LoadTradeLogs",
+		"from", from,
+		"to", to,
+	)
+
+	var records []tradeLogRecord
+	if err := ps.db.Select(&records, fmt.Sprintf(`
+SELECT * FROM "%s" WHERE timestamp >= $1 AND timestamp <= $2 ORDER BY block_number, log_index
+`, tradeLogsTableName), from.UTC(), to.UTC()); err != nil {
+		return nil, err
+	}
+	logger.Debugw("loaded trade logs", "trade_logs", len(records))
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	const feesQueryFmt = `
+SELECT f.trade_id, f.reserve_addr, f.reserve_name, %[1]s, f.amount
+FROM "%[2]s" AS f JOIN "%[3]s" AS t ON t.id = f.trade_id
+WHERE t.timestamp >= $1 AND t.timestamp <= $2
+ORDER BY f.trade_id, f.ordinal
+`
+	var burnFees []feeRecord
+	if err := ps.db.Select(&burnFees, fmt.Sprintf(feesQueryFmt,
+		"'' AS wallet_addr, '' AS wallet_name", burnFeesTableName, tradeLogsTableName),
+		from.UTC(), to.UTC()); err != nil {
+		return nil, err
+	}
+	burnFeesByTrade := make(map[int64][]common.BurnFee)
+	for _, fee := range burnFees {
+		amount, err := parseNumeric(fee.Amount)
+		if err != nil {
+			return nil, err
+		}
+		burnFeesByTrade[fee.TradeID] = append(burnFeesByTrade[fee.TradeID], common.BurnFee{
+			ReserveAddress: ethereum.HexToAddress(fee.ReserveAddr),
+			ReserveName:    fee.ReserveName,
+			Amount:         amount,
+		})
+	}
+
+	var walletFees []feeRecord
+	if err := ps.db.Select(&walletFees, fmt.Sprintf(feesQueryFmt,
+		"f.wallet_addr, f.wallet_name", walletFeesTableName, tradeLogsTableName),
+		from.UTC(), to.UTC()); err != nil {
+		return nil, err
+	}
+	walletFeesByTrade := make(map[int64][]common.WalletFee)
+	for _, fee := range walletFees {
+		amount, err := parseNumeric(fee.Amount)
+		if err != nil {
+			return nil, err
+		}
+		walletFeesByTrade[fee.TradeID] = append(walletFeesByTrade[fee.TradeID], common.WalletFee{
+			ReserveAddress: ethereum.HexToAddress(fee.ReserveAddr),
+			ReserveName:    fee.ReserveName,
+			WalletAddress:  ethereum.HexToAddress(fee.WalletAddr),
+			WalletName:     fee.WalletName,
+			Amount:         amount,
+		})
+	}
+
+	var result []common.TradeLog
+	for _, record := range records {
+		tradeLog, err := ps.recordToTradeLog(record)
+		if err != nil {
+			return nil, err
+		}
+		tradeLog.BurnFees = burnFeesByTrade[record.ID]
+		tradeLog.WalletFees = walletFeesByTrade[record.ID]
+		result = append(result, tradeLog)
+	}
+	return result, nil
+}
+
+// DeleteTradeLogsAfter removes all trade logs with timestamp after the given
+// time, the fees of removed trades are removed by cascade. The crawled ranges
+// ending after the given time are removed as well.
+func (ps *PostgresStorage) DeleteTradeLogsAfter(t time.Time) error {
+	logger := ps.sugar.With(
+		"func", "tradelogs/storage/PostgresStorage.DeleteTradeLogsAfter",
+		"time", t,
+	)
+
+	res, err := ps.db.Exec(fmt.Sprintf(`DELETE FROM "%s" WHERE timestamp > $1`, tradeLogsTableName), t.UTC())
+	if err != nil {
+		return err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	logger.Infow("deleted trade logs", "trade_logs", deleted)
+
+	_, err = ps.db.Exec(fmt.Sprintf(`DELETE FROM "%s" WHERE to_block_time > $1`, crawledRangesTableName), t.UTC())
+	return err
+}
+
+// GetAggregatedBurnFee returns the sum of burn fees of given reserves by hour
+// or day, keyed by reserve address and timestamp in milliseconds. All reserves
+// are returned if no reserve address is given.
+func (ps *PostgresStorage) GetAggregatedBurnFee(from, to time.Time, freq string, reserveAddrs []ethereum.Address) (map[ethereum.Address]map[string]float64, error) {
+	logger := ps.sugar.With(
+		"func", "tradelogs/storage/PostgresStorage.GetAggregatedBurnFee",
+		"from", from,
+		"to", to,
+		"freq", freq,
+		"reserve_addrs", reserveAddrs,
+	)
+
+	truncField, ok := freqToTruncField[strings.ToLower(freq)]
+	if !ok {
+		return nil, fmt.Errorf("invalid burn fee frequency %s", freq)
+	}
+
+	// an empty array instead of NULL to match all reserves
+	addrs := []string{}
+	for _, rsvAddr := range reserveAddrs {
+		addrs = append(addrs, rsvAddr.Hex())
+	}
+
+	var records []struct {
+		Time        time.Time `db:"time"`
+		ReserveAddr string    `db:"reserve_addr"`
+		Amount      string    `db:"amount"`
+	}
+	if err := ps.db.Select(&records, fmt.Sprintf(`
+SELECT date_trunc('%[1]s', t.timestamp) AS time, b.reserve_addr, SUM(b.amount) AS amount
+FROM "%[2]s" AS b JOIN "%[3]s" AS t ON t.id = b.trade_id
+WHERE date_trunc('%[1]s', t.timestamp) >= $1 AND date_trunc('%[1]s', t.timestamp) <= $2
+  AND (cardinality($3::TEXT[]) = 0 OR b.reserve_addr = ANY($3))
+GROUP BY 1, 2
+`, truncField, burnFeesTableName, tradeLogsTableName),
+		from.UTC(), to.UTC(), pq.Array(addrs)); err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		logger.Debug("empty aggregated burn fee result")
+		return nil, nil
+	}
+
+	result := make(map[ethereum.Address]map[string]float64)
+	for _, record := range records {
+		amountInWei, err := parseNumeric(record.Amount)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := ps.coreClient.FromWei(ps.tokens.KNC, amountInWei)
+		if err != nil {
+			return nil, err
+		}
+
+		reserve := ethereum.HexToAddress(record.ReserveAddr)
+		if _, ok := result[reserve]; !ok {
+			result[reserve] = make(map[string]float64)
+		}
+		key := strconv.FormatUint(timeutil.TimeToTimestampMs(record.Time.UTC()), 10)
+		result[reserve][key] = amount
+	}
+	return result, nil
+}
+
+// GetAggregatedWalletFee returns the sum of wallet fees earned by given wallet
+// by hour or day, keyed by reserve address and timestamp in milliseconds. All
+// reserves are returned if no reserve address is given.
+func (ps *PostgresStorage) GetAggregatedWalletFee(from, to time.Time, freq string, walletAddr ethereum.Address, reserveAddrs []ethereum.Address) (map[ethereum.Address]map[string]float64, error) {
+	logger := ps.sugar.With(
+		"func", "tradelogs/storage/PostgresStorage.GetAggregatedWalletFee",
+		"from", from,
+		"to", to,
+		"freq", freq,
+		"wallet_addr", walletAddr.Hex(),
+		"reserve_addrs", reserveAddrs,
+	)
+
+	truncField, ok := freqToTruncField[strings.ToLower(freq)]
+	if !ok {
+		return nil, fmt.Errorf("invalid wallet fee frequency %s", freq)
+	}
+
+	// an empty array instead of NULL to match all reserves
+	addrs := []string{}
+	for _, rsvAddr := range reserveAddrs {
+		addrs = append(addrs, rsvAddr.Hex())
+	}
+
+	var records []struct {
+		Time        time.Time `db:"time"`
+		ReserveAddr string    `db:"reserve_addr"`
+		Amount      string    `db:"amount"`
+	}
+	if err := ps.db.Select(&records, fmt.Sprintf(`
+SELECT date_trunc('%[1]s', t.timestamp) AS time, w.reserve_addr, SUM(w.amount) AS amount
+FROM "%[2]s" AS w JOIN "%[3]s" AS t ON t.id = w.trade_id
+WHERE date_trunc('%[1]s', t.timestamp) >= $1 AND date_trunc('%[1]s', t.timestamp) <= $2
+  AND w.wallet_addr = $3
+  AND (cardinality($4::TEXT[]) = 0 OR w.reserve_addr = ANY($4))
+GROUP BY 1, 2
+`, truncField, walletFeesTableName, tradeLogsTableName),
+		from.UTC(), to.UTC(), walletAddr.Hex(), pq.Array(addrs)); err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		logger.Debug("empty aggregated wallet fee result")
+		return nil, nil
+	}
+
+	result := make(map[ethereum.Address]map[string]float64)
+	for _, record := range records {
+		amountInWei, err := parseNumeric(record.Amount)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := ps.coreClient.FromWei(ps.tokens.KNC, amountInWei)
+		if err != nil {
+			return nil, err
+		}
+
+		reserve := ethereum.HexToAddress(record.ReserveAddr)
+		if _, ok := result[reserve]; !ok {
+			result[reserve] = make(map[string]float64)
+		}
+		key := strconv.FormatUint(timeutil.TimeToTimestampMs(record.Time.UTC()), 10)
+		result[reserve][key] = amount
+	}
+	return result, nil
+}
+
+// GetWalletStats returns the statistics of trades routed through given wallet
+// by hour or day, keyed by timestamp in milliseconds. A trade is routed through
+// the wallet of its first wallet fee.
+func (ps *PostgresStorage) GetWalletStats(from, to time.Time, freq string, walletAddr ethereum.Address) (map[uint64]*common.WalletStats, error) {
+	logger := ps.sugar.With(
+		"func", "tradelogs/storage/PostgresStorage.GetWalletStats",
+		"from", from,
+		"to", to,
+		"freq", freq,
+		"wallet_addr", walletAddr.Hex(),
+	)
+
+	truncField, ok := freqToTruncField[strings.ToLower(freq)]
+	if !ok {
+		return nil, fmt.Errorf("invalid wallet stats frequency %s", freq)
+	}
+
+	var records []struct {
+		Time       time.Time `db:"time"`
+		TradeCount int64     `db:"trade_count"`
+		ETHVolume  string    `db:"eth_volume"`
+		USDVolume  float64   `db:"usd_volume"`
+		Fee        string    `db:"fee"`
+	}
+	if err := ps.db.Select(&records, fmt.Sprintf(`
+SELECT date_trunc('%[1]s', t.timestamp) AS time,
+       COUNT(*) AS trade_count,
+       SUM(t.eth_amount) AS eth_volume,
+       COALESCE(SUM(t.eth_amount * t.eth_usd_rate::NUMERIC) / 1e18, 0)::DOUBLE PRECISION AS usd_volume,
+       SUM((SELECT COALESCE(SUM(f.amount), 0) FROM "%[2]s" AS f WHERE f.trade_id = t.id AND f.wallet_addr = $3)) AS fee
+FROM "%[3]s" AS t JOIN "%[2]s" AS w ON w.trade_id = t.id AND w.ordinal = 0
+WHERE date_trunc('%[1]s', t.timestamp) >= $1 AND date_trunc('%[1]s', t.timestamp) <= $2
+  AND w.wallet_addr = $3
+GROUP BY 1
+`, truncField, walletFeesTableName, tradeLogsTableName),
+		from.UTC(), to.UTC(), walletAddr.Hex()); err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		logger.Debug("empty wallet stats result")
+		return nil, nil
+	}
+
+	result := make(map[uint64]*common.WalletStats)
+	for _, record := range records {
+		ethVolume, err := parseNumeric(record.ETHVolume)
+		if err != nil {
+			return nil, err
+		}
+		fee, err := parseNumeric(record.Fee)
+		if err != nil {
+			return nil, err
+		}
+
+		stats := &common.WalletStats{
+			TradeCount: uint64(record.TradeCount),
+			USDVolume:  record.USDVolume,
+		}
+		if stats.ETHVolume, err = ps.coreClient.FromWei(blockchain.ETHAddr, ethVolume); err != nil {
+			return nil, err
+		}
+		if stats.Fee, err = ps.coreClient.FromWei(ps.tokens.KNC, fee); err != nil {
+			return nil, err
+		}
+		result[timeutil.TimeToTimestampMs(record.Time.UTC())] = stats
+	}
+	return result, nil
+}
+
+// GetAssetVolume returns the volume of given token by hour or day, keyed by
+// timestamp in milliseconds. The trades from ETH to ETH and from WETH to WETH
+// are not counted.
+func (ps *PostgresStorage) GetAssetVolume(token core.Token, fromTime, toTime uint64, frequency string) (map[uint64]*common.VolumeStats, error) {
+	logger := ps.sugar.With(
+		"func", "tradelogs/storage/PostgresStorage.GetAssetVolume",
+		"token", token.Address,
+		"from", fromTime,
+		"to", toTime,
+	)
+
+	truncField, ok := freqToTruncField[strings.ToLower(frequency)]
+	if !ok {
+		return nil, fmt.Errorf("frequency %s is not supported", frequency)
+	}
+
+	var (
+		tokenAddr = ethereum.HexToAddress(token.Address)
+		from      = timeutil.TimestampMsToTime(fromTime).UTC()
+		to        = timeutil.TimestampMsToTime(toTime).UTC()
+		records   []struct {
+			Time        time.Time `db:"time"`
+			TokenVolume string    `db:"token_volume"`
+			ETHVolume   string    `db:"eth_volume"`
+			USDVolume   float64   `db:"usd_volume"`
+		}
+	)
+
+	if err := ps.db.Select(&records, fmt.Sprintf(`
+SELECT date_trunc('%[1]s', timestamp) AS time,
+       SUM(token_amount) AS token_volume,
+       SUM(eth_amount) AS eth_volume,
+       COALESCE(SUM(eth_amount * eth_usd_rate::NUMERIC) / 1e18, 0)::DOUBLE PRECISION AS usd_volume
+FROM (
+  SELECT timestamp, src_amount AS token_amount, eth_amount, eth_usd_rate FROM "%[2]s"
+  WHERE src_addr = $1 AND timestamp >= $2 AND timestamp <= $3
+    AND NOT ((src_addr = $4 OR dst_addr = $5) AND (src_addr = $5 OR dst_addr = $4))
+  UNION ALL
+  SELECT timestamp, dst_amount AS token_amount, eth_amount, eth_usd_rate FROM "%[2]s"
+  WHERE dst_addr = $1 AND timestamp >= $2 AND timestamp <= $3
+    AND NOT ((src_addr = $4 OR dst_addr = $5) AND (src_addr = $5 OR dst_addr = $4))
+) AS volumes
+GROUP BY 1
+`, truncField, tradeLogsTableName),
+		tokenAddr.Hex(), from, to, blockchain.ETHAddr.Hex(), ps.tokens.WETH.Hex()); err != nil {
+		return nil, err
+	}
+	logger.Debugw("got result for asset volume query", "records", len(records))
+
+	// all periods in time range are returned, filled with zero volume
+	result := make(map[uint64]*common.VolumeStats)
+	step := time.Hour
+	if truncField == "day" {
+		step = 24 * time.Hour
+	}
+	for ts := from.Truncate(step); !ts.After(to); ts = ts.Add(step) {
+		result[timeutil.TimeToTimestampMs(ts)] = &common.VolumeStats{}
+	}
+
+	for _, record := range records {
+		tokenVolume, err := parseNumeric(record.TokenVolume)
+		if err != nil {
+			return nil, err
+		}
+		ethVolume, err := parseNumeric(record.ETHVolume)
+		if err != nil {
+			return nil, err
+		}
+
+		stats := &common.VolumeStats{USDAmount: record.USDVolume}
+		if stats.Volume, err = ps.coreClient.FromWei(tokenAddr, tokenVolume); err != nil {
+			return nil, err
+		}
+		if stats.ETHAmount, err = ps.coreClient.FromWei(blockchain.ETHAddr, ethVolume); err != nil {
+			return nil, err
+		}
+		result[timeutil.TimeToTimestampMs(record.Time.UTC())] = stats
+	}
+	return result, nil
+}
+
+// GetReserveVolume returns the volume handled by given reserve by hour or day,
+// keyed by token address and timestamp in milliseconds. The reserves of a trade
+// are given by its burn fees in order: the first one for the source token and
+// the next one for the destination token, if the token is burnable. The trades
+// from ETH to ETH and from WETH to WETH are not counted.
+func (ps *PostgresStorage) GetReserveVolume(rsvAddr ethereum.Address, tokenAddrs []ethereum.Address, fromTime, toTime uint64, frequency string) (map[ethereum.Address]map[uint64]*common.VolumeStats, error) {
+	logger := ps.sugar.With(
+		"func", "tradelogs/storage/PostgresStorage.GetReserveVolume",
+		"reserve", rsvAddr.Hex(),
+		"tokens", tokenAddrs,
+		"from", fromTime,
+		"to", toTime,
+	)
+
+	truncField, ok := freqToTruncField[strings.ToLower(frequency)]
+	if !ok {
+		return nil, fmt.Errorf("frequency %s is not supported", frequency)
+	}
+
+	// an empty array instead of NULL to match all tokens
+	addrs := []string{}
+	for _, tokenAddr := range tokenAddrs {
+		addrs = append(addrs, tokenAddr.Hex())
+	}
+	var notBurnAddrs []string
+	for _, token := range ps.tokens.NotBurnTokens() {
+		notBurnAddrs = append(notBurnAddrs, token.Hex())
+	}
+
+	var (
+		from    = timeutil.TimestampMsToTime(fromTime).UTC()
+		to      = timeutil.TimestampMsToTime(toTime).UTC()
+		records []struct {
+			Time        time.Time `db:"time"`
+			TokenAddr   string    `db:"token_addr"`
+			TokenVolume string    `db:"token_volume"`
+			ETHVolume   string    `db:"eth_volume"`
+			USDVolume   float64   `db:"usd_volume"`
+		}
+	)
+
+	if err := ps.db.Select(&records, fmt.Sprintf(`
+SELECT date_trunc('%[1]s', timestamp) AS time,
+       token_addr,
+       SUM(token_amount) AS token_volume,
+       SUM(eth_amount) AS eth_volume,
+       COALESCE(SUM(eth_amount * eth_usd_rate::NUMERIC) / 1e18, 0)::DOUBLE PRECISION AS usd_volume
+FROM (
+  SELECT t.timestamp, t.src_addr AS token_addr, t.src_amount AS token_amount, t.eth_amount, t.eth_usd_rate
+  FROM "%[2]s" AS t JOIN "%[3]s" AS b ON b.trade_id = t.id AND b.ordinal = 0
+  WHERE b.reserve_addr = $1 AND t.timestamp >= $2 AND t.timestamp <= $3
+    AND t.src_addr <> ALL($4)
+    AND NOT ((t.src_addr = $6 OR t.dst_addr = $7) AND (t.src_addr = $7 OR t.dst_addr = $6))
+  UNION ALL
+  SELECT t.timestamp, t.dst_addr AS token_addr, t.dst_amount AS token_amount, t.eth_amount, t.eth_usd_rate
+  FROM "%[2]s" AS t JOIN "%[3]s" AS b ON b.trade_id = t.id
+    AND b.ordinal = CASE WHEN t.src_addr <> ALL($4) THEN 1 ELSE 0 END
+  WHERE b.reserve_addr = $1 AND t.timestamp >= $2 AND t.timestamp <= $3
+    AND t.dst_addr <> ALL($4)
+    AND NOT ((t.src_addr = $6 OR t.dst_addr = $7) AND (t.src_addr = $7 OR t.dst_addr = $6))
+) AS volumes
+WHERE cardinality($5::TEXT[]) = 0 OR token_addr = ANY($5)
+GROUP BY 1, 2
+`, truncField, tradeLogsTableName, burnFeesTableName),
+		rsvAddr.Hex(), from, to, pq.Array(notBurnAddrs), pq.Array(addrs),
+		blockchain.ETHAddr.Hex(), ps.tokens.WETH.Hex()); err != nil {
+		return nil, err
+	}
+	logger.Debugw("got result for reserve volume query", "records", len(records))
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	step := time.Hour
+	if truncField == "day" {
+		step = 24 * time.Hour
+	}
+	result := make(map[ethereum.Address]map[uint64]*common.VolumeStats)
+	for _, record := range records {
+		tokenAddr := ethereum.HexToAddress(record.TokenAddr)
+		// all periods in time range are returned for the traded tokens,
+		// filled with zero volume
+		if _, ok := result[tokenAddr]; !ok {
+			result[tokenAddr] = make(map[uint64]*common.VolumeStats)
+			for ts := from.Truncate(step); !ts.After(to); ts = ts.Add(step) {
+				result[tokenAddr][timeutil.TimeToTimestampMs(ts)] = &common.VolumeStats{}
+			}
+		}
+
+		tokenVolume, err := parseNumeric(record.TokenVolume)
+		if err != nil {
+			return nil, err
+		}
+		ethVolume, err := parseNumeric(record.ETHVolume)
+		if err != nil {
+			return nil, err
+		}
+
+		stats := &common.VolumeStats{USDAmount: record.USDVolume}
+		if stats.Volume, err = ps.coreClient.FromWei(tokenAddr, tokenVolume); err != nil {
+			return nil, err
+		}
+		if stats.ETHAmount, err = ps.coreClient.FromWei(blockchain.ETHAddr, ethVolume); err != nil {
+			return nil, err
+		}
+		result[tokenAddr][timeutil.TimeToTimestampMs(record.Time.UTC())] = stats
+	}
+	return result, nil
+}
+
+// SaveCrawledRange records that trade logs of given block range are crawled and stored.
+func (ps *PostgresStorage) SaveCrawledRange(r common.BlockRange, toBlockTime time.Time) error {
+	_, err := ps.db.Exec(fmt.Sprintf(`
+INSERT INTO "%s" (from_block, to_block, crawled_at, to_block_time) VALUES ($1, $2, $3, $4)
+`, crawledRangesTableName), int64(r.FromBlock), int64(r.ToBlock), time.Now().UTC(), toBlockTime.UTC())
+	return err
+}
+
+// LoadCrawledRanges returns the recorded crawled block ranges that overlap with
+// the given block range.
+func (ps *PostgresStorage) LoadCrawledRanges(fromBlock, toBlock uint64) ([]common.BlockRange, error) {
+	var records []struct {
+		FromBlock uint64 `db:"from_block"`
+		ToBlock   uint64 `db:"to_block"`
+	}
+	if err := ps.db.Select(&records, fmt.Sprintf(`
+SELECT from_block, to_block FROM "%s" WHERE to_block >= $1 AND from_block <= $2
+`, crawledRangesTableName), int64(fromBlock), int64(toBlock)); err != nil {
+		return nil, err
+	}
+
+	var ranges []common.BlockRange
+	for _, record := range records {
+		ranges = append(ranges, common.BlockRange{FromBlock: record.FromBlock, ToBlock: record.ToBlock})
+	}
+	return ranges, nil
+}
+
+// CountTrades returns the number of stored trades in given time range, inclusive.
+func (ps *PostgresStorage) CountTrades(from, to time.Time) (uint64, error) {
+	var count uint64
+	err := ps.db.Get(&count, fmt.Sprintf(`
+SELECT COUNT(1) FROM "%s" WHERE timestamp >= $1 AND timestamp <= $2
+`, tradeLogsTableName), from.UTC(), to.UTC())
+	return count, err
+}
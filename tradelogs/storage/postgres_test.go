@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
+	"github.com/KyberNetwork/reserve-stats/lib/core"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+const (
+	postgresHost     = "127.0.0.1"
+	postgresPort     = 5432
+	postgresUser     = "reserve_stats"
+	postgresPassword = "reserve_stats"
+	postgresDatabase = "reserve_stats"
+)
+
+func newTestPostgresStorage(sugar *zap.SugaredLogger) (*PostgresStorage, error) {
+	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		postgresHost,
+		postgresPort,
+		postgresUser,
+		postgresPassword,
+		postgresDatabase,
+	)
+	db, err := sqlx.Connect("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgresStorage(sugar, db, core.NewMockClient(), blockchain.MainnetTokenAddresses())
+}
+
+func TestPostgresStorage(t *testing.T) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugar := logger.Sugar()
+
+	ps, err := newTestPostgresStorage(sugar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		assert.Nil(t, ps.DeleteAllTables(), "database should be deleted completely")
+	}()
+
+	tradeLogs, err := getSampleTradeLogs("testdata/trade_logs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates, err := getSampleRates(tradeLogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// saving the same trade logs again replaces the stored ones
+	for i := 0; i < 2; i++ {
+		if err = ps.SaveTradeLogs(tradeLogs, rates); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		from = tradeLogs[0].Timestamp
+		to   = tradeLogs[len(tradeLogs)-1].Timestamp
+	)
+	loaded, err := ps.LoadTradeLogs(from, to)
+	assert.NoError(t, err)
+	assert.Len(t, loaded, len(tradeLogs))
+	for i, tradeLog := range loaded {
+		assert.Equal(t, tradeLogs[i].TransactionHash, tradeLog.TransactionHash)
+		assert.True(t, tradeLogs[i].Timestamp.Equal(tradeLog.Timestamp))
+		// amounts are stored without loss
+		assert.Equal(t, tradeLogs[i].SrcAmount.String(), tradeLog.SrcAmount.String())
+		assert.Equal(t, tradeLogs[i].DestAmount.String(), tradeLog.DestAmount.String())
+		assert.Equal(t, len(tradeLogs[i].BurnFees), len(tradeLog.BurnFees))
+		assert.Equal(t, len(tradeLogs[i].WalletFees), len(tradeLog.WalletFees))
+	}
+
+	count, err := ps.CountTrades(from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(len(tradeLogs)), count)
+
+	burnFees, err := ps.GetAggregatedBurnFee(from, to, "h", nil)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, burnFees)
+
+	assert.NoError(t, ps.SaveCrawledRange(common.BlockRange{FromBlock: 100, ToBlock: 199}, time.Now()))
+	ranges, err := ps.LoadCrawledRanges(150, 300)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.BlockRange{{FromBlock: 100, ToBlock: 199}}, ranges)
+
+	assert.NoError(t, ps.DeleteTradeLogsAfter(from))
+	count, err = ps.CountTrades(from, to.Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+}
@@ -26,6 +26,10 @@ var (
 		"h": "volume_hour",
 		"d": "volume_day",
 	}
+	reserveMeasurementName = map[string]string{
+		"h": "rsv_volume_hour",
+		"d": "rsv_volume_day",
+	}
 )
 
 // GetAssetVolume returns the volume of a specific assset(token) between a period and with desired frequency
@@ -64,6 +68,90 @@ func (is *InfluxStorage) GetAssetVolume(token core.Token, fromTime, toTime uint6
 	return convertQueryResultToVolume(response[0].Series[0])
 }
 
+// GetReserveVolume returns the volume handled by given reserve between a period and with desired frequency,
+// keyed by token address. The volume of a token traded by the reserve in both sides are summed.
+func (is *InfluxStorage) GetReserveVolume(rsvAddr ethereum.Address, tokenAddrs []ethereum.Address, fromTime, toTime uint64, frequency string) (map[ethereum.Address]map[uint64]*common.VolumeStats, error) {
+	var (
+		logger = is.sugar.With(
+			"func", "tradelogs/storage/InfluxStorage.GetReserveVolume",
+			"reserve", rsvAddr.Hex(),
+			"tokens", tokenAddrs,
+			"from", fromTime,
+			"to", toTime,
+		)
+	)
+	mName, ok := reserveMeasurementName[strings.ToLower(frequency)]
+	if !ok {
+		return nil, fmt.Errorf("frequency %s is not supported", frequency)
+	}
+
+	// the reserve of a trade side and the token of that side are tagged by
+	// src_rsv_addr and src_addr or by dst_rsv_addr and dst_addr
+	var sideConds []string
+	for _, side := range []string{"src", "dst"} {
+		cond := fmt.Sprintf("%s_rsv_addr='%s'", side, rsvAddr.Hex())
+		if len(tokenAddrs) != 0 {
+			var tokenConds []string
+			for _, tokenAddr := range tokenAddrs {
+				tokenConds = append(tokenConds, fmt.Sprintf("%s_addr='%s'", side, tokenAddr.Hex()))
+			}
+			cond += fmt.Sprintf(" AND (%s)", strings.Join(tokenConds, " OR "))
+		}
+		sideConds = append(sideConds, fmt.Sprintf("(%s)", cond))
+	}
+	var (
+		timeFilter = fmt.Sprintf("(time >=%d%s AND time <= %d%s)", fromTime, timePrecision, toTime, timePrecision)
+		cmd        = fmt.Sprintf("SELECT SUM(token_volume) as %s, SUM(eth_volume) as %s, sum(usd_volume) as %s FROM %s WHERE %s AND (%s) GROUP BY src_rsv_addr, dst_rsv_addr, src_addr, dst_addr, time(1%s) fill(0)",
+			tokenVolumeField, ethVolumeField, fiatVolumeField, mName, timeFilter, strings.Join(sideConds, " OR "), frequency)
+	)
+
+	logger.Debugw("get reserve volume query rendered", "query", cmd)
+	response, err := is.queryDB(is.influxClient, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response) == 0 || len(response[0].Series) == 0 {
+		return nil, nil
+	}
+
+	// the volume of each token is grouped by either src_addr or dst_addr tag,
+	// depends on the side of trades that the reserve handled
+	result := make(map[ethereum.Address]map[uint64]*common.VolumeStats)
+	for _, row := range response[0].Series {
+		var tokenStr string
+		switch rsvAddr.Hex() {
+		case row.Tags["src_rsv_addr"]:
+			tokenStr = row.Tags["src_addr"]
+		case row.Tags["dst_rsv_addr"]:
+			tokenStr = row.Tags["dst_addr"]
+		default:
+			logger.Warnw("unexpected series of other reserve", "tags", row.Tags)
+			continue
+		}
+		volumes, err := convertQueryResultToVolume(row)
+		if err != nil {
+			return nil, err
+		}
+
+		tokenAddr := ethereum.HexToAddress(tokenStr)
+		if _, ok := result[tokenAddr]; !ok {
+			result[tokenAddr] = make(map[uint64]*common.VolumeStats)
+		}
+		for ts, vol := range volumes {
+			stats, ok := result[tokenAddr][ts]
+			if !ok {
+				result[tokenAddr][ts] = vol
+				continue
+			}
+			stats.Volume += vol.Volume
+			stats.ETHAmount += vol.ETHAmount
+			stats.USDAmount += vol.USDAmount
+		}
+	}
+	return result, nil
+}
+
 func convertQueryResultToVolume(row influxModel.Row) (map[uint64]*common.VolumeStats, error) {
 	result := make(map[uint64]*common.VolumeStats)
 	if len(row.Values) == 0 {
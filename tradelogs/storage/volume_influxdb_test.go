@@ -6,10 +6,14 @@ import (
 	"testing"
 	"time"
 
+	ethereum "github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
 	"github.com/KyberNetwork/reserve-stats/lib/core"
 	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
+	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
 )
 
 func doInfluxHTTPReq(client http.Client, cmd, endpoint, db string) error {
@@ -31,29 +35,6 @@ func doInfluxHTTPReq(client http.Client, cmd, endpoint, db string) error {
 	return nil
 }
 
-func aggregationTestData(is *InfluxStorage) error {
-	const (
-		DstAmountHourCmd = "SELECT SUM(dst_amount) AS token_volume, SUM(eth_amount) AS eth_volume, SUM(usd_amount) AS usd_volume INTO volume_hour FROM (SELECT dst_amount, eth_amount, eth_amount*eth_usd_rate AS usd_amount FROM trades WHERE (src_addr!='0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE' AND dst_addr!='0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2') OR (src_addr!='0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2' AND dst_addr!='0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE')) GROUP BY \"dst_addr\", time(1h)"
-		DstAmountDayCmd  = "SELECT SUM(token_volume) as token_volume, SUM(eth_volume) as eth_volume, SUM(usd_volume) AS usd_volume INTO volume_day FROM volume_hour WHERE dst_addr!='' GROUP BY \"dst_addr\", time(1d)"
-		SrcAmountHourCmd = "SELECT SUM(src_amount) AS token_volume, SUM(eth_amount) AS eth_volume, SUM(usd_amount) AS usd_volume INTO volume_hour FROM (SELECT src_amount, eth_amount, eth_amount*eth_usd_rate AS usd_amount FROM trades WHERE (src_addr!='0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE' AND dst_addr!='0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2') OR (src_addr!='0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2' AND dst_addr!='0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE')) GROUP BY \"src_addr\", time(1h)"
-		SrcAmountDayCmd  = "SELECT SUM(token_volume) as token_volume, SUM(eth_volume) as eth_volume, SUM(usd_volume) AS usd_volume INTO volume_day FROM volume_hour WHERE src_addr!=''GROUP BY \"src_addr\", time(1d)"
-		endpoint         = "http://127.0.0.1:8086/"
-	)
-	if _, err := is.queryDB(is.influxClient, DstAmountHourCmd); err != nil {
-		return err
-	}
-	if _, err := is.queryDB(is.influxClient, DstAmountDayCmd); err != nil {
-		return err
-	}
-	if _, err := is.queryDB(is.influxClient, SrcAmountHourCmd); err != nil {
-		return err
-	}
-	if _, err := is.queryDB(is.influxClient, SrcAmountDayCmd); err != nil {
-		return err
-	}
-	return nil
-}
-
 func TestGetAssetVolume(t *testing.T) {
 	const (
 		dbName = "test_volume"
@@ -65,14 +46,13 @@ func TestGetAssetVolume(t *testing.T) {
 		timeStamp = "2018-10-11T09:00:00Z"
 	)
 
-	is, err := newTestInfluxStorage(dbName)
-	assert.NoError(t, err)
+	is := newTestInfluxStorage(t, dbName, core.NewMockClient())
 
 	defer func() {
 		assert.NoError(t, is.tearDown())
 	}()
 	assert.NoError(t, loadTestData(dbName))
-	assert.NoError(t, aggregationTestData(is))
+	assert.NoError(t, is.Aggregate(timeutil.TimestampMsToTime(fromTime), timeutil.TimestampMsToTime(toTime)))
 	volume, err := is.GetAssetVolume(core.ETHToken, fromTime, toTime, freq)
 	assert.NoError(t, err)
 
@@ -90,3 +70,61 @@ func TestGetAssetVolume(t *testing.T) {
 		t.Fatal(fmt.Errorf("Expect USD amount to be %.18f, got %.18f", ethAmount, result.USDAmount))
 	}
 }
+
+func TestGetReserveVolume(t *testing.T) {
+	is := newTestInfluxStorage(t, "test_reserve_volume", weiCoreClient{MockClient: core.NewMockClient()})
+	defer func() {
+		assert.NoError(t, is.tearDown())
+	}()
+
+	var (
+		ts       = time.Date(2018, 10, 8, 12, 30, 0, 0, time.UTC)
+		reserve1 = ethereum.HexToAddress("0x63825c174ab367968EC60f061753D3bbD36A0D8F")
+		reserve2 = ethereum.HexToAddress("0x21433Dec9Cb634A23c6A4BbcCe08c83f5aC2EC18")
+		from     = timeutil.TimeToTimestampMs(ts.Truncate(time.Hour))
+		to       = timeutil.TimeToTimestampMs(ts)
+	)
+	tradeLogs := []common.TradeLog{
+		// token to ETH trade, reserve1 handled the source side
+		{
+			Timestamp:           ts,
+			TransactionHash:     ethereum.HexToHash("0x01"),
+			EtherReceivalAmount: ether(2),
+			SrcAddress:          blockchain.KNCAddr,
+			DestAddress:         blockchain.ETHAddr,
+			SrcAmount:           ether(600),
+			DestAmount:          ether(2),
+			BurnFees:            []common.BurnFee{{ReserveAddress: reserve1, Amount: ether(1)}},
+		},
+		// ETH to token trade, reserve2 handled the destination side
+		{
+			Timestamp:           ts,
+			TransactionHash:     ethereum.HexToHash("0x02"),
+			EtherReceivalAmount: ether(1),
+			SrcAddress:          blockchain.ETHAddr,
+			DestAddress:         blockchain.KNCAddr,
+			SrcAmount:           ether(1),
+			DestAmount:          ether(300),
+			BurnFees:            []common.BurnFee{{ReserveAddress: reserve2, Amount: ether(1)}},
+		},
+	}
+	assert.NoError(t, is.SaveTradeLogs(tradeLogs, []tokenrate.ETHUSDRate{{Rate: 200}, {Rate: 200}}))
+	assert.NoError(t, is.Aggregate(ts, ts))
+
+	volumes, err := is.GetReserveVolume(reserve1, nil, from, to, "h")
+	assert.NoError(t, err)
+	assert.Equal(t, map[ethereum.Address]map[uint64]*common.VolumeStats{
+		blockchain.KNCAddr: {from: {Volume: 600, ETHAmount: 2, USDAmount: 400}},
+	}, volumes)
+
+	volumes, err = is.GetReserveVolume(reserve2, nil, from, to, "h")
+	assert.NoError(t, err)
+	assert.Equal(t, map[ethereum.Address]map[uint64]*common.VolumeStats{
+		blockchain.KNCAddr: {from: {Volume: 300, ETHAmount: 1, USDAmount: 200}},
+	}, volumes)
+
+	// the ETH side of trades is not handled by reserve
+	volumes, err = is.GetReserveVolume(reserve1, []ethereum.Address{blockchain.ETHAddr}, from, to, "h")
+	assert.NoError(t, err)
+	assert.Nil(t, volumes)
+}
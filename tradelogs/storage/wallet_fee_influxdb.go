@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/influxdata/influxdb/client/v2"
+
+	"github.com/KyberNetwork/reserve-stats/lib/influxdb"
+	"github.com/KyberNetwork/reserve-stats/lib/timeutil"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+var (
+	freqToWalletFeeMeasurement = map[string]string{
+		"h": "hourly_wallet_fees",
+		"d": "daily_wallet_fees",
+	}
+	freqToWalletStatsMeasurement = map[string]string{
+		"h": "hourly_wallet_stats",
+		"d": "daily_wallet_stats",
+	}
+)
+
+// GetAggregatedWalletFee get aggregated wallet fee of a wallet in a time range given the reserve address
+func (is *InfluxStorage) GetAggregatedWalletFee(from, to time.Time, freq string, walletAddr ethereum.Address, reserveAddrs []ethereum.Address) (map[ethereum.Address]map[string]float64, error) {
+	logger := is.sugar.With(
+		"func", "tradelogs/storage/InfluxStorage.GetAggregatedWalletFee",
+		"from", from,
+		"to", to,
+		"freq", freq,
+		"wallet_addr", walletAddr.Hex(),
+		"reserve_addrs", reserveAddrs,
+	)
+
+	measurement, ok := freqToWalletFeeMeasurement[strings.ToLower(freq)]
+	if !ok {
+		return nil, fmt.Errorf("invalid wallet fee frequency %s", freq)
+	}
+
+	cmd := fmt.Sprintf(`SELECT sum_amount, reserve_addr FROM "%s" WHERE '%s' <= time AND time <= '%s' AND "wallet_addr" = '%s'`,
+		measurement, from.Format(time.RFC3339), to.Format(time.RFC3339), walletAddr.Hex())
+	var reserveConds []string
+	for _, rsvAddr := range reserveAddrs {
+		reserveConds = append(reserveConds, fmt.Sprintf(`"reserve_addr" = '%s'`, rsvAddr.Hex()))
+	}
+	if len(reserveConds) != 0 {
+		cmd += fmt.Sprintf(" AND (%s)", strings.Join(reserveConds, " OR "))
+	}
+
+	logger.Debugw("rendered query statement", "query", cmd)
+	res, err := is.queryDB(is.influxClient, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res) == 0 || len(res[0].Series) == 0 {
+		logger.Debug("empty aggregated wallet fee result")
+		return nil, nil
+	}
+
+	result := make(map[ethereum.Address]map[string]float64)
+	for _, row := range res[0].Series[0].Values {
+		// the aggregated wallet fees have the same columns as burn fees
+		ts, amount, reserve, err := is.rowToAggregatedBurnFee(row)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := result[reserve]; !ok {
+			result[reserve] = make(map[string]float64)
+		}
+		result[reserve][strconv.FormatUint(timeutil.TimeToTimestampMs(ts), 10)] = amount
+	}
+	return result, nil
+}
+
+// GetWalletStats returns the trade count, volume and earned fees of the trades
+// routed through given wallet in a time range, by hour or day.
+func (is *InfluxStorage) GetWalletStats(from, to time.Time, freq string, walletAddr ethereum.Address) (map[uint64]*common.WalletStats, error) {
+	logger := is.sugar.With(
+		"func", "tradelogs/storage/InfluxStorage.GetWalletStats",
+		"from", from,
+		"to", to,
+		"freq", freq,
+		"wallet_addr", walletAddr.Hex(),
+	)
+
+	statsMeasurement, ok := freqToWalletStatsMeasurement[strings.ToLower(freq)]
+	if !ok {
+		return nil, fmt.Errorf("invalid wallet stats frequency %s", freq)
+	}
+	feeMeasurement := freqToWalletFeeMeasurement[strings.ToLower(freq)]
+
+	var (
+		cond = fmt.Sprintf(`'%s' <= time AND time <= '%s' AND "wallet_addr" = '%s'`,
+			from.Format(time.RFC3339), to.Format(time.RFC3339), walletAddr.Hex())
+		cmd = fmt.Sprintf(`SELECT trade_count, eth_volume, usd_volume FROM "%s" WHERE %s; `, statsMeasurement, cond) +
+			fmt.Sprintf(`SELECT SUM(sum_amount) AS fee FROM "%s" WHERE %s GROUP BY time(1%s) fill(none)`, feeMeasurement, cond, strings.ToLower(freq))
+	)
+
+	logger.Debugw("rendered query statement", "query", cmd)
+	res, err := is.queryDB(is.influxClient, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint64]*common.WalletStats)
+	getStats := func(tsValue interface{}) (*common.WalletStats, error) {
+		ts, err := influxdb.GetTimeFromInterface(tsValue)
+		if err != nil {
+			return nil, err
+		}
+		key := timeutil.TimeToTimestampMs(ts)
+		if _, ok := result[key]; !ok {
+			result[key] = &common.WalletStats{}
+		}
+		return result[key], nil
+	}
+
+	if len(res) > 0 && len(res[0].Series) > 0 {
+		for _, row := range res[0].Series[0].Values {
+			stats, err := getStats(row[0])
+			if err != nil {
+				return nil, err
+			}
+			tradeCount, err := influxdb.GetInt64FromInterface(row[1])
+			if err != nil {
+				return nil, err
+			}
+			stats.TradeCount = uint64(tradeCount)
+			if stats.ETHVolume, err = influxdb.GetFloat64FromInterface(row[2]); err != nil {
+				return nil, err
+			}
+			if stats.USDVolume, err = influxdb.GetFloat64FromInterface(row[3]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(res) > 1 && len(res[1].Series) > 0 {
+		for _, row := range res[1].Series[0].Values {
+			stats, err := getStats(row[0])
+			if err != nil {
+				return nil, err
+			}
+			if stats.Fee, err = influxdb.GetFloat64FromInterface(row[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		logger.Debug("empty wallet stats result")
+		return nil, nil
+	}
+	return result, nil
+}
+
+// backfillWalletFeeFields adds the eth_amount and eth_usd_rate fields of the
+// trades to the wallet fees in given time range, from inclusive and to
+// exclusive, that are stored before these fields are introduced. The wallet
+// fees are written again with only these fields, which are merged with their
+// existing fields.
+func (is *InfluxStorage) backfillWalletFeeFields(from, to time.Time) error {
+	logger := is.sugar.With(
+		"func", "tradelogs/storage/InfluxStorage.backfillWalletFeeFields",
+		"from", from,
+		"to", to,
+	)
+
+	timeCond := fmt.Sprintf("time >= '%s' AND time < '%s'", from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	res, err := is.queryDB(is.influxClient, fmt.Sprintf(
+		`SELECT "amount", "eth_amount" FROM "wallet_fees" WHERE %[1]s GROUP BY *; `+
+			`SELECT "eth_amount", "eth_usd_rate" FROM "trades" WHERE %[1]s GROUP BY "tx_hash", "log_index"`, timeCond))
+	if err != nil {
+		return err
+	}
+	if len(res) < 2 || len(res[0].Series) == 0 {
+		return nil
+	}
+
+	type tradeKey struct {
+		txHash   string
+		logIndex string
+	}
+	trades := make(map[tradeKey][]interface{})
+	for _, series := range res[1].Series {
+		if len(series.Values) == 0 {
+			continue
+		}
+		// columns: time, eth_amount, eth_usd_rate
+		trades[tradeKey{txHash: series.Tags["tx_hash"], logIndex: series.Tags["log_index"]}] = series.Values[0][1:]
+	}
+
+	bp, err := client.NewBatchPoints(client.BatchPointsConfig{Database: is.dbName})
+	if err != nil {
+		return err
+	}
+	for _, series := range res[0].Series {
+		trade, ok := trades[tradeKey{txHash: series.Tags["tx_hash"], logIndex: series.Tags["log_index"]}]
+		if !ok {
+			continue
+		}
+		for _, row := range series.Values {
+			// columns: time, amount, eth_amount
+			if row[2] != nil {
+				continue
+			}
+			ts, err := influxdb.GetTimeFromInterface(row[0])
+			if err != nil {
+				return err
+			}
+			ethAmount, err := influxdb.GetFloat64FromInterface(trade[0])
+			if err != nil {
+				return err
+			}
+			fields := map[string]interface{}{"eth_amount": ethAmount}
+			// the trades stored without ETH/USD rate do not have the field
+			if trade[1] != nil {
+				ethUSDRate, err := influxdb.GetFloat64FromInterface(trade[1])
+				if err != nil {
+					return err
+				}
+				fields["eth_usd_rate"] = ethUSDRate
+			}
+			pt, err := client.NewPoint("wallet_fees", series.Tags, fields, ts)
+			if err != nil {
+				return err
+			}
+			bp.AddPoint(pt)
+		}
+	}
+	if len(bp.Points()) == 0 {
+		return nil
+	}
+
+	logger.Infow("adding trade fields to wallet fees", "wallet_fees", len(bp.Points()))
+	return is.influxClient.Write(bp)
+}
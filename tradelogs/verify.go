@@ -0,0 +1,345 @@
+package tradelogs
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+	"github.com/KyberNetwork/reserve-stats/tradelogs/storage"
+)
+
+// DefaultAmountTolerance is the default maximum relative difference between
+// an amount on chain and the stored amount, as amounts are stored as floats.
+const DefaultAmountTolerance = 1e-9
+
+// TradeRef identifies a trade log.
+type TradeRef struct {
+	Timestamp       time.Time     `json:"timestamp"`
+	BlockNumber     uint64        `json:"block_number"`
+	TransactionHash ethereum.Hash `json:"tx_hash"`
+	LogIndex        uint          `json:"log_index"`
+}
+
+func newTradeRef(tradeLog common.TradeLog) TradeRef {
+	return TradeRef{
+		Timestamp:       tradeLog.Timestamp,
+		BlockNumber:     tradeLog.BlockNumber,
+		TransactionHash: tradeLog.TransactionHash,
+		LogIndex:        tradeLog.LogIndex,
+	}
+}
+
+// FieldMismatch is a field of trade log which stored value is different from
+// the value decoded from chain.
+type FieldMismatch struct {
+	Field  string `json:"field"`
+	Chain  string `json:"chain"`
+	Stored string `json:"stored"`
+}
+
+// TradeMismatch is a stored trade log which fields are different from the
+// trade log decoded from chain.
+type TradeMismatch struct {
+	TradeRef
+	Fields []FieldMismatch `json:"fields"`
+}
+
+// VerifyReport is the result of comparing trade logs decoded from chain with
+// stored trade logs of a block range.
+type VerifyReport struct {
+	common.BlockRange
+	// CheckedRanges are the windows of the block range that are compared,
+	// all windows are checked in a full scan.
+	CheckedRanges []common.BlockRange `json:"checked_ranges"`
+	ChainTrades   uint64              `json:"chain_trades"`
+	StoredTrades  uint64              `json:"stored_trades"`
+	Mismatches    []TradeMismatch     `json:"mismatches"`
+	// Missing are the trades on chain which are not stored.
+	Missing []TradeRef `json:"missing"`
+	// Extra are the stored trades which are not on chain.
+	Extra []TradeRef `json:"extra"`
+}
+
+// Verifier compares the trade logs decoded from chain with the stored trade
+// logs field by field.
+type Verifier struct {
+	sugar     *zap.SugaredLogger
+	crawler   *TradeLogCrawler
+	storage   storage.Interface
+	timeout   time.Duration
+	tolerance float64
+}
+
+// NewVerifier creates a new Verifier instance. The amounts are considered
+// equal if their relative difference is at most given tolerance.
+func NewVerifier(sugar *zap.SugaredLogger, crawler *TradeLogCrawler, st storage.Interface, timeout time.Duration, tolerance float64) *Verifier {
+	return &Verifier{
+		sugar:     sugar,
+		crawler:   crawler,
+		storage:   st,
+		timeout:   timeout,
+		tolerance: tolerance,
+	}
+}
+
+// sampleWindows splits the block range from fromBlock to toBlock, inclusive,
+// to windows of given size. If samples is not zero and less than the number
+// of windows, only the given number of evenly spread windows are returned.
+func sampleWindows(fromBlock, toBlock, window uint64, samples int) []common.BlockRange {
+	var windows []common.BlockRange
+	for from := fromBlock; from <= toBlock; from += window {
+		to := from + window - 1
+		if to > toBlock || to < from {
+			to = toBlock
+		}
+		windows = append(windows, common.BlockRange{FromBlock: from, ToBlock: to})
+		if to == toBlock {
+			break
+		}
+	}
+
+	if samples <= 0 || samples >= len(windows) {
+		return windows
+	}
+	sampled := make([]common.BlockRange, samples)
+	for i := range sampled {
+		sampled[i] = windows[i*len(windows)/samples]
+	}
+	return sampled
+}
+
+// Verify compares the trade logs from fromBlock to toBlock, inclusive, in
+// windows of given number of blocks. If samples is not zero, only the given
+// number of windows are compared instead of scanning the whole range.
+func (v *Verifier) Verify(fromBlock, toBlock, window uint64, samples int) (VerifyReport, error) {
+	logger := v.sugar.With(
+		"func", "tradelogs/Verifier.Verify",
+		"from_block", fromBlock,
+		"to_block", toBlock,
+	)
+
+	report := VerifyReport{BlockRange: common.BlockRange{FromBlock: fromBlock, ToBlock: toBlock}}
+	if window == 0 {
+		return report, fmt.Errorf("invalid window: %d", window)
+	}
+
+	for _, w := range sampleWindows(fromBlock, toBlock, window, samples) {
+		chainLogs, storedLogs, err := v.load(w.FromBlock, w.ToBlock)
+		if err != nil {
+			return report, err
+		}
+
+		mismatches, missing, extra := compareTradeLogs(chainLogs, storedLogs, v.tolerance)
+		logger.Infow("verified window",
+			"window_from_block", w.FromBlock,
+			"window_to_block", w.ToBlock,
+			"chain_trades", len(chainLogs),
+			"stored_trades", len(storedLogs),
+			"mismatches", len(mismatches),
+			"missing", len(missing),
+			"extra", len(extra))
+
+		report.CheckedRanges = append(report.CheckedRanges, w)
+		report.ChainTrades += uint64(len(chainLogs))
+		report.StoredTrades += uint64(len(storedLogs))
+		report.Mismatches = append(report.Mismatches, mismatches...)
+		report.Missing = append(report.Missing, missing...)
+		report.Extra = append(report.Extra, extra...)
+	}
+	return report, nil
+}
+
+// load returns the trade logs decoded from chain and the stored trade logs
+// of given block range.
+func (v *Verifier) load(fromBlock, toBlock uint64) ([]common.TradeLog, []common.TradeLog, error) {
+	var chainLogs []common.TradeLog
+	if query, ok := v.crawler.FilterQuery(fromBlock, toBlock); ok {
+		logs, err := v.crawler.logFetcher.fetch(query, fromBlock, toBlock, v.timeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		if chainLogs, err = v.crawler.decodeTradeLogs(logs, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	times, err := v.crawler.txTime.ResolveMany([]uint64{fromBlock, toBlock})
+	if err != nil {
+		return nil, nil, err
+	}
+	loaded, err := v.storage.LoadTradeLogs(times[fromBlock], times[toBlock])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the blocks at the edges of time range may be out of block range if
+	// they have the same timestamp
+	var storedLogs []common.TradeLog
+	for _, tradeLog := range loaded {
+		if tradeLog.BlockNumber >= fromBlock && tradeLog.BlockNumber <= toBlock {
+			storedLogs = append(storedLogs, tradeLog)
+		}
+	}
+	return chainLogs, storedLogs, nil
+}
+
+type tradeKey struct {
+	txHash   ethereum.Hash
+	logIndex uint
+}
+
+// compareTradeLogs matches the trade logs decoded from chain with the stored
+// trade logs by transaction hash and log index, returning the mismatched,
+// missing and extra trades.
+func compareTradeLogs(chainLogs, storedLogs []common.TradeLog, tolerance float64) ([]TradeMismatch, []TradeRef, []TradeRef) {
+	var (
+		mismatches []TradeMismatch
+		missing    []TradeRef
+		extra      []TradeRef
+		stored     = make(map[tradeKey]common.TradeLog, len(storedLogs))
+	)
+
+	for _, tradeLog := range storedLogs {
+		stored[tradeKey{txHash: tradeLog.TransactionHash, logIndex: tradeLog.LogIndex}] = tradeLog
+	}
+
+	for _, chainLog := range chainLogs {
+		key := tradeKey{txHash: chainLog.TransactionHash, logIndex: chainLog.LogIndex}
+		storedLog, ok := stored[key]
+		if !ok {
+			missing = append(missing, newTradeRef(chainLog))
+			continue
+		}
+		delete(stored, key)
+
+		if fields := compareTradeLog(chainLog, storedLog, tolerance); len(fields) != 0 {
+			mismatches = append(mismatches, TradeMismatch{TradeRef: newTradeRef(chainLog), Fields: fields})
+		}
+	}
+
+	for _, storedLog := range storedLogs {
+		if _, ok := stored[tradeKey{txHash: storedLog.TransactionHash, logIndex: storedLog.LogIndex}]; ok {
+			extra = append(extra, newTradeRef(storedLog))
+		}
+	}
+	return mismatches, missing, extra
+}
+
+// fieldComparer collects the mismatched fields of a trade log.
+type fieldComparer struct {
+	tolerance float64
+	fields    []FieldMismatch
+}
+
+func (fc *fieldComparer) add(field string, chain, stored interface{}) {
+	fc.fields = append(fc.fields, FieldMismatch{
+		Field:  field,
+		Chain:  fmt.Sprint(chain),
+		Stored: fmt.Sprint(stored),
+	})
+}
+
+func (fc *fieldComparer) uint64(field string, chain, stored uint64) {
+	if chain != stored {
+		fc.add(field, chain, stored)
+	}
+}
+
+func (fc *fieldComparer) address(field string, chain, stored ethereum.Address) {
+	if chain != stored {
+		fc.add(field, chain.Hex(), stored.Hex())
+	}
+}
+
+func (fc *fieldComparer) time(field string, chain, stored time.Time) {
+	if !chain.Equal(stored) {
+		fc.add(field, chain.UTC().Format(time.RFC3339), stored.UTC().Format(time.RFC3339))
+	}
+}
+
+// amount compares the amounts in wei, a nil amount is zero.
+func (fc *fieldComparer) amount(field string, chain, stored *big.Int) {
+	if chain == nil {
+		chain = big.NewInt(0)
+	}
+	if stored == nil {
+		stored = big.NewInt(0)
+	}
+	if chain.Cmp(stored) == 0 {
+		return
+	}
+
+	var (
+		diff    = new(big.Float).SetInt(new(big.Int).Abs(new(big.Int).Sub(chain, stored)))
+		largest = new(big.Float).SetInt(new(big.Int).Abs(chain))
+	)
+	if s := new(big.Float).SetInt(new(big.Int).Abs(stored)); s.Cmp(largest) > 0 {
+		largest = s
+	}
+	if diff.Cmp(new(big.Float).Mul(largest, big.NewFloat(fc.tolerance))) > 0 {
+		fc.add(field, chain.String(), stored.String())
+	}
+}
+
+// compareTradeLog returns the fields of stored trade log which are different
+// from the trade log decoded from chain. The fields added by enrichers are not
+// compared.
+func compareTradeLog(chainLog, storedLog common.TradeLog, tolerance float64) []FieldMismatch {
+	fc := &fieldComparer{tolerance: tolerance}
+
+	fc.time("timestamp", chainLog.Timestamp, storedLog.Timestamp)
+	fc.uint64("block_number", chainLog.BlockNumber, storedLog.BlockNumber)
+	fc.address("eth_receival_sender", chainLog.EtherReceivalSender, storedLog.EtherReceivalSender)
+	fc.amount("eth_receival_amount", chainLog.EtherReceivalAmount, storedLog.EtherReceivalAmount)
+	fc.address("user_addr", chainLog.UserAddress, storedLog.UserAddress)
+	fc.address("src_addr", chainLog.SrcAddress, storedLog.SrcAddress)
+	fc.address("dst_addr", chainLog.DestAddress, storedLog.DestAddress)
+	fc.amount("src_amount", chainLog.SrcAmount, storedLog.SrcAmount)
+	fc.amount("dst_amount", chainLog.DestAmount, storedLog.DestAmount)
+
+	// the order of stored fees is not preserved, the fees are compared in
+	// order of reserve address
+	chainBurnFees, storedBurnFees := sortedBurnFees(chainLog.BurnFees), sortedBurnFees(storedLog.BurnFees)
+	fc.uint64("burn_fees", uint64(len(chainBurnFees)), uint64(len(storedBurnFees)))
+	for i := 0; i < len(chainBurnFees) && i < len(storedBurnFees); i++ {
+		field := fmt.Sprintf("burn_fees[%d]", i)
+		fc.address(field+".reserve_addr", chainBurnFees[i].ReserveAddress, storedBurnFees[i].ReserveAddress)
+		fc.amount(field+".amount", chainBurnFees[i].Amount, storedBurnFees[i].Amount)
+	}
+
+	chainWalletFees, storedWalletFees := sortedWalletFees(chainLog.WalletFees), sortedWalletFees(storedLog.WalletFees)
+	fc.uint64("wallet_fees", uint64(len(chainWalletFees)), uint64(len(storedWalletFees)))
+	for i := 0; i < len(chainWalletFees) && i < len(storedWalletFees); i++ {
+		field := fmt.Sprintf("wallet_fees[%d]", i)
+		fc.address(field+".reserve_addr", chainWalletFees[i].ReserveAddress, storedWalletFees[i].ReserveAddress)
+		fc.address(field+".wallet_addr", chainWalletFees[i].WalletAddress, storedWalletFees[i].WalletAddress)
+		fc.amount(field+".amount", chainWalletFees[i].Amount, storedWalletFees[i].Amount)
+	}
+	return fc.fields
+}
+
+func sortedBurnFees(fees []common.BurnFee) []common.BurnFee {
+	sorted := make([]common.BurnFee, len(fees))
+	copy(sorted, fees)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ReserveAddress.Hex() < sorted[j].ReserveAddress.Hex()
+	})
+	return sorted
+}
+
+func sortedWalletFees(fees []common.WalletFee) []common.WalletFee {
+	sorted := make([]common.WalletFee, len(fees))
+	copy(sorted, fees)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].ReserveAddress != sorted[j].ReserveAddress {
+			return sorted[i].ReserveAddress.Hex() < sorted[j].ReserveAddress.Hex()
+		}
+		return sorted[i].WalletAddress.Hex() < sorted[j].WalletAddress.Hex()
+	})
+	return sorted
+}
@@ -0,0 +1,91 @@
+package tradelogs
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
+)
+
+func TestSampleWindows(t *testing.T) {
+	assert.Equal(t, []common.BlockRange{
+		{FromBlock: 100, ToBlock: 199},
+		{FromBlock: 200, ToBlock: 299},
+		{FromBlock: 300, ToBlock: 349},
+	}, sampleWindows(100, 349, 100, 0))
+
+	assert.Equal(t, []common.BlockRange{
+		{FromBlock: 100, ToBlock: 109},
+		{FromBlock: 150, ToBlock: 159},
+	}, sampleWindows(100, 199, 10, 2))
+
+	// more samples than windows scans the whole range
+	assert.Len(t, sampleWindows(100, 199, 10, 20), 10)
+}
+
+func TestCompareTradeLogs(t *testing.T) {
+	var (
+		ts       = time.Unix(1539000000, 0).UTC()
+		reserve1 = ethereum.HexToAddress("0x63825c174ab367968EC60f061753D3bbD36A0D8F")
+		reserve2 = ethereum.HexToAddress("0x21433Dec9Cb634A23c6A4BbcCe08c83f5aC2EC18")
+		wallet   = ethereum.HexToAddress("0xDECAF9CD2367cdbb726E904cD6397eDFcAe6068D")
+	)
+
+	newTradeLog := func(txHash string, logIndex uint) common.TradeLog {
+		return common.TradeLog{
+			Timestamp:       ts,
+			BlockNumber:     6000000,
+			TransactionHash: ethereum.HexToHash(txHash),
+			LogIndex:        logIndex,
+			SrcAmount:       big.NewInt(1000000000000000000),
+			DestAmount:      big.NewInt(300000000),
+			BurnFees: []common.BurnFee{
+				{ReserveAddress: reserve1, Amount: big.NewInt(100)},
+				{ReserveAddress: reserve2, Amount: big.NewInt(200)},
+			},
+			WalletFees: []common.WalletFee{
+				{ReserveAddress: reserve1, WalletAddress: wallet, Amount: big.NewInt(50)},
+			},
+		}
+	}
+
+	var (
+		matched  = newTradeLog("0x01", 1)
+		mismatch = newTradeLog("0x02", 2)
+		missing  = newTradeLog("0x03", 3)
+		extra    = newTradeLog("0x04", 4)
+	)
+
+	storedMatched := newTradeLog("0x01", 1)
+	// float conversion error is tolerated and the order of fees is not compared
+	storedMatched.SrcAmount = big.NewInt(1000000000000000001)
+	storedMatched.BurnFees[0], storedMatched.BurnFees[1] = storedMatched.BurnFees[1], storedMatched.BurnFees[0]
+
+	storedMismatch := newTradeLog("0x02", 2)
+	storedMismatch.Timestamp = ts.Add(time.Second)
+	storedMismatch.DestAmount = big.NewInt(200000000)
+	storedMismatch.WalletFees = nil
+
+	mismatches, missingRefs, extraRefs := compareTradeLogs(
+		[]common.TradeLog{matched, mismatch, missing},
+		[]common.TradeLog{extra, storedMismatch, storedMatched},
+		1e-9,
+	)
+
+	assert.Equal(t, []TradeMismatch{
+		{
+			TradeRef: newTradeRef(mismatch),
+			Fields: []FieldMismatch{
+				{Field: "timestamp", Chain: "2018-10-08T12:00:00Z", Stored: "2018-10-08T12:00:01Z"},
+				{Field: "dst_amount", Chain: "300000000", Stored: "200000000"},
+				{Field: "wallet_fees", Chain: "1", Stored: "0"},
+			},
+		},
+	}, mismatches)
+	assert.Equal(t, []TradeRef{newTradeRef(missing)}, missingRefs)
+	assert.Equal(t, []TradeRef{newTradeRef(extra)}, extraRefs)
+}
@@ -7,10 +7,10 @@ import (
 	libapp "github.com/KyberNetwork/reserve-stats/lib/app"
 	"github.com/KyberNetwork/reserve-stats/lib/httputil"
 	"github.com/KyberNetwork/reserve-stats/lib/influxdb"
+	"github.com/KyberNetwork/reserve-stats/lib/tokenrate"
 	"github.com/KyberNetwork/reserve-stats/tradelogs/common"
 	"github.com/KyberNetwork/reserve-stats/users/http"
 	"github.com/KyberNetwork/reserve-stats/users/storage"
-	"github.com/KyberNetwork/tokenrate/coingecko"
 	"github.com/urfave/cli"
 )
 
@@ -26,6 +26,7 @@ func main() {
 	app.Flags = append(app.Flags, libapp.NewPostgreSQLFlags(defaultDB)...)
 	app.Flags = append(app.Flags, httputil.NewHTTPCliFlags(httputil.UsersPort)...)
 	app.Flags = append(app.Flags, influxdb.NewCliFlags()...)
+	app.Flags = append(app.Flags, tokenrate.NewCliFlags()...)
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
 	}
@@ -70,7 +71,12 @@ func run(c *cli.Context) error {
 		return err
 	}
 
-	server := http.NewServer(sugar, coingecko.New(), userDB,
+	rateProvider, err := tokenrate.NewProviderFromContext(sugar, c)
+	if err != nil {
+		return err
+	}
+
+	server := http.NewServer(sugar, rateProvider, userDB,
 		httputil.NewHTTPAddressFromContext(c), influxStorage)
 	return server.Run()
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/KyberNetwork/reserve-stats/lib/blockchain"
 	_ "github.com/KyberNetwork/reserve-stats/lib/httputil/validators" // import custom validator functions
+	"github.com/KyberNetwork/reserve-stats/lib/metrics"
 	"github.com/KyberNetwork/reserve-stats/users/common"
 	"github.com/KyberNetwork/reserve-stats/users/storage"
 	"github.com/KyberNetwork/tokenrate"
@@ -115,6 +116,7 @@ func (s *Server) createOrUpdate(c *gin.Context) {
 }
 
 func (s *Server) register() {
+	metrics.Instrument(s.r)
 	s.r.GET("/users", s.getTransactionLimit)
 	s.r.POST("/users", s.createOrUpdate)
 }